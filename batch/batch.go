@@ -49,7 +49,9 @@ type Func struct {
 	MaxSize int
 	// WaitInterval is the duration of a timer that is reset every time the
 	// batch function is invoked. Many will be invoked when either the
-	// WaitInterval or MaxDuration expires.
+	// WaitInterval or MaxDuration expires, i.e. once invocations go idle,
+	// without waiting for unrelated goroutines (such as slow resolvers in the
+	// same query) to finish.
 	WaitInterval time.Duration
 	// MaxDuration limits the duration of a batch. After waiting for
 	// MaxDuration, Many will be invoked even if some goroutines are still
@@ -504,7 +504,7 @@ func TestExecutorQueriesWithArgs(t *testing.T) {
 				}`,
 			Output:        "",
 			Error:         true,
-			ExpectedError: "error parsing args for \"usersWithArgs\": name: not a string",
+			ExpectedError: "error parsing args for \"usersWithArgs\": name: missing required value",
 		},
 	}
 	for _, testCase := range testCases {
@@ -578,6 +578,96 @@ func TestExecutorQueriesWithUnionTypes(t *testing.T) {
 	}
 }
 
+func TestExecutorQueriesWithInterfaceTypes(t *testing.T) {
+	type User struct {
+		Id   int64
+		Name string
+	}
+	type Admin struct {
+		Id         int64
+		SuperPower string
+	}
+	type Member struct {
+		schemabuilder.Interface
+		*User
+		*Admin
+	}
+	type UserKeys struct {
+		Id int64
+	}
+	s1 := schemabuilder.NewSchemaWithName("s1")
+	user := s1.Object("User", User{}, schemabuilder.FetchObjectFromKeys(func(args struct{ Keys []UserKeys }) []*User {
+		users := make([]*User, 0, len(args.Keys))
+		for _, key := range args.Keys {
+			users = append(users, &User{Id: key.Id, Name: "testUser"})
+		}
+		return users
+	}))
+	user.Key("id")
+	admin := s1.Object("Admin", Admin{})
+	admin.Key("id")
+	s1.Query().FieldFunc("members", func(ctx context.Context) ([]*Member, error) {
+		return []*Member{
+			{Admin: &Admin{Id: 1, SuperPower: "flying"}},
+			{User: &User{Id: 2, Name: "testUser"}},
+		}, nil
+	})
+
+	type UserWithSecret struct {
+		Id int64
+	}
+	s2 := schemabuilder.NewSchemaWithName("s2")
+	userWithSecret := s2.Object("User", UserWithSecret{}, schemabuilder.FetchObjectFromKeys(func(args struct{ Keys []*UserWithSecret }) []*UserWithSecret {
+		return args.Keys
+	}))
+	userWithSecret.Key("id")
+	userWithSecret.FieldFunc("secret", func(ctx context.Context, user *UserWithSecret) string {
+		return "shhhhh"
+	})
+
+	ctx := context.Background()
+	execs, err := makeExecutors(map[string]*schemabuilder.Schema{
+		"s1": s1,
+		"s2": s2,
+	})
+	require.NoError(t, err)
+	e, err := NewExecutor(ctx, execs, &SchemaSyncerConfig{SchemaSyncer: NewIntrospectionSchemaSyncer(ctx, execs, nil)})
+	require.NoError(t, err)
+
+	// Shared fields are resolved for every member, and the fields of a
+	// fragment only for the members it narrows to, including federated fields.
+	runAndValidateQueryResults(t, ctx, e, `
+		query Foo {
+			members {
+				id
+				... on Admin {
+					superPower
+				}
+				... on User {
+					name
+					secret
+				}
+			}
+		}`, `
+		{
+			"members":[
+				{
+					"__key":1,
+					"__typename":"Admin",
+					"id":1,
+					"superPower":"flying"
+				},
+				{
+					"__key":2,
+					"__typename":"User",
+					"id":2,
+					"name":"testUser",
+					"secret":"shhhhh"
+				}
+			]
+		}`)
+}
+
 func TestExecutorQueriesWithFragments(t *testing.T) {
 	e, _, _, _, err := createExecutorWithFederatedUser()
 	require.NoError(t, err)
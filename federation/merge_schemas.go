@@ -55,7 +55,7 @@ func (t *introspectionTypeRef) String() string {
 		return "<nil>"
 	}
 	switch t.Kind {
-	case "SCALAR", "ENUM", "UNION", "OBJECT", "INPUT_OBJECT":
+	case "SCALAR", "ENUM", "UNION", "INTERFACE", "OBJECT", "INPUT_OBJECT":
 		return t.Name
 	case "NON_NULL":
 		return t.OfType.String() + "!"
@@ -144,7 +144,7 @@ func mergeTypeRefs(a, b *introspectionTypeRef, isInput bool) (*introspectionType
 	}
 	switch a.Kind {
 	// Basic types must be identical.
-	case "SCALAR", "ENUM", "INPUT_OBJECT", "UNION", "OBJECT":
+	case "SCALAR", "ENUM", "INPUT_OBJECT", "UNION", "INTERFACE", "OBJECT":
 		if a.Name != b.Name {
 			return nil, errors.New("types must be identical")
 		}
@@ -348,6 +348,18 @@ func mergeTypes(a, b introspectionType, mode MergeMode) (*introspectionType, err
 		}
 		merged.PossibleTypes = possibleTypes
 
+	case "INTERFACE":
+		fields, err := mergeFields(a.Fields, b.Fields, mode)
+		if err != nil {
+			return nil, fmt.Errorf("merging fields: %v", err)
+		}
+		merged.Fields = fields
+		possibleTypes, err := mergePossibleTypes(a.PossibleTypes, b.PossibleTypes, mode)
+		if err != nil {
+			return nil, fmt.Errorf("merging possible types: %v", err)
+		}
+		merged.PossibleTypes = possibleTypes
+
 	case "ENUM":
 		enumValues, err := mergeEnumValues(a.EnumValues, b.EnumValues, mode)
 		if err != nil {
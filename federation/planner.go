@@ -332,7 +332,9 @@ func (e *Planner) planObject(typ *graphql.Object, selectionSet *graphql.Selectio
 
 }
 
-func (e *Planner) planUnion(typ *graphql.Union, selectionSet *graphql.SelectionSet, service string) (*Plan, error) {
+// planUnion plans a query on a union or interface typ, with the given possible
+// types.
+func (e *Planner) planUnion(typ graphql.Type, types map[string]*graphql.Object, selectionSet *graphql.SelectionSet, service string) (*Plan, error) {
 	plan := &Plan{
 		// TODO: only include __typename if needed for dispatching? ie. len(types) > 1 and len(fragments) > 0?
 		// TODO: ensure __typename doesn't conflict with another field?
@@ -367,26 +369,26 @@ func (e *Planner) planUnion(typ *graphql.Union, selectionSet *graphql.SelectionS
 		seenFragments[fragment.On] = struct{}{}
 
 		// All fragments must be on concrete types
-		typ, ok := typ.Types[fragment.On]
+		obj, ok := types[fragment.On]
 		if !ok {
-			return nil, fmt.Errorf("unexpected fragment on %s for typ %s", fragment.On, typ.Name)
+			return nil, fmt.Errorf("unexpected fragment on %s for typ %s", fragment.On, typ)
 		}
 
 		// Create a plan for all fragment types
-		concretePlan, err := e.plan(typ, fragment.SelectionSet, service)
+		concretePlan, err := e.plan(obj, fragment.SelectionSet, service)
 		if err != nil {
 			return nil, err
 		}
 
 		// Query the fields known to the current with a local fragment.
 		plan.SelectionSet.Fragments = append(plan.SelectionSet.Fragments, &graphql.Fragment{
-			On:           typ.Name,
+			On:           obj.Name,
 			SelectionSet: concretePlan.SelectionSet,
 		})
 
 		// Make subplans conditional on the current type.
 		for _, subPlan := range concretePlan.After {
-			subPlan.Path = append(subPlan.Path, PathStep{Kind: KindType, Name: typ.Name})
+			subPlan.Path = append(subPlan.Path, PathStep{Kind: KindType, Name: obj.Name})
 			plan.After = append(plan.After, subPlan)
 		}
 	}
@@ -406,7 +408,10 @@ func (e *Planner) plan(typIface graphql.Type, selectionSet *graphql.SelectionSet
 		return e.planObject(typ, selectionSet, service)
 
 	case *graphql.Union:
-		return e.planUnion(typ, selectionSet, service)
+		return e.planUnion(typ, typ.Types, selectionSet, service)
+
+	case *graphql.Interface:
+		return e.planUnion(typ, typ.Types, selectionSet, service)
 
 	default:
 		return nil, fmt.Errorf("bad typ %v", typIface)
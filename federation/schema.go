@@ -326,7 +326,7 @@ func lookupType(t *introspectionTypeRef, all map[string]graphql.Type) (*introspe
 		return nil, errors.New("malformed typeref")
 	}
 	switch t.Kind {
-	case "SCALAR", "OBJECT", "UNION", "INPUT_OBJECT", "ENUM":
+	case "SCALAR", "OBJECT", "UNION", "INTERFACE", "INPUT_OBJECT", "ENUM":
 		return t, nil
 	case "LIST":
 		return lookupType(t.OfType, all)
@@ -344,7 +344,7 @@ func lookupTypeRef(t *introspectionTypeRef, all map[string]graphql.Type) (graphq
 	}
 
 	switch t.Kind {
-	case "SCALAR", "OBJECT", "UNION", "INPUT_OBJECT", "ENUM":
+	case "SCALAR", "OBJECT", "UNION", "INTERFACE", "INPUT_OBJECT", "ENUM":
 		// TODO: enforce type?
 		typ, ok := all[t.Name]
 		if !ok {
@@ -401,6 +401,46 @@ func parseInputFields(source []introspectionInputField, all map[string]graphql.T
 	return fields, nil
 }
 
+// parseFields maps the fields of an object or interface to graphql fields
+func parseFields(typ introspectionType, all map[string]graphql.Type) (map[string]*graphql.Field, error) {
+	fields := make(map[string]*graphql.Field)
+	for _, field := range typ.Fields {
+		fieldTyp, err := lookupTypeRef(field.Type, all)
+		if err != nil {
+			return nil, fmt.Errorf("typ %s field %s has bad typ: %v",
+				typ.Name, field.Name, err)
+		}
+
+		parsed, err := parseInputFields(field.Args, all)
+		if err != nil {
+			return nil, fmt.Errorf("field %s input: %v", field.Name, err)
+		}
+
+		fields[field.Name] = &graphql.Field{
+			Args: parsed,
+			Type: fieldTyp,
+		}
+	}
+	return fields, nil
+}
+
+// parsePossibleTypes maps the possible types of a union or interface to
+// graphql objects
+func parsePossibleTypes(typ introspectionType, all map[string]graphql.Type) (map[string]*graphql.Object, error) {
+	types := make(map[string]*graphql.Object)
+	for _, other := range typ.PossibleTypes {
+		if other.Kind != "OBJECT" {
+			return nil, fmt.Errorf("typ %s has possible typ not OBJECT: %v", typ.Name, other)
+		}
+		obj, ok := all[other.Name].(*graphql.Object)
+		if !ok {
+			return nil, fmt.Errorf("typ %s possible typ %s does not refer to obj", typ.Name, other.Name)
+		}
+		types[obj.Name] = obj
+	}
+	return types, nil
+}
+
 // parseSchema takes the introspected schema, validates the types,
 // and maps every field to the graphql types
 func parseSchema(schema *IntrospectionQueryResult) (map[string]graphql.Type, error) {
@@ -432,6 +472,11 @@ func parseSchema(schema *IntrospectionQueryResult) (map[string]graphql.Type, err
 				Name: typ.Name,
 			}
 
+		case "INTERFACE":
+			all[typ.Name] = &graphql.Interface{
+				Name: typ.Name,
+			}
+
 		case "ENUM":
 			all[typ.Name] = &graphql.Enum{
 				Type: typ.Name,
@@ -446,25 +491,10 @@ func parseSchema(schema *IntrospectionQueryResult) (map[string]graphql.Type, err
 	for _, typ := range schema.Schema.Types {
 		switch typ.Kind {
 		case "OBJECT":
-			fields := make(map[string]*graphql.Field)
-			for _, field := range typ.Fields {
-				fieldTyp, err := lookupTypeRef(field.Type, all)
-				if err != nil {
-					return nil, fmt.Errorf("typ %s field %s has bad typ: %v",
-						typ.Name, field.Name, err)
-				}
-
-				parsed, err := parseInputFields(field.Args, all)
-				if err != nil {
-					return nil, fmt.Errorf("field %s input: %v", field.Name, err)
-				}
-
-				fields[field.Name] = &graphql.Field{
-					Args: parsed,
-					Type: fieldTyp,
-				}
+			fields, err := parseFields(typ, all)
+			if err != nil {
+				return nil, err
 			}
-
 			all[typ.Name].(*graphql.Object).Fields = fields
 
 		case "INPUT_OBJECT":
@@ -476,20 +506,25 @@ func parseSchema(schema *IntrospectionQueryResult) (map[string]graphql.Type, err
 			all[typ.Name].(*graphql.InputObject).InputFields = parsed
 
 		case "UNION":
-			types := make(map[string]*graphql.Object)
-			for _, other := range typ.PossibleTypes {
-				if other.Kind != "OBJECT" {
-					return nil, fmt.Errorf("typ %s has possible typ not OBJECT: %v", typ.Name, other)
-				}
-				typ, ok := all[other.Name].(*graphql.Object)
-				if !ok {
-					return nil, fmt.Errorf("typ %s possible typ %s does not refer to obj", typ.Name, other.Name)
-				}
-				types[typ.Name] = typ
+			types, err := parsePossibleTypes(typ, all)
+			if err != nil {
+				return nil, err
 			}
-
 			all[typ.Name].(*graphql.Union).Types = types
 
+		case "INTERFACE":
+			fields, err := parseFields(typ, all)
+			if err != nil {
+				return nil, err
+			}
+			types, err := parsePossibleTypes(typ, all)
+			if err != nil {
+				return nil, err
+			}
+			iface := all[typ.Name].(*graphql.Interface)
+			iface.Fields = fields
+			iface.Types = types
+
 		case "ENUM":
 			// XXX: introspection relies on the EnumValues map.
 			reverseMap := make(map[interface{}]string)
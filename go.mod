@@ -1,6 +1,6 @@
 module github.com/samsarahq/thunder
 
-go 1.15
+go 1.18
 
 require (
 	github.com/davecgh/go-spew v1.1.1-0.20170829195320-a47672248388
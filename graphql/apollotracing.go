@@ -0,0 +1,149 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ApolloTracing collects the timing of the resolvers of an execution, in the
+// Apollo Tracing format (https://github.com/apollographql/apollo-tracing),
+// which it is encoded as by encoding/json.
+//
+// Resolvers are timed for every value they resolve: a batch resolver resolves
+// the field for all of its sources in one call, so every source gets an entry
+// with the start offset and duration of the whole call.
+type ApolloTracing struct {
+	mu        sync.Mutex
+	start     time.Time
+	end       time.Time
+	resolvers []*ApolloResolverTiming
+}
+
+// ApolloResolverTiming is the timing of a resolver for a single value.
+type ApolloResolverTiming struct {
+	// Path is the path of the value in the response, with list indices as
+	// ints.
+	Path       []interface{} `json:"path"`
+	ParentType string        `json:"parentType"`
+	FieldName  string        `json:"fieldName"`
+	ReturnType string        `json:"returnType"`
+	// StartOffset is the time from the start of the execution until the
+	// resolver was called.
+	StartOffset time.Duration `json:"startOffset"`
+	Duration    time.Duration `json:"duration"`
+}
+
+// NewApolloTracing returns an empty ApolloTracing.
+func NewApolloTracing() *ApolloTracing {
+	return &ApolloTracing{}
+}
+
+type apolloTracingKey struct{}
+
+// WithQueryApolloTracing collects the resolver timing of the execution run
+// with the returned context in tracing.  See also WithApolloTracing to include
+// it in HTTP responses.
+func WithQueryApolloTracing(ctx context.Context, tracing *ApolloTracing) context.Context {
+	return context.WithValue(ctx, apolloTracingKey{}, tracing)
+}
+
+// Resolvers returns the timing of the resolvers called so far.
+func (t *ApolloTracing) Resolvers() []*ApolloResolverTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*ApolloResolverTiming(nil), t.resolvers...)
+}
+
+// begin marks the start of the execution.
+func (t *ApolloTracing) begin() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.start = time.Now()
+}
+
+// finish marks the end of the execution.
+func (t *ApolloTracing) finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.end = time.Now()
+}
+
+// wrap wraps resolve to time it for each of destinations.
+func (t *ApolloTracing) wrap(unit *WorkUnit, destinations []*outputNode, resolve func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		start := time.Now()
+		err := resolve(ctx)
+		duration := time.Since(start)
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		for _, dest := range destinations {
+			t.resolvers = append(t.resolvers, &ApolloResolverTiming{
+				Path:        apolloPath(dest),
+				ParentType:  unit.objectName,
+				FieldName:   unit.selection.Name,
+				ReturnType:  unit.field.Type.String(),
+				StartOffset: start.Sub(t.start),
+				Duration:    duration,
+			})
+		}
+		return err
+	}
+}
+
+// apolloPath returns the path of dest in the response, from the root.
+func apolloPath(dest *outputNode) []interface{} {
+	var path []interface{}
+	for cur := dest.pathTracker; cur != nil && cur.parent != nil; cur = cur.parent {
+		if cur.path == "" {
+			continue
+		}
+		if idx, err := strconv.Atoi(cur.path); err == nil {
+			path = append(path, idx)
+		} else {
+			path = append(path, cur.path)
+		}
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// MarshalJSON encodes the timing in the Apollo Tracing format.
+func (t *ApolloTracing) MarshalJSON() ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	resolvers := t.resolvers
+	if resolvers == nil {
+		resolvers = []*ApolloResolverTiming{}
+	}
+	// The execution may still be running, e.g. if it timed out.
+	end := t.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return json.Marshal(apolloTracingJSON{
+		Version:   1,
+		StartTime: t.start,
+		EndTime:   end,
+		Duration:  end.Sub(t.start),
+		Execution: apolloExecutionJSON{Resolvers: resolvers},
+	})
+}
+
+type apolloTracingJSON struct {
+	Version   int                 `json:"version"`
+	StartTime time.Time           `json:"startTime"`
+	EndTime   time.Time           `json:"endTime"`
+	Duration  time.Duration       `json:"duration"`
+	Execution apolloExecutionJSON `json:"execution"`
+}
+
+type apolloExecutionJSON struct {
+	Resolvers []*ApolloResolverTiming `json:"resolvers"`
+}
@@ -0,0 +1,65 @@
+package graphql
+
+import "context"
+
+// An AuthorizeFunc decides whether a field may be resolved for a source.  It
+// returns an error to deny access, which the field then fails with, without
+// calling its resolver.
+type AuthorizeFunc func(ctx context.Context, field *Field, source interface{}) error
+
+// WithAuthorizer checks every field an execution resolves with authorize,
+// before the field's own Authorize, if any.  Fields read from struct fields are
+// checked as well as resolved ones.
+func WithAuthorizer(authorize AuthorizeFunc) ExecutorOption {
+	return func(e *Executor) {
+		e.authorize = authorize
+	}
+}
+
+// authorizeWorkUnit checks every source of a unit with the executor's and the
+// field's AuthorizeFuncs, failing the destinations of the sources that are
+// denied.  It returns a unit with the remaining sources, or nil if there are
+// none.  Batch fields are checked per source too, so a denied source only
+// fails its own value.
+func authorizeWorkUnit(unit *WorkUnit) *WorkUnit {
+	authorize := executionInfoFromContext(unit.Ctx).authorize
+	if authorize == nil && unit.field.Authorize == nil {
+		return unit
+	}
+
+	var sources []interface{}
+	var destinations []*outputNode
+	for idx, source := range unit.sources {
+		err := checkAuthorized(unit.Ctx, authorize, unit.field, source)
+		if err != nil {
+			unit.destinations[idx].Fail(err)
+			continue
+		}
+		sources = append(sources, source)
+		destinations = append(destinations, unit.destinations[idx])
+	}
+	if len(sources) == len(unit.sources) {
+		return unit
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+	authorized := *unit
+	authorized.sources = sources
+	authorized.destinations = destinations
+	return &authorized
+}
+
+// checkAuthorized calls the executor's and the field's AuthorizeFuncs, if
+// any, in that order.
+func checkAuthorized(ctx context.Context, authorize AuthorizeFunc, field *Field, source interface{}) error {
+	if authorize != nil {
+		if err := authorize(ctx, field, source); err != nil {
+			return err
+		}
+	}
+	if field.Authorize != nil {
+		return field.Authorize(ctx, field, source)
+	}
+	return nil
+}
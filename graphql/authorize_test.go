@@ -0,0 +1,102 @@
+package graphql_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/samsarahq/thunder/batch"
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize(t *testing.T) {
+	type User struct {
+		Id int64
+	}
+
+	var mu sync.Mutex
+	var resolved []string
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		resolved = append(resolved, call)
+	}
+
+	// Only user 1 may be seen in full.
+	denyOthers := func(ctx context.Context, field *graphql.Field, source interface{}) error {
+		if id := source.(*User).Id; id != 1 {
+			return fmt.Errorf("user %d is private", id)
+		}
+		return nil
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("users", func() []*User {
+		return []*User{{Id: 1}, {Id: 2}}
+	})
+	user := builder.Object("User", User{})
+	user.FieldFunc("public", func(u *User) *string {
+		record(fmt.Sprintf("public %d", u.Id))
+		value := "public"
+		return &value
+	})
+	user.FieldFunc("secret", func(u *User) *string {
+		record(fmt.Sprintf("secret %d", u.Id))
+		value := "secret"
+		return &value
+	}, schemabuilder.Authorize(denyOthers))
+	user.BatchFieldFunc("batched", func(ctx context.Context, users map[batch.Index]*User) map[batch.Index]*string {
+		values := make(map[batch.Index]*string, len(users))
+		for idx, u := range users {
+			record(fmt.Sprintf("batched %d", u.Id))
+			value := "batched"
+			values[idx] = &value
+		}
+		return values
+	}, schemabuilder.Authorize(denyOthers))
+	schema := builder.MustBuild()
+
+	run := func(e graphql.ExecutorRunner, query string) (interface{}, error) {
+		mu.Lock()
+		resolved = nil
+		mu.Unlock()
+		q := graphql.MustParse(query, nil)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+		return e.Execute(context.Background(), schema.Query, nil, q)
+	}
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithPartialResults())
+
+	// Allowed: fields without a check, and sources that pass it.
+	res, err := run(e, `{ users { id public } }`)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"users": [{"id": 1, "public": "public"}, {"id": 2, "public": "public"}]}`), internal.AsJSON(res))
+	assert.ElementsMatch(t, []string{"public 1", "public 2"}, resolved)
+
+	// Denied and mixed: every source is checked on its own, including for
+	// batch fields, and denied sources are never resolved.
+	res, err = run(e, `{ users { id secret batched } }`)
+	assert.Equal(t, internal.ParseJSON(`{"users": [
+		{"id": 1, "secret": "secret", "batched": "batched"},
+		{"id": 2, "secret": null, "batched": null}
+	]}`), internal.AsJSON(res))
+	assert.ElementsMatch(t, []string{"users.1.secret: user 2 is private", "users.1.batched: user 2 is private"}, errorMessages(err))
+	assert.ElementsMatch(t, []string{"secret 1", "batched 1"}, resolved)
+
+	// The executor's authorizer checks every field.
+	e = graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithAuthorizer(func(ctx context.Context, field *graphql.Field, source interface{}) error {
+		if _, ok := source.(*User); ok {
+			return errors.New("denied")
+		}
+		return nil
+	}))
+	_, err = run(e, `{ users { public } }`)
+	assert.EqualError(t, err, "users.0.public: denied")
+	assert.Empty(t, resolved)
+}
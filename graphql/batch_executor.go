@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/samsarahq/thunder/reactive"
 )
@@ -22,6 +25,13 @@ type WorkUnit struct {
 	destinations []*outputNode
 	useBatch     bool
 	objectName   string
+
+	// dependents are the gates of sibling fields waiting on this unit.
+	dependents []*dependencyGate
+
+	// grouped, if set, are the units of fields in the same BatchGroup that
+	// this unit resolves in its place.
+	grouped []*WorkUnit
 }
 
 type nonExpensive struct{}
@@ -90,20 +100,549 @@ type UnitResolver func(*WorkUnit) []*WorkUnit
 // to control how we traverse the Execution graph.  Examples would include using
 // a bounded goroutine pool, or using unbounded goroutine generation for each
 // work unit.
+//
+// Run must call resolver on every starting unit and on every unit returned by
+// resolver, and only return once all of them have been resolved.  The order
+// and concurrency of resolution are up to the scheduler, so e.g. priority or
+// work-stealing queues can be plugged in without changes to the executor.
+//
+// A single unit can fan out into any number of units (e.g. one per item of a
+// large list), so schedulers must queue them without blocking (e.g. in an
+// unbounded queue); blocking on a bounded channel that only the enqueueing
+// goroutine drains deadlocks.
 type WorkScheduler interface {
 	Run(resolver UnitResolver, startingUnits ...*WorkUnit)
 }
 
-func NewExecutor(scheduler WorkScheduler) ExecutorRunner {
-	return &Executor{
-		scheduler: scheduler,
+func NewExecutor(scheduler WorkScheduler, options ...ExecutorOption) ExecutorRunner {
+	e := &Executor{
+		scheduler:   scheduler,
+		retryBudget: DefaultRetryBudget,
+		operationDirectives: map[string]OperationDirectiveFunc{
+			"timeout": timeoutDirective,
+		},
 	}
+	for _, opt := range options {
+		opt(e)
+	}
+	return e
 }
 
 // BatchExecutor is a GraphQL executor.  Given a query it can run through the
 // execution of the request.
 type Executor struct {
 	scheduler WorkScheduler
+
+	// maxFragmentSpreads is the maximum number of fragment spreads (counting
+	// duplicates) a query may contain.  Zero means unlimited.
+	maxFragmentSpreads int
+
+	// maxRootSelections is the maximum number of root-level selections
+	// (counting every alias separately) a query may contain.  Zero means
+	// unlimited.
+	maxRootSelections int
+
+	// maxDepth is the maximum nesting of object and list selections a query
+	// may contain.  Zero means unlimited.
+	maxDepth int
+
+	// maxComplexity is the maximum estimated cost of a query.  Zero means
+	// unlimited.
+	maxComplexity int
+
+	// maxExpensiveUnits is the maximum number of work units scheduled for
+	// the expensive fields of a list of objects.  Zero means unlimited.
+	maxExpensiveUnits int
+
+	// maxExecutionUnits is the maximum number of work units an execution
+	// may schedule.  Zero means unlimited.
+	maxExecutionUnits int
+
+	// expensiveSem, if set, holds a token for every expensive resolver
+	// running, across all executions.
+	expensiveSem chan struct{}
+
+	// tracer, if set, is used to start a span around every external resolver,
+	// and around every work unit if traceUnits is set.
+	tracer     Tracer
+	traceUnits bool
+	// traceSampled, if set, decides whether an execution is traced.
+	traceSampled func() bool
+	// requestIDKey, if set, is the context key holding the request ID that
+	// spans and logged errors are tagged with.
+	requestIDKey interface{}
+	// errorLogger, if set, is called with any error returned by Execute.
+	errorLogger ErrorLogger
+
+	// operationTimeout is the default timeout for executing an operation.
+	operationTimeout time.Duration
+	// operationDirectives handle directives on the operation itself.
+	operationDirectives map[string]OperationDirectiveFunc
+
+	// batchPolicy, if set, decides at runtime whether batch fields with a
+	// fallback resolver are batched.
+	batchPolicy BatchPolicy
+	// inFlightUnits counts the work units currently being run by the
+	// scheduler, across all executions.  It is only tracked if batchPolicy
+	// is set.
+	inFlightUnits int64
+
+	// nilListsAsNull resolves nil slices returned for nullable lists as null
+	// rather than an empty list.
+	nilListsAsNull bool
+
+	// batchingDisabled resolves batch fields one source at a time.
+	batchingDisabled bool
+
+	// partialResults returns the rest of the result along with field errors.
+	partialResults bool
+
+	// errorPresenter, if set, presents the errors executions fail with.
+	errorPresenter ErrorPresenter
+
+	// clock, if set, is returned by Now during executions.
+	clock Clock
+
+	// stallTimeout, if set, aborts executions that make no progress for
+	// that long.
+	stallTimeout time.Duration
+
+	// mocks, if set, holds the canned values fields are resolved from.  See
+	// NewMockExecutor.
+	mocks map[string]interface{}
+
+	// batchStats, if set, records the batch sizes of every execution.
+	batchStats *BatchStats
+
+	// retryBudget limits the number of retries in every execution.
+	retryBudget int
+
+	// snapshot, if set, establishes the data snapshot of every execution.
+	snapshot SnapshotFunc
+
+	// isAuthenticated, if set, reports whether the caller may query data
+	// rather than only introspect the schema.
+	isAuthenticated func(ctx context.Context) bool
+
+	// authorize, if set, is called for every field and source before the
+	// field is resolved.
+	authorize AuthorizeFunc
+
+	// reportExecutionStats, if set, is called with the stats of every
+	// execution.
+	reportExecutionStats func(ctx context.Context, stats ExecutionStats)
+
+	// mu guards the fields below, which track executions for Shutdown.
+	mu           sync.Mutex
+	shuttingDown bool
+	executions   map[*activeExecution]struct{}
+	// drained is closed once the last execution finishes during shutdown.
+	drained chan struct{}
+}
+
+// activeExecution is a call to Execute that has not yet returned.
+type activeExecution struct {
+	cancel context.CancelFunc
+}
+
+// ErrExecutorShutdown is returned by Execute once Shutdown has been called.
+var ErrExecutorShutdown = errors.New("graphql: executor is shut down")
+
+// Shutdown stops the executor from accepting new queries and waits for
+// in-flight executions to finish.  If ctx is done first, the contexts of the
+// remaining executions are canceled and ctx's error is returned.
+func (e *Executor) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	e.shuttingDown = true
+	if len(e.executions) == 0 {
+		e.mu.Unlock()
+		return nil
+	}
+	if e.drained == nil {
+		e.drained = make(chan struct{})
+	}
+	drained := e.drained
+	e.mu.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		e.mu.Lock()
+		for execution := range e.executions {
+			execution.cancel()
+		}
+		e.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// startExecution registers a new execution, returning a context that is
+// canceled if Shutdown gives up waiting on it.
+func (e *Executor) startExecution(ctx context.Context) (context.Context, *activeExecution, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.shuttingDown {
+		return nil, nil, ErrExecutorShutdown
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	execution := &activeExecution{cancel: cancel}
+	if e.executions == nil {
+		e.executions = make(map[*activeExecution]struct{})
+	}
+	e.executions[execution] = struct{}{}
+	return ctx, execution, nil
+}
+
+// finishExecution unregisters an execution started by startExecution.
+func (e *Executor) finishExecution(execution *activeExecution) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	execution.cancel()
+	delete(e.executions, execution)
+	if len(e.executions) == 0 && e.drained != nil {
+		close(e.drained)
+		e.drained = nil
+	}
+}
+
+// ExecutorOption configures an Executor created by NewExecutor.
+type ExecutorOption func(*Executor)
+
+// WithMaxFragmentSpreads limits the total number of fragment spreads a query
+// may contain, counting every time a fragment is spread (including repeated
+// spreads of the same fragment).  Queries over the limit are rejected before
+// any resolvers run.  This guards against queries that nest fragments to
+// explode the amount of work done flattening selections.
+func WithMaxFragmentSpreads(max int) ExecutorOption {
+	return func(e *Executor) {
+		e.maxFragmentSpreads = max
+	}
+}
+
+// WithMaxRootSelections limits the number of root-level selections a query
+// may contain, counting every alias of a field separately.  This guards
+// against queries that alias the same expensive root field many times.
+func WithMaxRootSelections(max int) ExecutorOption {
+	return func(e *Executor) {
+		e.maxRootSelections = max
+	}
+}
+
+// WithMaxDepth limits how deeply the selections of a query may nest, e.g. to
+// reject recursive friend-of-friend queries.  Every field with a selection
+// set (i.e. an object or a list of objects) counts as one level, so that
+// "{ users { friends { name } } }" has a depth of 2.  Queries over the limit
+// are rejected before any resolvers run, with an error naming the path that
+// is too deep.
+func WithMaxDepth(max int) ExecutorOption {
+	return func(e *Executor) {
+		e.maxDepth = max
+	}
+}
+
+// BatchPolicy decides whether a batch field that has a fallback resolver is
+// resolved as a batch, given the number of work units the executor is
+// currently running.  Policies may also consult their own load signal.
+type BatchPolicy func(ctx context.Context, inFlightUnits int) bool
+
+// WithNilListsAsNull resolves a nil slice (or nil Iterator) returned for a
+// nullable list field as null, while an empty slice still resolves as [].  By default both resolve
+// as [].  Nil slices returned for non-null lists always resolve as [].  Lists
+// returned by a schemabuilder FieldFunc are non-null unless the FieldFunc is
+// marked schemabuilder.Nullable.
+func WithNilListsAsNull() ExecutorOption {
+	return func(e *Executor) {
+		e.nilListsAsNull = true
+	}
+}
+
+// WithBatchingDisabled calls the resolvers of batch fields with a single
+// source at a time, rather than with every source at once.  It is meant for
+// debugging, e.g. to check whether batching causes a bug, since results are
+// the same either way.
+func WithBatchingDisabled() ExecutorOption {
+	return func(e *Executor) {
+		e.batchingDisabled = true
+	}
+}
+
+// WithPartialResults returns the result of a query whose fields failed along
+// with their errors, as Errors, rather than only the first error.  Failed
+// values are null, and, as in the GraphQL spec, a null in a non-null position
+// nulls its parent instead, so that a failed element of a list of nullable
+// elements only nulls that element, while one of a list of non-null elements
+// nulls the list.  A null resolved for a non-null value is an error too.
+func WithPartialResults() ExecutorOption {
+	return func(e *Executor) {
+		e.partialResults = true
+	}
+}
+
+// WithErrorPresenter presents the errors executions fail with, e.g. to
+// sanitize their messages or attach codes in extensions.  Execute then fails
+// with a *PresentedError holding the presentation of the error (e.g. for an
+// invalid query), or, if fields failed, with the Errors of a *PresentedError
+// for every failed field.  HTTPHandler and the websocket server send the
+// presentations as is, even if the handler has an ErrorRegistry (see
+// WithErrorRegistry): a presented error is sent as presented, and only other
+// errors are formatted by the registry.  To present errors with a registry,
+// pass its Present method.  Errors are logged before they are presented.
+func WithErrorPresenter(presenter ErrorPresenter) ExecutorOption {
+	return func(e *Executor) {
+		e.errorPresenter = presenter
+	}
+}
+
+// ErrUnauthenticated is returned for queries of data by unauthenticated
+// callers of an executor configured with WithPublicIntrospection.
+var ErrUnauthenticated = NewClientError("authentication required")
+
+// WithPublicIntrospection allows unauthenticated callers to introspect the
+// schema, but not to query data: an operation selecting any root field other
+// than __schema, __type or __typename fails with ErrUnauthenticated unless
+// isAuthenticated returns true for the execution context.
+func WithPublicIntrospection(isAuthenticated func(ctx context.Context) bool) ExecutorOption {
+	return func(e *Executor) {
+		e.isAuthenticated = isAuthenticated
+	}
+}
+
+// isIntrospectionField returns whether a root field is an introspection field.
+func isIntrospectionField(name string) bool {
+	return name == "__schema" || name == "__type" || name == "__typename"
+}
+
+// WithMaxExpensiveUnits limits the number of work units scheduled for the
+// expensive fields of a list of objects.  Each expensive field normally gets a
+// unit per object, so a large list with several expensive fields can schedule
+// a huge number of units; above the limit, the objects are instead split into
+// chunks that each resolve their objects sequentially.
+func WithMaxExpensiveUnits(max int) ExecutorOption {
+	return func(e *Executor) {
+		e.maxExpensiveUnits = max
+	}
+}
+
+// WithMaxExpensiveConcurrency limits the number of resolvers of expensive
+// fields running at once, across all executions, so that a long list with an
+// expensive field doesn't open a connection to a downstream per object at
+// once.  Resolvers beyond the limit wait for a running one to return.  Zero
+// (or less) means unlimited.
+func WithMaxExpensiveConcurrency(max int) ExecutorOption {
+	return func(e *Executor) {
+		if max <= 0 {
+			e.expensiveSem = nil
+			return
+		}
+		e.expensiveSem = make(chan struct{}, max)
+	}
+}
+
+// WithBatchPolicy configures the executor to only batch fields with a
+// fallback resolver (see schemabuilder's BatchFieldFuncWithFallback) when the
+// policy allows it.  Batching adds latency waiting for sources to accumulate,
+// which is only worth it when the server is under load.
+func WithBatchPolicy(policy BatchPolicy) ExecutorOption {
+	return func(e *Executor) {
+		e.batchPolicy = policy
+	}
+}
+
+// BatchAboveLoad returns a BatchPolicy that batches once at least threshold
+// work units are in flight.
+func BatchAboveLoad(threshold int) BatchPolicy {
+	return func(ctx context.Context, inFlightUnits int) bool {
+		return inFlightUnits >= threshold
+	}
+}
+
+type executionInfoKey struct{}
+
+// executionInfo is the per-execution state of an Executor, stored in the
+// context of every work unit.
+type executionInfo struct {
+	tracer     Tracer
+	traceUnits bool
+	requestID  string
+	// operationKind is the kind of the operation, i.e. "query" or "mutation".
+	operationKind string
+
+	batchPolicy   BatchPolicy
+	inFlightUnits *int64
+
+	nilListsAsNull    bool
+	batchingDisabled  bool
+	partialResults    bool
+	maxExpensiveUnits int
+	expensiveSem      chan struct{}
+	clock             Clock
+	mocks             map[string]interface{}
+
+	// batchStats record the batch sizes of the execution, for the executor
+	// and the query.
+	batchStats []*BatchStats
+
+	// apolloTracing, if set, collects the timing of the query's resolvers.
+	apolloTracing *ApolloTracing
+
+	// retriesLeft, if set, counts the retries left in the execution's retry
+	// budget.
+	retriesLeft *int64
+
+	// authorize, if set, checks every field before it is resolved.
+	authorize AuthorizeFunc
+
+	// stats and limiter, if set, count the work units of the execution for
+	// WithExecutionStats and WithMaxExecutionUnits.
+	stats   *executionStatsRecorder
+	limiter *unitLimiter
+
+	// deferCollector, if set, collects the fragments deferred by @defer
+	// instead of resolving them.  See ExecuteIncremental.
+	deferCollector *deferCollector
+
+	// ctx is the context of the execution, which outlives the contexts of
+	// its resolvers.
+	ctx context.Context
+	// dataLoaders holds the loads of the DataLoaders used by the execution.
+	dataLoadersMu sync.Mutex
+	dataLoaders   map[*DataLoader]*dataLoaderState
+
+	// snapshot is the token returned by the executor's SnapshotFunc.
+	snapshot    interface{}
+	hasSnapshot bool
+
+	// cleanups are registered with OnComplete, and run once the execution
+	// finishes.
+	cleanupsMu sync.Mutex
+	cleanups   []func()
+	// cleanupsDetached counts the resolvers that the cleanups wait on, since
+	// they may outlive the execution.
+	cleanupsDetached int
+	// cleanupsRequested is set once the execution has finished, so the
+	// cleanups run as soon as no resolvers are detached.
+	cleanupsRequested bool
+	// cleanupsDone is set once the cleanups have run, after which new
+	// callbacks run immediately.
+	cleanupsDone bool
+}
+
+// withExecutionInfo returns a context carrying the executionInfo for a
+// single call to Execute.
+func (e *Executor) withExecutionInfo(ctx context.Context, query *Query) context.Context {
+	info := &executionInfo{
+		operationKind: query.Kind,
+		tracer:        e.tracer,
+		traceUnits:    e.traceUnits,
+		batchPolicy:   e.batchPolicy,
+		inFlightUnits: &e.inFlightUnits,
+
+		nilListsAsNull:    e.nilListsAsNull,
+		batchingDisabled:  e.batchingDisabled,
+		partialResults:    e.partialResults,
+		maxExpensiveUnits: e.maxExpensiveUnits,
+		expensiveSem:      e.expensiveSem,
+		clock:             e.clock,
+		mocks:             e.mocks,
+		authorize:         e.authorize,
+	}
+	info.requestID, _ = e.requestID(ctx)
+	if e.traceSampled != nil && !e.traceSampled() {
+		info.tracer = nil
+	}
+	if e.batchStats != nil {
+		info.batchStats = append(info.batchStats, e.batchStats)
+	}
+	retriesLeft := int64(e.retryBudget)
+	info.retriesLeft = &retriesLeft
+	if e.reportExecutionStats != nil {
+		info.stats = &executionStatsRecorder{}
+	}
+	if e.maxExecutionUnits > 0 {
+		info.limiter = &unitLimiter{max: e.maxExecutionUnits}
+	}
+	if stats, ok := ctx.Value(batchStatsKey{}).(*BatchStats); ok {
+		info.batchStats = append(info.batchStats, stats)
+	}
+	if tracing, ok := ctx.Value(apolloTracingKey{}).(*ApolloTracing); ok {
+		info.apolloTracing = tracing
+		tracing.begin()
+		// The first cleanup registered runs last, once the execution is done.
+		info.cleanups = append(info.cleanups, tracing.finish)
+	}
+	ctx = context.WithValue(ctx, executionInfoKey{}, info)
+	info.ctx = ctx
+	return ctx
+}
+
+// OnComplete registers fn to be called once the execution ctx belongs to has
+// finished, e.g. to release a lock or transaction held by a resolver.
+// Callbacks run in the reverse order they were registered in.  They also run
+// if the execution times out or is canceled, once the resolvers still running
+// have returned.  If ctx is not part of an execution, or the execution's
+// callbacks have already run, fn is called immediately.
+func OnComplete(ctx context.Context, fn func()) {
+	info, _ := ctx.Value(executionInfoKey{}).(*executionInfo)
+	if info == nil {
+		fn()
+		return
+	}
+	info.cleanupsMu.Lock()
+	if info.cleanupsDone {
+		info.cleanupsMu.Unlock()
+		fn()
+		return
+	}
+	defer info.cleanupsMu.Unlock()
+	info.cleanups = append(info.cleanups, fn)
+}
+
+// runCleanups calls the callbacks registered with OnComplete, in LIFO order,
+// once no detached resolvers are running.
+func (info *executionInfo) runCleanups() {
+	info.cleanupsMu.Lock()
+	info.cleanupsRequested = true
+	if info.cleanupsDetached > 0 || info.cleanupsDone {
+		info.cleanupsMu.Unlock()
+		return
+	}
+	cleanups := info.cleanups
+	info.cleanups = nil
+	info.cleanupsDone = true
+	info.cleanupsMu.Unlock()
+
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+}
+
+// detachCleanups delays the OnComplete callbacks until done is closed, for
+// executions (or fields) that return before all of their resolvers have
+// finished.
+func (info *executionInfo) detachCleanups(done <-chan struct{}) {
+	info.cleanupsMu.Lock()
+	info.cleanupsDetached++
+	info.cleanupsMu.Unlock()
+
+	go func() {
+		<-done
+		info.cleanupsMu.Lock()
+		info.cleanupsDetached--
+		run := info.cleanupsDetached == 0 && info.cleanupsRequested
+		info.cleanupsMu.Unlock()
+		if run {
+			info.runCleanups()
+		}
+	}()
+}
+
+func executionInfoFromContext(ctx context.Context) *executionInfo {
+	info, _ := ctx.Value(executionInfoKey{}).(*executionInfo)
+	if info == nil {
+		return &executionInfo{}
+	}
+	return info
 }
 
 // Execute executes a query by traversing the GraphQL query graph and resolving
@@ -115,55 +654,410 @@ func (e *Executor) Execute(ctx context.Context, typ Type, source interface{}, qu
 	if !ok {
 		return nil, fmt.Errorf("expected query or mutation object for execution, got: %s", typ.String())
 	}
+	if query.Kind == "subscription" {
+		return nil, NewClientError("subscriptions must be executed with Subscribe")
+	}
+
+	var result interface{}
+	err := e.runExecution(ctx, queryObject, query, func(ctx context.Context) (err error) {
+		result, err = e.execute(ctx, queryObject, source, query)
+		return err
+	})
+	return result, err
+}
+
+// runExecution calls run with the context of a new execution of the query,
+// after checking the query's limits and applying its operation directives.
+// root is the object the query selects on, whose selections are checked with
+// checkQuery, or nil for executions of selections nested in a query that was
+// checked already (e.g. deferred fragments).
+func (e *Executor) runExecution(ctx context.Context, root *Object, query *Query, run func(ctx context.Context) error) error {
+	if root != nil {
+		if err := e.checkQuery(ctx, root, query); err != nil {
+			return e.presentError(ctx, err)
+		}
+	}
+	if err := e.CheckQueryLimits(query); err != nil {
+		return e.presentError(ctx, err)
+	}
 
-	topLevelSelections, err := Flatten(query.SelectionSet)
+	config, err := e.operationConfig(query)
+	if err != nil {
+		return e.presentError(ctx, err)
+	}
+
+	ctx, execution, err := e.startExecution(ctx)
+	if err != nil {
+		return err
+	}
+	defer e.finishExecution(execution)
+
+	ctx, cancel := withOperationConfig(ctx, config)
+	defer cancel()
+
+	ctx = e.withExecutionInfo(ctx, query)
+	defer executionInfoFromContext(ctx).runCleanups()
+
+	if e.snapshot != nil {
+		err = e.establishSnapshot(ctx)
+	}
+	if err == nil {
+		err = run(ctx)
+	}
+	info := executionInfoFromContext(ctx)
+	if info.stats != nil {
+		if stats, ok := info.stats.finish(); ok {
+			e.reportExecutionStats(ctx, stats)
+		}
+	}
+	if info.limiter != nil && info.limiter.isExceeded() {
+		err = ErrTooManyExecutionUnits
+	}
+	return e.reportError(ctx, err)
+}
+
+// reportError logs and presents an error of the execution ctx belongs to,
+// with the executor's ErrorLogger and ErrorPresenter, if any.
+func (e *Executor) reportError(ctx context.Context, err error) error {
+	if err != nil && e.errorLogger != nil {
+		e.errorLogger.Error(ctx, err, executionInfoFromContext(ctx).tags())
+	}
+	return e.presentError(ctx, err)
+}
+
+// presentError presents err with the executor's ErrorPresenter, if any.
+func (e *Executor) presentError(ctx context.Context, err error) error {
+	if err != nil && e.errorPresenter != nil {
+		err = presentError(ctx, e.errorPresenter, err)
+	}
+	return err
+}
+
+// CheckQueryLimits checks the shape of a parsed query against the limits set
+// by WithMaxFragmentSpreads and WithMaxDepth.  It doesn't need the query to
+// be prepared, so servers call it before PrepareQuery to reject queries over
+// the limits before validating them, which could be as costly as running
+// them.  Execute checks the limits too.
+func (e *Executor) CheckQueryLimits(query *Query) error {
+	if e.maxFragmentSpreads > 0 {
+		if err := checkFragmentSpreads(query.SelectionSet, e.maxFragmentSpreads); err != nil {
+			return err
+		}
+	}
+	if e.maxDepth > 0 {
+		if err := checkDepth(query.SelectionSet, e.maxDepth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkQuery checks the selections of a query on root, the object it
+// selects on, against the executor's limits.
+func (e *Executor) checkQuery(ctx context.Context, root *Object, query *Query) error {
+	if e.maxComplexity > 0 {
+		complexity, err := QueryComplexity(root, query.SelectionSet)
+		if err != nil {
+			return err
+		}
+		if complexity > e.maxComplexity {
+			return NewClientError("query is too complex: its cost of %d exceeds the maximum of %d", complexity, e.maxComplexity)
+		}
+	}
+	if e.isAuthenticated != nil && !e.isAuthenticated(ctx) {
+		selections, err := Flatten(query.SelectionSet)
+		if err != nil {
+			return err
+		}
+		for _, selection := range selections {
+			if !isIntrospectionField(selection.Name) {
+				return ErrUnauthenticated
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Executor) execute(ctx context.Context, queryObject *Object, source interface{}, query *Query) (interface{}, error) {
+	if e.stallTimeout > 0 {
+		// Cancel the resolvers of an execution aborted by the watchdog.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+	}
+
+	selectionSet := query.SelectionSet
+	var deferred []*Fragment
+	if executionInfoFromContext(ctx).deferCollector != nil {
+		var err error
+		selectionSet, deferred, err = splitDeferred(selectionSet)
+		if err != nil {
+			return nil, err
+		}
+	}
+	topLevelSelections, err := Flatten(selectionSet)
 	if err != nil {
 		return nil, err
 	}
+	if e.maxRootSelections > 0 && len(topLevelSelections) > e.maxRootSelections {
+		return nil, NewClientError("too many root selections: query exceeds the maximum of %d", e.maxRootSelections)
+	}
 	topLevelRespWriter := newTopLevelOutputNode(query.Name)
-	initialSelectionWorkUnits := make([]*WorkUnit, 0, len(topLevelSelections))
+	if len(deferred) > 0 {
+		executionInfoFromContext(ctx).deferCollector.add(queryObject, []interface{}{source}, []*outputNode{topLevelRespWriter}, deferred)
+	}
+	planned := make([]*plannedSelection, 0, len(topLevelSelections))
 	writers := make(map[string]*outputNode)
 	for _, selection := range topLevelSelections {
-		ok, err := ShouldIncludeNode(selection.Directives)
-		if err != nil {
-			return nil, err
-		}
-		if !ok {
-			continue
-		}
 		field, ok := queryObject.Fields[selection.Name]
 		if !ok {
 			return nil, fmt.Errorf("invalid top-level selection %q", selection.Name)
 		}
 
-		writer := newOutputNode(topLevelRespWriter, selection.Alias)
+		writer := newSelectionOutputNode(topLevelRespWriter, selection)
+		writer.nonNull = isNonNull(field.Type)
 		writers[selection.Alias] = writer
 
-		initialSelectionWorkUnits = append(
-			initialSelectionWorkUnits,
-			&WorkUnit{
+		planned = append(planned, &plannedSelection{
+			selection: selection,
+			field:     field,
+			units: []*WorkUnit{{
 				Ctx:          ctx,
 				sources:      []interface{}{source},
 				field:        field,
 				destinations: []*outputNode{writer},
 				selection:    selection,
 				objectName:   queryObject.Name,
-			},
-		)
+			}},
+		})
+	}
+	var initialSelectionWorkUnits []*WorkUnit
+	if query.Kind == "mutation" {
+		for _, p := range planned {
+			initialSelectionWorkUnits = append(initialSelectionWorkUnits, p.units...)
+		}
+	} else {
+		initialSelectionWorkUnits = orderDependentUnits(planned)
 	}
 
-	e.scheduler.Run(executeWorkUnit, initialSelectionWorkUnits...)
+	resolver := e.executionResolver(ctx, len(initialSelectionWorkUnits))
+	var watchdog *stallWatchdog
+	if e.stallTimeout > 0 {
+		watchdog = newStallWatchdog(e.stallTimeout, len(initialSelectionWorkUnits))
+		resolver = watchdog.wrap(resolver)
+	}
+	run := func() { e.scheduler.Run(resolver, initialSelectionWorkUnits...) }
+	if query.Kind == "mutation" {
+		// Mutation fields run serially, in the order they were selected in:
+		// each one, including its selections, finishes before the next one
+		// starts.  The remaining fields are skipped once one fails, unless
+		// partial results are returned.
+		run = func() {
+			for _, p := range planned {
+				if ctx.Err() != nil || (!e.partialResults && topLevelRespWriter.errRecorder.get() != nil) {
+					return
+				}
+				e.scheduler.Run(resolver, p.units...)
+			}
+		}
+	}
+	finished := runUntilDeadline(ctx, watchdog, run)
+	if !finished && watchdog != nil {
+		if err := watchdog.stallError(); err != nil {
+			return nil, err
+		}
+	}
+	if limiter := executionInfoFromContext(ctx).limiter; limiter != nil && limiter.isExceeded() {
+		return nil, ErrTooManyExecutionUnits
+	}
 
-	if topLevelRespWriter.errRecorder.err != nil {
-		return nil, topLevelRespWriter.errRecorder.err
+	err = topLevelRespWriter.errRecorder.get()
+	if ctx.Err() == context.DeadlineExceeded && (!finished || errors.Is(err, context.DeadlineExceeded)) {
+		// The deadline expired before every unit finished, so return what has
+		// been resolved so far (with nulls for the rest) along with an error.
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+		return outputNodeToJSON(writers), WrapAsSafeError(ctx.Err(), "operation timed out")
+	}
+	var timeoutErr *fieldTimeoutError
+	if errors.As(err, &timeoutErr) {
+		// A field timed out (see Field.Timeout), so return the rest of the
+		// result along with its error.
+		return outputNodeToJSON(writers), err
+	}
+	if err != nil {
+		if e.partialResults {
+			result, _ := outputNodeToPartialJSON(writers)
+			return result, topLevelRespWriter.errRecorder.all()
+		}
+		if e.errorPresenter != nil {
+			// Present the error of every failed field.
+			return nil, topLevelRespWriter.errRecorder.all()
+		}
+		return nil, err
 	}
 	return outputNodeToJSON(writers), nil
 }
 
+// unitResolver returns the UnitResolver the executor schedules work with.
+func (e *Executor) unitResolver() UnitResolver {
+	if e.batchPolicy == nil {
+		return executeWorkUnit
+	}
+	return func(unit *WorkUnit) []*WorkUnit {
+		atomic.AddInt64(&e.inFlightUnits, 1)
+		defer atomic.AddInt64(&e.inFlightUnits, -1)
+		return executeWorkUnit(unit)
+	}
+}
+
+// executionResolver returns the UnitResolver an execution schedules units
+// with, after counting the units it starts the scheduler with.  Units are
+// counted for the execution's stats and limit, if any.
+func (e *Executor) executionResolver(ctx context.Context, startingUnits int) UnitResolver {
+	resolver := e.unitResolver()
+	info := executionInfoFromContext(ctx)
+	if info.stats != nil {
+		info.stats.schedule(startingUnits)
+		resolver = info.stats.wrap(resolver)
+	}
+	if info.limiter != nil {
+		info.limiter.schedule(startingUnits)
+		resolver = info.limiter.wrap(resolver)
+	}
+	return resolver
+}
+
+// runUntilDeadline calls run, returning false if the context's deadline
+// expires (or the watchdog, if any, detects a stall) before run returns.  In
+// that case run keeps going in the background, and the execution's OnComplete
+// callbacks are delayed until it finishes.
+func runUntilDeadline(ctx context.Context, watchdog *stallWatchdog, run func()) bool {
+	if _, ok := ctx.Deadline(); !ok && watchdog == nil {
+		run()
+		return true
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		run()
+	}()
+
+	var stalled <-chan struct{}
+	if watchdog != nil {
+		go watchdog.watch(done)
+		stalled = watchdog.stalled
+	}
+
+	select {
+	case <-done:
+		return true
+	case <-stalled:
+	case <-ctx.Done():
+		if ctx.Err() != context.DeadlineExceeded {
+			// Only a deadline (such as the operation timeout) produces a
+			// partial result; otherwise wait for the resolvers to notice
+			// cancelation.
+			<-done
+			return true
+		}
+	}
+
+	info := executionInfoFromContext(ctx)
+	info.detachCleanups(done)
+	return false
+}
+
 // executeWorkUnit executes/resolves a work unit and checks the
 // selections of the unit to determine if it needs to schedule more work (which
 // will be returned as new work units that will need to get scheduled.
 func executeWorkUnit(unit *WorkUnit) []*WorkUnit {
+	if info := executionInfoFromContext(unit.Ctx); info.tracer != nil && info.traceUnits {
+		return traceWorkUnit(info.tracer, unit)
+	}
+	return runWorkUnit(unit)
+}
+
+// runWorkUnit is a helper function for executeWorkUnit that executes the unit,
+// within its span if it is traced.
+func runWorkUnit(unit *WorkUnit) []*WorkUnit {
+	var units []*WorkUnit
+	if err := unit.Ctx.Err(); err != nil {
+		// Once the execution is canceled (e.g. the client went away) or
+		// timed out, fail the remaining units rather than calling their
+		// resolvers for nothing.
+		for _, dest := range unit.destinations {
+			dest.Fail(err)
+		}
+	} else {
+		units = safeResolveWorkUnit(unit)
+	}
+	for _, gate := range unit.dependents {
+		units = append(units, gate.release()...)
+	}
+	return units
+}
+
+// safeResolveWorkUnit resolves a unit, failing it if anything panics outside
+// of its resolvers (which recover on their own), e.g. a transformer, so that a
+// bad field can't take down the scheduler's goroutine along with the server.
+func safeResolveWorkUnit(unit *WorkUnit) (units []*WorkUnit) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err := fmt.Errorf("resolving %s.%s: %w", unit.objectName, unit.selection.Name, panicError(panicErr))
+			for _, dest := range unit.destinations {
+				dest.Fail(err)
+			}
+			units = nil
+		}
+	}()
+	return resolveWorkUnit(unit)
+}
+
+func resolveWorkUnit(unit *WorkUnit) []*WorkUnit {
+	if len(unit.grouped) > 0 {
+		// The members run concurrently, so that their resolvers are called
+		// together, e.g. to coalesce the calls of a batch.Func they share.
+		memberUnits := make([][]*WorkUnit, len(unit.grouped))
+		var wg sync.WaitGroup
+		for i, member := range unit.grouped {
+			wg.Add(1)
+			go func(i int, member *WorkUnit) {
+				defer wg.Done()
+				memberUnits[i] = executeWorkUnit(member)
+			}(i, member)
+		}
+		wg.Wait()
+
+		var units []*WorkUnit
+		for _, newUnits := range memberUnits {
+			units = append(units, newUnits...)
+		}
+		return units
+	}
+
+	if !FieldEnabled(unit.Ctx, unit.field) {
+		// The query was prepared with the field's feature flag enabled, but it
+		// isn't for this execution.
+		err := NewClientError(`unknown field "%s"`, unit.selection.Name)
+		for _, dest := range unit.destinations {
+			dest.Fail(err)
+		}
+		return nil
+	}
+
+	if unit = authorizeWorkUnit(unit); unit == nil {
+		return nil
+	}
+
+	if mocks := executionInfoFromContext(unit.Ctx).mocks; mocks != nil {
+		if units, ok := executeMockWorkUnit(unit, mocks); ok {
+			return units
+		}
+	}
+
 	if unit.field.Batch && unit.useBatch {
 		return executeBatchWorkUnit(unit)
 	}
@@ -180,14 +1074,63 @@ func executeWorkUnit(unit *WorkUnit) []*WorkUnit {
 }
 
 func executeBatchWorkUnit(unit *WorkUnit) []*WorkUnit {
-	results, err := SafeExecuteBatchResolver(unit.Ctx, unit.field, unit.sources, unit.selection.Args, unit.selection.SelectionSet)
+	executionInfoFromContext(unit.Ctx).recordBatch(unit.objectName+"."+unit.selection.Name, len(unit.sources))
+
+	var results []interface{}
+	err := callResolver(unit.Ctx, unit, unit.destinations, func(ctx context.Context) (err error) {
+		selectionSet, err := resolverSelectionSet(unit.field, unit.selection.SelectionSet)
+		if err != nil {
+			return err
+		}
+		err = resolveWithRetries(ctx, unit.field, func() (err error) {
+			results, err = SafeExecuteBatchResolver(ctx, unit.field, unit.sources, unit.selection.Args, selectionSet)
+			return err
+		})
+		if err != nil {
+			// Keep the results returned along with the error if the
+			// fallback fails too, for the sources the error doesn't fail.
+			if fallbackResults, fallbackErr := executeBatchFallback(ctx, unit.field, unit.sources, unit.selection.Args, selectionSet, results, err); fallbackErr == nil {
+				results, err = fallbackResults, nil
+			}
+		}
+		return err
+	})
+	var sourceErrs map[int]error
 	if err != nil {
-		for _, dest := range unit.destinations {
-			dest.Fail(err)
+		// An error made only of BatchSourceErrors fails just those sources,
+		// and the others are resolved from the results returned with it.
+		// Without results for the other sources, they fail with err too.
+		var ok bool
+		sourceErrs, ok = splitBatchSourceErrors(err, len(unit.sources))
+		if !ok || len(results) != len(unit.sources) {
+			for idx, dest := range unit.destinations {
+				if sourceErr, ok := sourceErrs[idx]; ok {
+					dest.Fail(sourceErr)
+				} else {
+					dest.Fail(err)
+				}
+			}
+			return nil
 		}
-		return nil
 	}
-	unitChildren, err := resolveBatch(unit.Ctx, results, unit.field.Type, unit.selection.SelectionSet, unit.destinations)
+	// Only fail the destinations of the results that fail, so that e.g. the
+	// other elements of a list are still resolved.
+	destinations := make([]*outputNode, 0, len(results))
+	processed := make([]interface{}, 0, len(results))
+	for idx, result := range results {
+		if sourceErr, ok := sourceErrs[idx]; ok {
+			unit.destinations[idx].Fail(sourceErr)
+			continue
+		}
+		result, err := processResult(unit.field, result)
+		if err != nil {
+			unit.destinations[idx].Fail(err)
+			continue
+		}
+		destinations = append(destinations, unit.destinations[idx])
+		processed = append(processed, result)
+	}
+	unitChildren, err := resolveBatch(unit.Ctx, processed, unit.field.Type, unit.selection.SelectionSet, destinations)
 	if err != nil {
 		for _, dest := range unit.destinations {
 			dest.Fail(err)
@@ -199,6 +1142,7 @@ func executeBatchWorkUnit(unit *WorkUnit) []*WorkUnit {
 
 func executeNonExpensiveWorkUnit(unit *WorkUnit) []*WorkUnit {
 	results := make([]interface{}, 0, len(unit.sources))
+	destinations := make([]*outputNode, 0, len(unit.sources))
 	for idx, src := range unit.sources {
 		ctx := unit.Ctx
 
@@ -207,17 +1151,33 @@ func executeNonExpensiveWorkUnit(unit *WorkUnit) []*WorkUnit {
 		if unit.objectName != "Mutation" {
 			ctx = context.WithValue(unit.Ctx, nonExpensive{}, struct{}{})
 		}
-		fieldResult, err := SafeExecuteResolver(ctx, unit.field, src, unit.selection.Args, unit.selection.SelectionSet)
+		if unit.field.EnrichContext != nil {
+			ctx = unit.field.EnrichContext(ctx, src)
+		}
+		var fieldResult interface{}
+		err := callResolver(ctx, unit, unit.destinations[idx:idx+1], func(ctx context.Context) (err error) {
+			selectionSet, err := resolverSelectionSet(unit.field, unit.selection.SelectionSet)
+			if err != nil {
+				return err
+			}
+			fieldResult, err = executeResolverWithFallback(ctx, unit.field, src, unit.selection.Args, selectionSet)
+			return err
+		})
+		if err == nil {
+			fieldResult, err = processResult(unit.field, fieldResult)
+		}
 		if err != nil {
-			// Fail the unit and exit.
+			// Only fail the source's own destination, so that e.g. the other
+			// elements of a list are still resolved.
 			unit.destinations[idx].Fail(err)
-			return nil
+			continue
 		}
 		results = append(results, fieldResult)
+		destinations = append(destinations, unit.destinations[idx])
 	}
-	unitChildren, err := resolveBatch(unit.Ctx, results, unit.field.Type, unit.selection.SelectionSet, unit.destinations)
+	unitChildren, err := resolveBatch(unit.Ctx, results, unit.field.Type, unit.selection.SelectionSet, destinations)
 	if err != nil {
-		for _, dest := range unit.destinations {
+		for _, dest := range destinations {
 			dest.Fail(err)
 		}
 		return nil
@@ -236,7 +1196,7 @@ func executeNonBatchWorkUnitWithCaching(src interface{}, dest *outputNode, unit
 	subDestRes, err := reactive.Cache(unit.Ctx, getWorkCacheKey(src, unit.field, unit.selection), func(ctx context.Context) (interface{}, error) {
 		subDest := newOutputNode(dest, "")
 		workUnits = executeNonBatchWorkUnit(ctx, src, subDest, unit)
-		return subDest.res, nil
+		return subDest.result(), nil
 	})
 	if err != nil {
 		dest.Fail(err)
@@ -260,9 +1220,41 @@ func getWorkCacheKey(src interface{}, field *Field, selection *Selection) resolv
 	return key
 }
 
+// acquireExpensive waits until the executor's limit on expensive resolvers
+// (see WithMaxExpensiveConcurrency) allows another one to run, and returns a
+// func to call once it has returned.  The limit is only held while the
+// resolver runs, not while its result is resolved (or while it waits to be
+// retried), so it can't deadlock.
+func (info *executionInfo) acquireExpensive(ctx context.Context) (func(), error) {
+	if info.expensiveSem == nil {
+		return func() {}, nil
+	}
+	select {
+	case info.expensiveSem <- struct{}{}:
+		return func() { <-info.expensiveSem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 // executeNonBatchWorkUnit resolves a non-batch field in our graphql response graph.
 func executeNonBatchWorkUnit(ctx context.Context, src interface{}, dest *outputNode, unit *WorkUnit) []*WorkUnit {
-	fieldResult, err := SafeExecuteResolver(ctx, unit.field, src, unit.selection.Args, unit.selection.SelectionSet)
+	resolveCtx := ctx
+	if unit.field.EnrichContext != nil {
+		resolveCtx = unit.field.EnrichContext(ctx, src)
+	}
+	var fieldResult interface{}
+	err := callResolver(resolveCtx, unit, []*outputNode{dest}, func(ctx context.Context) (err error) {
+		selectionSet, err := resolverSelectionSet(unit.field, unit.selection.SelectionSet)
+		if err != nil {
+			return err
+		}
+		fieldResult, err = executeResolverWithFallback(ctx, unit.field, src, unit.selection.Args, selectionSet)
+		return err
+	})
+	if err == nil {
+		fieldResult, err = processResult(unit.field, fieldResult)
+	}
 	if err != nil {
 		dest.Fail(err)
 		return nil
@@ -275,6 +1267,23 @@ func executeNonBatchWorkUnit(ctx context.Context, src interface{}, dest *outputN
 	return subFieldWorkUnits
 }
 
+// processResult runs a resolved value through the field's Transformers, in
+// order, and checks the final value with its Validate func, if it has one.
+func processResult(field *Field, value interface{}) (interface{}, error) {
+	for _, transform := range field.Transformers {
+		var err error
+		if value, err = transform(value); err != nil {
+			return nil, err
+		}
+	}
+	if field.Validate != nil {
+		if err := field.Validate(value); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
 // resolveBatch traverses the provided sources and fills in result data and
 // returns new work units that are required to resolve the rest of the
 // query result.
@@ -284,16 +1293,21 @@ func resolveBatch(ctx context.Context, sources []interface{}, typ Type, selectio
 	}
 	switch typ := typ.(type) {
 	case *Scalar:
-		return nil, resolveScalarBatch(sources, typ, destinations)
+		return nil, resolveScalarBatch(ctx, sources, typ, destinations)
 	case *Enum:
 		return nil, resolveEnumBatch(sources, typ, destinations)
 	case *List:
-		return resolveListBatch(ctx, sources, typ, selectionSet, destinations)
+		return resolveListBatch(ctx, sources, typ, true, selectionSet, destinations)
 	case *Union:
 		return resolveUnionBatch(ctx, sources, typ, selectionSet, destinations)
+	case *Interface:
+		return resolveInterfaceBatch(ctx, sources, typ, selectionSet, destinations)
 	case *Object:
 		return resolveObjectBatch(ctx, sources, typ, selectionSet, destinations)
 	case *NonNull:
+		if list, ok := typ.Type.(*List); ok {
+			return resolveListBatch(ctx, sources, list, false, selectionSet, destinations)
+		}
 		return resolveBatch(ctx, sources, typ.Type, selectionSet, destinations)
 	default:
 		panic(typ)
@@ -301,29 +1315,63 @@ func resolveBatch(ctx context.Context, sources []interface{}, typ Type, selectio
 }
 
 // Resolves the scalar type value for all the provided sources.
-func resolveScalarBatch(sources []interface{}, typ *Scalar, destinations []*outputNode) error {
+func resolveScalarBatch(ctx context.Context, sources []interface{}, typ *Scalar, destinations []*outputNode) error {
+	if coercion := scalarCoercion(ctx, typ); coercion != nil {
+		for i, source := range sources {
+			res, err := coerceScalar(coercion, source)
+			if err != nil {
+				destinations[i].Fail(err)
+				continue
+			}
+			fillValue(ctx, destinations[i], res)
+		}
+		return nil
+	}
+
 	for i, source := range sources {
 		if typ.Unwrapper == nil {
-			destinations[i].Fill(unwrap(source))
+			fillValue(ctx, destinations[i], unwrap(source))
 			continue
 		}
 		res, err := typ.Unwrapper(source)
 		if err != nil {
-			return err
+			// Only fail the source's own destination, so that the error
+			// names its path and the other sources (e.g. the other elements
+			// of a list) are still resolved.
+			destinations[i].Fail(err)
+			continue
 		}
-		destinations[i].Fill(res)
+		fillValue(ctx, destinations[i], res)
 	}
 	return nil
 }
 
+// fillValue fills dest with a resolved value, or with null using fillNull.
+func fillValue(ctx context.Context, dest *outputNode, value interface{}) {
+	if value == nil {
+		fillNull(ctx, dest)
+		return
+	}
+	dest.Fill(value)
+}
+
+// fillNull fills dest with null.  When returning partial results (see
+// WithPartialResults), a null for a non-null value fails dest instead, so
+// that, as the GraphQL spec prescribes, its nearest nullable ancestor is null.
+func fillNull(ctx context.Context, dest *outputNode) {
+	if dest.nonNull && executionInfoFromContext(ctx).partialResults {
+		dest.Fail(errors.New("cannot return null for a non-null value"))
+		return
+	}
+	dest.Fill(nil)
+}
+
 // Resolves the enum type value for all the provided sources.
 func resolveEnumBatch(sources []interface{}, typ *Enum, destinations []*outputNode) error {
 	for i, source := range sources {
 		val := unwrap(source)
 		if mapVal, ok := typ.ReverseMap[val]; !ok {
-			err := errors.New("enum is not valid")
-			destinations[i].Fail(err)
-			return err
+			destinations[i].Fail(errors.New("enum is not valid"))
 		} else {
 			destinations[i].Fill(mapVal)
 		}
@@ -333,26 +1381,49 @@ func resolveEnumBatch(sources []interface{}, typ *Enum, destinations []*outputNo
 
 // Flattens the sources for the list type and calls into an unwrapper method for
 // the list's subtype.
-func resolveListBatch(ctx context.Context, sources []interface{}, typ *List, selectionSet *SelectionSet, destinations []*outputNode) ([]*WorkUnit, error) {
+func resolveListBatch(ctx context.Context, sources []interface{}, typ *List, nullable bool, selectionSet *SelectionSet, destinations []*outputNode) ([]*WorkUnit, error) {
+	nilAsNull := nullable && executionInfoFromContext(ctx).nilListsAsNull
+
 	reflectedSources := make([]reflect.Value, len(sources))
+	failed := make([]bool, len(sources))
 	numFlattenedSources := 0
 	for idx, source := range sources {
 		reflectedSources[idx] = reflect.ValueOf(source)
+		if isIterator(reflectedSources[idx]) {
+			items, err := pullIterator(reflectedSources[idx], typ.MaxItems)
+			if err != nil {
+				// Only fail the iterator's own list, so that the other lists
+				// are still resolved.
+				destinations[idx].Fail(err)
+				failed[idx] = true
+				continue
+			}
+			reflectedSources[idx] = reflect.ValueOf(items)
+		}
 		if reflectedSources[idx].IsValid() {
 			numFlattenedSources += reflectedSources[idx].Len()
 		}
 	}
 
+	elemNonNull := isNonNull(typ.Type)
 	flattenedResps := make([]*outputNode, 0, numFlattenedSources)
 	flattenedSources := make([]interface{}, 0, numFlattenedSources)
 	for idx, slice := range reflectedSources {
-		if !slice.IsValid() {
-			destinations[idx].Fill(make([]interface{}, 0))
+		if failed[idx] {
+			continue
+		}
+		if !slice.IsValid() || (nilAsNull && slice.Kind() == reflect.Slice && slice.IsNil()) {
+			if nilAsNull {
+				destinations[idx].Fill(nil)
+			} else {
+				destinations[idx].Fill(make([]interface{}, 0))
+			}
 			continue
 		}
 		respList := make([]interface{}, slice.Len())
 		for i := 0; i < slice.Len(); i++ {
 			writer := newOutputNode(destinations[idx], strconv.Itoa(i))
+			writer.nonNull = elemNonNull
 			respList[i] = writer
 			flattenedResps = append(flattenedResps, writer)
 			flattenedSources = append(flattenedSources, slice.Index(i).Interface())
@@ -362,58 +1433,219 @@ func resolveListBatch(ctx context.Context, sources []interface{}, typ *List, sel
 	return resolveBatch(ctx, flattenedSources, typ.Type, selectionSet, flattenedResps)
 }
 
+var (
+	iteratorType = reflect.TypeOf(Iterator(nil))
+	boolType     = reflect.TypeOf(false)
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// isIterator returns whether the value is an Iterator or a func with the
+// same shape (func() (T, bool, error)).
+func isIterator(value reflect.Value) bool {
+	if !value.IsValid() || value.Kind() != reflect.Func {
+		return false
+	}
+	typ := value.Type()
+	return typ == iteratorType || (typ.NumIn() == 0 && typ.NumOut() == 3 && typ.Out(1) == boolType && typ.Out(2) == errorType)
+}
+
+// iteratorFunc converts a non-nil iterator function into an Iterator.
+func iteratorFunc(iterator reflect.Value) Iterator {
+	if next, ok := iterator.Interface().(Iterator); ok {
+		return next
+	}
+	return func() (interface{}, bool, error) {
+		out := iterator.Call(nil)
+		if err, _ := out[2].Interface().(error); err != nil {
+			return nil, false, err
+		}
+		return out[0].Interface(), out[1].Bool(), nil
+	}
+}
+
+// pullIterator pulls items from the iterator until it is exhausted or
+// maxItems items have been pulled.  A nil iterator has no items, and, like a
+// nil slice, is resolved as null with WithNilListsAsNull.
+func pullIterator(iterator reflect.Value, maxItems int) (items []interface{}, err error) {
+	if iterator.IsNil() {
+		return nil, nil
+	}
+	if maxItems <= 0 {
+		maxItems = DefaultMaxIteratorItems
+	}
+
+	next := iteratorFunc(iterator)
+	for len(items) < maxItems {
+		item, ok, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
 // Traverses the Union type and resolves or creates work units to resolve
 // all of the sub-objects for all the provided sources.
 func resolveUnionBatch(ctx context.Context, sources []interface{}, typ *Union, selectionSet *SelectionSet, destinations []*outputNode) ([]*WorkUnit, error) {
-	sourcesByType := make(map[string][]interface{}, len(typ.Types))
-	destinationsByType := make(map[string][]*outputNode, len(typ.Types))
+	sourcesByType, destinationsByType, err := splitSourcesByMember(ctx, sources, "union", typ.Name, typ.Types, typ.MemberOf, destinations)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fields selected directly on the union, including __typename, are
+	// shared by every member, and are resolved along with the fragments
+	// matching each member.  Members without a matching fragment still
+	// resolve them.
+	commonSelections := selectionSet.Selections
+
+	var workUnits []*WorkUnit
+	for srcType, sources := range sourcesByType {
+		gqlType := typ.Types[srcType]
+		if len(commonSelections) == 0 {
+			for _, fragment := range selectionSet.Fragments {
+				if fragment.On != srcType {
+					continue
+				}
+				units, err := resolveObjectBatch(ctx, sources, gqlType, fragment.SelectionSet, destinationsByType[srcType])
+				if err != nil {
+					return nil, err
+				}
+				workUnits = append(workUnits, units...)
+			}
+			continue
+		}
+
+		memberSelectionSet := &SelectionSet{Selections: commonSelections}
+		for i, fragment := range selectionSet.Fragments {
+			if fragment.On == srcType {
+				memberSelectionSet.addFragment(selectionSet, i, fragment)
+			}
+		}
+		units, err := resolveObjectBatch(ctx, sources, gqlType, memberSelectionSet, destinationsByType[srcType])
+		if err != nil {
+			return nil, err
+		}
+		workUnits = append(workUnits, units...)
+	}
+	return workUnits, nil
+}
+
+// splitSourcesByMember groups the sources of a union or interface by the
+// member type they hold.  Sources are one-hot structs, with a field named
+// after each member type of which only one is set, unless memberOf is set to
+// look up the member type of sources that are member values themselves.
+// A one-hot struct with no member or several members set fails its
+// destination, and the whole batch.
+func splitSourcesByMember(ctx context.Context, sources []interface{}, kind, name string, types map[string]*Object, memberOf func(interface{}) (string, bool), destinations []*outputNode) (map[string][]interface{}, map[string][]*outputNode, error) {
+	sourcesByType := make(map[string][]interface{}, len(types))
+	destinationsByType := make(map[string][]*outputNode, len(types))
 	for idx, src := range sources {
-		union := reflect.ValueOf(src)
-		if !union.IsValid() || (union.Kind() == reflect.Ptr && union.IsNil()) {
-			// Don't create a destination for any nil Unions types
-			destinations[idx].Fill(nil)
+		value := reflect.ValueOf(src)
+		if !value.IsValid() || (value.Kind() == reflect.Ptr && value.IsNil()) {
+			// Don't create a destination for any nil sources
+			fillNull(ctx, destinations[idx])
+			continue
+		}
+
+		if memberOf != nil {
+			srcType, ok := memberOf(src)
+			if !ok {
+				err := fmt.Errorf("%s type field returned %T, which is not one of its members", kind, src)
+				destinations[idx].Fail(err)
+				return nil, nil, err
+			}
+			sourcesByType[srcType] = append(sourcesByType[srcType], src)
+			destinationsByType[srcType] = append(destinationsByType[srcType], destinations[idx])
 			continue
 		}
 
 		srcType := ""
-		if union.Kind() == reflect.Ptr && union.Elem().Kind() == reflect.Struct {
-			union = union.Elem()
+		if value.Kind() == reflect.Ptr && value.Elem().Kind() == reflect.Struct {
+			value = value.Elem()
 		}
-		for typString := range typ.Types {
-			inner := union.FieldByName(typString)
+		for typString := range types {
+			inner := value.FieldByName(typString)
 			if inner.IsNil() {
 				continue
 			}
 			if srcType != "" {
-				return nil, fmt.Errorf("union type field should only return one value, but received: %s %s", srcType, typString)
+				// Fail the source's own destination first, so the error
+				// reports its full path (including any list index).
+				err := fmt.Errorf("%s type field should only return one value, but received: %s %s", kind, srcType, typString)
+				destinations[idx].Fail(err)
+				return nil, nil, err
 			}
 			srcType = typString
 			sourcesByType[srcType] = append(sourcesByType[srcType], inner.Interface())
 			destinationsByType[srcType] = append(destinationsByType[srcType], destinations[idx])
 		}
+		if srcType == "" {
+			// A non-nil value without any member set can't be resolved;
+			// fail it like a value with several members set.
+			err := fmt.Errorf("%s type field should return one value of %s, but received none", kind, name)
+			destinations[idx].Fail(err)
+			return nil, nil, err
+		}
+	}
+	return sourcesByType, destinationsByType, nil
+}
+
+// resolveInterfaceBatch resolves every source of an interface as its member
+// type, with the fields selected on the interface and the fragments narrowing
+// it to that member.
+func resolveInterfaceBatch(ctx context.Context, sources []interface{}, typ *Interface, selectionSet *SelectionSet, destinations []*outputNode) ([]*WorkUnit, error) {
+	sourcesByType, destinationsByType, err := splitSourcesByMember(ctx, sources, "interface", typ.Name, typ.Types, nil, destinations)
+	if err != nil {
+		return nil, err
 	}
 
 	var workUnits []*WorkUnit
 	for srcType, sources := range sourcesByType {
-		gqlType := typ.Types[srcType]
-		for _, fragment := range selectionSet.Fragments {
-			if fragment.On != srcType {
-				continue
-			}
-			units, err := resolveObjectBatch(ctx, sources, gqlType, fragment.SelectionSet, destinationsByType[srcType])
-			if err != nil {
-				return nil, err
-			}
-			workUnits = append(workUnits, units...)
+		units, err := resolveObjectBatch(ctx, sources, typ.Types[srcType], narrowSelectionSet(selectionSet, typ.Name, srcType), destinationsByType[srcType])
+		if err != nil {
+			return nil, err
 		}
-
+		workUnits = append(workUnits, units...)
 	}
 	return workUnits, nil
 }
 
+// narrowSelectionSet returns the part of a selection set on an interface that
+// applies to its member srcType: the selections on the interface, including
+// those in fragments on the interface, and the fragments on srcType.
+func narrowSelectionSet(selectionSet *SelectionSet, iface, srcType string) *SelectionSet {
+	narrowed := &SelectionSet{Selections: selectionSet.Selections}
+	for i, fragment := range selectionSet.Fragments {
+		switch fragment.On {
+		case srcType:
+			narrowed.addFragment(selectionSet, i, fragment)
+		case iface:
+			narrowed.addFragment(selectionSet, i, &Fragment{
+				On:           iface,
+				SelectionSet: narrowSelectionSet(fragment.SelectionSet, iface, srcType),
+				Directives:   fragment.Directives,
+			})
+		}
+	}
+	return narrowed
+}
+
 // Traverses the object selections and resolves or creates work units to resolve
 // all of the object fields for every source passed in.
 func resolveObjectBatch(ctx context.Context, sources []interface{}, typ *Object, selectionSet *SelectionSet, destinations []*outputNode) ([]*WorkUnit, error) {
+	collector := executionInfoFromContext(ctx).deferCollector
+	var deferred []*Fragment
+	if collector != nil {
+		var err error
+		selectionSet, deferred, err = splitDeferred(selectionSet)
+		if err != nil {
+			return nil, err
+		}
+	}
 	selections, err := Flatten(selectionSet)
 	if err != nil {
 		return nil, err
@@ -447,18 +1679,31 @@ func resolveObjectBatch(ctx context.Context, sources []interface{}, typ *Object,
 	for idx, source := range sources {
 		value := reflect.ValueOf(source)
 		if !value.IsValid() || (value.Kind() == reflect.Ptr && value.IsNil()) {
-			destinations[idx].Fill(nil)
+			fillNull(ctx, destinations[idx])
 			continue
 		}
 		nonNilSources = append(nonNilSources, source)
 		destMap := make(map[string]interface{}, len(selections))
-		destinations[idx].Fill(destMap)
 		nonNilDestinations = append(nonNilDestinations, destMap)
 		originDestinations = append(originDestinations, destinations[idx])
 	}
+	if len(deferred) > 0 {
+		collector.add(typ, nonNilSources, originDestinations, deferred)
+	}
+
+	// Expensive fields get a unit per source, unless that exceeds the
+	// executor's limit, in which case the sources are split into chunks.
+	unitsPerExpensive := len(nonNilSources)
+	if maxUnits := executionInfoFromContext(ctx).maxExpensiveUnits; maxUnits > 0 && numExpensive*unitsPerExpensive > maxUnits {
+		unitsPerExpensive = maxUnits / numExpensive
+		if unitsPerExpensive < 1 {
+			unitsPerExpensive = 1
+		}
+	}
 
 	// Number of Work Units = (NumExpensiveFields x NumSources) + NumNonExpensiveFields
-	workUnits := make([]*WorkUnit, 0, numNonExpensive+(numExpensive*len(nonNilSources)))
+	workUnits := make([]*WorkUnit, 0, numNonExpensive+(numExpensive*unitsPerExpensive))
+	planned := make([]*plannedSelection, 0, numNonExpensive+numExpensive)
 
 	// for every selection, resolve the value or schedule an work unit for the field
 	for _, selection := range selections {
@@ -469,20 +1714,15 @@ func resolveObjectBatch(ctx context.Context, sources []interface{}, typ *Object,
 			continue
 		}
 
-		if ok, err := ShouldIncludeNode(selection.Directives); err != nil {
-			return nil, nestPathError(selection.Alias, err)
-		} else if !ok {
-			continue
-		}
-
+		field := typ.Fields[selection.Name]
 		destForSelection := make([]*outputNode, 0, len(nonNilDestinations))
 		for idx, destMap := range nonNilDestinations {
-			filler := newOutputNode(originDestinations[idx], selection.Alias)
+			filler := newSelectionOutputNode(originDestinations[idx], selection)
+			filler.nonNull = isNonNull(field.Type)
 			destForSelection = append(destForSelection, filler)
 			destMap[selection.Alias] = filler
 		}
 
-		field := typ.Fields[selection.Name]
 		unit := &WorkUnit{
 			Ctx:          ctx,
 			field:        field,
@@ -495,15 +1735,22 @@ func resolveObjectBatch(ctx context.Context, sources []interface{}, typ *Object,
 		switch {
 		case shouldUseBatch(ctx, field):
 			unit.useBatch = true
-			if field.NumParallelInvocationsFunc != nil {
-				workUnits = append(workUnits, splitToNWorkUnits(unit, field.NumParallelInvocationsFunc(ctx, len(unit.sources)))...)
+			if executionInfoFromContext(ctx).batchingDisabled {
+				// Call the batch resolver with one source at a time.
+				planned = append(planned, &plannedSelection{selection: selection, field: field, units: splitWorkUnit(unit)})
+			} else if field.NumParallelInvocationsFunc != nil {
+				planned = append(planned, &plannedSelection{selection: selection, field: field, units: splitToNWorkUnits(unit, field.NumParallelInvocationsFunc(ctx, len(unit.sources)))})
 			} else {
-				workUnits = append(workUnits, unit)
+				planned = append(planned, &plannedSelection{selection: selection, field: field, units: []*WorkUnit{unit}})
 			}
 		case field.Expensive:
 			// Expensive fields should be executed as multiple "Units".  The scheduler
 			// controls how the units are executed
-			workUnits = append(workUnits, splitWorkUnit(unit)...)
+			if unitsPerExpensive < len(unit.sources) {
+				planned = append(planned, &plannedSelection{selection: selection, field: field, units: splitToNWorkUnits(unit, unitsPerExpensive)})
+			} else {
+				planned = append(planned, &plannedSelection{selection: selection, field: field, units: splitWorkUnit(unit)})
+			}
 		case field.External:
 			// External non-Expensive fields should be fast (so we can run them at the
 			// same time), but, since they are still external functions we don't want
@@ -511,10 +1758,14 @@ func resolveObjectBatch(ctx context.Context, sources []interface{}, typ *Object,
 			// So we create an work unit with all the fields to execute
 			// asynchronously.
 			if field.NumParallelInvocationsFunc != nil {
-				workUnits = append(workUnits, splitToNWorkUnits(unit, field.NumParallelInvocationsFunc(ctx, len(unit.sources)))...)
+				planned = append(planned, &plannedSelection{selection: selection, field: field, units: splitToNWorkUnits(unit, field.NumParallelInvocationsFunc(ctx, len(unit.sources)))})
 			} else {
-				workUnits = append(workUnits, unit)
+				planned = append(planned, &plannedSelection{selection: selection, field: field, units: []*WorkUnit{unit}})
 			}
+		case len(field.DependsOn) > 0:
+			// Fields with dependencies can't be resolved immediately, since their
+			// dependencies might still be running.
+			planned = append(planned, &plannedSelection{selection: selection, field: field, units: []*WorkUnit{unit}})
 		default:
 			// If the fields are not expensive or external the work time should be
 			// bounded, so we can resolve them immediately.
@@ -524,6 +1775,7 @@ func resolveObjectBatch(ctx context.Context, sources []interface{}, typ *Object,
 			)
 		}
 	}
+	workUnits = append(workUnits, groupBatchUnits(orderDependentUnits(planned))...)
 
 	if typ.KeyField != nil {
 		destForSelection := make([]*outputNode, 0, len(nonNilDestinations))
@@ -545,11 +1797,159 @@ func resolveObjectBatch(ctx context.Context, sources []interface{}, typ *Object,
 		)
 	}
 
-	return workUnits, nil
+	// Only attach the maps once they hold all of their fields, so a partial
+	// result never sees a map being written to.
+	for idx, destMap := range nonNilDestinations {
+		originDestinations[idx].Fill(destMap)
+	}
+
+	return coalesceWorkUnits(ctx, workUnits), nil
+}
+
+// coalesceWorkUnits merges batch work units that call the same resolver with
+// the same arguments, so a single call covers all of their sources.  Such
+// units come from sibling fields resolving to the same type, e.g. the "name"
+// of both the "author" and "editor" users of a post.
+//
+// Only units with leaf (or identical) selection sets are merged, since the
+// results of a merged unit are all resolved with the same selection set.
+func coalesceWorkUnits(ctx context.Context, units []*WorkUnit) []*WorkUnit {
+	if len(units) < 2 || executionInfoFromContext(ctx).batchingDisabled {
+		return units
+	}
+
+	coalesced := units[:0]
+	for _, unit := range units {
+		merged := false
+		if canCoalesce(unit) {
+			for _, target := range coalesced {
+				if canCoalesce(target) && sameBatchCall(target, unit) {
+					// The slices may be shared with other units, so copy them
+					// rather than appending in place.
+					target.sources = append(target.sources[:len(target.sources):len(target.sources)], unit.sources...)
+					target.destinations = append(target.destinations[:len(target.destinations):len(target.destinations)], unit.destinations...)
+					target.dependents = append(target.dependents[:len(target.dependents):len(target.dependents)], unit.dependents...)
+					merged = true
+					break
+				}
+			}
+		}
+		if !merged {
+			coalesced = append(coalesced, unit)
+		}
+	}
+	return coalesced
+}
+
+// canCoalesce returns whether a work unit can be merged with others.  Units
+// split up on purpose (see NumParallelInvocationsFunc) are left alone.
+func canCoalesce(unit *WorkUnit) bool {
+	return unit.useBatch && unit.field.Batch && unit.field.NumParallelInvocationsFunc == nil
+}
+
+// sameBatchCall returns whether two batch work units would call their
+// resolver the same way.
+func sameBatchCall(a, b *WorkUnit) bool {
+	return a.field == b.field &&
+		a.Ctx == b.Ctx &&
+		a.selection.SelectionSet == b.selection.SelectionSet &&
+		reflect.DeepEqual(a.selection.Args, b.selection.Args)
+}
+
+// groupBatchUnits replaces the batch units of fields with a BatchGroup by a
+// single unit per group, which resolves all of them.
+func groupBatchUnits(units []*WorkUnit) []*WorkUnit {
+	var grouped []*WorkUnit
+	groups := make(map[string]*WorkUnit)
+	for _, unit := range units {
+		if !unit.useBatch || unit.field.BatchGroup == "" {
+			grouped = append(grouped, unit)
+			continue
+		}
+		group, ok := groups[unit.field.BatchGroup]
+		if !ok {
+			// The group unit takes the first member's field, selection and
+			// sources so it can be described, e.g. by the stall watchdog.
+			group = &WorkUnit{
+				Ctx:        unit.Ctx,
+				field:      unit.field,
+				selection:  unit.selection,
+				sources:    unit.sources,
+				objectName: unit.objectName,
+			}
+			groups[unit.field.BatchGroup] = group
+			grouped = append(grouped, group)
+		}
+		group.grouped = append(group.grouped, unit)
+	}
+	return grouped
+}
+
+// plannedSelection is a selection of an object along with the work units that
+// resolve it, before they are scheduled.
+type plannedSelection struct {
+	selection *Selection
+	field     *Field
+	units     []*WorkUnit
+}
+
+// dependencyGate holds back the units of a field until the units of the
+// sibling fields it depends on have all finished.
+type dependencyGate struct {
+	remaining int64
+	units     []*WorkUnit
+}
+
+// release marks one of the dependencies as finished, returning the held units
+// once all of them are.
+func (g *dependencyGate) release() []*WorkUnit {
+	if atomic.AddInt64(&g.remaining, -1) > 0 {
+		return nil
+	}
+	return g.units
+}
+
+// orderDependentUnits returns the units of the planned selections that can be
+// scheduled right away.  The units of a field with DependsOn are instead held
+// by a gate that the units of its selected dependencies release as they
+// finish, so the field resolves only after all of them.
+func orderDependentUnits(planned []*plannedSelection) []*WorkUnit {
+	byName := make(map[string][]*plannedSelection, len(planned))
+	for _, p := range planned {
+		byName[p.selection.Name] = append(byName[p.selection.Name], p)
+	}
+
+	var ready []*WorkUnit
+	for _, p := range planned {
+		gate := &dependencyGate{units: p.units}
+		for _, name := range p.field.DependsOn {
+			for _, dependency := range byName[name] {
+				for _, unit := range dependency.units {
+					unit.dependents = append(unit.dependents, gate)
+					gate.remaining++
+				}
+			}
+		}
+		if gate.remaining == 0 {
+			ready = append(ready, p.units...)
+		}
+	}
+	return ready
 }
 
 // shouldUseBatch determines whether we will execute this field as a batch
-// based on the field information.
+// based on the field information and the executor's batch policy.
 func shouldUseBatch(ctx context.Context, field *Field) bool {
-	return field.Batch && field.UseBatchFunc(ctx)
+	if !field.Batch || !field.UseBatchFunc(ctx) {
+		return false
+	}
+	// Fields without a fallback resolver can only be batched.
+	if field.Resolve == nil {
+		return true
+	}
+	info := executionInfoFromContext(ctx)
+	if info.batchPolicy == nil {
+		return true
+	}
+	return info.batchPolicy(ctx, int(atomic.LoadInt64(info.inFlightUnits)))
 }
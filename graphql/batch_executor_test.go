@@ -3,9 +3,13 @@ package graphql_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/samsarahq/thunder/batch"
 	"github.com/samsarahq/thunder/graphql"
@@ -442,3 +446,1691 @@ func (q *counterGoroutineScheduler) runEnqueue(resolver graphql.UnitResolver, un
 		}(unit)
 	}
 }
+
+func TestMaxFragmentSpreads(t *testing.T) {
+	type Object struct {
+		Key string
+	}
+
+	var runs int64
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("object", func(ctx context.Context) *Object {
+		atomic.AddInt64(&runs, 1)
+		return &Object{Key: "key1"}
+	})
+	schema := builder.MustBuild()
+
+	// Every fragment spreads the previous fragment 10 times, so the query
+	// contains 10 + 10^2 + 10^3 + 10^4 fragment spreads.
+	var query strings.Builder
+	query.WriteString("{ object { ...F3 ...F3 ...F3 ...F3 ...F3 ...F3 ...F3 ...F3 ...F3 ...F3 } }\n")
+	query.WriteString("fragment F0 on Object { key }\n")
+	for i := 1; i <= 3; i++ {
+		fmt.Fprintf(&query, "fragment F%d on Object {", i)
+		for j := 0; j < 10; j++ {
+			fmt.Fprintf(&query, " ...F%d", i-1)
+		}
+		query.WriteString(" }\n")
+	}
+
+	q := graphql.MustParse(query.String(), nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithMaxFragmentSpreads(1000))
+	_, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "too many fragment spreads")
+	require.Equal(t, int64(0), atomic.LoadInt64(&runs), "resolvers should not run")
+
+	e = graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithMaxFragmentSpreads(20000))
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	require.Equal(t, internal.ParseJSON(`{"object": {"key": "key1"}}`), internal.AsJSON(res))
+	require.Equal(t, int64(1), atomic.LoadInt64(&runs))
+}
+
+func TestMaxFragmentSpreadsAtRoot(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("key", func() string { return "key1" })
+	schema := builder.MustBuild()
+
+	// Every fragment spreads the next one twice, at the root selection set,
+	// so the query contains 2^40 fragment spreads.  Parsing it must not
+	// expand them, so that the limit gets to reject it.
+	var query strings.Builder
+	query.WriteString("{ ...F0 }\n")
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&query, "fragment F%d on Query { ...F%d ...F%d }\n", i, i+1, i+1)
+	}
+	query.WriteString("fragment F40 on Query { key }\n")
+
+	q, err := graphql.Parse(query.String(), nil)
+	require.NoError(t, err)
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithMaxFragmentSpreads(1000)).(*graphql.Executor)
+	err = e.CheckQueryLimits(q)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "too many fragment spreads")
+	_, err = e.Execute(context.Background(), schema.Query, nil, q)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "too many fragment spreads")
+}
+
+func TestMaxRootSelections(t *testing.T) {
+	var runs int64
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("expensive", func(ctx context.Context) string {
+		atomic.AddInt64(&runs, 1)
+		return "value"
+	})
+	schema := builder.MustBuild()
+
+	var query strings.Builder
+	query.WriteString("{")
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&query, " a%d: expensive", i)
+	}
+	query.WriteString(" }")
+
+	q := graphql.MustParse(query.String(), nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithMaxRootSelections(100))
+	_, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.EqualError(t, err, "too many root selections: query exceeds the maximum of 100")
+	require.Equal(t, int64(0), atomic.LoadInt64(&runs), "resolvers should not run")
+
+	e = graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithMaxRootSelections(500))
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	require.Len(t, res, 500)
+	require.Equal(t, int64(500), atomic.LoadInt64(&runs))
+}
+
+func TestMaxDepth(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	var runs int64
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("me", func(ctx context.Context) *User {
+		atomic.AddInt64(&runs, 1)
+		return &User{Name: "alice"}
+	})
+	user := builder.Object("User", User{})
+	user.FieldFunc("friend", func(u *User) *User {
+		return &User{Name: u.Name + "'s friend"}
+	})
+	schema := builder.MustBuild()
+
+	// "me" and "friend" nest three levels; "name" is a leaf.
+	q := graphql.MustParse(`
+		{ me { name ...Friends } }
+		fragment Friends on User { friend { best: friend { name } } }
+	`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithMaxDepth(3))
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	require.Equal(t, internal.ParseJSON(`{"me": {"name": "alice", "friend": {"best": {"name": "alice's friend's friend"}}}}`), internal.AsJSON(res))
+	require.Equal(t, int64(1), atomic.LoadInt64(&runs))
+
+	e = graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithMaxDepth(2))
+	_, err = e.Execute(context.Background(), schema.Query, nil, q)
+	require.EqualError(t, err, "query is too deep: me.friend.best exceeds the maximum depth of 2")
+	require.Equal(t, int64(1), atomic.LoadInt64(&runs), "resolvers should not run")
+}
+
+func TestIteratorList(t *testing.T) {
+	var pulled int64
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("numbers", func(ctx context.Context) func() (int64, bool, error) {
+		return func() (int64, bool, error) {
+			n := atomic.AddInt64(&pulled, 1)
+			if n > 1000 {
+				return 0, false, nil
+			}
+			return n, true, nil
+		}
+	}, schemabuilder.MaxItems(100))
+	builder.Query().FieldFunc("failing", func(ctx context.Context) func() (string, bool, error) {
+		return func() (string, bool, error) {
+			return "", false, errors.New("iterator failed")
+		}
+	})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ numbers }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+
+	numbers := internal.AsJSON(res).(map[string]interface{})["numbers"].([]interface{})
+	require.Len(t, numbers, 100)
+	require.Equal(t, float64(1), numbers[0])
+	require.Equal(t, float64(100), numbers[99])
+	require.Equal(t, int64(100), atomic.LoadInt64(&pulled))
+
+	q = graphql.MustParse(`{ failing }`, nil)
+	_, err = e.Execute(context.Background(), schema.Query, nil, q)
+	require.EqualError(t, err, "failing: iterator failed")
+}
+
+func TestIteratorListFailsOwnList(t *testing.T) {
+	type Object struct {
+		Key string
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("objects", func() []Object {
+		return []Object{{Key: "a"}, {Key: "b"}, {Key: "c"}}
+	})
+	obj := builder.Object("Object", Object{})
+	obj.FieldFunc("tags", func(o Object) func() (string, bool, error) {
+		pulled := false
+		return func() (string, bool, error) {
+			if o.Key == "b" {
+				return "", false, errors.New("iterator failed")
+			}
+			if pulled {
+				return "", false, nil
+			}
+			pulled = true
+			return "tagfor" + o.Key, true, nil
+		}
+	}, schemabuilder.Nullable)
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ objects { key tags } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	// Only the list of the failing iterator fails.
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithPartialResults())
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	assert.Equal(t, []string{"objects.1.tags: iterator failed"}, errorMessages(err))
+	assert.Equal(t, internal.ParseJSON(`{"objects": [
+		{"key": "a", "tags": ["tagfora"]},
+		{"key": "b", "tags": null},
+		{"key": "c", "tags": ["tagforc"]}
+	]}`), internal.AsJSON(res))
+}
+
+func TestBatchPolicy(t *testing.T) {
+	type Object struct {
+		Key string
+	}
+
+	var batchCalls, fallbackCalls int64
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("objects", func() []Object {
+		return []Object{{Key: "a"}, {Key: "b"}, {Key: "c"}}
+	})
+	obj := builder.Object("Object", Object{})
+	obj.BatchFieldFuncWithFallback("value", func(ctx context.Context, o map[batch.Index]Object) (map[batch.Index]string, error) {
+		atomic.AddInt64(&batchCalls, 1)
+		res := make(map[batch.Index]string, len(o))
+		for idx, val := range o {
+			res[idx] = "valfor" + val.Key
+		}
+		return res, nil
+	}, func(ctx context.Context, o Object) (*string, error) {
+		atomic.AddInt64(&fallbackCalls, 1)
+		str := "valfor" + o.Key
+		return &str, nil
+	}, func(ctx context.Context) bool { return true })
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ objects { key value } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	// highLoad stands in for a pluggable load sensor.
+	var highLoad bool
+	sensorPolicy := func(ctx context.Context, inFlightUnits int) bool {
+		return highLoad
+	}
+
+	for _, tt := range []struct {
+		name          string
+		policy        graphql.BatchPolicy
+		highLoad      bool
+		wantBatch     int64
+		wantFallbacks int64
+	}{
+		{name: "sensor low load", policy: sensorPolicy, highLoad: false, wantFallbacks: 3},
+		{name: "sensor high load", policy: sensorPolicy, highLoad: true, wantBatch: 1},
+		// The unit resolving "objects" is in flight while "value" is scheduled.
+		{name: "in-flight units above threshold", policy: graphql.BatchAboveLoad(1), wantBatch: 1},
+		{name: "in-flight units below threshold", policy: graphql.BatchAboveLoad(100), wantFallbacks: 3},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt64(&batchCalls, 0)
+			atomic.StoreInt64(&fallbackCalls, 0)
+			highLoad = tt.highLoad
+
+			e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithBatchPolicy(tt.policy))
+			res, err := e.Execute(context.Background(), schema.Query, nil, q)
+			require.NoError(t, err)
+			assert.Equal(t, internal.ParseJSON(`{"objects": [
+				{"key": "a", "value": "valfora"},
+				{"key": "b", "value": "valforb"},
+				{"key": "c", "value": "valforc"}
+			]}`), internal.AsJSON(res))
+			assert.Equal(t, tt.wantBatch, atomic.LoadInt64(&batchCalls))
+			assert.Equal(t, tt.wantFallbacks, atomic.LoadInt64(&fallbackCalls))
+		})
+	}
+}
+
+func TestBatchingDisabled(t *testing.T) {
+	type Object struct {
+		Key string
+	}
+
+	var mu sync.Mutex
+	var batchSizes []int
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("objects", func() []Object {
+		return []Object{{Key: "a"}, {Key: "b"}, {Key: "c"}}
+	})
+	obj := builder.Object("Object", Object{})
+	obj.BatchFieldFunc("value", func(ctx context.Context, o map[batch.Index]Object) (map[batch.Index]string, error) {
+		mu.Lock()
+		batchSizes = append(batchSizes, len(o))
+		mu.Unlock()
+		res := make(map[batch.Index]string, len(o))
+		for idx, val := range o {
+			res[idx] = "valfor" + val.Key
+		}
+		return res, nil
+	})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ objects { key value } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	batched, err := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler()).Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3}, batchSizes)
+
+	batchSizes = nil
+	unbatched, err := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithBatchingDisabled()).Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 1, 1}, batchSizes)
+
+	assert.Equal(t, internal.AsJSON(batched), internal.AsJSON(unbatched))
+}
+
+// joinedErrors joins errors like errors.Join.
+type joinedErrors []error
+
+func (e joinedErrors) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e joinedErrors) Unwrap() []error { return e }
+
+func TestBatchSourceErrors(t *testing.T) {
+	type Object struct {
+		Key string
+	}
+
+	var failKeys map[string]bool
+	var otherErr error
+	var dropResults bool
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("objects", func() []Object {
+		return []Object{{Key: "a"}, {Key: "b"}, {Key: "c"}}
+	})
+	obj := builder.Object("Object", Object{})
+	obj.BatchFieldFunc("value", func(ctx context.Context, o map[batch.Index]Object) (map[batch.Index]string, error) {
+		var errs joinedErrors
+		res := make(map[batch.Index]string, len(o))
+		for idx, val := range o {
+			if failKeys[val.Key] {
+				errs = append(errs, graphql.NewBatchSourceError(idx, fmt.Errorf("%s failed", val.Key)))
+			}
+			res[idx] = "valfor" + val.Key
+		}
+		if otherErr != nil {
+			errs = append(errs, otherErr)
+		}
+		if dropResults {
+			res = nil
+		}
+		if len(errs) > 0 {
+			return res, errs
+		}
+		return res, nil
+	})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ objects { key value } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	partial := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithPartialResults())
+
+	// Each source is failed with its own error.
+	failKeys = map[string]bool{"c": true}
+	_, err := e.Execute(context.Background(), schema.Query, nil, q)
+	assert.EqualError(t, err, "objects.2.value: c failed")
+
+	failKeys = map[string]bool{"b": true, "c": true}
+	_, err = e.Execute(context.Background(), schema.Query, nil, q)
+	assert.EqualError(t, err, "objects.1.value: b failed")
+
+	// The other sources resolve to the results returned with the errors.
+	result, err := partial.Execute(context.Background(), schema.Query, nil, q)
+	assert.Equal(t, []string{"objects.1.value: b failed", "objects.2.value: c failed"}, errorMessages(err))
+	assert.Equal(t, internal.ParseJSON(`{"objects": [
+		{"key": "a", "value": "valfora"},
+		{"key": "b", "value": null},
+		{"key": "c", "value": null}
+	]}`), internal.AsJSON(result))
+
+	// Without results, the other sources fail too rather than being null.
+	failKeys = map[string]bool{"c": true}
+	dropResults = true
+	_, err = e.Execute(context.Background(), schema.Query, nil, q)
+	assert.EqualError(t, err, "objects.0.value: c failed")
+	dropResults = false
+
+	// An error that isn't for a single source fails all of them.
+	failKeys = map[string]bool{"c": true}
+	otherErr = errors.New("database unavailable")
+	_, err = e.Execute(context.Background(), schema.Query, nil, q)
+	assert.EqualError(t, err, "objects.0.value: c failed\ndatabase unavailable")
+}
+
+func TestFirstSuccess(t *testing.T) {
+	type User struct {
+		Id int64
+	}
+
+	var primaryErr, secondaryErr error
+	var secondaryMissing map[int64]bool
+	var secondarySources []interface{}
+	secondary := func(ctx context.Context, sources []interface{}, args interface{}, selectionSet *graphql.SelectionSet) ([]interface{}, error) {
+		secondarySources = sources
+		if secondaryErr != nil {
+			return nil, secondaryErr
+		}
+		var errs joinedErrors
+		results := make([]interface{}, len(sources))
+		for i, source := range sources {
+			if id := source.(*User).Id; secondaryMissing[id] {
+				errs = append(errs, graphql.NewBatchSourceError(batch.NewIndex(i), fmt.Errorf("user %d missing", id)))
+				continue
+			}
+			results[i] = fmt.Sprintf("secondary%d", source.(*User).Id)
+		}
+		if len(errs) > 0 {
+			return results, errs
+		}
+		return results, nil
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("users", func() []*User {
+		return []*User{{Id: 1}, {Id: 2}}
+	})
+	user := builder.Object("User", User{})
+	user.Use(func(field string, next graphql.BatchResolver) graphql.BatchResolver {
+		if field != "name" {
+			return next
+		}
+		return graphql.FirstSuccess(next, secondary)
+	})
+	user.BatchFieldFunc("name", func(ctx context.Context, users map[batch.Index]*User) (map[batch.Index]string, error) {
+		if primaryErr != nil {
+			return nil, primaryErr
+		}
+		var errs joinedErrors
+		names := make(map[batch.Index]string, len(users))
+		for idx, u := range users {
+			if u.Id == 2 {
+				errs = append(errs, graphql.NewBatchSourceError(idx, errors.New("not replicated")))
+				continue
+			}
+			names[idx] = fmt.Sprintf("primary%d", u.Id)
+		}
+		if len(errs) > 0 {
+			return names, errs
+		}
+		return names, nil
+	})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ users { id name } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+
+	// The secondary resolves the sources the primary failed for.
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"users": [{"id": 1, "name": "primary1"}, {"id": 2, "name": "secondary2"}]}`), internal.AsJSON(res))
+	assert.Equal(t, []interface{}{&User{Id: 2}}, secondarySources)
+
+	// If the primary fails entirely, the secondary resolves every source.
+	primaryErr = errors.New("primary unavailable")
+	res, err = e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"users": [{"id": 1, "name": "secondary1"}, {"id": 2, "name": "secondary2"}]}`), internal.AsJSON(res))
+
+	// If every resolver fails, the last one's error is returned.
+	secondaryErr = errors.New("secondary unavailable")
+	_, err = e.Execute(context.Background(), schema.Query, nil, q)
+	assert.EqualError(t, err, "users.0.name: secondary unavailable")
+
+	// Sources every resolver fails for are failed on their own, and the
+	// others still resolve.
+	primaryErr, secondaryErr = nil, nil
+	secondaryMissing = map[int64]bool{2: true}
+	partial := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithPartialResults())
+	res, err = partial.Execute(context.Background(), schema.Query, nil, q)
+	assert.Equal(t, []string{"users.1.name: user 2 missing"}, errorMessages(err))
+	assert.Equal(t, internal.ParseJSON(`{"users": [{"id": 1, "name": "primary1"}, {"id": 2, "name": null}]}`), internal.AsJSON(res))
+}
+
+// countingScheduler counts the work units run by a scheduler.
+type countingScheduler struct {
+	graphql.WorkScheduler
+	units int64
+}
+
+func (s *countingScheduler) Run(resolver graphql.UnitResolver, startingUnits ...*graphql.WorkUnit) {
+	s.WorkScheduler.Run(func(unit *graphql.WorkUnit) []*graphql.WorkUnit {
+		atomic.AddInt64(&s.units, 1)
+		return resolver(unit)
+	}, startingUnits...)
+}
+
+// fifoScheduler is a minimal WorkScheduler that runs work units one at a
+// time, in the order they are enqueued.
+type fifoScheduler struct{}
+
+func (fifoScheduler) Run(resolver graphql.UnitResolver, startingUnits ...*graphql.WorkUnit) {
+	queue := append([]*graphql.WorkUnit(nil), startingUnits...)
+	for len(queue) > 0 {
+		unit := queue[0]
+		queue = queue[1:]
+		queue = append(queue, resolver(unit)...)
+	}
+}
+
+func TestAlternateScheduler(t *testing.T) {
+	type User struct {
+		Id int64
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("users", func() []*User {
+		return []*User{{Id: 1}, {Id: 2}, {Id: 3}}
+	})
+	user := builder.Object("User", User{})
+	user.BatchFieldFunc("name", func(ctx context.Context, users map[batch.Index]*User) (map[batch.Index]string, error) {
+		names := make(map[batch.Index]string, len(users))
+		for idx, u := range users {
+			names[idx] = fmt.Sprintf("user%d", u.Id)
+		}
+		return names, nil
+	})
+	user.FieldFunc("friends", func(u *User) []*User {
+		return []*User{{Id: u.Id * 10}}
+	})
+	user.FieldFunc("score", func(ctx context.Context, u *User) int64 {
+		return u.Id * 100
+	})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ users { id name score friends { id name } } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	expected, err := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler()).Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	res, err := graphql.NewExecutor(fifoScheduler{}).Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.AsJSON(expected), internal.AsJSON(res))
+}
+
+func TestSchedulerFanOut(t *testing.T) {
+	type User struct {
+		Id int64
+	}
+
+	// Every user's expensive field is its own work unit, so resolving the
+	// list enqueues well over 10000 units at once.
+	const numUsers = 25000
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("users", func() []*User {
+		users := make([]*User, numUsers)
+		for i := range users {
+			users[i] = &User{Id: int64(i)}
+		}
+		return users
+	})
+	user := builder.Object("User", User{})
+	user.FieldFunc("score", func(ctx context.Context, u *User) int64 {
+		return u.Id * 2
+	}, schemabuilder.Expensive)
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ users { score } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	for name, scheduler := range map[string]graphql.WorkScheduler{
+		"immediate": graphql.NewImmediateGoroutineScheduler(),
+		"fifo":      fifoScheduler{},
+	} {
+		t.Run(name, func(t *testing.T) {
+			res, err := graphql.NewExecutor(scheduler).Execute(context.Background(), schema.Query, nil, q)
+			require.NoError(t, err)
+			users := internal.AsJSON(res).(map[string]interface{})["users"].([]interface{})
+			require.Len(t, users, numUsers)
+			assert.Equal(t, float64(2*(numUsers-1)), users[numUsers-1].(map[string]interface{})["score"])
+		})
+	}
+}
+
+func TestCanceledExecutionSkipsResolvers(t *testing.T) {
+	type User struct {
+		Id int64
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int64
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("users", func() []*User {
+		// The client goes away while the users are being loaded.
+		cancel()
+		return []*User{{Id: 1}, {Id: 2}}
+	})
+	user := builder.Object("User", User{})
+	user.BatchFieldFunc("name", func(ctx context.Context, users map[batch.Index]*User) (map[batch.Index]string, error) {
+		atomic.AddInt64(&calls, 1)
+		return nil, nil
+	})
+	user.FieldFunc("friends", func(u *User) []*User {
+		atomic.AddInt64(&calls, 1)
+		return nil
+	})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ users { name friends { id } } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	_, err := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler()).Execute(ctx, schema.Query, nil, q)
+	assert.True(t, errors.Is(err, context.Canceled), "expected context.Canceled, got %v", err)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&calls))
+}
+
+func TestPanicsFailTheirField(t *testing.T) {
+	type User struct {
+		Id int64
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("users", func() []*User {
+		return []*User{{Id: 1}, {Id: 2}}
+	})
+	user := builder.Object("User", User{})
+	user.BatchFieldFunc("name", func(ctx context.Context, users map[batch.Index]*User) (map[batch.Index]string, error) {
+		panic("bad resolver")
+	})
+	user.FieldFunc("email", func(u *User) string {
+		return "user@example.com"
+	}, schemabuilder.Transform(func(value interface{}) (interface{}, error) {
+		panic("bad transformer")
+	}))
+	schema := builder.MustBuild()
+
+	for query, wantErr := range map[string]string{
+		`{ users { name } }`:  "users.0.name: graphql: panic: bad resolver",
+		`{ users { email } }`: "users.0.email: resolving User.email: graphql: panic: bad transformer",
+	} {
+		q := graphql.MustParse(query, nil)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler()).Execute(context.Background(), schema.Query, nil, q)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			require.Error(t, err)
+			assert.True(t, strings.HasPrefix(err.Error(), wantErr), "unexpected error %v", err)
+			assert.Contains(t, err.Error(), "goroutine", "expected a stack trace")
+		case <-time.After(5 * time.Second):
+			t.Fatalf("execution of %s did not finish", query)
+		}
+	}
+}
+
+func TestCoalesceSiblingBatches(t *testing.T) {
+	type User struct {
+		Id int64
+	}
+	type Post struct {
+		Title  string
+		Author *User
+		Editor *User
+	}
+
+	var calls [][]int64
+	var mu sync.Mutex
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("posts", func() []*Post {
+		return []*Post{
+			{Title: "a", Author: &User{Id: 1}, Editor: &User{Id: 2}},
+			{Title: "b", Author: &User{Id: 3}, Editor: &User{Id: 1}},
+		}
+	})
+	builder.Object("Post", Post{})
+	user := builder.Object("User", User{})
+	user.BatchFieldFunc("name", func(ctx context.Context, users map[batch.Index]*User) (map[batch.Index]string, error) {
+		var ids []int64
+		names := make(map[batch.Index]string, len(users))
+		for idx, u := range users {
+			ids = append(ids, u.Id)
+			names[idx] = fmt.Sprintf("user%d", u.Id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		mu.Lock()
+		calls = append(calls, ids)
+		mu.Unlock()
+		return names, nil
+	})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ posts { title author { name } editor { name } } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"posts": [
+		{"title": "a", "author": {"name": "user1"}, "editor": {"name": "user2"}},
+		{"title": "b", "author": {"name": "user3"}, "editor": {"name": "user1"}}
+	]}`), internal.AsJSON(res))
+
+	// A single call resolves the names of both the authors and the editors.
+	assert.Equal(t, [][]int64{{1, 1, 2, 3}}, calls)
+}
+
+func TestBatchGroup(t *testing.T) {
+	type Object struct {
+		Key int64
+	}
+
+	var mu sync.Mutex
+	var calls []string
+	batchField := func(name string) func(ctx context.Context, objects map[batch.Index]Object) (map[batch.Index]string, error) {
+		return func(ctx context.Context, objects map[batch.Index]Object) (map[batch.Index]string, error) {
+			mu.Lock()
+			calls = append(calls, name)
+			mu.Unlock()
+			res := make(map[batch.Index]string, len(objects))
+			for idx, o := range objects {
+				res[idx] = fmt.Sprintf("%s%d", name, o.Key)
+			}
+			return res, nil
+		}
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("objects", func() []Object {
+		return []Object{{Key: 1}, {Key: 2}}
+	})
+	obj := builder.Object("Object", Object{})
+	obj.BatchFieldFunc("a1", batchField("a1"), schemabuilder.BatchGroup("A"))
+	obj.BatchFieldFunc("a2", batchField("a2"), schemabuilder.BatchGroup("A"))
+	obj.BatchFieldFunc("b", batchField("b"), schemabuilder.BatchGroup("B"))
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ objects { a1 a2 b } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	scheduler := &countingScheduler{WorkScheduler: graphql.NewImmediateGoroutineScheduler()}
+	e := graphql.NewExecutor(scheduler)
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"objects": [
+		{"a1": "a11", "a2": "a21", "b": "b1"},
+		{"a1": "a12", "a2": "a22", "b": "b2"}
+	]}`), internal.AsJSON(res))
+
+	// The root field's unit, one unit resolving both fields of group A, and
+	// one for group B.
+	assert.Equal(t, int64(3), atomic.LoadInt64(&scheduler.units))
+	assert.ElementsMatch(t, []string{"a1", "a2", "b"}, calls)
+}
+
+func TestBatchGroupCoalesces(t *testing.T) {
+	type Object struct {
+		Key int64
+	}
+
+	var mu sync.Mutex
+	var calls [][]interface{}
+	lookup := &batch.Func{
+		Many: func(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+			mu.Lock()
+			calls = append(calls, keys)
+			mu.Unlock()
+			return keys, nil
+		},
+		// Wait long enough for the other member, which only runs alongside
+		// this one if the members run concurrently.
+		WaitInterval: 50 * time.Millisecond,
+		MaxDuration:  time.Second,
+	}
+	batchField := func(name string) func(ctx context.Context, objects map[batch.Index]Object) (map[batch.Index]string, error) {
+		return func(ctx context.Context, objects map[batch.Index]Object) (map[batch.Index]string, error) {
+			value, err := lookup.Invoke(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			res := make(map[batch.Index]string, len(objects))
+			for idx := range objects {
+				res[idx] = value.(string)
+			}
+			return res, nil
+		}
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("objects", func() []Object {
+		return []Object{{Key: 1}, {Key: 2}}
+	})
+	obj := builder.Object("Object", Object{})
+	obj.BatchFieldFunc("a1", batchField("a1"), schemabuilder.BatchGroup("A"))
+	obj.BatchFieldFunc("a2", batchField("a2"), schemabuilder.BatchGroup("A"))
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ objects { a1 a2 } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := e.Execute(batch.WithBatching(context.Background()), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"objects": [
+		{"a1": "a1", "a2": "a2"},
+		{"a1": "a1", "a2": "a2"}
+	]}`), internal.AsJSON(res))
+
+	// The members of the group run together, so their lookups are made in a
+	// single call.
+	require.Len(t, calls, 1)
+	assert.ElementsMatch(t, []interface{}{"a1", "a2"}, calls[0])
+}
+
+func TestMaxExpensiveUnits(t *testing.T) {
+	type Object struct {
+		Key int64
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("objects", func() []Object {
+		objects := make([]Object, 10000)
+		for i := range objects {
+			objects[i].Key = int64(i)
+		}
+		return objects
+	})
+	obj := builder.Object("Object", Object{})
+	for _, name := range []string{"a", "b", "c"} {
+		obj.FieldFunc(name, func(o Object) int64 {
+			return o.Key
+		}, schemabuilder.Expensive)
+	}
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ objects { a b c } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	scheduler := &countingScheduler{WorkScheduler: graphql.NewImmediateGoroutineScheduler()}
+	e := graphql.NewExecutor(scheduler, graphql.WithMaxExpensiveUnits(300))
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+
+	objects := internal.AsJSON(res).(map[string]interface{})["objects"].([]interface{})
+	require.Len(t, objects, 10000)
+	assert.Equal(t, map[string]interface{}{"a": float64(9999), "b": float64(9999), "c": float64(9999)}, objects[9999])
+
+	// The root field's unit, plus at most 300 for the expensive fields rather
+	// than one for each of the 30000 fields.
+	assert.Equal(t, int64(301), atomic.LoadInt64(&scheduler.units))
+}
+
+func TestExecutorShutdown(t *testing.T) {
+	var started, release chan struct{}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("slow", func(ctx context.Context) (string, error) {
+		started <- struct{}{}
+		select {
+		case <-release:
+			return "done", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	})
+	builder.Query().FieldFunc("fast", func() string { return "fast" })
+	schema := builder.MustBuild()
+
+	slow := graphql.MustParse(`{ slow }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, slow.SelectionSet))
+	fast := graphql.MustParse(`{ fast }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, fast.SelectionSet))
+
+	t.Run("drains in-flight queries", func(t *testing.T) {
+		started, release = make(chan struct{}), make(chan struct{})
+		e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler()).(*graphql.Executor)
+
+		const numQueries = 3
+		results := make(chan error, numQueries)
+		for i := 0; i < numQueries; i++ {
+			go func() {
+				res, err := e.Execute(context.Background(), schema.Query, nil, slow)
+				if err == nil && !assert.Equal(t, map[string]interface{}{"slow": "done"}, internal.AsJSON(res)) {
+					err = errors.New("unexpected result")
+				}
+				results <- err
+			}()
+		}
+		for i := 0; i < numQueries; i++ {
+			<-started
+		}
+
+		shutdownErr := make(chan error, 1)
+		go func() {
+			shutdownErr <- e.Shutdown(context.Background())
+		}()
+
+		// New queries are rejected once shutdown has started.
+		require.Eventually(t, func() bool {
+			_, err := e.Execute(context.Background(), schema.Query, nil, fast)
+			return err == graphql.ErrExecutorShutdown
+		}, time.Second, time.Millisecond)
+
+		select {
+		case err := <-shutdownErr:
+			t.Fatalf("shutdown returned before queries finished: %v", err)
+		default:
+		}
+
+		close(release)
+		for i := 0; i < numQueries; i++ {
+			assert.NoError(t, <-results)
+		}
+		assert.NoError(t, <-shutdownErr)
+	})
+
+	t.Run("cancels queries after the deadline", func(t *testing.T) {
+		started, release = make(chan struct{}), make(chan struct{})
+		e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler()).(*graphql.Executor)
+
+		result := make(chan error, 1)
+		go func() {
+			_, err := e.Execute(context.Background(), schema.Query, nil, slow)
+			result <- err
+		}()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, context.DeadlineExceeded, e.Shutdown(ctx))
+		assert.EqualError(t, <-result, "slow: context canceled")
+	})
+}
+
+func TestFieldFallback(t *testing.T) {
+	type User struct {
+		Id int64
+	}
+
+	status := func(u *User) (string, error) {
+		if u.Id == 2 {
+			return "", errors.New("status service unavailable")
+		}
+		return "fresh", nil
+	}
+	var fallbackSources []interface{}
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("users", func() []*User {
+		return []*User{{Id: 1}, {Id: 2}}
+	})
+	user := builder.Object("User", User{})
+	user.FieldFunc("status", status)
+	user.FieldFunc("cachedStatus", status, schemabuilder.Fallback(func(ctx context.Context, sources []interface{}, args interface{}, selectionSet *graphql.SelectionSet) ([]interface{}, error) {
+		fallbackSources = sources
+		results := make([]interface{}, len(sources))
+		for i := range sources {
+			results[i] = "stale"
+		}
+		return results, nil
+	}))
+	user.FieldFunc("uncachedStatus", status, schemabuilder.Fallback(func(ctx context.Context, sources []interface{}, args interface{}, selectionSet *graphql.SelectionSet) ([]interface{}, error) {
+		return nil, errors.New("cache miss")
+	}))
+	schema := builder.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	execute := func(query string) (interface{}, error) {
+		q := graphql.MustParse(query, nil)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+		return e.Execute(context.Background(), schema.Query, nil, q)
+	}
+
+	// Without a fallback the error fails the query.
+	_, err := execute(`{ users { id status } }`)
+	require.EqualError(t, err, "users.1.status: status service unavailable")
+
+	// A fallback supplies stale values for the sources that failed.
+	res, err := execute(`{ users { id cachedStatus } }`)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"users": [
+		{"id": 1, "cachedStatus": "fresh"},
+		{"id": 2, "cachedStatus": "stale"}
+	]}`), internal.AsJSON(res))
+	assert.Equal(t, []interface{}{&User{Id: 2}}, fallbackSources)
+
+	// If the fallback fails too, the original error is returned.
+	_, err = execute(`{ users { id uncachedStatus } }`)
+	require.EqualError(t, err, "users.1.uncachedStatus: status service unavailable")
+}
+
+func TestBatchFieldFallback(t *testing.T) {
+	type Object struct {
+		Key string
+	}
+
+	var fallbackSources []interface{}
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("objects", func() []Object {
+		return []Object{{Key: "a"}, {Key: "b"}, {Key: "c"}}
+	})
+	obj := builder.Object("Object", Object{})
+	obj.BatchFieldFunc("value", func(ctx context.Context, o map[batch.Index]Object) (map[batch.Index]string, error) {
+		var errs joinedErrors
+		res := make(map[batch.Index]string, len(o))
+		for idx, val := range o {
+			if val.Key == "b" {
+				errs = append(errs, graphql.NewBatchSourceError(idx, errors.New("b failed")))
+				continue
+			}
+			res[idx] = "primary" + val.Key
+		}
+		if len(errs) > 0 {
+			return res, errs
+		}
+		return res, nil
+	}, schemabuilder.Fallback(func(ctx context.Context, sources []interface{}, args interface{}, selectionSet *graphql.SelectionSet) ([]interface{}, error) {
+		fallbackSources = sources
+		results := make([]interface{}, len(sources))
+		for i, source := range sources {
+			results[i] = "fallback" + source.(Object).Key
+		}
+		return results, nil
+	}))
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ objects { key value } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+
+	// Only the failed source falls back, and the others keep the results of
+	// the batch resolver.
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"objects": [
+		{"key": "a", "value": "primarya"},
+		{"key": "b", "value": "fallbackb"},
+		{"key": "c", "value": "primaryc"}
+	]}`), internal.AsJSON(res))
+	assert.Equal(t, []interface{}{Object{Key: "b"}}, fallbackSources)
+}
+
+func TestFieldEnrichContext(t *testing.T) {
+	type tenantKey struct{}
+	type User struct {
+		Id       int64
+		TenantId string
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("users", func() []*User {
+		return []*User{{Id: 1, TenantId: "acme"}, {Id: 2, TenantId: "globex"}}
+	})
+	enrich := schemabuilder.EnrichContext(func(ctx context.Context, source interface{}) context.Context {
+		return context.WithValue(ctx, tenantKey{}, source.(*User).TenantId)
+	})
+	user := builder.Object("User", User{})
+	user.FieldFunc("tenant", func(ctx context.Context, u *User) string {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		return tenant
+	}, schemabuilder.Expensive, enrich)
+	user.FieldFunc("cheapTenant", func(ctx context.Context, u *User) string {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		return tenant
+	}, enrich)
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ users { id tenant cheapTenant } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"users": [
+		{"id": 1, "tenant": "acme", "cheapTenant": "acme"},
+		{"id": 2, "tenant": "globex", "cheapTenant": "globex"}
+	]}`), internal.AsJSON(res))
+}
+
+func TestFieldDependsOn(t *testing.T) {
+	type Report struct {
+		Id int64
+	}
+
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("report", func() *Report {
+		return &Report{Id: 1}
+	})
+	report := builder.Object("Report", Report{})
+	report.FieldFunc("a", func(r *Report) int64 {
+		time.Sleep(20 * time.Millisecond)
+		record("a")
+		return 1
+	}, schemabuilder.Expensive)
+	report.FieldFunc("b", func(ctx context.Context, r *Report) int64 {
+		time.Sleep(10 * time.Millisecond)
+		record("b")
+		return 2
+	})
+	report.FieldFunc("c", func(r *Report) int64 {
+		record("c")
+		return 3
+	}, schemabuilder.DependsOn("a", "b"))
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ report { c a b } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"report": {"a": 1, "b": 2, "c": 3}}`), internal.AsJSON(res))
+	require.Len(t, events, 3)
+	assert.Equal(t, "c", events[2])
+
+	// Unselected dependencies are ignored.
+	events = nil
+	q = graphql.MustParse(`{ report { c } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	res, err = e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"report": {"c": 3}}`), internal.AsJSON(res))
+	assert.Equal(t, []string{"c"}, events)
+
+	builder = schemabuilder.NewSchema()
+	builder.Query().FieldFunc("a", func() int64 { return 1 }, schemabuilder.DependsOn("b"))
+	builder.Query().FieldFunc("b", func() int64 { return 2 }, schemabuilder.DependsOn("a"))
+	_, err = builder.Build()
+	assert.EqualError(t, err, "bad type schemabuilder.query: field dependency cycle: a -> b -> a")
+
+	builder = schemabuilder.NewSchema()
+	builder.Query().FieldFunc("a", func() int64 { return 1 }, schemabuilder.DependsOn("missing"))
+	_, err = builder.Build()
+	assert.EqualError(t, err, "bad type schemabuilder.query: field a depends on unknown field missing")
+}
+
+func TestNilListsAsNull(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("nilTags", func() []string {
+		return nil
+	}, schemabuilder.Nullable)
+	builder.Query().FieldFunc("emptyTags", func() []string {
+		return []string{}
+	}, schemabuilder.Nullable)
+	builder.Query().FieldFunc("requiredTags", func() []string {
+		return nil
+	})
+	// A nil iterator resolves like a nil slice.
+	builder.Query().FieldFunc("nilIteratorTags", func() func() (string, bool, error) {
+		return nil
+	}, schemabuilder.Nullable)
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ nilTags emptyTags requiredTags nilIteratorTags }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"nilTags": [], "emptyTags": [], "requiredTags": [], "nilIteratorTags": []}`), internal.AsJSON(res))
+
+	e = graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithNilListsAsNull())
+	res, err = e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"nilTags": null, "emptyTags": [], "requiredTags": [], "nilIteratorTags": null}`), internal.AsJSON(res))
+}
+
+func TestPartialResults(t *testing.T) {
+	type Item struct {
+		Id int64
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("items", func() []*Item {
+		return []*Item{{Id: 1}, {Id: 2}, {Id: 3}}
+	}, schemabuilder.Nullable, schemabuilder.NullableElements)
+	builder.Query().FieldFunc("requiredItems", func() []Item {
+		return []Item{{Id: 1}, {Id: 2}, {Id: 3}}
+	}, schemabuilder.Nullable)
+	builder.Query().FieldFunc("nonNullItems", func() []Item {
+		return []Item{{Id: 1}, {Id: 2}, {Id: 3}}
+	})
+	builder.Query().FieldFunc("count", func() int64 {
+		return 3
+	})
+	item := builder.Object("Item", Item{})
+	item.FieldFunc("name", func(i *Item) (string, error) {
+		if i.Id == 2 {
+			return "", errors.New("no name")
+		}
+		return fmt.Sprint("item ", i.Id), nil
+	})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ items { id name } requiredItems { id name } count }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	assert.Nil(t, res)
+	assert.Error(t, err)
+
+	// The failed element of items is null, while requiredItems, whose
+	// elements cannot be null, is null as a whole.
+	e = graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithPartialResults())
+	res, err = e.Execute(context.Background(), schema.Query, nil, q)
+	assert.ElementsMatch(t, []string{"items.1.name: no name", "requiredItems.1.name: no name"}, errorMessages(err))
+	assert.Equal(t, internal.ParseJSON(`{
+		"items": [{"id": 1, "name": "item 1"}, null, {"id": 3, "name": "item 3"}],
+		"requiredItems": null,
+		"count": 3
+	}`), internal.AsJSON(res))
+
+	// A null in a non-null position at the root nulls the whole result.
+	q = graphql.MustParse(`{ nonNullItems { id name } count }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	res, err = e.Execute(context.Background(), schema.Query, nil, q)
+	assert.Error(t, err)
+	assert.Nil(t, res)
+}
+
+func TestPartialResultsErrors(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("user", func() (*string, error) {
+		return nil, errors.New("user service is down")
+	})
+	builder.Query().FieldFunc("groups", func() ([]string, error) {
+		return nil, errors.New("group service is down")
+	}, schemabuilder.Nullable)
+	builder.Query().FieldFunc("count", func() int64 {
+		return 3
+	})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ user groups count }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithPartialResults())
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	assert.ElementsMatch(t, []string{"user: user service is down", "groups: group service is down"}, errorMessages(err))
+	assert.Equal(t, internal.ParseJSON(`{"user": null, "groups": null, "count": 3}`), internal.AsJSON(res))
+
+	var paths [][]interface{}
+	for _, err := range err.(graphql.Errors) {
+		paths = append(paths, graphql.ErrorPath(err))
+	}
+	assert.ElementsMatch(t, [][]interface{}{{"user"}, {"groups"}}, paths)
+}
+
+// errorMessages returns the messages of the Errors of an execution.
+func errorMessages(err error) []string {
+	var msgs []string
+	for _, err := range err.(graphql.Errors) {
+		msgs = append(msgs, err.Error())
+	}
+	return msgs
+}
+
+func TestPartialResultsNullPropagation(t *testing.T) {
+	type C struct{}
+	type B struct{ C C }
+	type A struct{ B B }
+	type Viewer struct{}
+	type Item struct{ Id int64 }
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("viewer", func() *Viewer {
+		return &Viewer{}
+	})
+	builder.Query().FieldFunc("a", func() *A {
+		return &A{}
+	})
+	builder.Query().FieldFunc("items", func() []*Item {
+		return []*Item{{Id: 1}, nil, {Id: 3}}
+	}, schemabuilder.Nullable)
+	builder.Query().FieldFunc("count", func() int64 {
+		return 3
+	})
+	builder.Object("Viewer", Viewer{}).FieldFunc("name", func() (string, error) {
+		return "", errors.New("no name")
+	})
+	builder.Object("A", A{})
+	builder.Object("B", B{})
+	builder.Object("C", C{}).FieldFunc("leaf", func() (string, error) {
+		return "", errors.New("no leaf")
+	})
+	builder.Object("Item", Item{})
+	schema := builder.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithPartialResults())
+	for _, c := range []struct {
+		query, result, err string
+	}{
+		// The non-null name nulls the nullable viewer.
+		{`{ viewer { name } count }`, `{"viewer": null, "count": 3}`, "viewer.name: no name"},
+		// The non-null leaf nulls the non-null c and b, up to the nullable a.
+		{`{ a { b { c { leaf } } } count }`, `{"a": null, "count": 3}`, "a.b.c.leaf: no leaf"},
+		// A nil element of a list of non-null elements nulls the list.
+		{`{ items { id } count }`, `{"items": null, "count": 3}`, "items.1: cannot return null for a non-null value"},
+	} {
+		q := graphql.MustParse(c.query, nil)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+		res, err := e.Execute(context.Background(), schema.Query, nil, q)
+		assert.EqualError(t, err, c.err, c.query)
+		assert.Equal(t, internal.ParseJSON(c.result), internal.AsJSON(res), c.query)
+	}
+
+	// Without partial results, the nil element is null.
+	q := graphql.MustParse(`{ items { id } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	e = graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"items": [{"id": 1}, null, {"id": 3}]}`), internal.AsJSON(res))
+}
+
+func TestErrorPresenter(t *testing.T) {
+	type User struct {
+		Id int64
+	}
+	errNotFound := errors.New("user not found")
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("users", func() []*User {
+		return []*User{{Id: 1}, {Id: 4}, {Id: 5}}
+	})
+	builder.Query().FieldFunc("broken", func() (int64, error) {
+		return 0, errors.New("secret internal details")
+	})
+	builder.Object("User", User{}).FieldFunc("name", func(u *User) (string, error) {
+		if u.Id > 1 {
+			return "", errNotFound
+		}
+		return "alice", nil
+	})
+	schema := builder.MustBuild()
+
+	presenter := func(ctx context.Context, err error) graphql.FormattedError {
+		if errors.Is(err, errNotFound) {
+			return graphql.FormattedError{Message: "not found", Extensions: map[string]interface{}{"code": "NOT_FOUND"}}
+		}
+		return graphql.FormattedError{Message: "internal server error"}
+	}
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithErrorPresenter(presenter), graphql.WithMaxRootSelections(2))
+
+	// The error of every failed field is presented.
+	q := graphql.MustParse(`{ users { name } broken }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	_, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.IsType(t, graphql.Errors{}, err)
+	var formatted []graphql.FormattedError
+	for _, err := range err.(graphql.Errors) {
+		var presented *graphql.PresentedError
+		require.True(t, errors.As(err, &presented), err.Error())
+		formatted = append(formatted, presented.FormattedError)
+	}
+	assert.ElementsMatch(t, []graphql.FormattedError{
+		{
+			Message:    "not found",
+			Locations:  []graphql.Location{{Line: 1, Column: 11}},
+			Path:       []interface{}{"users", 1, "name"},
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		},
+		{
+			Message:    "not found",
+			Locations:  []graphql.Location{{Line: 1, Column: 11}},
+			Path:       []interface{}{"users", 2, "name"},
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		},
+		{
+			Message:   "internal server error",
+			Locations: []graphql.Location{{Line: 1, Column: 18}},
+			Path:      []interface{}{"broken"},
+		},
+	}, formatted)
+
+	// Errors of the query itself are presented too.
+	q = graphql.MustParse(`{ a: broken b: broken c: broken }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	_, err = e.Execute(context.Background(), schema.Query, nil, q)
+	var presented *graphql.PresentedError
+	require.True(t, errors.As(err, &presented))
+	assert.Equal(t, graphql.FormattedError{Message: "internal server error"}, presented.FormattedError)
+	assert.Equal(t, "internal server error", graphql.SanitizeError(err))
+}
+
+func TestOnComplete(t *testing.T) {
+	type Transaction struct {
+		Id int64
+	}
+
+	var events []string
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("transaction", func(ctx context.Context) *Transaction {
+		events = append(events, "begin outer")
+		graphql.OnComplete(ctx, func() { events = append(events, "release outer") })
+		return &Transaction{Id: 1}
+	})
+	transaction := builder.Object("Transaction", Transaction{})
+	transaction.FieldFunc("nested", func(ctx context.Context, tx *Transaction) int64 {
+		events = append(events, "begin nested")
+		graphql.OnComplete(ctx, func() { events = append(events, "release nested") })
+		return tx.Id
+	})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ transaction { nested } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	require.Equal(t, internal.ParseJSON(`{"transaction": {"nested": 1}}`), internal.AsJSON(res))
+
+	// Cleanups run after the whole query, in reverse order of registration.
+	require.Equal(t, []string{"begin outer", "begin nested", "release nested", "release outer"}, events)
+
+	// Outside of an execution, the callback runs immediately.
+	called := false
+	graphql.OnComplete(context.Background(), func() { called = true })
+	require.True(t, called)
+}
+
+func TestOnCompleteAfterTimeout(t *testing.T) {
+	released := make(chan string, 3)
+	var lateCtx context.Context
+	unblock := make(chan struct{})
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("slow", func(ctx context.Context) string {
+		graphql.OnComplete(ctx, func() { released <- "slow" })
+		<-unblock
+		lateCtx = ctx
+		return "done"
+	})
+	builder.Query().FieldFunc("canceled", func(ctx context.Context) (string, error) {
+		graphql.OnComplete(ctx, func() { released <- "canceled" })
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ slow canceled }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithOperationTimeout(10*time.Millisecond))
+	_, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "operation timed out")
+
+	// The cleanups wait for the resolver still running, but do run.
+	select {
+	case name := <-released:
+		t.Fatalf("cleanup %s ran before the slow resolver returned", name)
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(unblock)
+	var names []string
+	for i := 0; i < 2; i++ {
+		select {
+		case name := <-released:
+			names = append(names, name)
+		case <-time.After(time.Second):
+			t.Fatal("cleanups did not run after the timeout")
+		}
+	}
+	assert.ElementsMatch(t, []string{"slow", "canceled"}, names)
+
+	// Callbacks registered after the cleanups ran are called immediately.
+	called := false
+	graphql.OnComplete(lateCtx, func() { called = true })
+	assert.True(t, called)
+}
+
+func TestFieldTimeout(t *testing.T) {
+	type Feed struct{}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("slow", func(ctx context.Context) (string, error) {
+		select {
+		case <-time.After(time.Second):
+			return "slow", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}, schemabuilder.Timeout(10*time.Millisecond))
+	builder.Query().FieldFunc("fast", func(ctx context.Context) string {
+		return "fast"
+	}, schemabuilder.Timeout(time.Second))
+	builder.Query().FieldFunc("feed", func() *Feed {
+		return &Feed{}
+	})
+	feed := builder.Object("Feed", Feed{})
+	feed.BatchFieldFunc("sleepy", func(ctx context.Context, feeds map[batch.Index]*Feed) (map[batch.Index]string, error) {
+		// Ignore the context.
+		time.Sleep(200 * time.Millisecond)
+		res := make(map[batch.Index]string, len(feeds))
+		for idx := range feeds {
+			res[idx] = "sleepy"
+		}
+		return res, nil
+	}, schemabuilder.Timeout(10*time.Millisecond))
+	feed.FieldFunc("name", func(f *Feed) string {
+		return "feed"
+	})
+	schema := builder.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+
+	q := graphql.MustParse(`{ slow fast feed { name } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.Error(t, err)
+	assert.Equal(t, "slow: field timed out after 10ms", err.Error())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	// The sibling fields still resolve.
+	assert.Equal(t, internal.ParseJSON(`{"slow": null, "fast": "fast", "feed": {"name": "feed"}}`), internal.AsJSON(res))
+
+	// The timeout bounds the query's latency even if the resolver ignores
+	// its context.
+	q = graphql.MustParse(`{ fast feed { name sleepy } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	start := time.Now()
+	res, err = e.Execute(context.Background(), schema.Query, nil, q)
+	assert.Less(t, int64(time.Since(start)), int64(100*time.Millisecond))
+	require.Error(t, err)
+	assert.Equal(t, "feed.sleepy: field timed out after 10ms", err.Error())
+	assert.Equal(t, internal.ParseJSON(`{"fast": "fast", "feed": {"name": "feed", "sleepy": null}}`), internal.AsJSON(res))
+}
+
+func TestMaxExpensiveConcurrency(t *testing.T) {
+	type Item struct {
+		Id int64
+	}
+
+	var running, peak int64
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("items", func() []*Item {
+		items := make([]*Item, 500)
+		for i := range items {
+			items[i] = &Item{Id: int64(i)}
+		}
+		return items
+	})
+	item := builder.Object("Item", Item{})
+	item.FieldFunc("details", func(ctx context.Context, i *Item) int64 {
+		current := atomic.AddInt64(&running, 1)
+		defer atomic.AddInt64(&running, -1)
+		for {
+			max := atomic.LoadInt64(&peak)
+			if current <= max || atomic.CompareAndSwapInt64(&peak, max, current) {
+				break
+			}
+		}
+		time.Sleep(100 * time.Microsecond)
+		return i.Id
+	}, schemabuilder.Expensive)
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ items { details } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithMaxExpensiveConcurrency(4))
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	items := internal.AsJSON(res).(map[string]interface{})["items"].([]interface{})
+	require.Len(t, items, 500)
+	assert.Equal(t, map[string]interface{}{"details": float64(499)}, items[499])
+
+	assert.True(t, peak > 0)
+	assert.True(t, peak <= 4, "peak concurrency %d exceeds the limit", peak)
+
+	// A limit of zero or less is unlimited.
+	for _, max := range []int{0, -1} {
+		e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithMaxExpensiveConcurrency(max))
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := e.Execute(ctx, schema.Query, nil, q)
+		cancel()
+		assert.NoError(t, err, max)
+	}
+}
+
+func TestMaxExpensiveConcurrencyRetries(t *testing.T) {
+	type Item struct {
+		Id int64
+	}
+
+	var mu sync.Mutex
+	var calls []string
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("items", func() []*Item {
+		return []*Item{{Id: 1}, {Id: 2}}
+	})
+	item := builder.Object("Item", Item{})
+	item.FieldFunc("details", func(ctx context.Context, i *Item) (int64, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, fmt.Sprint(i.Id))
+		if len(calls) <= 2 {
+			return 0, errors.New("flaky")
+		}
+		return i.Id, nil
+	}, schemabuilder.Expensive, schemabuilder.Retry(graphql.RetryPolicy{MaxAttempts: 2, Backoff: 50 * time.Millisecond}))
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ items { details } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithMaxExpensiveConcurrency(1))
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"items": [{"details": 1}, {"details": 2}]}`), internal.AsJSON(res))
+
+	// The slot is released while waiting to retry, so both items are called
+	// before either is retried.
+	require.Len(t, calls, 4)
+	assert.ElementsMatch(t, []string{"1", "2"}, calls[:2])
+}
+
+func TestSerialMutations(t *testing.T) {
+	type Result struct {
+		Name string
+	}
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("unused", func() string { return "" })
+	builder.Mutation().FieldFunc("first", func() *Result {
+		record("first")
+		return &Result{Name: "first"}
+	})
+	builder.Mutation().FieldFunc("second", func() *Result {
+		record("second")
+		return &Result{Name: "second"}
+	})
+	builder.Object("Result", Result{}).FieldFunc("nested", func(r *Result) string {
+		// Give the next mutation a chance to start if it were not waiting.
+		time.Sleep(10 * time.Millisecond)
+		record(r.Name + ".nested")
+		return r.Name
+	}, schemabuilder.Expensive)
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`mutation { second { nested } first { nested } again: second { name } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Mutation, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := e.Execute(context.Background(), schema.Mutation, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{
+		"second": {"nested": "second"},
+		"first": {"nested": "first"},
+		"again": {"name": "second"}
+	}`), internal.AsJSON(res))
+	assert.Equal(t, []string{"second", "second.nested", "first", "first.nested", "second"}, calls)
+
+	// Root fields selected by fragments run in source order too.
+	calls = nil
+	q = graphql.MustParse(`
+		mutation { first { name } ...F ... on Mutation { again: first { name } } last: second { name } }
+		fragment F on Mutation { second { name } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Mutation, q.SelectionSet))
+	_, err = e.Execute(context.Background(), schema.Mutation, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second", "first", "second"}, calls)
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/samsarahq/thunder/batch"
 	"github.com/samsarahq/thunder/graphql"
@@ -807,3 +808,57 @@ func TestBatchFieldFuncExecution(t *testing.T) {
 		}
 	}
 }
+
+func TestBatchFuncFlushesWhileSlowFieldRuns(t *testing.T) {
+	type User struct {
+		ID int64
+	}
+
+	flushed := make(chan []interface{}, 1)
+	names := &batch.Func{
+		Many: func(ctx context.Context, args []interface{}) ([]interface{}, error) {
+			flushed <- args
+			results := make([]interface{}, len(args))
+			for i, arg := range args {
+				results[i] = fmt.Sprintf("user %d", arg)
+			}
+			return results, nil
+		},
+		WaitInterval: 5 * time.Millisecond,
+		MaxDuration:  time.Minute,
+	}
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("users", func() []*User {
+		return []*User{{ID: 1}, {ID: 2}}
+	})
+	query.FieldFunc("slow", func(ctx context.Context) (string, error) {
+		// The batch must flush while this field is still running.
+		select {
+		case args := <-flushed:
+			return fmt.Sprint(args), nil
+		case <-time.After(10 * time.Second):
+			return "", errors.New("batch never flushed")
+		}
+	}, schemabuilder.Expensive)
+	user := schema.Object("User", User{})
+	user.FieldFunc("name", func(ctx context.Context, u *User) (string, error) {
+		name, err := names.Invoke(ctx, u.ID)
+		if err != nil {
+			return "", err
+		}
+		return name.(string), nil
+	}, schemabuilder.Expensive)
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ slow users { name } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := e.Execute(batch.WithBatching(context.Background()), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+
+	slow := internal.AsJSON(res).(map[string]interface{})["slow"]
+	require.Contains(t, []interface{}{"[1 2]", "[2 1]"}, slow)
+	require.Equal(t, internal.ParseJSON(`[{"name": "user 1"}, {"name": "user 2"}]`), internal.AsJSON(res).(map[string]interface{})["users"])
+}
@@ -0,0 +1,82 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+)
+
+// BatchStats measures how well batching coalesces sources, by counting the
+// calls made to the batch resolver of every field and the sources they
+// resolved.  A low average batch size means batching isn't helping much, e.g.
+// because a field's sources are spread across many work units.  It is safe
+// for concurrent use.
+type BatchStats struct {
+	mu     sync.Mutex
+	fields map[string]FieldBatchStats
+}
+
+// FieldBatchStats are the BatchStats of a single field.
+type FieldBatchStats struct {
+	// Calls is the number of times the field's batch resolver was called.
+	Calls int
+	// Sources is the total number of sources it was called with.
+	Sources int
+}
+
+// AverageBatchSize returns the average number of sources per call.
+func (s FieldBatchStats) AverageBatchSize() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Sources) / float64(s.Calls)
+}
+
+// NewBatchStats returns empty BatchStats.
+func NewBatchStats() *BatchStats {
+	return &BatchStats{fields: make(map[string]FieldBatchStats)}
+}
+
+// Fields returns the stats of every batch field resolved so far, keyed by
+// field, e.g. "User.name".
+func (s *BatchStats) Fields() map[string]FieldBatchStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fields := make(map[string]FieldBatchStats, len(s.fields))
+	for name, stats := range s.fields {
+		fields[name] = stats
+	}
+	return fields
+}
+
+func (s *BatchStats) record(field string, sources int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.fields[field]
+	stats.Calls++
+	stats.Sources += sources
+	s.fields[field] = stats
+}
+
+// WithBatchStats records the batch stats of every execution in stats,
+// aggregated across executions.
+func WithBatchStats(stats *BatchStats) ExecutorOption {
+	return func(e *Executor) {
+		e.batchStats = stats
+	}
+}
+
+type batchStatsKey struct{}
+
+// WithQueryBatchStats records the batch stats of the executions run with the
+// returned context in stats, e.g. to report them for a single query.
+func WithQueryBatchStats(ctx context.Context, stats *BatchStats) context.Context {
+	return context.WithValue(ctx, batchStatsKey{}, stats)
+}
+
+// recordBatch records a call to a batch resolver with the given number of
+// sources in the stats of the execution.
+func (info *executionInfo) recordBatch(field string, sources int) {
+	for _, stats := range info.batchStats {
+		stats.record(field, sources)
+	}
+}
@@ -0,0 +1,58 @@
+package graphql_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/samsarahq/thunder/batch"
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchStats(t *testing.T) {
+	type User struct {
+		Id int64
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("users", func() []*User {
+		return []*User{{Id: 1}, {Id: 2}, {Id: 3}, {Id: 4}}
+	})
+	user := builder.Object("User", User{})
+	user.BatchFieldFunc("name", func(ctx context.Context, users map[batch.Index]*User) (map[batch.Index]string, error) {
+		names := make(map[batch.Index]string, len(users))
+		for idx, u := range users {
+			names[idx] = fmt.Sprintf("user%d", u.Id)
+		}
+		return names, nil
+	})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ users { name } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	aggregated := graphql.NewBatchStats()
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithBatchStats(aggregated))
+
+	// All users are resolved by a single batch.
+	query := graphql.NewBatchStats()
+	_, err := e.Execute(graphql.WithQueryBatchStats(context.Background(), query), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]graphql.FieldBatchStats{"User.name": {Calls: 1, Sources: 4}}, query.Fields())
+	assert.Equal(t, 4.0, query.Fields()["User.name"].AverageBatchSize())
+
+	_, err = e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]graphql.FieldBatchStats{"User.name": {Calls: 2, Sources: 8}}, aggregated.Fields())
+
+	// Without batching, every user is resolved on its own.
+	query = graphql.NewBatchStats()
+	unbatched := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithBatchingDisabled())
+	_, err = unbatched.Execute(graphql.WithQueryBatchStats(context.Background(), query), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, query.Fields()["User.name"].AverageBatchSize())
+	assert.Equal(t, 4, query.Fields()["User.name"].Calls)
+}
@@ -0,0 +1,35 @@
+package graphql
+
+import (
+	"context"
+	"time"
+)
+
+// Clock tells the current time.  Tests can use a fake Clock to make resolvers
+// that depend on the time deterministic.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is a func that implements Clock.
+type ClockFunc func() time.Time
+
+// Now calls f.
+func (f ClockFunc) Now() time.Time { return f() }
+
+// WithClock sets the clock returned by Now during executions.
+func WithClock(clock Clock) ExecutorOption {
+	return func(e *Executor) {
+		e.clock = clock
+	}
+}
+
+// Now returns the current time according to the clock of the executor
+// running the current execution, or time.Now if it has none.  Resolvers should
+// use Now instead of time.Now so that tests can control the time.
+func Now(ctx context.Context) time.Time {
+	if clock := executionInfoFromContext(ctx).clock; clock != nil {
+		return clock.Now()
+	}
+	return time.Now()
+}
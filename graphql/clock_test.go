@@ -0,0 +1,47 @@
+package graphql_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClock(t *testing.T) {
+	type Post struct {
+		Title string
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("post", func() *Post {
+		return &Post{Title: "hello"}
+	})
+	post := builder.Object("Post", Post{})
+	post.FieldFunc("createdAt", func(ctx context.Context, p *Post) time.Time {
+		return graphql.Now(ctx)
+	})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ post { createdAt } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	fakeNow := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithClock(graphql.ClockFunc(func() time.Time {
+		return fakeNow
+	})))
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"post": map[string]interface{}{"createdAt": fakeNow}}, res)
+
+	// Without a clock, Now is the real time.
+	before := time.Now()
+	e = graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err = e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	createdAt := res.(map[string]interface{})["post"].(map[string]interface{})["createdAt"].(time.Time)
+	assert.False(t, createdAt.Before(before))
+}
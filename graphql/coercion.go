@@ -0,0 +1,38 @@
+package graphql
+
+import (
+	"context"
+	"reflect"
+)
+
+// ScalarCoercion converts a scalar value into the value sent in the response.
+type ScalarCoercion func(value interface{}) (interface{}, error)
+
+// ScalarCoercions are the coercions for scalars, by the scalar's type name
+// (e.g. "Time").
+type ScalarCoercions map[string]ScalarCoercion
+
+type scalarCoercionsKey struct{}
+
+// WithScalarCoercions overrides how the executor outputs scalars for
+// executions with the returned context, e.g. to send timestamps as integers
+// to legacy clients.  A coercion is used instead of the scalar's Unwrapper,
+// and is passed the dereferenced value; null values are not coerced.
+func WithScalarCoercions(ctx context.Context, coercions ScalarCoercions) context.Context {
+	return context.WithValue(ctx, scalarCoercionsKey{}, coercions)
+}
+
+// scalarCoercion returns the coercion for the scalar in the context, if any.
+func scalarCoercion(ctx context.Context, typ *Scalar) ScalarCoercion {
+	coercions, _ := ctx.Value(scalarCoercionsKey{}).(ScalarCoercions)
+	return coercions[typ.Type]
+}
+
+// coerceScalar converts a scalar value with a coercion.
+func coerceScalar(coercion ScalarCoercion, source interface{}) (interface{}, error) {
+	value := unwrap(source)
+	if v := reflect.ValueOf(value); !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		return nil, nil
+	}
+	return coercion(value)
+}
@@ -0,0 +1,61 @@
+package graphql_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScalarCoercions(t *testing.T) {
+	createdAt := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("createdAt", func() time.Time {
+		return createdAt
+	})
+	builder.Query().FieldFunc("deletedAt", func() *time.Time {
+		return nil
+	})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ createdAt deletedAt }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+
+	legacy := graphql.ScalarCoercions{
+		"Time": func(value interface{}) (interface{}, error) {
+			return value.(time.Time).Unix(), nil
+		},
+	}
+	modern := graphql.ScalarCoercions{
+		"Time": func(value interface{}) (interface{}, error) {
+			return value.(time.Time).Format(time.RFC3339), nil
+		},
+	}
+
+	for _, tt := range []struct {
+		name      string
+		coercions graphql.ScalarCoercions
+		want      interface{}
+	}{
+		{name: "default", want: createdAt},
+		{name: "legacy", coercions: legacy, want: int64(1577934245)},
+		{name: "modern", coercions: modern, want: "2020-01-02T03:04:05Z"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.coercions != nil {
+				ctx = graphql.WithScalarCoercions(ctx, tt.coercions)
+			}
+			res, err := e.Execute(ctx, schema.Query, nil, q)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, res.(map[string]interface{})["createdAt"])
+			assert.Nil(t, res.(map[string]interface{})["deletedAt"])
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package graphql
+
+// Weights used by WithMaxComplexity to estimate the cost of fields without a
+// Complexity func.
+const (
+	// DefaultFieldComplexity is the cost of selecting a field.
+	DefaultFieldComplexity = 1
+	// DefaultExpensiveComplexity is the cost of selecting an Expensive field.
+	DefaultExpensiveComplexity = 10
+	// DefaultListSize is the estimated number of values in a list, by which
+	// the cost of a list's sub-selections is multiplied.
+	DefaultListSize = 10
+)
+
+// ComplexityFunc computes the cost of selecting a field, given its parsed
+// arguments and the cost of its sub-selections for a single value, e.g. to
+// multiply the cost of the sub-selections by a "first" page size argument.
+type ComplexityFunc func(args interface{}, childComplexity int) int
+
+// WithMaxComplexity rejects queries whose estimated cost exceeds max before
+// any resolvers run.  See QueryComplexity for how the cost is estimated.
+func WithMaxComplexity(max int) ExecutorOption {
+	return func(e *Executor) {
+		e.maxComplexity = max
+	}
+}
+
+// QueryComplexity estimates the cost of selecting selectionSet on typ.  Every
+// selected field costs DefaultFieldComplexity (or DefaultExpensiveComplexity
+// if it is Expensive), plus the cost of its sub-selections, multiplied by
+// DefaultListSize for each list the field's type is nested in.  Fields with a
+// Complexity func compute their own cost instead.  Selections on unions and
+// interfaces cost as much as their most costly member.
+//
+// Costs saturate at the largest int rather than overflowing, and a negative
+// cost returned by a Complexity func counts as the largest int too.
+//
+// The arguments passed to Complexity funcs are only parsed once the query is
+// prepared with PrepareQuery.
+func QueryComplexity(typ Type, selectionSet *SelectionSet) (int, error) {
+	if selectionSet == nil {
+		return 0, nil
+	}
+
+	switch typ := typ.(type) {
+	case *NonNull:
+		return QueryComplexity(typ.Type, selectionSet)
+	case *List:
+		return QueryComplexity(typ.Type, selectionSet)
+	case *Object:
+		return objectComplexity(typ, selectionSet)
+	case *Union:
+		return maxMemberComplexity(typ.Types, selectionSet)
+	case *Interface:
+		return maxMemberComplexity(typ.Types, selectionSet)
+	default:
+		return 0, nil
+	}
+}
+
+// objectComplexity is a helper function for QueryComplexity that estimates the
+// cost of a selection on an object.
+func objectComplexity(typ *Object, selectionSet *SelectionSet) (int, error) {
+	selections, err := Flatten(selectionSet)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, selection := range selections {
+		// Fields of other members of a union or interface are skipped.
+		field, ok := typ.Fields[selection.Name]
+		if !ok {
+			continue
+		}
+		childComplexity, err := QueryComplexity(field.Type, selection.SelectionSet)
+		if err != nil {
+			return 0, err
+		}
+		total = addComplexity(total, fieldComplexity(field, selection.Args, childComplexity))
+	}
+	return total, nil
+}
+
+// maxMemberComplexity returns the cost of the most costly member of a union or
+// interface.
+func maxMemberComplexity(types map[string]*Object, selectionSet *SelectionSet) (int, error) {
+	max := 0
+	for _, member := range types {
+		complexity, err := objectComplexity(member, selectionSet)
+		if err != nil {
+			return 0, err
+		}
+		if complexity > max {
+			max = complexity
+		}
+	}
+	return max, nil
+}
+
+// fieldComplexity returns the cost of selecting field, given the cost of its
+// sub-selections for a single value.
+func fieldComplexity(field *Field, args interface{}, childComplexity int) int {
+	if field.Complexity != nil {
+		complexity := field.Complexity(args, childComplexity)
+		if complexity < 0 {
+			// The func's own arithmetic overflowed.
+			return maxComplexity
+		}
+		return complexity
+	}
+
+	complexity := DefaultFieldComplexity
+	if field.Expensive {
+		complexity = DefaultExpensiveComplexity
+	}
+	typ := field.Type
+	for {
+		if nonNull, ok := typ.(*NonNull); ok {
+			typ = nonNull.Type
+		}
+		list, ok := typ.(*List)
+		if !ok {
+			break
+		}
+		childComplexity = mulComplexity(childComplexity, DefaultListSize)
+		typ = list.Type
+	}
+	return addComplexity(complexity, childComplexity)
+}
+
+// maxComplexity is the largest int, at which costs saturate.
+const maxComplexity = int(^uint(0) >> 1)
+
+// addComplexity adds two non-negative costs, saturating at maxComplexity.
+func addComplexity(a, b int) int {
+	if a > maxComplexity-b {
+		return maxComplexity
+	}
+	return a + b
+}
+
+// mulComplexity multiplies two non-negative costs, saturating at
+// maxComplexity.
+func mulComplexity(a, b int) int {
+	if b != 0 && a > maxComplexity/b {
+		return maxComplexity
+	}
+	return a * b
+}
@@ -0,0 +1,170 @@
+package graphql_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxComplexity(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	builder := schemabuilder.NewSchema()
+	query := builder.Query()
+	query.FieldFunc("users", func() []*User {
+		return []*User{{Name: "alice"}, {Name: "bob"}}
+	})
+	type searchArgs struct {
+		First int64
+	}
+	query.FieldFunc("search", func(args searchArgs) []*User {
+		return []*User{{Name: "alice"}}
+	}, schemabuilder.Complexity(func(args searchArgs, childComplexity int) int {
+		return 1 + int(args.First)*childComplexity
+	}))
+	user := builder.Object("User", User{})
+	user.FieldFunc("friends", func(u *User) []*User {
+		return []*User{{Name: u.Name + "'s friend"}}
+	}, schemabuilder.Expensive)
+	schema := builder.MustBuild()
+
+	for _, tc := range []struct {
+		query      string
+		complexity int
+		err        string
+	}{
+		// Every user costs 1 for its name plus 10 (as it is expensive) and
+		// 10 times the cost of a friend for its friends.
+		{query: `{ users { name friends { name } } }`, complexity: 211, err: "query is too complex: its cost of 211 exceeds the maximum of 200"},
+		{query: `{ users { name } }`, complexity: 11},
+		{query: `{ search(first: 100) { name } }`, complexity: 101},
+		{query: `{ search(first: 500) { name } }`, complexity: 501, err: "query is too complex: its cost of 501 exceeds the maximum of 200"},
+		// Costs saturate rather than overflowing, whether in nested lists or
+		// in Complexity funcs.
+		{
+			query:      "{ users { " + strings.Repeat("friends { ", 20) + "name" + strings.Repeat(" }", 20) + " } }",
+			complexity: maxInt,
+			err:        fmt.Sprintf("query is too complex: its cost of %d exceeds the maximum of 200", maxInt),
+		},
+		{
+			query:      fmt.Sprintf(`{ search(first: %d) { name } }`, maxInt),
+			complexity: maxInt,
+			err:        fmt.Sprintf("query is too complex: its cost of %d exceeds the maximum of 200", maxInt),
+		},
+	} {
+		q := graphql.MustParse(tc.query, nil)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+		complexity, err := graphql.QueryComplexity(schema.Query, q.SelectionSet)
+		require.NoError(t, err)
+		assert.Equal(t, tc.complexity, complexity, tc.query)
+
+		e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithMaxComplexity(200))
+		_, err = e.Execute(context.Background(), schema.Query, nil, q)
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err, tc.query)
+		} else {
+			assert.NoError(t, err, tc.query)
+		}
+	}
+
+	// NDJSON executions are checked too.
+	q := graphql.MustParse(`{ users { name friends { name } } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithMaxComplexity(200)).(*graphql.Executor)
+	var buf bytes.Buffer
+	err := e.ExecuteNDJSON(context.Background(), &buf, schema.Query, nil, q)
+	assert.EqualError(t, err, "query is too complex: its cost of 211 exceeds the maximum of 200")
+	assert.Empty(t, buf.String())
+}
+
+// maxInt is the largest int, at which query costs saturate.
+const maxInt = int(^uint(0) >> 1)
+
+func TestComplexityBadFunc(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("hello", func() string {
+		return "hello"
+	}, schemabuilder.Complexity(func(args struct{}, childComplexity int) int {
+		return 1
+	}))
+	_, err := builder.Build()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Complexity should be a func(int) int")
+}
+
+func TestComplexityUnion(t *testing.T) {
+	type Cheap struct {
+		Name string
+	}
+	type Costly struct {
+		Name string
+	}
+	type Result struct {
+		schemabuilder.Union
+		*Cheap
+		*Costly
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("result", func() *Result {
+		return &Result{Cheap: &Cheap{Name: "cheap"}}
+	})
+	builder.Object("Cheap", Cheap{})
+	costly := builder.Object("Costly", Costly{})
+	costly.FieldFunc("details", func(c *Costly) string {
+		return "details"
+	}, schemabuilder.Expensive)
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ result { ... on Cheap { name } ... on Costly { name details } } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	// The union costs as much as Costly, its most costly member.
+	complexity, err := graphql.QueryComplexity(schema.Query, q.SelectionSet)
+	require.NoError(t, err)
+	assert.Equal(t, 1+1+10, complexity)
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithMaxComplexity(12))
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"result": {"name": "cheap"}}`), internal.AsJSON(res))
+}
+
+func TestComplexityPaginated(t *testing.T) {
+	type Node struct {
+		Id int64
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Object("Node", Node{}).Key("id")
+	builder.Query().FieldFunc("nodes", func() []Node {
+		return []Node{{Id: 1}, {Id: 2}}
+	}, schemabuilder.Paginated, schemabuilder.Complexity(func(args schemabuilder.ConnectionArgs, childComplexity int) int {
+		if args.First == nil {
+			return childComplexity
+		}
+		return int(*args.First) * childComplexity
+	}))
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ nodes(first: 100) { totalCount } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	complexity, err := graphql.QueryComplexity(schema.Query, q.SelectionSet)
+	require.NoError(t, err)
+	assert.Equal(t, 100, complexity)
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithMaxComplexity(50))
+	_, err = e.Execute(context.Background(), schema.Query, nil, q)
+	assert.EqualError(t, err, "query is too complex: its cost of 100 exceeds the maximum of 50")
+}
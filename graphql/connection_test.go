@@ -2,10 +2,13 @@ package graphql_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/samsarahq/thunder/batch"
 	"github.com/samsarahq/thunder/graphql"
@@ -1900,3 +1903,119 @@ func TestConnectionCursor(t *testing.T) {
 	}`)
 
 }
+
+func TestConnectionEmpty(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	item := schema.Object("item", Item{})
+	item.Key("id")
+	schema.Query().FieldFunc("items", func() []Item {
+		return nil
+	}, schemabuilder.Paginated)
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{
+		items(first: 2) {
+			totalCount
+			edges { node { id } cursor }
+			pageInfo { hasNextPage hasPrevPage startCursor endCursor pages }
+		}
+	}`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	e := testgraphql.NewExecutorWrapper(t)
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{
+		"items": {
+			"totalCount": 0,
+			"edges": [],
+			"pageInfo": {"hasNextPage": false, "hasPrevPage": false, "startCursor": "", "endCursor": "", "pages": []}
+		}
+	}`), internal.AsJSON(result))
+}
+
+func TestPaginatedFieldOptions(t *testing.T) {
+	cache := &mapStaleCache{values: make(map[string]interface{}), set: make(chan string, 10)}
+	var flakyCalls int64
+
+	schema := schemabuilder.NewSchema()
+	schema.Object("item", Item{}).Key("id")
+	query := schema.Query()
+	query.FieldFunc("slow", func(ctx context.Context) ([]Item, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, schemabuilder.Paginated, schemabuilder.Timeout(10*time.Millisecond))
+	query.FieldFunc("flaky", func() ([]Item, error) {
+		if atomic.AddInt64(&flakyCalls, 1) < 2 {
+			return nil, errors.New("unavailable")
+		}
+		return []Item{{Id: 1}}, nil
+	}, schemabuilder.Paginated, schemabuilder.Retry(graphql.RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}))
+	query.FieldFunc("cached", func() []Item {
+		return []Item{{Id: 1}}
+	}, schemabuilder.Paginated, schemabuilder.StaleWhileRevalidate(cache, func(source, args interface{}) string {
+		return "cached"
+	}, time.Second, time.Second))
+	query.FieldFunc("normalized", func() []Item {
+		return []Item{{Id: 1}}
+	}, schemabuilder.Paginated, schemabuilder.NormalizeArgs(func(args schemabuilder.ConnectionArgs) (schemabuilder.ConnectionArgs, error) {
+		if args.First != nil && *args.First > 10 {
+			return args, errors.New("first must be at most 10")
+		}
+		return args, nil
+	}))
+	query.FieldFunc("delegated", func() []Item {
+		return []Item{{Id: 1}}
+	}, schemabuilder.Paginated, schemabuilder.Delegated)
+	builtSchema := schema.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	execute := func(query string) (interface{}, error) {
+		q := graphql.MustParse(query, nil)
+		if err := graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet); err != nil {
+			return nil, err
+		}
+		return e.Execute(context.Background(), builtSchema.Query, nil, q)
+	}
+
+	_, err := execute(`{ slow { totalCount } }`)
+	assert.EqualError(t, err, "slow: field timed out after 10ms")
+
+	res, err := execute(`{ flaky { totalCount } }`)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"flaky": {"totalCount": 1}}`), internal.AsJSON(res))
+	assert.Equal(t, int64(2), flakyCalls)
+
+	_, err = execute(`{ cached { totalCount } }`)
+	require.NoError(t, err)
+	assert.Equal(t, "cached", <-cache.set)
+
+	_, err = execute(`{ normalized(first: 20) { totalCount } }`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "first must be at most 10")
+	_, err = execute(`{ normalized(first: 5) { totalCount } }`)
+	assert.NoError(t, err)
+
+	assert.True(t, builtSchema.Query.(*graphql.Object).Fields["delegated"].Delegated)
+}
+
+func TestManualPaginationWithFallbackNormalizeArgs(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Object("item", Item{}).Key("id")
+	schema.Query().ManualPaginationWithFallback("items",
+		func(args ManualArgs) ([]Item, schemabuilder.PaginationInfo, error) {
+			return nil, schemabuilder.PaginationInfo{}, nil
+		},
+		func(args Args) []Item {
+			return nil
+		},
+		func(ctx context.Context) bool {
+			return false
+		},
+		schemabuilder.Paginated,
+		schemabuilder.NormalizeArgs(func(args ManualArgs) (ManualArgs, error) {
+			return args, nil
+		}))
+	_, err := schema.Build()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "NormalizeArgs is not supported for manually paginated fields with a fallback")
+}
@@ -0,0 +1,237 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/samsarahq/thunder/batch"
+)
+
+// A DataLoader loads values by key for the resolvers of an execution.  Keys
+// requested by any resolver within WaitInterval of each other are loaded in a
+// single call, every key is only loaded once, and loaded values are cached
+// for the rest of the execution, so that e.g. the owners of items resolved in
+// separate work units are loaded together.  Keys must be comparable.
+//
+// A DataLoader is meant to be shared by the executions of an executor, for
+// example in a package-level variable; the loads and cache are kept per
+// execution.  Outside of an execution, every call loads its keys separately.
+type DataLoader struct {
+	load func(ctx context.Context, keys []interface{}) ([]interface{}, error)
+
+	// WaitInterval is how long a load waits for more keys after the last key
+	// was requested.  Defaults to batch.DefaultWaitInterval.
+	WaitInterval time.Duration
+	// MaxDuration limits how long a load waits for more keys after the first
+	// key was requested.  Defaults to batch.DefaultMaxDuration.
+	MaxDuration time.Duration
+}
+
+// NewDataLoader returns a DataLoader loading values with load, a function
+// of the form func(ctx context.Context, keys []K) ([]V, error), returning a
+// value for every key, in the same order.  K and V may be interface{}.
+func NewDataLoader(load interface{}) *DataLoader {
+	if load, ok := load.(func(context.Context, []interface{}) ([]interface{}, error)); ok {
+		return &DataLoader{load: load}
+	}
+
+	fun := reflect.ValueOf(load)
+	typ := fun.Type()
+	if typ.Kind() != reflect.Func || typ.NumIn() != 2 || typ.In(0) != contextType || typ.In(1).Kind() != reflect.Slice ||
+		typ.NumOut() != 2 || typ.Out(0).Kind() != reflect.Slice || typ.Out(1) != errorType {
+		panic(fmt.Sprintf("data loader must be a func(context.Context, []K) ([]V, error), got %s", typ))
+	}
+	keysType := typ.In(1)
+	return &DataLoader{load: func(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+		typedKeys := reflect.MakeSlice(keysType, len(keys), len(keys))
+		for i, key := range keys {
+			if key != nil {
+				typedKeys.Index(i).Set(reflect.ValueOf(key))
+			}
+		}
+		out := fun.Call([]reflect.Value{reflect.ValueOf(ctx), typedKeys})
+		if err, _ := out[1].Interface().(error); err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, out[0].Len())
+		for i := range values {
+			values[i] = out[0].Index(i).Interface()
+		}
+		return values, nil
+	}}
+}
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// Load returns the value for key.
+func (l *DataLoader) Load(ctx context.Context, key interface{}) (interface{}, error) {
+	values, err := l.LoadMany(ctx, []interface{}{key})
+	if err != nil {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+// LoadMany returns the values for keys, in the same order.
+func (l *DataLoader) LoadMany(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+	info := executionInfoFromContext(ctx)
+	if info.ctx == nil {
+		return safeLoad(ctx, l, keys)
+	}
+	loads := info.dataLoader(l).request(keys)
+	values := make([]interface{}, len(loads))
+	for i, load := range loads {
+		select {
+		case <-load.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if load.err != nil {
+			return nil, load.err
+		}
+		values[i] = load.value
+	}
+	return values, nil
+}
+
+// BatchResolver returns a resolver for a batch field (see Field.Batch) that
+// resolves every source to the value of its key.
+func (l *DataLoader) BatchResolver(key func(source interface{}) interface{}) BatchResolver {
+	return func(ctx context.Context, sources []interface{}, args interface{}, selectionSet *SelectionSet) ([]interface{}, error) {
+		keys := make([]interface{}, len(sources))
+		for i, source := range sources {
+			keys[i] = key(source)
+		}
+		return l.LoadMany(ctx, keys)
+	}
+}
+
+// dataLoaderLoad is the load of a single key by a DataLoader.
+type dataLoaderLoad struct {
+	// done is closed once value and err are set.
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// dataLoaderState holds the loads of a DataLoader for an execution.
+type dataLoaderState struct {
+	// ctx is the context of the execution, with batching, which keys are
+	// loaded with, so that a load doesn't fail because the resolver that
+	// requested it first was canceled.
+	ctx context.Context
+	// fn coalesces the keys requested within WaitInterval of each other.
+	// Its arguments are the keys requested by a call to request.
+	fn *batch.Func
+
+	mu sync.Mutex
+	// loads holds the load of every key requested so far, except for those
+	// that failed, so that they are loaded again if requested again.
+	loads map[interface{}]*dataLoaderLoad
+}
+
+// dataLoader returns the state of the DataLoader for the execution.
+func (e *executionInfo) dataLoader(l *DataLoader) *dataLoaderState {
+	e.dataLoadersMu.Lock()
+	defer e.dataLoadersMu.Unlock()
+	if e.dataLoaders == nil {
+		e.dataLoaders = make(map[*DataLoader]*dataLoaderState)
+	}
+	state, ok := e.dataLoaders[l]
+	if !ok {
+		ctx := e.ctx
+		if !batch.HasBatching(ctx) {
+			ctx = batch.WithBatching(ctx)
+		}
+		state = &dataLoaderState{
+			ctx: ctx,
+			fn: &batch.Func{
+				Many: func(ctx context.Context, args []interface{}) ([]interface{}, error) {
+					var keys []interface{}
+					for _, arg := range args {
+						keys = append(keys, arg.([]interface{})...)
+					}
+					values, err := safeLoad(ctx, l, keys)
+					if err != nil {
+						return nil, err
+					}
+					results := make([]interface{}, len(args))
+					for i, arg := range args {
+						n := len(arg.([]interface{}))
+						results[i], values = values[:n], values[n:]
+					}
+					return results, nil
+				},
+				WaitInterval: l.WaitInterval,
+				MaxDuration:  l.MaxDuration,
+			},
+			loads: make(map[interface{}]*dataLoaderLoad),
+		}
+		e.dataLoaders[l] = state
+	}
+	return state
+}
+
+// request returns the loads of keys, loading the keys not requested before.
+func (s *dataLoaderState) request(keys []interface{}) []*dataLoaderLoad {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	loads := make([]*dataLoaderLoad, len(keys))
+	var newKeys []interface{}
+	var newLoads []*dataLoaderLoad
+	for i, key := range keys {
+		load, ok := s.loads[key]
+		if !ok {
+			load = &dataLoaderLoad{done: make(chan struct{})}
+			s.loads[key] = load
+			newKeys = append(newKeys, key)
+			newLoads = append(newLoads, load)
+		}
+		loads[i] = load
+	}
+	if len(newKeys) > 0 {
+		// The load runs on its own, so that callers can stop waiting for it
+		// when their context is canceled.
+		go s.load(newKeys, newLoads)
+	}
+	return loads
+}
+
+// load loads keys along with the keys of other requests, and completes
+// their loads.
+func (s *dataLoaderState) load(keys []interface{}, loads []*dataLoaderLoad) {
+	result, err := s.fn.Invoke(s.ctx, keys)
+	if err != nil {
+		s.mu.Lock()
+		for _, key := range keys {
+			delete(s.loads, key)
+		}
+		s.mu.Unlock()
+	}
+	for i, load := range loads {
+		if err != nil {
+			load.err = err
+		} else {
+			load.value = result.([]interface{})[i]
+		}
+		close(load.done)
+	}
+}
+
+// safeLoad calls the DataLoader's load function, recovering panics and
+// checking that it returned a value for every key.
+func safeLoad(ctx context.Context, l *DataLoader, keys []interface{}) (values []interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			values, err = nil, fmt.Errorf("data loader panicked: %v", p)
+		}
+	}()
+	values, err = l.load(ctx, keys)
+	if err == nil && len(values) != len(keys) {
+		return nil, fmt.Errorf("data loader returned %d values for %d keys", len(values), len(keys))
+	}
+	return values, err
+}
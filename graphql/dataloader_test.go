@@ -0,0 +1,183 @@
+package graphql_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type loaderItem struct {
+	Id      int64
+	OwnerId int64
+}
+
+type loaderOwner struct {
+	Name string
+}
+
+// recordingLoader returns a DataLoader that records the keys of every call.
+func recordingLoader() (*graphql.DataLoader, func() [][]int64) {
+	var mu sync.Mutex
+	var calls [][]int64
+	loader := graphql.NewDataLoader(func(ctx context.Context, ownerIds []int64) ([]*loaderOwner, error) {
+		mu.Lock()
+		calls = append(calls, ownerIds)
+		mu.Unlock()
+		owners := make([]*loaderOwner, len(ownerIds))
+		for i := range owners {
+			owners[i] = &loaderOwner{Name: "owner"}
+		}
+		return owners, nil
+	})
+	return loader, func() [][]int64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+}
+
+func TestDataLoader(t *testing.T) {
+	loader, calls := recordingLoader()
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("items", func() []*loaderItem {
+		return []*loaderItem{{Id: 1, OwnerId: 7}, {Id: 2, OwnerId: 7}, {Id: 3, OwnerId: 7}, {Id: 4, OwnerId: 7}}
+	})
+	item := builder.Object("Item", loaderItem{})
+	// owner is expensive, so every item's owner is resolved in its own unit.
+	item.FieldFunc("owner", func(ctx context.Context, i *loaderItem) (*loaderOwner, error) {
+		owner, err := loader.Load(ctx, i.OwnerId)
+		if err != nil {
+			return nil, err
+		}
+		return owner.(*loaderOwner), nil
+	}, schemabuilder.Expensive)
+	builder.Object("Owner", loaderOwner{})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ items { id owner { name } } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"items": [
+		{"id": 1, "owner": {"name": "owner"}},
+		{"id": 2, "owner": {"name": "owner"}},
+		{"id": 3, "owner": {"name": "owner"}},
+		{"id": 4, "owner": {"name": "owner"}}
+	]}`), internal.AsJSON(res))
+	assert.Equal(t, [][]int64{{7}}, calls())
+
+	// Values are only cached for an execution.
+	_, err = e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, [][]int64{{7}, {7}}, calls())
+}
+
+func TestDataLoaderBatchResolver(t *testing.T) {
+	loader, calls := recordingLoader()
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("items", func() []*loaderItem {
+		return []*loaderItem{{Id: 1, OwnerId: 7}, {Id: 2, OwnerId: 7}}
+	})
+	builder.Query().FieldFunc("moreItems", func() []*loaderItem {
+		return []*loaderItem{{Id: 3, OwnerId: 7}, {Id: 4, OwnerId: 7}}
+	})
+	builder.Object("Item", loaderItem{}).FieldFunc("owner", func(i *loaderItem) *loaderOwner {
+		return nil
+	})
+	builder.Object("Owner", loaderOwner{})
+	schema := builder.MustBuild()
+
+	// Resolve owner with the loader instead.
+	owner := schema.Query.(*graphql.Object).Fields["items"].Type.(*graphql.NonNull).Type.(*graphql.List).Type.(*graphql.NonNull).Type.(*graphql.Object).Fields["owner"]
+	owner.Resolve = nil
+	owner.Batch = true
+	owner.UseBatchFunc = func(context.Context) bool { return true }
+	owner.BatchResolver = loader.BatchResolver(func(source interface{}) interface{} {
+		return source.(*loaderItem).OwnerId
+	})
+
+	// The owners of items and moreItems are resolved in separate units, but
+	// loaded together.
+	q := graphql.MustParse(`{ items { id owner { name } } moreItems { id owner { name } } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{
+		"items": [{"id": 1, "owner": {"name": "owner"}}, {"id": 2, "owner": {"name": "owner"}}],
+		"moreItems": [{"id": 3, "owner": {"name": "owner"}}, {"id": 4, "owner": {"name": "owner"}}]
+	}`), internal.AsJSON(res))
+	assert.Equal(t, [][]int64{{7}}, calls())
+}
+
+func TestDataLoaderExecutionContext(t *testing.T) {
+	var mu sync.Mutex
+	var loadErrs []error
+	loader := graphql.NewDataLoader(func(ctx context.Context, ownerIds []int64) ([]*loaderOwner, error) {
+		mu.Lock()
+		loadErrs = append(loadErrs, ctx.Err())
+		mu.Unlock()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		owners := make([]*loaderOwner, len(ownerIds))
+		for i := range owners {
+			owners[i] = &loaderOwner{Name: "owner"}
+		}
+		return owners, nil
+	})
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("items", func() []*loaderItem {
+		return []*loaderItem{{Id: 1, OwnerId: 7}, {Id: 2, OwnerId: 7}, {Id: 3, OwnerId: 7}}
+	})
+	requested := make(chan struct{})
+	item := builder.Object("Item", loaderItem{})
+	item.FieldFunc("owner", func(ctx context.Context, i *loaderItem) (*loaderOwner, error) {
+		// The first item requests its owner first, and gives up on it before
+		// it is loaded, which must not fail the load for the other items.
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		if i.Id == 1 {
+			cancel()
+		} else {
+			<-requested
+		}
+		owner, err := loader.Load(ctx, i.OwnerId)
+		if i.Id == 1 {
+			close(requested)
+		}
+		if err != nil {
+			return &loaderOwner{Name: "canceled"}, nil
+		}
+		return owner.(*loaderOwner), nil
+	}, schemabuilder.Expensive)
+	builder.Object("Owner", loaderOwner{})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ items { id owner { name } } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"items": [
+		{"id": 1, "owner": {"name": "canceled"}},
+		{"id": 2, "owner": {"name": "owner"}},
+		{"id": 3, "owner": {"name": "owner"}}
+	]}`), internal.AsJSON(res))
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []error{nil}, loadErrs)
+}
@@ -0,0 +1,38 @@
+package graphql
+
+import "context"
+
+// DataSource is where a resolver should read its data from.
+type DataSource int
+
+const (
+	// ReadReplica is used for queries, which can tolerate replication lag.
+	ReadReplica DataSource = iota
+	// Primary is used for mutations, and for fields marked as requiring it.
+	Primary
+)
+
+func (d DataSource) String() string {
+	if d == Primary {
+		return "primary"
+	}
+	return "replica"
+}
+
+type primaryKey struct{}
+
+// OperationKindFromContext returns the kind of the operation being executed,
+// i.e. "query" or "mutation", or "" outside of an execution.
+func OperationKindFromContext(ctx context.Context) string {
+	return executionInfoFromContext(ctx).operationKind
+}
+
+// DataSourceFromContext returns where a resolver should read from: the primary
+// database for mutations and fields with RequiresPrimary set, and a read
+// replica otherwise.
+func DataSourceFromContext(ctx context.Context) DataSource {
+	if OperationKindFromContext(ctx) == "mutation" || ctx.Value(primaryKey{}) != nil {
+		return Primary
+	}
+	return ReadReplica
+}
@@ -0,0 +1,42 @@
+package graphql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataSourceFromContext(t *testing.T) {
+	dataSource := func(ctx context.Context) string {
+		return graphql.OperationKindFromContext(ctx) + "/" + graphql.DataSourceFromContext(ctx).String()
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("read", dataSource)
+	builder.Query().FieldFunc("consistentRead", dataSource, schemabuilder.RequiresPrimary)
+	builder.Mutation().FieldFunc("write", dataSource)
+	schema := builder.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	ctx := context.Background()
+
+	q := graphql.MustParse(`{ read consistentRead }`, nil)
+	require.NoError(t, graphql.PrepareQuery(ctx, schema.Query, q.SelectionSet))
+	res, err := e.Execute(ctx, schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"read": "query/replica", "consistentRead": "query/primary"}`), internal.AsJSON(res))
+
+	q = graphql.MustParse(`mutation { write }`, nil)
+	require.NoError(t, graphql.PrepareQuery(ctx, schema.Mutation, q.SelectionSet))
+	res, err = e.Execute(ctx, schema.Mutation, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"write": "mutation/primary"}`), internal.AsJSON(res))
+
+	// Outside of an execution, reads default to a replica.
+	assert.Equal(t, graphql.ReadReplica, graphql.DataSourceFromContext(ctx))
+}
@@ -0,0 +1,148 @@
+package graphql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DecodeResult decodes a result returned by Execute into dest, which must be
+// a non-nil pointer.  Unlike marshaling the result to JSON and back, scalars
+// keep their Go values (e.g. a time.Time stays a time.Time).
+//
+// Objects decode into structs or maps.  A struct field is matched to a
+// response key by its `graphql` tag (as used by the schemabuilder), its
+// `json` tag, or otherwise by case-insensitively comparing its name.  Keys
+// without a matching struct field are ignored.
+func DecodeResult(result interface{}, dest interface{}) error {
+	value := reflect.ValueOf(dest)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("decode destination must be a non-nil pointer, got %T", dest)
+	}
+	return decodeValue(outputNodeToJSON(result), value.Elem(), nil)
+}
+
+// decodeValue decodes src into dest, tracking the path to report errors.
+func decodeValue(src interface{}, dest reflect.Value, path []string) error {
+	if src == nil {
+		dest.Set(reflect.Zero(dest.Type()))
+		return nil
+	}
+
+	switch dest.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(dest.Type().Elem())
+		if err := decodeValue(src, elem.Elem(), path); err != nil {
+			return err
+		}
+		dest.Set(elem)
+		return nil
+
+	case reflect.Interface:
+		srcValue := reflect.ValueOf(src)
+		if !srcValue.Type().AssignableTo(dest.Type()) {
+			return decodeError(path, "cannot assign %T to %s", src, dest.Type())
+		}
+		dest.Set(srcValue)
+		return nil
+
+	case reflect.Struct:
+		object, ok := src.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for key, value := range object {
+			field, ok := decodeStructField(dest.Type(), key)
+			if !ok {
+				continue
+			}
+			if err := decodeValue(value, dest.FieldByIndex(field.Index), append(path, key)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		object, ok := src.(map[string]interface{})
+		if !ok || dest.Type().Key().Kind() != reflect.String {
+			break
+		}
+		m := reflect.MakeMapWithSize(dest.Type(), len(object))
+		for key, value := range object {
+			elem := reflect.New(dest.Type().Elem()).Elem()
+			if err := decodeValue(value, elem, append(path, key)); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(dest.Type().Key()), elem)
+		}
+		dest.Set(m)
+		return nil
+
+	case reflect.Slice:
+		list, ok := src.([]interface{})
+		if !ok {
+			break
+		}
+		slice := reflect.MakeSlice(dest.Type(), len(list), len(list))
+		for i, value := range list {
+			if err := decodeValue(value, slice.Index(i), append(path, fmt.Sprint(i))); err != nil {
+				return err
+			}
+		}
+		dest.Set(slice)
+		return nil
+	}
+
+	// Everything else is a scalar or enum value.
+	srcValue := reflect.ValueOf(src)
+	switch {
+	case srcValue.Type().AssignableTo(dest.Type()):
+		dest.Set(srcValue)
+	case isNumberKind(srcValue.Kind()) && isNumberKind(dest.Kind()):
+		dest.Set(srcValue.Convert(dest.Type()))
+	case srcValue.Kind() == dest.Kind() && srcValue.Type().ConvertibleTo(dest.Type()):
+		dest.Set(srcValue.Convert(dest.Type()))
+	default:
+		return decodeError(path, "cannot decode %T into %s", src, dest.Type())
+	}
+	return nil
+}
+
+// decodeStructField finds the struct field a response key decodes into.
+func decodeStructField(typ reflect.Type, key string) (reflect.StructField, bool) {
+	byName, found := reflect.StructField{}, false
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		for _, tag := range []string{"graphql", "json"} {
+			name := strings.Split(field.Tag.Get(tag), ",")[0]
+			if name == key {
+				return field, true
+			}
+		}
+		if !found && strings.EqualFold(field.Name, key) {
+			byName, found = field, true
+		}
+	}
+	return byName, found
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func decodeError(path []string, format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	if len(path) == 0 {
+		return err
+	}
+	return fmt.Errorf("%s: %s", strings.Join(path, "."), err)
+}
@@ -0,0 +1,118 @@
+package graphql_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeResult(t *testing.T) {
+	type Pet struct {
+		Name string
+	}
+	type User struct {
+		Id        int64
+		Name      string
+		CreatedAt time.Time
+		Pets      []*Pet
+	}
+
+	createdAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("users", func() []*User {
+		return []*User{
+			{Id: 1, Name: "alice", CreatedAt: createdAt, Pets: []*Pet{{Name: "rex"}, {Name: "tom"}}},
+			{Id: 2, Name: "bob", CreatedAt: createdAt},
+		}
+	})
+	builder.Query().FieldFunc("viewer", func() *User { return nil })
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{
+		users { id name createdAt pets { name } }
+		me: viewer { id }
+	}`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+
+	type decodedPet struct {
+		Name string
+	}
+	var decoded struct {
+		Users []struct {
+			ID        int32 `json:"id"`
+			Name      string
+			CreatedAt time.Time
+			Pets      []decodedPet
+		}
+		Me *struct {
+			Id int64
+		} `graphql:"me"`
+	}
+	require.NoError(t, graphql.DecodeResult(res, &decoded))
+
+	require.Len(t, decoded.Users, 2)
+	assert.Equal(t, int32(1), decoded.Users[0].ID)
+	assert.Equal(t, "alice", decoded.Users[0].Name)
+	assert.Equal(t, createdAt, decoded.Users[0].CreatedAt)
+	assert.Equal(t, []decodedPet{{Name: "rex"}, {Name: "tom"}}, decoded.Users[0].Pets)
+	assert.Equal(t, "bob", decoded.Users[1].Name)
+	assert.Equal(t, []decodedPet{}, decoded.Users[1].Pets)
+	assert.Nil(t, decoded.Me)
+
+	var bad struct {
+		Users []struct {
+			Name int64
+		}
+	}
+	assert.EqualError(t, graphql.DecodeResult(res, &bad), "users.0.name: cannot decode string into int64")
+	assert.EqualError(t, graphql.DecodeResult(res, bad), "decode destination must be a non-nil pointer, got struct { Users []struct { Name int64 } }")
+}
+
+func TestMarshalResult(t *testing.T) {
+	type Pet struct {
+		Name string
+	}
+	type User struct {
+		Name    string
+		Pets    []*Pet
+		Manager *User
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("users", func() []*User {
+		return []*User{
+			{Name: "alice", Pets: []*Pet{{Name: "rex"}, {Name: "tom"}}, Manager: &User{Name: "carol"}},
+			{Name: "bob"},
+		}
+	})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{
+		users { name pets { petName: name } boss: manager { name } }
+	}`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	result, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+
+	data, err := graphql.MarshalResult(result)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"users": [
+			{"name": "alice", "pets": [{"petName": "rex"}, {"petName": "tom"}], "boss": {"name": "carol"}},
+			{"name": "bob", "pets": [], "boss": null}
+		]
+	}`, string(data))
+
+	data, err = graphql.MarshalResult(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "null", string(data))
+}
@@ -13,16 +13,19 @@ func TestDefaultArgs(t *testing.T) {
 	type Inner struct {
 		OptionalValue string
 		RequiredValue string
+		Limit         int64
 	}
 
 	query := schema.Query()
 	query.FieldFunc("inner", func(input struct {
 		OptionalInput string `graphql:",optional"`
 		RequiredInput string
+		Limit         int64 `default:"10"`
 	}) Inner {
 		return Inner{
 			OptionalValue: input.OptionalInput,
 			RequiredValue: input.RequiredInput,
+			Limit:         input.Limit,
 		}
 	})
 
@@ -60,4 +63,29 @@ func TestDefaultArgs(t *testing.T) {
 			requiredValue
 		}
 	}`)
+
+	snap.SnapshotQuery("wrong parameter type", `{
+		inner(
+			requiredInput: 3,
+		) {
+			requiredValue
+		}
+	}`, testgraphql.RecordError)
+
+	snap.SnapshotQuery("missing defaulted parameter", `{
+		inner(
+			requiredInput: "teeeeeeeest",
+		) {
+			limit
+		}
+	}`)
+
+	snap.SnapshotQuery("provided defaulted parameter", `{
+		inner(
+			requiredInput: "teeeeeeeest",
+			limit: 20,
+		) {
+			limit
+		}
+	}`)
 }
@@ -0,0 +1,288 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+const (
+	DEFER = "defer"
+	LABEL = "label"
+)
+
+var _ IncrementalExecutorRunner = &Executor{}
+
+// ExecuteIncremental executes a query like Execute, except that the fragments
+// with a @defer directive are resolved after the rest of the query.  The
+// initial result leaves out the deferred fragments, which are then sent on
+// the returned channel as they resolve, each as a payload with the path of
+// the object it belongs to.  The channel is closed once every deferred
+// fragment, including those nested in deferred fragments, has been sent.  If
+// the query defers nothing, the returned channel is nil.
+//
+// Deferred fragments are resolved as part of the query's execution, which
+// only finishes once the channel is closed: they share its limits, deadline,
+// retry budget and snapshot, and each fragment is resolved once, in batches,
+// for all of the objects it is deferred on.
+func (e *Executor) ExecuteIncremental(ctx context.Context, typ Type, source interface{}, query *Query) (interface{}, <-chan IncrementalPayload, error) {
+	queryObject, ok := typ.(*Object)
+	if !ok {
+		return nil, nil, fmt.Errorf("expected query or mutation object for execution, got: %s", typ.String())
+	}
+	if query.Kind == "subscription" {
+		return nil, nil, NewClientError("subscriptions must be executed with Subscribe")
+	}
+
+	type initialResult struct {
+		result  interface{}
+		hasNext bool
+		err     error
+	}
+	initial := make(chan initialResult, 1)
+	patches := make(chan IncrementalPayload)
+	go func() {
+		defer close(patches)
+		var result interface{}
+		sent := false
+		err := e.runExecution(ctx, queryObject, query, func(ctx context.Context) (err error) {
+			collector := &deferCollector{}
+			executionInfoFromContext(ctx).deferCollector = collector
+			result, err = e.execute(ctx, queryObject, source, query)
+			pending := collector.take()
+			if err != nil || len(pending) == 0 {
+				return err
+			}
+
+			sent = true
+			initial <- initialResult{result: result, hasNext: true}
+			for len(pending) > 0 {
+				deferred := pending[0]
+				pending = pending[1:]
+				for _, payload := range e.executeDeferred(ctx, query.Name, deferred) {
+					select {
+					case patches <- payload:
+					case <-ctx.Done():
+						return nil
+					}
+				}
+				pending = append(pending, collector.take()...)
+			}
+			return nil
+		})
+		if !sent {
+			initial <- initialResult{result: result, err: err}
+		}
+	}()
+
+	r := <-initial
+	if !r.hasNext {
+		return r.result, nil, r.err
+	}
+	return r.result, patches, nil
+}
+
+// executeDeferred resolves a deferred fragment for all of the objects it is
+// deferred on at once, returning a payload for each object.  Fragments it
+// defers in turn are added to the execution's deferCollector.
+func (e *Executor) executeDeferred(ctx context.Context, queryName string, deferred *deferredFragment) []IncrementalPayload {
+	// Every object is resolved into its own response, whose errors and
+	// nested deferred fragments have the object's path.
+	roots := make([]*outputNode, len(deferred.sources))
+	destinations := make([]*outputNode, len(deferred.sources))
+	payloads := make([]IncrementalPayload, len(deferred.sources))
+	for i, path := range deferred.paths {
+		roots[i] = newTopLevelOutputNode(queryName)
+		destinations[i] = roots[i]
+		for _, elem := range path {
+			destinations[i] = newOutputNode(destinations[i], fmt.Sprint(elem))
+		}
+		payloads[i] = IncrementalPayload{Path: path, Label: deferred.label}
+	}
+	fail := func(err error) []IncrementalPayload {
+		err = e.reportError(ctx, err)
+		for i := range payloads {
+			payloads[i].Err = err
+		}
+		return payloads
+	}
+
+	units, err := resolveObjectBatch(ctx, deferred.sources, deferred.typ, deferred.fragment.SelectionSet, destinations)
+	if err != nil {
+		return fail(err)
+	}
+	resolver := e.executionResolver(ctx, len(units))
+	var watchdog *stallWatchdog
+	if e.stallTimeout > 0 {
+		watchdog = newStallWatchdog(e.stallTimeout, len(units))
+		resolver = watchdog.wrap(resolver)
+	}
+	if !runUntilDeadline(ctx, watchdog, func() { e.scheduler.Run(resolver, units...) }) {
+		if watchdog != nil {
+			if err := watchdog.stallError(); err != nil {
+				return fail(err)
+			}
+		}
+		return fail(WrapAsSafeError(ctx.Err(), "operation timed out"))
+	}
+	if limiter := executionInfoFromContext(ctx).limiter; limiter != nil && limiter.isExceeded() {
+		return fail(ErrTooManyExecutionUnits)
+	}
+
+	for i, root := range roots {
+		err := root.errRecorder.get()
+		switch {
+		case err == nil:
+			payloads[i].Data = outputNodeToJSON(destinations[i])
+		case e.partialResults:
+			payloads[i].Data, _ = outputNodeToPartialJSON(destinations[i])
+			payloads[i].Err = e.reportError(ctx, root.errRecorder.all())
+		case e.errorPresenter != nil:
+			payloads[i].Err = e.reportError(ctx, root.errRecorder.all())
+		default:
+			payloads[i].Err = e.reportError(ctx, err)
+		}
+	}
+	return payloads
+}
+
+// deferredFragment is a fragment whose resolution was deferred by @defer,
+// along with the objects it was deferred on.
+type deferredFragment struct {
+	typ      *Object
+	fragment *Fragment
+	label    string
+
+	sources []interface{}
+	// paths are the paths in the response of the objects.
+	paths [][]interface{}
+}
+
+// deferCollector collects the deferred fragments of an execution.
+type deferCollector struct {
+	mu       sync.Mutex
+	deferred []*deferredFragment
+}
+
+// add defers the fragments of the objects written to destinations.
+func (c *deferCollector) add(typ *Object, sources []interface{}, destinations []*outputNode, fragments []*Fragment) {
+	if len(sources) == 0 {
+		return
+	}
+	paths := make([][]interface{}, len(destinations))
+	for i, destination := range destinations {
+		paths[i] = responsePath(destination)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, fragment := range fragments {
+		_, label := deferDirective(fragment)
+		c.deferred = append(c.deferred, &deferredFragment{
+			typ:      typ,
+			fragment: fragment,
+			label:    label,
+			sources:  sources,
+			paths:    paths,
+		})
+	}
+}
+
+// take returns the fragments deferred so far, in the order they were deferred.
+func (c *deferCollector) take() []*deferredFragment {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	deferred := c.deferred
+	c.deferred = nil
+	return deferred
+}
+
+// responsePath returns the path in the response of the value written to node.
+// List indices are ints, and field names strings.
+func responsePath(node *outputNode) []interface{} {
+	var reversed []string
+	// The top-level node is the response itself.
+	for cur := node.pathTracker; cur != nil && cur.parent != nil; cur = cur.parent {
+		reversed = append(reversed, cur.path)
+	}
+	path := make([]interface{}, 0, len(reversed))
+	for i := len(reversed) - 1; i >= 0; i-- {
+		if index, err := strconv.Atoi(reversed[i]); err == nil {
+			path = append(path, index)
+		} else {
+			path = append(path, reversed[i])
+		}
+	}
+	return path
+}
+
+// deferDirective returns whether a fragment is deferred by a @defer
+// directive, and the directive's label.  As with @skip and @include, an "if"
+// argument of false disables the directive.
+func deferDirective(fragment *Fragment) (bool, string) {
+	directive := findDirectiveWithName(fragment.Directives, DEFER)
+	if directive == nil {
+		return false, ""
+	}
+	args, _ := directive.Args.(map[string]interface{})
+	if enabled, ok := args[IF].(bool); ok && !enabled {
+		return false, ""
+	}
+	label, _ := args[LABEL].(string)
+	return true, label
+}
+
+// splitDeferred returns selectionSet without its deferred fragments, including
+// those in its other fragments, and the deferred fragments.  Fragments
+// excluded by @skip or @include are left for Flatten to drop.
+func splitDeferred(selectionSet *SelectionSet) (*SelectionSet, []*Fragment, error) {
+	visiting := make(map[*SelectionSet]bool)
+	var split func(*SelectionSet) (*SelectionSet, []*Fragment, error)
+	split = func(selectionSet *SelectionSet) (*SelectionSet, []*Fragment, error) {
+		if visiting[selectionSet] {
+			// Fragment spreads form a cycle, which Flatten reports.
+			return selectionSet, nil, nil
+		}
+		visiting[selectionSet] = true
+		defer delete(visiting, selectionSet)
+
+		var deferred []*Fragment
+		// kept holds the fragments that are not deferred.
+		kept := &SelectionSet{Selections: selectionSet.Selections}
+		changed := false
+		for i, fragment := range selectionSet.Fragments {
+			ok, err := ShouldIncludeNode(fragment.Directives)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !ok {
+				kept.addFragment(selectionSet, i, fragment)
+				continue
+			}
+			if isDeferred, _ := deferDirective(fragment); isDeferred {
+				deferred = append(deferred, fragment)
+				changed = true
+				continue
+			}
+
+			inner, innerDeferred, err := split(fragment.SelectionSet)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(innerDeferred) > 0 {
+				copied := *fragment
+				copied.SelectionSet = inner
+				fragment = &copied
+				deferred = append(deferred, innerDeferred...)
+				changed = true
+			}
+			kept.addFragment(selectionSet, i, fragment)
+		}
+		if !changed {
+			return selectionSet, nil, nil
+		}
+		return kept, deferred, nil
+	}
+	return split(selectionSet)
+}
@@ -0,0 +1,103 @@
+package graphql_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/samsarahq/thunder/batch"
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteIncremental(t *testing.T) {
+	type User struct {
+		Id int64
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("user", func() *User {
+		return &User{Id: 1}
+	})
+	builder.Query().FieldFunc("users", func() []*User {
+		return []*User{{Id: 2}, {Id: 3}}
+	})
+	user := builder.Object("User", User{})
+	user.FieldFunc("slow", func(u *User) int64 {
+		return u.Id * 10
+	})
+	var batchCalls int64
+	user.BatchFieldFunc("batched", func(ctx context.Context, users map[batch.Index]*User) (map[batch.Index]int64, error) {
+		atomic.AddInt64(&batchCalls, 1)
+		res := make(map[batch.Index]int64, len(users))
+		for idx, u := range users {
+			res[idx] = u.Id * 100
+		}
+		return res, nil
+	})
+	schema := builder.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler()).(*graphql.Executor)
+	execute := func(query string) (interface{}, []graphql.IncrementalPayload) {
+		q := graphql.MustParse(query, nil)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+		initial, patches, err := e.ExecuteIncremental(context.Background(), schema.Query, nil, q)
+		require.NoError(t, err)
+		var payloads []graphql.IncrementalPayload
+		if patches != nil {
+			for patch := range patches {
+				payloads = append(payloads, patch)
+			}
+		}
+		return internal.AsJSON(initial), payloads
+	}
+
+	initial, payloads := execute(`{ user { id ... on User @defer(label: "slow") { slow } } }`)
+	assert.Equal(t, internal.ParseJSON(`{"user": {"id": 1}}`), initial)
+	require.Len(t, payloads, 1)
+	assert.Equal(t, []interface{}{"user"}, payloads[0].Path)
+	assert.Equal(t, "slow", payloads[0].Label)
+	assert.NoError(t, payloads[0].Err)
+	assert.Equal(t, internal.ParseJSON(`{"slow": 10}`), internal.AsJSON(payloads[0].Data))
+
+	// Every object of a list gets its own payload, and deferred fragments
+	// may nest.
+	initial, payloads = execute(`{ users { id ... on User @defer { slow ... on User @defer { id } } } }`)
+	assert.Equal(t, internal.ParseJSON(`{"users": [{"id": 2}, {"id": 3}]}`), initial)
+	require.Len(t, payloads, 4)
+	assert.Equal(t, []interface{}{"users", 0}, payloads[0].Path)
+	assert.Equal(t, internal.ParseJSON(`{"slow": 20}`), internal.AsJSON(payloads[0].Data))
+	assert.Equal(t, []interface{}{"users", 1}, payloads[1].Path)
+	assert.Equal(t, internal.ParseJSON(`{"slow": 30}`), internal.AsJSON(payloads[1].Data))
+	assert.Equal(t, []interface{}{"users", 0}, payloads[2].Path)
+	assert.Equal(t, internal.ParseJSON(`{"id": 2}`), internal.AsJSON(payloads[2].Data))
+	assert.Equal(t, []interface{}{"users", 1}, payloads[3].Path)
+	assert.Equal(t, internal.ParseJSON(`{"id": 3}`), internal.AsJSON(payloads[3].Data))
+
+	// A deferred fragment is resolved once for all of the objects it is
+	// deferred on, so its batch fields are resolved in a single batch.
+	initial, payloads = execute(`{ users { id ... on User @defer { batched } } }`)
+	assert.Equal(t, internal.ParseJSON(`{"users": [{"id": 2}, {"id": 3}]}`), initial)
+	require.Len(t, payloads, 2)
+	assert.Equal(t, []interface{}{"users", 0}, payloads[0].Path)
+	assert.Equal(t, internal.ParseJSON(`{"batched": 200}`), internal.AsJSON(payloads[0].Data))
+	assert.Equal(t, []interface{}{"users", 1}, payloads[1].Path)
+	assert.Equal(t, internal.ParseJSON(`{"batched": 300}`), internal.AsJSON(payloads[1].Data))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&batchCalls))
+
+	// Deferring can be disabled, and nothing is streamed if nothing is
+	// deferred.
+	initial, payloads = execute(`{ user { id ... on User @defer(if: false) { slow } } }`)
+	assert.Equal(t, internal.ParseJSON(`{"user": {"id": 1, "slow": 10}}`), initial)
+	assert.Empty(t, payloads)
+
+	// Execute resolves deferred fragments right away.
+	q := graphql.MustParse(`{ user { id ... on User @defer { slow } } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	result, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"user": {"id": 1, "slow": 10}}`), internal.AsJSON(result))
+}
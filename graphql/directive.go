@@ -10,12 +10,16 @@ const (
 	IF      = "if"
 )
 
-// ShouldIncludeNode validates and checks the value of a skip or include directive
+// ShouldIncludeNode validates and checks the value of a skip or include directive.
+// As in the spec, a node with both is only included if it is not skipped and
+// is included.
 func ShouldIncludeNode(directives []*Directive) (bool, error) {
 	skipDirective := findDirectiveWithName(directives, SKIP)
 	if skipDirective != nil {
-		b, err := parseIf(skipDirective)
-		return !b, err
+		skip, err := parseIf(skipDirective)
+		if err != nil || skip {
+			return false, err
+		}
 	}
 
 	includeDirective := findDirectiveWithName(directives, INCLUDE)
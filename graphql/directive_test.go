@@ -3,6 +3,7 @@ package graphql_test
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 	"testing"
 
 	"github.com/samsarahq/thunder/graphql"
@@ -254,3 +255,54 @@ func TestDirectivesWithErrors(t *testing.T) {
 	assert.Equal(t, err.Error(), "expected type boolean, found type string in \"if\" argument")
 
 }
+
+func TestFlattenDirectives(t *testing.T) {
+	q := graphql.MustParse(`{
+		skipped @skip(if: $skip)
+		both @skip(if: $skip) @include(if: true)
+		excluded @skip(if: false) @include(if: false)
+		included @include(if: $include)
+		items { id } items @skip(if: true) { name }
+	}`, map[string]interface{}{"skip": true, "include": true})
+
+	selections, err := graphql.Flatten(q.SelectionSet)
+	assert.NoError(t, err)
+	var names []string
+	for _, selection := range selections {
+		names = append(names, selection.Name)
+		if selection.Name == "items" {
+			// The skipped selection of items isn't merged in.
+			assert.Len(t, selection.SelectionSet.Selections, 1)
+		}
+	}
+	assert.ElementsMatch(t, []string{"included", "items"}, names)
+}
+
+func TestSkippedFieldsAreNotExecuted(t *testing.T) {
+	var calls int64
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	for _, name := range []string{"a", "b", "c"} {
+		name := name
+		query.FieldFunc(name, func() string {
+			atomic.AddInt64(&calls, 1)
+			return name
+		})
+	}
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{
+		a @skip(if: $skip)
+		b @skip(if: $skip) @include(if: true)
+		c @include(if: true)
+	}`, map[string]interface{}{"skip": true})
+	assert.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+
+	scheduler := &countingScheduler{WorkScheduler: graphql.NewImmediateGoroutineScheduler()}
+	e := graphql.NewExecutor(scheduler)
+	val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"c": "c"}, val)
+	assert.Equal(t, int64(1), scheduler.units)
+	assert.Equal(t, int64(1), calls)
+}
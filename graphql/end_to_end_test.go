@@ -3,11 +3,15 @@ package graphql_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/samsarahq/thunder/batch"
 	"github.com/samsarahq/thunder/concurrencylimiter"
 	"github.com/samsarahq/thunder/graphql"
 	"github.com/samsarahq/thunder/graphql/schemabuilder"
@@ -15,6 +19,7 @@ import (
 	"github.com/samsarahq/thunder/internal/testgraphql"
 	"github.com/samsarahq/thunder/reactive"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPathError(t *testing.T) {
@@ -212,6 +217,941 @@ func TestEnum(t *testing.T) {
 
 }
 
+func TestEnumCaseInsensitiveInput(t *testing.T) {
+	type status int32
+
+	for _, caseInsensitive := range []bool{true, false} {
+		schema := schemabuilder.NewSchema()
+		enumMap := map[string]status{
+			"ACTIVE":   status(1),
+			"INACTIVE": status(2),
+		}
+		if caseInsensitive {
+			schema.Enum(status(1), enumMap, schemabuilder.CaseInsensitiveInput)
+		} else {
+			schema.Enum(status(1), enumMap)
+		}
+		schema.Query().FieldFunc("status", func(args struct{ Status status }) status {
+			return args.Status
+		})
+		builtSchema := schema.MustBuild()
+
+		q := graphql.MustParse(`{ status(status: active) }`, nil)
+		err := graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet)
+		if !caseInsensitive {
+			assert.EqualError(t, err, `error parsing args for "status": status: unknown enum value active`)
+			continue
+		}
+		require.NoError(t, err)
+
+		e := testgraphql.NewExecutorWrapper(t)
+		val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"status": "ACTIVE",
+		}, internal.AsJSON(val))
+	}
+}
+
+func TestEnumAlias(t *testing.T) {
+	type country int32
+
+	schema := schemabuilder.NewSchema()
+	schema.Enum(country(1), map[string]country{
+		"UnitedStates": country(1),
+		"Canada":       country(2),
+	}, schemabuilder.CaseInsensitiveInput, schemabuilder.EnumAlias("US", "UnitedStates"), schemabuilder.EnumAlias("usa", "UnitedStates"))
+	schema.Query().FieldFunc("country", func(args struct{ Country country }) country {
+		return args.Country
+	})
+	builtSchema := schema.MustBuild()
+	e := testgraphql.NewExecutorWrapper(t)
+
+	for input, want := range map[string]string{
+		"UnitedStates": "UnitedStates",
+		"CANADA":       "Canada",
+		"US":           "UnitedStates",
+		"usa":          "UnitedStates",
+		"Usa":          "UnitedStates",
+	} {
+		q := graphql.MustParse(fmt.Sprintf(`{ country(country: %s) }`, input), nil)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet), input)
+		val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+		require.NoError(t, err, input)
+		assert.Equal(t, map[string]interface{}{"country": want}, internal.AsJSON(val), input)
+	}
+
+	q := graphql.MustParse(`{ country(country: Mexico) }`, nil)
+	assert.EqualError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet), `error parsing args for "country": country: unknown enum value Mexico`)
+
+	assert.PanicsWithValue(t, "enum alias US stands for America, which is not a value of graphql_test.country", func() {
+		schemabuilder.NewSchema().Enum(country(1), map[string]country{"UnitedStates": country(1)}, schemabuilder.EnumAlias("US", "America"))
+	})
+}
+
+func TestSanitizeArgs(t *testing.T) {
+	trim := func(value interface{}) (interface{}, error) {
+		if s, ok := value.(string); ok {
+			return strings.TrimSpace(s), nil
+		}
+		return value, nil
+	}
+	rejectControl := func(value interface{}) (interface{}, error) {
+		if s, ok := value.(string); ok && strings.ContainsAny(s, "\x00\x1b") {
+			return nil, errors.New("contains control characters")
+		}
+		return value, nil
+	}
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("echo", func(args struct {
+		Name    string
+		Comment *string
+	}) string {
+		if args.Comment != nil {
+			return "<" + args.Name + "|" + *args.Comment + ">"
+		}
+		return "<" + args.Name + ">"
+	}, schemabuilder.SanitizeArg("name", trim), schemabuilder.SanitizeArgs(rejectControl))
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ echo(name: "  alice  ", comment: " hi ") }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	e := testgraphql.NewExecutorWrapper(t)
+	val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"echo": "<alice| hi >",
+	}, internal.AsJSON(val))
+
+	q = graphql.MustParse(`{ echo(name: "bob\u001b") }`, nil)
+	err = graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet)
+	assert.EqualError(t, err, `error parsing args for "echo": name: contains control characters`)
+}
+
+func TestArgConstraints(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("search", func(args struct {
+		Limit  int64   `min:"1" max:"100"`
+		Query  string  `maxLength:"10"`
+		Sort   *string `pattern:"^(asc|desc)$"`
+		Offset *int32  `min:"0"`
+	}) int64 {
+		return args.Limit
+	})
+	builtSchema := schema.MustBuild()
+
+	for query, wantErr := range map[string]string{
+		`{ search(limit: 10, query: "cats") }`:                         "",
+		`{ search(limit: 10, query: "cats", sort: "asc", offset: 0) }`: "",
+		`{ search(limit: 0, query: "cats") }`:                          `error parsing args for "search": limit: must be at least 1`,
+		`{ search(limit: 101, query: "cats") }`:                        `error parsing args for "search": limit: must be at most 100`,
+		`{ search(limit: 10, query: "a very long query") }`:            `error parsing args for "search": query: must be at most 10 characters long`,
+		`{ search(limit: 10, query: "cats", sort: "random") }`:         `error parsing args for "search": sort: must match pattern ^(asc|desc)$`,
+		`{ search(limit: 10, query: "cats", offset: -1) }`:             `error parsing args for "search": offset: must be at least 0`,
+	} {
+		q := graphql.MustParse(query, nil)
+		err := graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet)
+		if wantErr == "" {
+			assert.NoError(t, err, query)
+		} else {
+			assert.EqualError(t, err, wantErr, query)
+		}
+	}
+
+	// Constraints must match the type of the argument.
+	schema = schemabuilder.NewSchema()
+	schema.Query().FieldFunc("bad", func(args struct {
+		Name string `min:"1"`
+	}) string {
+		return args.Name
+	})
+	_, err := schema.Build()
+	assert.Contains(t, err.Error(), "field name: min and max constraints require a number, not string")
+}
+
+type testAddressInput struct {
+	City string
+	Zip  *string
+}
+
+type testPhoneInput struct {
+	Kind   string
+	Number string
+}
+
+type testUserInput struct {
+	Name    string
+	Address testAddressInput
+	Phones  []testPhoneInput
+}
+
+func TestInputObjects(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Mutation().FieldFunc("createUser", func(args struct{ Input testUserInput }) string {
+		summary := fmt.Sprintf("%s from %s", args.Input.Name, args.Input.Address.City)
+		if args.Input.Address.Zip != nil {
+			summary += " " + *args.Input.Address.Zip
+		}
+		for _, phone := range args.Input.Phones {
+			summary += fmt.Sprintf(", %s: %s", phone.Kind, phone.Number)
+		}
+		return summary
+	})
+	builtSchema := schema.MustBuild()
+
+	e := testgraphql.NewExecutorWrapper(t)
+	for _, c := range []struct {
+		query     string
+		variables map[string]interface{}
+		want      string
+	}{
+		{
+			query: `mutation { createUser(input: {name: "alice", address: {city: "Paris"}, phones: []}) }`,
+			want:  "alice from Paris",
+		},
+		{
+			query: `mutation { createUser(input: {name: "bob", address: {city: "Oslo", zip: "0150"}, phones: [{kind: "home", number: "1"}, {kind: "work", number: "2"}]}) }`,
+			want:  "bob from Oslo 0150, home: 1, work: 2",
+		},
+		{
+			query: `mutation ($input: testUserInput_InputObject!) { createUser(input: $input) }`,
+			variables: map[string]interface{}{"input": map[string]interface{}{
+				"name":    "carol",
+				"address": map[string]interface{}{"city": "Rome"},
+				"phones":  []interface{}{map[string]interface{}{"kind": "cell", "number": "3"}},
+			}},
+			want: "carol from Rome, cell: 3",
+		},
+	} {
+		q := graphql.MustParse(c.query, c.variables)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Mutation, q.SelectionSet), c.query)
+		res, err := e.Execute(context.Background(), builtSchema.Mutation, nil, q)
+		require.NoError(t, err, c.query)
+		assert.Equal(t, map[string]interface{}{"createUser": c.want}, res, c.query)
+	}
+
+	for query, wantErr := range map[string]string{
+		`mutation { createUser(input: {name: "alice", address: {city: "Paris", country: "FR"}, phones: []}) }`:            `error parsing args for "createUser": input: address: unknown field country`,
+		`mutation { createUser(input: {name: "alice", address: {city: "Paris"}, phones: [{kind: "home", numbr: "1"}]}) }`: `error parsing args for "createUser": input: phones: number: missing required value`,
+		`mutation { createUser(input: {name: "alice", address: {zip: "75001"}, phones: []}) }`:                            `error parsing args for "createUser": input: address: city: missing required value`,
+		`mutation { createUser(input: {name: "alice", address: {city: "Paris"}, phones: []}, dryRun: true) }`:             `error parsing args for "createUser": unknown field dryRun`,
+	} {
+		q := graphql.MustParse(query, nil)
+		err := graphql.PrepareQuery(context.Background(), builtSchema.Mutation, q.SelectionSet)
+		assert.EqualError(t, err, wantErr, query)
+	}
+}
+
+func TestArgMapsTo(t *testing.T) {
+	type UserArgs struct {
+		UserID int64 `graphql:"user_id"`
+		Limit  int64
+	}
+	type Feed struct{}
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("posts", func(args UserArgs) string {
+		return fmt.Sprintf("posts of %d, limit %d", args.UserID, args.Limit)
+	}, schemabuilder.ArgMapsTo("userId", "user_id"))
+	query.FieldFunc("feed", func() *Feed {
+		return &Feed{}
+	})
+	feed := schema.Object("Feed", Feed{})
+	feed.BatchFieldFunc("posts", func(ctx context.Context, feeds map[batch.Index]*Feed, args UserArgs) (map[batch.Index]string, error) {
+		res := make(map[batch.Index]string, len(feeds))
+		for idx := range feeds {
+			res[idx] = fmt.Sprintf("posts of %d", args.UserID)
+		}
+		return res, nil
+	}, schemabuilder.ArgMapsTo("userId", "user_id"))
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ posts(userId: 7, limit: 2) feed { posts(userId: 8, limit: 1) } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	e := testgraphql.NewExecutorWrapper(t)
+	val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"posts": "posts of 7, limit 2",
+		"feed":  map[string]interface{}{"posts": "posts of 8"},
+	}, internal.AsJSON(val))
+
+	// The argument is only exposed under its name in the schema.
+	for _, query := range []string{
+		`{ posts(user_id: 7, limit: 2) }`,
+		`{ posts(userId: 7, user_id: 8, limit: 2) }`,
+		`{ feed { posts(user_id: 8, limit: 1) } }`,
+	} {
+		q = graphql.MustParse(query, nil)
+		err = graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet)
+		assert.EqualError(t, err, `error parsing args for "posts": unknown field user_id`, query)
+	}
+
+	schema = schemabuilder.NewSchema()
+	schema.Query().FieldFunc("posts", func(args UserArgs) string {
+		return ""
+	}, schemabuilder.ArgMapsTo("userId", "userID"))
+	_, err = schema.Build()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "argument userId maps to unknown argument userID")
+}
+
+func TestJSONArgs(t *testing.T) {
+	type sortOrder int64
+	type Filter struct {
+		MinAge int64     `json:"min_age"`
+		Order  sortOrder `json:"order"`
+	}
+	type SearchArgs struct {
+		UserName string   `json:"user_name"`
+		Filter   Filter   `json:"filter"`
+		Tags     []string `json:"tags,omitempty"`
+		Internal string   `json:"-"`
+	}
+
+	var got SearchArgs
+	schema := schemabuilder.NewSchema()
+	schema.Enum(sortOrder(0), map[string]interface{}{
+		"asc":  sortOrder(1),
+		"desc": sortOrder(2),
+	})
+	schema.Query().FieldFunc("search", func(args SearchArgs) string {
+		got = args
+		return args.UserName
+	}, schemabuilder.JSONArgs)
+	schema.Query().FieldFunc("filter", func(args struct{ Filter Filter }) int64 {
+		return args.Filter.MinAge
+	})
+	builtSchema := schema.MustBuild()
+
+	// The input object of a struct decoded with JSONArgs is distinct from the
+	// one of the same struct decoded as usual, since its fields are named
+	// differently.
+	fields := builtSchema.Query.(*graphql.Object).Fields
+	assert.Equal(t, "Filter_JSONInputObject!", fields["search"].Args["filter"].String())
+	assert.Equal(t, "Filter_InputObject!", fields["filter"].Args["filter"].String())
+
+	e := testgraphql.NewExecutorWrapper(t)
+	q := graphql.MustParse(`{ search(user_name: "alice", filter: {min_age: 21, order: desc}, tags: ["a", "b"]) }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"search": "alice"}, internal.AsJSON(val))
+	assert.Equal(t, SearchArgs{
+		UserName: "alice",
+		Filter:   Filter{MinAge: 21, Order: sortOrder(2)},
+		Tags:     []string{"a", "b"},
+	}, got)
+
+	// Fields tagged omitempty are optional, but others are required.
+	q = graphql.MustParse(`{ search(user_name: "bob", filter: {min_age: 0, order: asc}) }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	_, err = e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, SearchArgs{UserName: "bob", Filter: Filter{Order: sortOrder(1)}}, got)
+
+	q = graphql.MustParse(`{ search(user_name: "bob", filter: {order: sideways}) }`, nil)
+	assert.Error(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+
+	// Scalars are parsed by the usual scalar parsers.
+	q = graphql.MustParse(`{ search(user_name: "bob", filter: {min_age: "21", order: asc}) }`, nil)
+	assert.EqualError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet), "error parsing args for \"search\": filter: min_age: not a number")
+
+	q = graphql.MustParse(`{ filter(filter: {minAge: 3, order: asc}) }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	val, err = e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"filter": float64(3)}, internal.AsJSON(val))
+}
+
+func TestRegisterScalar(t *testing.T) {
+	type DateTime struct {
+		time.Time
+	}
+	type Event struct {
+		At       DateTime
+		Canceled *DateTime
+	}
+
+	schema := schemabuilder.NewSchema()
+	schema.RegisterScalar(DateTime{}, "DateTime",
+		func(value interface{}) (interface{}, error) {
+			s, ok := value.(string)
+			if !ok {
+				return nil, errors.New("not a string")
+			}
+			t, err := time.Parse(time.RFC3339, s)
+			return DateTime{t}, err
+		},
+		func(value interface{}) (interface{}, error) {
+			return value.(DateTime).Format(time.RFC3339), nil
+		})
+	schema.Object("Event", Event{})
+	type eventArgs struct {
+		At    DateTime
+		Until *DateTime
+	}
+	schema.Query().FieldFunc("event", func(args eventArgs) *Event {
+		return &Event{At: DateTime{args.At.Add(time.Hour)}, Canceled: args.Until}
+	})
+	type jsonEventArgs struct {
+		At DateTime `json:"at"`
+	}
+	schema.Query().FieldFunc("jsonEvent", func(args jsonEventArgs) *Event {
+		return &Event{At: args.At}
+	}, schemabuilder.JSONArgs)
+	builtSchema := schema.MustBuild()
+	e := testgraphql.NewExecutorWrapper(t)
+
+	q := graphql.MustParse(`query($at: DateTime!) { event(at: $at) { at canceled } }`, map[string]interface{}{
+		"at": "2020-01-02T03:04:05Z",
+	})
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"event": {"at": "2020-01-02T04:04:05Z", "canceled": null}}`), internal.AsJSON(val))
+
+	q = graphql.MustParse(`{ event(at: "2020-01-02T03:04:05+02:00", until: "2021-06-07T08:09:10Z") { at canceled } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	val, err = e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"event": {"at": "2020-01-02T04:04:05+02:00", "canceled": "2021-06-07T08:09:10Z"}}`), internal.AsJSON(val))
+
+	q = graphql.MustParse(`{ event(at: "yesterday") { at } }`, nil)
+	assert.Error(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+
+	// Registered scalars are parsed by their parser with JSONArgs too.
+	assert.Equal(t, "DateTime!", builtSchema.Query.(*graphql.Object).Fields["jsonEvent"].Args["at"].String())
+	q = graphql.MustParse(`{ jsonEvent(at: "2020-01-02T03:04:05Z") { at } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	val, err = e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"jsonEvent": {"at": "2020-01-02T03:04:05Z"}}`), internal.AsJSON(val))
+}
+
+func TestScalarErrorPath(t *testing.T) {
+	type Zip string
+	type Address struct {
+		Zip Zip
+	}
+	type User struct {
+		Addresses []Address
+	}
+
+	schema := schemabuilder.NewSchema()
+	schema.RegisterScalar(Zip(""), "Zip",
+		func(value interface{}) (interface{}, error) {
+			return Zip(value.(string)), nil
+		},
+		func(value interface{}) (interface{}, error) {
+			if len(value.(Zip)) != 5 {
+				return nil, fmt.Errorf("bad zip %q", value)
+			}
+			return string(value.(Zip)), nil
+		})
+	schema.Query().FieldFunc("user", func() *User {
+		return &User{Addresses: []Address{{Zip: "94107"}, {Zip: "10001"}, {Zip: "123"}}}
+	})
+	schema.Object("User", User{})
+	schema.Object("Address", Address{})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ user { addresses { zip } } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	_, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.EqualError(t, err, `user.addresses.2.zip: bad zip "123"`)
+}
+
+func TestFieldMapping(t *testing.T) {
+	type Country struct {
+		Code string
+	}
+	type Address struct {
+		City    string
+		Country *Country
+	}
+	type User struct {
+		Name    string
+		Address *Address
+	}
+
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("users", func() []*User {
+		return []*User{
+			{Name: "alice", Address: &Address{City: "Paris", Country: &Country{Code: "FR"}}},
+			{Name: "bob"},
+		}
+	})
+	user := schema.Object("User", User{})
+	user.FieldMapping("city", "from source.Address.City")
+	user.FieldMapping("countryCode", "from source.Address.Country.Code")
+	user.FieldMapping("country", "from source.Address.Country")
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ users { name city countryCode country { code } } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	e := testgraphql.NewExecutorWrapper(t)
+	val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"users": [
+		{"name": "alice", "city": "Paris", "countryCode": "FR", "country": {"code": "FR"}},
+		{"name": "bob", "city": "", "countryCode": "", "country": null}
+	]}`), internal.AsJSON(val))
+
+	assert.PanicsWithValue(t, "bad mapping for field zip: graphql_test.Address has no exported field Zip", func() {
+		schemabuilder.NewSchema().Object("User", User{}).FieldMapping("zip", "from source.Address.Zip")
+	})
+}
+
+func TestNormalizeArgs(t *testing.T) {
+	type RangeArgs struct {
+		From int64
+		To   *int64
+	}
+
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("numbers", func(args RangeArgs) []int64 {
+		var numbers []int64
+		for i := args.From; i <= *args.To; i++ {
+			numbers = append(numbers, i)
+		}
+		return numbers
+	}, schemabuilder.NormalizeArgs(func(args RangeArgs) (RangeArgs, error) {
+		if args.To == nil {
+			to := args.From + 2
+			args.To = &to
+		}
+		if args.From > *args.To {
+			return args, fmt.Errorf("from must be at most to")
+		}
+		return args, nil
+	}))
+	builtSchema := schema.MustBuild()
+
+	e := testgraphql.NewExecutorWrapper(t)
+	q := graphql.MustParse(`{ explicit: numbers(from: 1, to: 3) derived: numbers(from: 5) }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"explicit": [1, 2, 3], "derived": [5, 6, 7]}`), internal.AsJSON(val))
+
+	q = graphql.MustParse(`{ numbers(from: 3, to: 1) }`, nil)
+	assert.EqualError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet),
+		`error parsing args for "numbers": from must be at most to`)
+
+	schema = schemabuilder.NewSchema()
+	schema.Query().FieldFunc("numbers", func(args RangeArgs) int64 {
+		return args.From
+	}, schemabuilder.NormalizeArgs(func(args *RangeArgs) error { return nil }))
+	_, err = schema.Build()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "NormalizeArgs should be a func(graphql_test.RangeArgs) (graphql_test.RangeArgs, error)")
+}
+
+func TestValidateResult(t *testing.T) {
+	type User struct {
+		Name   string
+		Status string
+	}
+
+	validStatus := func(value interface{}) error {
+		switch value.(string) {
+		case "active", "suspended":
+			return nil
+		default:
+			return fmt.Errorf("invalid status %q", value)
+		}
+	}
+
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("users", func() []*User {
+		return []*User{{Name: "alice", Status: "active"}, {Name: "bob", Status: "deleted"}}
+	})
+	user := schema.Object("User", User{})
+	user.FieldFunc("status", func(u *User) string {
+		return u.Status
+	}, schemabuilder.Validate(validStatus))
+	user.BatchFieldFunc("batchStatus", func(ctx context.Context, users map[batch.Index]*User) (map[batch.Index]string, error) {
+		statuses := make(map[batch.Index]string, len(users))
+		for idx, u := range users {
+			statuses[idx] = u.Status
+		}
+		return statuses, nil
+	}, schemabuilder.Validate(validStatus))
+	builtSchema := schema.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	for _, field := range []string{"status", "batchStatus"} {
+		q := graphql.MustParse(fmt.Sprintf(`{ users { name %s } }`, field), nil)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+		_, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+		assert.EqualError(t, err, fmt.Sprintf(`users.1.%s: invalid status "deleted"`, field))
+	}
+}
+
+func TestFeatureFlag(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("old", func() string { return "old" })
+	query.FieldFunc("new", func() string { return "new" }, schemabuilder.FeatureFlag("new-field"))
+	builtSchema := schema.MustBuild()
+
+	enabled := func(flag string) graphql.FeatureFlags {
+		return func(ctx context.Context, f string) bool { return f == flag }
+	}
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	ctx := graphql.WithFeatureFlags(context.Background(), enabled("new-field"))
+	q := graphql.MustParse(`{ old new }`, nil)
+	require.NoError(t, graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet))
+	val, err := e.Execute(ctx, builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"old": "old", "new": "new"}, internal.AsJSON(val))
+
+	for _, ctx := range []context.Context{
+		context.Background(),
+		graphql.WithFeatureFlags(context.Background(), enabled("other-field")),
+	} {
+		q := graphql.MustParse(`{ old new }`, nil)
+		assert.EqualError(t, graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet), `unknown field "new"`)
+
+		q = graphql.MustParse(`{ old }`, nil)
+		assert.NoError(t, graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet))
+	}
+
+	// The flag is checked again when the query is executed.
+	q = graphql.MustParse(`{ old new }`, nil)
+	require.NoError(t, graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet))
+	_, err = e.Execute(context.Background(), builtSchema.Query, nil, q)
+	assert.EqualError(t, err, `unknown field "new"`)
+}
+
+func TestFeatureFlagUnion(t *testing.T) {
+	type Cat struct{ Name string }
+	type Dog struct{ Name string }
+	type Pet struct {
+		schemabuilder.Union
+		*Cat
+		*Dog
+	}
+
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("pet", func() *Pet {
+		return &Pet{Cat: &Cat{Name: "tom"}}
+	})
+	for _, obj := range []*schemabuilder.Object{schema.Object("Cat", Cat{}), schema.Object("Dog", Dog{})} {
+		obj.FieldFunc("secret", func() string { return "secret" }, schemabuilder.FeatureFlag("secret"))
+	}
+	builtSchema := schema.MustBuild()
+
+	// Fields selected directly on a union are gated like those of objects.
+	q := graphql.MustParse(`{ pet { name secret } }`, nil)
+	assert.EqualError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet), `unknown field "secret"`)
+
+	ctx := graphql.WithFeatureFlags(context.Background(), func(ctx context.Context, flag string) bool { return flag == "secret" })
+	q = graphql.MustParse(`{ pet { name secret } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	val, err := e.Execute(ctx, builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"pet": {"name": "tom", "secret": "secret"}}`), internal.AsJSON(val))
+}
+
+func TestErrorLocations(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("users", func() []*User {
+		return []*User{{Name: "alice"}}
+	})
+	user := schema.Object("User", User{})
+	user.FieldFunc("email", func(u *User) (string, error) {
+		return "", errors.New("no email")
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`
+{
+  users {
+    name
+    email
+  }
+}`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	_, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.EqualError(t, err, "users.0.email: no email")
+
+	want := []graphql.Location{{Line: 5, Column: 5}}
+	assert.Equal(t, want, graphql.ErrorLocations(err))
+	assert.Equal(t, want, (&graphql.ErrorRegistry{}).Format(err).Locations)
+}
+
+func TestNullResult(t *testing.T) {
+	type Sensor struct {
+		Reading int64
+		Online  bool
+	}
+
+	var calls, fallbacks int
+	reading := func(s *Sensor) (graphql.Nullable[int64], error) {
+		calls++
+		if !s.Online {
+			return graphql.Nullable[int64]{}, nil
+		}
+		return graphql.NewNullable(s.Reading), nil
+	}
+
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("sensors", func() []*Sensor {
+		return []*Sensor{{Reading: 0, Online: true}, {Reading: 5, Online: false}}
+	})
+	sensor := schema.Object("Sensor", Sensor{})
+	// A null is a result rather than an error, so it is neither retried nor
+	// replaced by the fallback.
+	sensor.FieldFunc("reading", reading, schemabuilder.Retry(graphql.RetryPolicy{MaxAttempts: 3}), schemabuilder.Fallback(func(ctx context.Context, sources []interface{}, args interface{}, selectionSet *graphql.SelectionSet) ([]interface{}, error) {
+		fallbacks++
+		return make([]interface{}, len(sources)), nil
+	}))
+	schemabuilder.FieldFunc(sensor, "typedReading", func(ctx context.Context, s *Sensor, args struct{}) (graphql.Nullable[int64], error) {
+		return reading(s)
+	})
+	builtSchema := schema.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+
+	q := graphql.MustParse(`{ sensors { reading typedReading } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"sensors": [
+		{"reading": 0, "typedReading": 0},
+		{"reading": null, "typedReading": null}
+	]}`), internal.AsJSON(val))
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, 0, fallbacks)
+
+	// The field has the type of the value, but is nullable, and can't be
+	// marked non-nullable.
+	nullableReading := func() graphql.Nullable[int64] { return graphql.Nullable[int64]{} }
+	schema = schemabuilder.NewSchema()
+	schema.Query().FieldFunc("reading", nullableReading)
+	builtSchema = schema.MustBuild()
+	assert.Equal(t, "int64", builtSchema.Query.(*graphql.Object).Fields["reading"].Type.String())
+
+	schema = schemabuilder.NewSchema()
+	schema.Query().FieldFunc("reading", nullableReading, schemabuilder.NonNullable)
+	_, err = schema.Build()
+	assert.EqualError(t, err, "bad method reading on type schemabuilder.query: func() graphql.Nullable[int64] returns a graphql.Nullable, but is marked non-nullable")
+}
+
+func TestTransformResult(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	trim := func(value interface{}) (interface{}, error) {
+		trimmed := strings.TrimSpace(value.(string))
+		if trimmed == "" {
+			return nil, errors.New("empty name")
+		}
+		return trimmed, nil
+	}
+	var uppercased []string
+	upper := func(value interface{}) (interface{}, error) {
+		uppercased = append(uppercased, value.(string))
+		return strings.ToUpper(value.(string)), nil
+	}
+
+	schema := schemabuilder.NewSchema()
+	var names []string
+	schema.Query().FieldFunc("users", func() []*User {
+		users := make([]*User, 0, len(names))
+		for _, name := range names {
+			users = append(users, &User{Name: name})
+		}
+		return users
+	})
+	user := schema.Object("User", User{})
+	user.FieldFunc("displayName", func(u *User) string {
+		return u.Name
+	}, schemabuilder.Transform(trim, upper))
+	builtSchema := schema.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	q := graphql.MustParse(`{ users { displayName } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+
+	names = []string{"  alice ", "bob"}
+	val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"users": [{"displayName": "ALICE"}, {"displayName": "BOB"}]}`), internal.AsJSON(val))
+	assert.Equal(t, []string{"alice", "bob"}, uppercased)
+
+	// A failing transformer fails the field without running the next ones.
+	names, uppercased = []string{"   "}, nil
+	_, err = e.Execute(context.Background(), builtSchema.Query, nil, q)
+	assert.EqualError(t, err, "users.0.displayName: empty name")
+	assert.Empty(t, uppercased)
+}
+
+func TestMaxListLength(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	ids := func() []int64 {
+		return []int64{1, 2, 3, 4, 5}
+	}
+	query.FieldFunc("truncated", ids, schemabuilder.MaxListLength(3, graphql.TruncateList))
+	query.FieldFunc("failed", ids, schemabuilder.MaxListLength(3, graphql.FailList))
+	query.FieldFunc("short", ids, schemabuilder.MaxListLength(5, graphql.FailList))
+	builtSchema := schema.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	run := func(query string) (interface{}, error) {
+		q := graphql.MustParse(query, nil)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+		val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+		return internal.AsJSON(val), err
+	}
+
+	val, err := run(`{ truncated short }`)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"truncated": [1, 2, 3], "short": [1, 2, 3, 4, 5]}`), val)
+
+	_, err = run(`{ failed }`)
+	assert.EqualError(t, err, "failed: list has 5 items, more than the maximum of 3")
+
+	assert.Panics(t, func() { schemabuilder.MaxListLength(-1, graphql.TruncateList) })
+}
+
+func TestMaxLength(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	text := func() string {
+		return "日本語のテキスト"
+	}
+	query.FieldFunc("truncated", text, schemabuilder.MaxLength(7, graphql.TruncateString))
+	query.FieldFunc("ellipsized", text, schemabuilder.MaxLength(10, graphql.EllipsizeString))
+	query.FieldFunc("failed", text, schemabuilder.MaxLength(7, graphql.FailString))
+	query.FieldFunc("short", text, schemabuilder.MaxLength(24, graphql.FailString))
+	query.FieldFunc("pointer", func() *string {
+		s := text()
+		return &s
+	}, schemabuilder.MaxLength(4, graphql.TruncateString))
+	builtSchema := schema.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	run := func(query string) (interface{}, error) {
+		q := graphql.MustParse(query, nil)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+		val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+		return internal.AsJSON(val), err
+	}
+
+	// Every rune takes 3 bytes, so strings are cut at the last rune that fits.
+	val, err := run(`{ truncated ellipsized short pointer }`)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{
+		"truncated": "日本",
+		"ellipsized": "日本…",
+		"short": "日本語のテキスト",
+		"pointer": "日"
+	}`), val)
+
+	_, err = run(`{ failed }`)
+	assert.EqualError(t, err, "failed: string has 24 bytes, more than the maximum of 7")
+
+	assert.Panics(t, func() { schemabuilder.MaxLength(-1, graphql.TruncateString) })
+}
+
+func TestObjectFieldMiddleware(t *testing.T) {
+	type User struct {
+		Id   int64
+		Name string
+	}
+	type Post struct {
+		Title string
+	}
+
+	var mu sync.Mutex
+	var wrapped []string
+	logField := func(field string, next graphql.BatchResolver) graphql.BatchResolver {
+		return func(ctx context.Context, sources []interface{}, args interface{}, selectionSet *graphql.SelectionSet) ([]interface{}, error) {
+			mu.Lock()
+			wrapped = append(wrapped, field)
+			mu.Unlock()
+			return next(ctx, sources, args, selectionSet)
+		}
+	}
+	hideSecrets := func(field string, next graphql.BatchResolver) graphql.BatchResolver {
+		if field != "secret" && field != "cachedSecret" {
+			return next
+		}
+		return func(ctx context.Context, sources []interface{}, args interface{}, selectionSet *graphql.SelectionSet) ([]interface{}, error) {
+			return nil, errors.New("unauthorized")
+		}
+	}
+
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("users", func() []*User {
+		return []*User{{Id: 1, Name: "alice"}, {Id: 2, Name: "bob"}}
+	})
+	schema.Query().FieldFunc("posts", func() []*Post {
+		return []*Post{{Title: "hello"}}
+	})
+	user := schema.Object("User", User{})
+	user.Use(logField, hideSecrets)
+	user.FieldFunc("secret", func(u *User) string {
+		return "secret"
+	})
+	user.FieldFunc("cachedSecret", func(u *User) string {
+		return "secret"
+	}, schemabuilder.Fallback(func(ctx context.Context, sources []interface{}, args interface{}, selectionSet *graphql.SelectionSet) ([]interface{}, error) {
+		return []interface{}{"cached secret"}, nil
+	}))
+	user.BatchFieldFunc("upperName", func(ctx context.Context, users map[batch.Index]*User) (map[batch.Index]string, error) {
+		names := make(map[batch.Index]string, len(users))
+		for idx, u := range users {
+			names[idx] = strings.ToUpper(u.Name)
+		}
+		return names, nil
+	})
+	schema.Object("Post", Post{})
+	builtSchema := schema.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	q := graphql.MustParse(`{ users { id name upperName } posts { title } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	res, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{
+		"users": [{"id": 1, "name": "alice", "upperName": "ALICE"}, {"id": 2, "name": "bob", "upperName": "BOB"}],
+		"posts": [{"title": "hello"}]
+	}`), internal.AsJSON(res))
+
+	// Every field of User is wrapped, but the fields of Post and Query are not.
+	sort.Strings(wrapped)
+	assert.Equal(t, []string{"id", "id", "name", "name", "upperName"}, wrapped)
+
+	q = graphql.MustParse(`{ users { secret } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	_, err = e.Execute(context.Background(), builtSchema.Query, nil, q)
+	assert.EqualError(t, err, "users.0.secret: unauthorized")
+
+	// Fallbacks are wrapped too, so they can't bypass the middlewares.
+	q = graphql.MustParse(`{ users { cachedSecret } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	_, err = e.Execute(context.Background(), builtSchema.Query, nil, q)
+	assert.EqualError(t, err, "users.0.cachedSecret: unauthorized")
+}
+
 // TestEndToEndAwaitAndCache tests that slow fields get run in parallel and cached.
 //
 // The test verifies that the `slow` field on user, which sleeps for 100ms, gets
@@ -1,9 +1,13 @@
 package graphql
 
 import (
+	"context"
 	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/gorilla/websocket"
+	"github.com/samsarahq/thunder/batch"
 )
 
 type SanitizedError interface {
@@ -65,6 +69,242 @@ func SanitizeError(err error) string {
 	return "Internal server error"
 }
 
+// FormattedError is the representation of an error that is sent to clients.
+type FormattedError struct {
+	Message    string                 `json:"message"`
+	Locations  []Location             `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// ErrorFormatter converts an error into the FormattedError sent to clients.
+type ErrorFormatter func(err error) FormattedError
+
+// CodeErrorFormatter returns an ErrorFormatter that sends the sanitized error
+// message along with the given code in the "code" extension.
+func CodeErrorFormatter(code string) ErrorFormatter {
+	return func(err error) FormattedError {
+		return FormattedError{
+			Message:    SanitizeError(err),
+			Extensions: map[string]interface{}{"code": code},
+		}
+	}
+}
+
+type registeredErrorFormatter struct {
+	typ       reflect.Type
+	formatter ErrorFormatter
+}
+
+// ErrorRegistry classifies errors by their type and formats each category
+// consistently, e.g. to attach a "code" that clients can switch on.
+//
+// For example, to report authorization and validation errors with their own
+// codes:
+//
+//	registry := &graphql.ErrorRegistry{}
+//	registry.Register(&AuthError{}, graphql.CodeErrorFormatter("UNAUTHORIZED"))
+//	registry.Register(graphql.ClientError{}, graphql.CodeErrorFormatter("BAD_REQUEST"))
+type ErrorRegistry struct {
+	formatters []registeredErrorFormatter
+
+	// Default formats errors that don't match any registered type.  If nil,
+	// the sanitized error message is sent without extensions.
+	Default ErrorFormatter
+}
+
+// Register adds a formatter for all errors with the same type as errType.
+func (r *ErrorRegistry) Register(errType error, formatter ErrorFormatter) {
+	r.formatters = append(r.formatters, registeredErrorFormatter{
+		typ:       reflect.TypeOf(errType),
+		formatter: formatter,
+	})
+}
+
+// Format formats err with the formatter of the first error in its tree that
+// has a registered type, visiting the errors it wraps (with Unwrap() error or
+// Unwrap() []error, e.g. errors.Join, which needs Go 1.20) depth-first, like
+// errors.As.  The matching error is passed to the formatter.  Unless the
+// formatter sets them, the locations of the error in the query (see
+// ErrorLocations) are included.
+func (r *ErrorRegistry) Format(err error) FormattedError {
+	formatted := r.format(err)
+	if formatted.Locations == nil {
+		formatted.Locations = ErrorLocations(err)
+	}
+	return formatted
+}
+
+// Present formats err like Format, so that the registry can present the
+// errors of an executor (see WithErrorPresenter).
+func (r *ErrorRegistry) Present(ctx context.Context, err error) FormattedError {
+	return r.Format(err)
+}
+
+func (r *ErrorRegistry) format(err error) FormattedError {
+	if formatted, ok := r.formatRegistered(err); ok {
+		return formatted
+	}
+	if r.Default != nil {
+		return r.Default(err)
+	}
+	return FormattedError{Message: SanitizeError(err)}
+}
+
+// formatRegistered formats the first error in err's tree that has a
+// registered type, if any.
+func (r *ErrorRegistry) formatRegistered(err error) (FormattedError, bool) {
+	if err == nil {
+		return FormattedError{}, false
+	}
+	typ := reflect.TypeOf(err)
+	for _, registered := range r.formatters {
+		if registered.typ == typ {
+			return registered.formatter(err), true
+		}
+	}
+
+	switch err := err.(type) {
+	case interface{ Unwrap() error }:
+		return r.formatRegistered(err.Unwrap())
+	case interface{ Unwrap() []error }:
+		for _, err := range err.Unwrap() {
+			if formatted, ok := r.formatRegistered(err); ok {
+				return formatted, true
+			}
+		}
+	}
+	return FormattedError{}, false
+}
+
+// Errors are the errors of the fields of an execution that returns partial
+// results (see WithPartialResults), in the order they failed in.  To inspect
+// each error, e.g. with errors.As, range over the Errors.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// ErrorPresenter converts the error an execution failed with into the
+// FormattedError sent to clients.  See WithErrorPresenter.  An
+// ErrorRegistry's Present method is an ErrorPresenter.
+type ErrorPresenter func(ctx context.Context, err error) FormattedError
+
+// PresentedError is the error returned by an executor with an ErrorPresenter.
+// It holds the presentation of the error the execution failed with, which it
+// wraps.
+type PresentedError struct {
+	FormattedError
+	err error
+}
+
+func (e *PresentedError) Error() string {
+	return e.err.Error()
+}
+
+// SanitizedError returns the presented message.
+func (e *PresentedError) SanitizedError() string {
+	return e.Message
+}
+
+// Unwrap returns the error the execution failed with.
+func (e *PresentedError) Unwrap() error {
+	return e.err
+}
+
+// presentError presents err, or each of Errors, with presenter.  Unless the
+// presenter sets them, the locations and path of the error in the query are
+// included.
+func presentError(ctx context.Context, presenter ErrorPresenter, err error) error {
+	switch err := err.(type) {
+	case *PresentedError:
+		return err
+	case Errors:
+		presented := make(Errors, len(err))
+		for i, err := range err {
+			presented[i] = presentError(ctx, presenter, err)
+		}
+		return presented
+	}
+	formatted := presenter(ctx, err)
+	if formatted.Locations == nil {
+		formatted.Locations = ErrorLocations(err)
+	}
+	if formatted.Path == nil {
+		formatted.Path = ErrorPath(err)
+	}
+	return &PresentedError{FormattedError: formatted, err: err}
+}
+
+// BatchSourceError is an error returned by a batch resolver that only affects
+// one of its sources.  A batch resolver can fail some of its sources by
+// returning several BatchSourceErrors joined into one error (e.g. with
+// errors.Join, which needs Go 1.20, or any error with an Unwrap() []error
+// method); each source is then failed with its own error.  Any other error
+// fails every source.
+type BatchSourceError interface {
+	error
+	BatchIndex() batch.Index
+}
+
+type batchSourceError struct {
+	index batch.Index
+	err   error
+}
+
+// NewBatchSourceError returns a BatchSourceError failing the source at index
+// with err.
+func NewBatchSourceError(index batch.Index, err error) error {
+	return &batchSourceError{index: index, err: err}
+}
+
+func (e *batchSourceError) Error() string           { return e.err.Error() }
+func (e *batchSourceError) Unwrap() error           { return e.err }
+func (e *batchSourceError) BatchIndex() batch.Index { return e.index }
+
+// splitBatchSourceErrors returns the error of each source of a batch, indexed
+// like the sources, if err consists only of BatchSourceErrors.
+func splitBatchSourceErrors(err error, numSources int) (map[int]error, bool) {
+	positions := make(map[batch.Index]int, numSources)
+	for i := 0; i < numSources; i++ {
+		positions[batch.NewIndex(i)] = i
+	}
+
+	errs := make(map[int]error)
+	var split func(err error) bool
+	split = func(err error) bool {
+		if sourceErr, ok := err.(BatchSourceError); ok {
+			i, ok := positions[sourceErr.BatchIndex()]
+			if !ok {
+				return false
+			}
+			if _, ok := errs[i]; !ok {
+				errs[i] = sourceErr
+			}
+			return true
+		}
+		joined, ok := err.(interface{ Unwrap() []error })
+		if !ok {
+			return false
+		}
+		for _, err := range joined.Unwrap() {
+			if !split(err) {
+				return false
+			}
+		}
+		return true
+	}
+	if !split(err) || len(errs) == 0 {
+		return nil, false
+	}
+	return errs, true
+}
+
 func isCloseError(err error) bool {
 	_, ok := err.(*websocket.CloseError)
 	return ok || err == websocket.ErrCloseSent
@@ -0,0 +1,95 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ExecutionStats describe the work units a single execution ran, e.g. for
+// capacity planning.
+type ExecutionStats struct {
+	// Units is the number of work units the scheduler ran.
+	Units int
+	// BatchUnits, ExpensiveUnits and PlainUnits count the units that
+	// resolved a batch field (or a group of them), an expensive field, and
+	// any other field, respectively.
+	BatchUnits     int
+	ExpensiveUnits int
+	PlainUnits     int
+	// PeakPendingUnits is the largest number of units that were queued or
+	// running at once.
+	PeakPendingUnits int
+	// Duration is the wall time the execution took, from planning the
+	// top-level selections until every unit finished.
+	Duration time.Duration
+}
+
+// WithExecutionStats calls report with the ExecutionStats of every execution
+// once its work units have run.  Executions rejected before running any units,
+// e.g. for exceeding a limit, are not reported.
+func WithExecutionStats(report func(ctx context.Context, stats ExecutionStats)) ExecutorOption {
+	return func(e *Executor) {
+		e.reportExecutionStats = report
+	}
+}
+
+// executionStatsRecorder counts the work units of an execution.
+type executionStatsRecorder struct {
+	mu      sync.Mutex
+	started time.Time
+	pending int
+	stats   ExecutionStats
+}
+
+// schedule records the units an execution starts the scheduler with, e.g. for
+// its top-level selections.
+func (r *executionStatsRecorder) schedule(units int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started.IsZero() {
+		r.started = time.Now()
+	}
+	r.pending += units
+	if r.pending > r.stats.PeakPendingUnits {
+		r.stats.PeakPendingUnits = r.pending
+	}
+}
+
+// wrap returns a UnitResolver that counts resolver's units.
+func (r *executionStatsRecorder) wrap(resolver UnitResolver) UnitResolver {
+	return func(unit *WorkUnit) []*WorkUnit {
+		units := resolver(unit)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.stats.Units++
+		switch {
+		case len(unit.grouped) > 0 || (unit.field.Batch && unit.useBatch):
+			r.stats.BatchUnits++
+		case unit.field.Expensive:
+			r.stats.ExpensiveUnits++
+		default:
+			r.stats.PlainUnits++
+		}
+		// The units are pending before the unit that scheduled them finishes.
+		if r.pending+len(units) > r.stats.PeakPendingUnits {
+			r.stats.PeakPendingUnits = r.pending + len(units)
+		}
+		r.pending += len(units) - 1
+		return units
+	}
+}
+
+// finish returns the stats of the execution, or false if it never scheduled
+// any units.
+func (r *executionStatsRecorder) finish() (ExecutionStats, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started.IsZero() {
+		return ExecutionStats{}, false
+	}
+	stats := r.stats
+	stats.Duration = time.Since(r.started)
+	return stats, true
+}
@@ -0,0 +1,73 @@
+package graphql_test
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/samsarahq/thunder/batch"
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutionStats(t *testing.T) {
+	type Item struct {
+		Id int64
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("items", func() []*Item {
+		return []*Item{{Id: 1}, {Id: 2}, {Id: 3}}
+	})
+	item := builder.Object("Item", Item{})
+	item.FieldFunc("expensive", func(i *Item) int64 {
+		return i.Id
+	}, schemabuilder.Expensive)
+	item.BatchFieldFunc("batched", func(ctx context.Context, items map[batch.Index]*Item) map[batch.Index]int64 {
+		values := make(map[batch.Index]int64, len(items))
+		for idx, i := range items {
+			values[idx] = i.Id
+		}
+		return values
+	})
+	schema := builder.MustBuild()
+
+	var mu sync.Mutex
+	var reported []graphql.ExecutionStats
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithExecutionStats(func(ctx context.Context, stats graphql.ExecutionStats) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, stats)
+	}))
+
+	q := graphql.MustParse(`{ items { id expensive batched } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	_, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+
+	require.Len(t, reported, 1)
+	stats := reported[0]
+	// items is resolved in a plain unit, which schedules a unit for every
+	// item's expensive field and a single unit for batched.
+	assert.Equal(t, 5, stats.Units)
+	assert.Equal(t, 1, stats.PlainUnits)
+	assert.Equal(t, 3, stats.ExpensiveUnits)
+	assert.Equal(t, 1, stats.BatchUnits)
+	assert.Equal(t, 5, stats.PeakPendingUnits)
+	assert.True(t, stats.Duration > 0)
+
+	// NDJSON executions are reported too, with the units of every chunk of
+	// elements.
+	reported = nil
+	var buf bytes.Buffer
+	require.NoError(t, e.(*graphql.Executor).ExecuteNDJSON(context.Background(), &buf, schema.Query, nil, q))
+	require.Len(t, reported, 1)
+	// The items fit in a chunk, which schedules a unit for every item's
+	// expensive field and a single unit for batched.
+	assert.Equal(t, 4, reported[0].Units)
+	assert.Equal(t, 3, reported[0].ExpensiveUnits)
+	assert.Equal(t, 1, reported[0].BatchUnits)
+}
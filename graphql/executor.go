@@ -3,14 +3,20 @@ package graphql
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"runtime"
+	"sort"
+	"strconv"
 )
 
 type pathError struct {
 	inner error
 	path  []string
+
+	// locations are the positions in the query of the field that failed.
+	locations []Location
 }
 
 func nestPathErrorMulti(path []string, err error) error {
@@ -21,8 +27,9 @@ func nestPathErrorMulti(path []string, err error) error {
 
 	if pe, ok := err.(*pathError); ok {
 		return &pathError{
-			inner: pe.inner,
-			path:  append(pe.path, path...),
+			inner:     pe.inner,
+			path:      append(pe.path, path...),
+			locations: pe.locations,
 		}
 	}
 
@@ -40,8 +47,9 @@ func nestPathError(key string, err error) error {
 
 	if pe, ok := err.(*pathError); ok {
 		return &pathError{
-			inner: pe.inner,
-			path:  append(pe.path, key),
+			inner:     pe.inner,
+			path:      append(pe.path, key),
+			locations: pe.locations,
 		}
 	}
 
@@ -58,6 +66,35 @@ func ErrorCause(err error) error {
 	return err
 }
 
+// ErrorLocations returns the positions in the query of the field that caused
+// err, if known.
+func ErrorLocations(err error) []Location {
+	var pe *pathError
+	if errors.As(err, &pe) {
+		return pe.locations
+	}
+	return nil
+}
+
+// ErrorPath returns the path in the response of the field that caused err, if
+// known.  List indices are ints, and field names strings.
+func ErrorPath(err error) []interface{} {
+	var pe *pathError
+	if !errors.As(err, &pe) || len(pe.path) == 0 {
+		return nil
+	}
+	path := make([]interface{}, 0, len(pe.path))
+	for i := len(pe.path) - 1; i >= 0; i-- {
+		// Field names and aliases can't start with a digit.
+		if index, err := strconv.Atoi(pe.path[i]); err == nil {
+			path = append(path, index)
+		} else {
+			path = append(path, pe.path[i])
+		}
+	}
+	return path
+}
+
 func (pe *pathError) Unwrap() error {
 	return pe.inner
 }
@@ -141,12 +178,79 @@ func PrepareQuery(ctx context.Context, typ Type, selectionSet *SelectionSet) err
 				if selection.SelectionSet != nil {
 					return NewClientError(`scalar field "__typename" must have no selection`)
 				}
-				for _, fragment := range selectionSet.Fragments {
-					fragment.SelectionSet.Selections = append(fragment.SelectionSet.Selections, selection)
+				continue
+			}
+
+			// Fields shared by every member of the union may be selected
+			// directly on the union.
+			field, err := commonUnionField(ctx, typ, selection.Name)
+			if err != nil {
+				return err
+			}
+			if !selection.parsed {
+				selection.parsed = true
+				if !isNilArgs(selection.UnparsedArgs) {
+					return NewClientError(`error parsing args for "%s": no args expected on union field`, selection.Name)
+				}
+				selection.Args, err = parseArguments(field, nil)
+				if err != nil {
+					return NewClientError(`error parsing args for "%s": %s`, selection.Name, err)
+				}
+			}
+			selection.ParentType = typ.Name
+
+			if err := PrepareQuery(ctx, field.Type, selection.SelectionSet); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *Interface:
+		if selectionSet == nil {
+			return NewClientError("object field must have selections")
+		}
+		for _, selection := range selectionSet.Selections {
+			if selection.Name == "__typename" {
+				if !isNilArgs(selection.UnparsedArgs) {
+					return NewClientError(`error parsing args for "__typename": no args expected`)
+				}
+				if selection.SelectionSet != nil {
+					return NewClientError(`scalar field "__typename" must have no selection`)
 				}
 				continue
 			}
-			return NewClientError(`unknown field "%s"`, selection.Name)
+
+			field, ok := typ.Fields[selection.Name]
+			if !ok || !FieldEnabled(ctx, field) {
+				return NewClientError(`unknown field "%s"`, selection.Name)
+			}
+			if !selection.parsed {
+				selection.parsed = true
+				parsed, err := parseArguments(field, selection.UnparsedArgs)
+				if err != nil {
+					return NewClientError(`error parsing args for "%s": %s`, selection.Name, err)
+				}
+				selection.Args = parsed
+			}
+			selection.ParentType = typ.Name
+
+			if err := PrepareQuery(ctx, field.Type, selection.SelectionSet); err != nil {
+				return err
+			}
+		}
+		for _, fragment := range selectionSet.Fragments {
+			// Fragments either select more of the interface's fields, or
+			// narrow it to one of its members.
+			var fragmentTyp Type = typ
+			if fragment.On != "" && fragment.On != typ.Name {
+				member, ok := typ.Types[fragment.On]
+				if !ok {
+					return NewClientError(`fragment on "%s" can never apply to interface %s`, fragment.On, typ.Name)
+				}
+				fragmentTyp = member
+			}
+			if err := PrepareQuery(ctx, fragmentTyp, fragment.SelectionSet); err != nil {
+				return err
+			}
 		}
 		return nil
 	case *Object:
@@ -164,15 +268,16 @@ func PrepareQuery(ctx context.Context, typ Type, selectionSet *SelectionSet) err
 				continue
 			}
 
+			// Fields gated by a disabled feature flag are treated as unknown.
 			field, ok := typ.Fields[selection.Name]
-			if !ok {
+			if !ok || !FieldEnabled(ctx, field) {
 				return NewClientError(`unknown field "%s"`, selection.Name)
 			}
 
 			// Only parse args once for a given selection.
 			if !selection.parsed {
 				selection.parsed = true
-				parsed, err := field.ParseArguments(selection.UnparsedArgs)
+				parsed, err := parseArguments(field, selection.UnparsedArgs)
 				if err != nil {
 					return NewClientError(`error parsing args for "%s": %s`, selection.Name, err)
 				}
@@ -203,25 +308,161 @@ func PrepareQuery(ctx context.Context, typ Type, selectionSet *SelectionSet) err
 	}
 }
 
+// commonUnionField returns the field with the given name if every member of
+// the union has it enabled, with the same type and no arguments.
+func commonUnionField(ctx context.Context, typ *Union, name string) (*Field, error) {
+	var common *Field
+	for _, member := range typ.Types {
+		field, ok := member.Fields[name]
+		if !ok || !FieldEnabled(ctx, field) {
+			return nil, NewClientError(`unknown field "%s"`, name)
+		}
+		if len(field.Args) != 0 {
+			return nil, NewClientError(`field "%s" takes arguments and must be selected in a fragment on union %s`, name, typ.Name)
+		}
+		if common != nil && common.Type.String() != field.Type.String() {
+			return nil, NewClientError(`field "%s" has different types on members of union %s`, name, typ.Name)
+		}
+		common = field
+	}
+	if common == nil {
+		return nil, NewClientError(`unknown field "%s"`, name)
+	}
+	return common, nil
+}
+
+// panicError converts a recovered panic into an error including the stack
+// trace of the panicking goroutine.
+func panicError(panicErr interface{}) error {
+	const size = 64 << 10
+	buf := make([]byte, size)
+	buf = buf[:runtime.Stack(buf, false)]
+	return &resolverPanic{value: panicErr, stack: buf}
+}
+
+// resolverPanic is the error of a resolver that panicked.
+type resolverPanic struct {
+	value interface{}
+	stack []byte
+}
+
+func (p *resolverPanic) Error() string {
+	return fmt.Sprintf("graphql: panic: %v\n%s", p.value, p.stack)
+}
+
 func SafeExecuteBatchResolver(ctx context.Context, field *Field, sources []interface{}, args interface{}, selectionSet *SelectionSet) (results []interface{}, err error) {
+	return safeExecuteBatch(ctx, field.BatchResolver, sources, args, selectionSet)
+}
+
+func safeExecuteBatch(ctx context.Context, resolver BatchResolver, sources []interface{}, args interface{}, selectionSet *SelectionSet) (results []interface{}, err error) {
 	defer func() {
 		if panicErr := recover(); panicErr != nil {
-			const size = 64 << 10
-			buf := make([]byte, size)
-			buf = buf[:runtime.Stack(buf, false)]
-			results, err = nil, fmt.Errorf("graphql: panic: %v\n%s", panicErr, buf)
+			results, err = nil, panicError(panicErr)
 		}
 	}()
-	return field.BatchResolver(ctx, sources, args, selectionSet)
+	return resolver(ctx, sources, args, selectionSet)
+}
+
+// executeFallback runs the field's Fallback resolver for sources whose
+// resolver failed with err.  The fallback's results are used if it succeeds,
+// otherwise the original error is returned.
+func executeFallback(ctx context.Context, field *Field, sources []interface{}, args interface{}, selectionSet *SelectionSet, err error) ([]interface{}, error) {
+	if field.Fallback == nil {
+		return nil, err
+	}
+	results, fallbackErr := safeExecuteBatch(ctx, field.Fallback, sources, args, selectionSet)
+	if fallbackErr != nil || len(results) != len(sources) {
+		return nil, err
+	}
+	return results, nil
+}
+
+// executeBatchFallback runs the field's Fallback resolver for the sources of a
+// batch resolver that failed with err.  If err is made only of
+// BatchSourceErrors and results were returned for every source, only the
+// failed sources fall back, and the results of the others are kept.
+func executeBatchFallback(ctx context.Context, field *Field, sources []interface{}, args interface{}, selectionSet *SelectionSet, results []interface{}, err error) ([]interface{}, error) {
+	sourceErrs, ok := splitBatchSourceErrors(err, len(sources))
+	if !ok || len(results) != len(sources) {
+		return executeFallback(ctx, field, sources, args, selectionSet, err)
+	}
+
+	failed := make([]int, 0, len(sourceErrs))
+	for idx := range sourceErrs {
+		failed = append(failed, idx)
+	}
+	sort.Ints(failed)
+	failedSources := make([]interface{}, len(failed))
+	for i, idx := range failed {
+		failedSources[i] = sources[idx]
+	}
+	fallbackResults, err := executeFallback(ctx, field, failedSources, args, selectionSet, err)
+	if err != nil {
+		return nil, err
+	}
+	merged := make([]interface{}, len(results))
+	copy(merged, results)
+	for i, idx := range failed {
+		merged[idx] = fallbackResults[i]
+	}
+	return merged, nil
+}
+
+// parseArguments parses the raw arguments of a selection of field, and then
+// normalizes them with the field's NormalizeArgs, if any.
+func parseArguments(field *Field, args interface{}) (interface{}, error) {
+	parsed, err := field.ParseArguments(args)
+	if err != nil || field.NormalizeArgs == nil {
+		return parsed, err
+	}
+	return field.NormalizeArgs(parsed)
+}
+
+// executeResolverWithFallback calls SafeExecuteResolver, retrying it per the
+// field's Retry policy, and falling back to the field's Fallback resolver on
+// error.  Every call of an expensive field's resolvers holds a slot of the
+// executor's limit on expensive resolvers.
+func executeResolverWithFallback(ctx context.Context, field *Field, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+	var result interface{}
+	err := resolveWithRetries(ctx, field, func() error {
+		return withExpensiveSlot(ctx, field, func() (err error) {
+			result, err = SafeExecuteResolver(ctx, field, source, args, selectionSet)
+			return err
+		})
+	})
+	if err != nil {
+		resolverErr := err
+		var results []interface{}
+		err = withExpensiveSlot(ctx, field, func() (err error) {
+			results, err = executeFallback(ctx, field, []interface{}{source}, args, selectionSet, resolverErr)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return results[0], nil
+	}
+	return result, nil
+}
+
+// withExpensiveSlot calls resolve, holding a slot of the executor's limit on
+// expensive resolvers (see WithMaxExpensiveConcurrency) if field is expensive.
+func withExpensiveSlot(ctx context.Context, field *Field, resolve func() error) error {
+	if !field.Expensive {
+		return resolve()
+	}
+	release, err := executionInfoFromContext(ctx).acquireExpensive(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return resolve()
 }
 
 func SafeExecuteResolver(ctx context.Context, field *Field, source, args interface{}, selectionSet *SelectionSet) (result interface{}, err error) {
 	defer func() {
 		if panicErr := recover(); panicErr != nil {
-			const size = 64 << 10
-			buf := make([]byte, size)
-			buf = buf[:runtime.Stack(buf, false)]
-			result, err = nil, fmt.Errorf("graphql: panic: %v\n%s", panicErr, buf)
+			result, err = nil, panicError(panicErr)
 		}
 	}()
 	return field.Resolve(ctx, source, args, selectionSet)
@@ -231,6 +472,22 @@ type ExecutorRunner interface {
 	Execute(ctx context.Context, typ Type, source interface{}, query *Query) (interface{}, error)
 }
 
+// QueryLimiter is implemented by executors that limit the shape of the
+// queries they run, such as Executor.  The HTTP handler and the websocket
+// server check queries with CheckQueryLimits before PrepareQuery.
+type QueryLimiter interface {
+	CheckQueryLimits(query *Query) error
+}
+
+// checkQueryLimits checks query with executor's CheckQueryLimits, if it is a
+// QueryLimiter.
+func checkQueryLimits(executor ExecutorRunner, query *Query) error {
+	if limiter, ok := executor.(QueryLimiter); ok {
+		return limiter.CheckQueryLimits(query)
+	}
+	return nil
+}
+
 type resolveAndExecuteCacheKey struct {
 	field     *Field
 	source    interface{}
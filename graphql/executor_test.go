@@ -268,6 +268,56 @@ func TestFlatten(t *testing.T) {
 	)
 }
 
+func TestFlattenSourceOrder(t *testing.T) {
+	query := graphql.MustParse(`
+		{ a ...F ... on Query { c ...G } e a }
+		fragment F on Query { b }
+		fragment G on Query { d }`, nil)
+	selections, err := graphql.Flatten(query.SelectionSet)
+	require.NoError(t, err)
+
+	var aliases []string
+	for _, selection := range selections {
+		aliases = append(aliases, selection.Alias)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, aliases)
+}
+
+func TestFlattenFragmentCycles(t *testing.T) {
+	// Parse rejects cycles, so build them by hand.
+	self := &graphql.Fragment{Name: "Self", On: "Query", SelectionSet: &graphql.SelectionSet{
+		Selections: []*graphql.Selection{{Name: "a", Alias: "a", UnparsedArgs: map[string]interface{}{}}},
+	}}
+	self.SelectionSet.Fragments = []*graphql.Fragment{self}
+
+	_, err := graphql.Flatten(&graphql.SelectionSet{Fragments: []*graphql.Fragment{self}})
+	assert.EqualError(t, err, "fragment spreads must not form cycles: Self -> Self")
+
+	a := &graphql.Fragment{Name: "A", On: "Query", SelectionSet: &graphql.SelectionSet{}}
+	b := &graphql.Fragment{Name: "B", On: "Query", SelectionSet: &graphql.SelectionSet{
+		Fragments: []*graphql.Fragment{a},
+	}}
+	a.SelectionSet.Fragments = []*graphql.Fragment{b}
+	query := &graphql.Query{Kind: "query", SelectionSet: &graphql.SelectionSet{
+		Selections: []*graphql.Selection{{Name: "a", Alias: "a", UnparsedArgs: map[string]interface{}{}}},
+		Fragments:  []*graphql.Fragment{a},
+	}}
+
+	_, err = graphql.Flatten(query.SelectionSet)
+	assert.EqualError(t, err, "fragment spreads must not form cycles: A -> B -> A")
+
+	// Execute rejects the query before running any resolvers.
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("a", func() int64 {
+		t.Error("a should not be resolved")
+		return 0
+	})
+	schema := builder.MustBuild()
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	_, err = e.Execute(context.Background(), schema.Query, nil, query)
+	assert.EqualError(t, err, "fragment spreads must not form cycles: A -> B -> A")
+}
+
 /*
 func TestMissingField(t *testing.T) {
 	q := MustParse(`
@@ -1,5 +1,7 @@
 package graphql
 
+import "sync/atomic"
+
 func PathErrorInit(inner error, path []string) error {
 	return &pathError{
 		inner: inner,
@@ -8,3 +10,18 @@ func PathErrorInit(inner error, path []string) error {
 }
 
 type PathError = pathError
+
+// IsParsed returns whether the query was kept parsed.
+func (q *PersistedQuery) IsParsed() bool {
+	return q.document != nil
+}
+
+// Parses returns the number of queries the cache parsed.
+func (c *QueryCache) Parses() int {
+	return int(atomic.LoadInt64(&c.parses))
+}
+
+// Prepares returns the number of queries the cache prepared.
+func (c *QueryCache) Prepares() int {
+	return int(atomic.LoadInt64(&c.prepares))
+}
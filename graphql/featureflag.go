@@ -0,0 +1,25 @@
+package graphql
+
+import "context"
+
+// FeatureFlags reports whether a feature flag is enabled for a request.
+type FeatureFlags func(ctx context.Context, flag string) bool
+
+type featureFlagsKey struct{}
+
+// WithFeatureFlags sets the feature flags that decide whether fields gated by
+// a flag (see Field.FeatureFlag) can be queried with the returned context.
+func WithFeatureFlags(ctx context.Context, flags FeatureFlags) context.Context {
+	return context.WithValue(ctx, featureFlagsKey{}, flags)
+}
+
+// FieldEnabled returns whether a field can be queried with ctx: either it
+// isn't gated by a feature flag, or the flag is enabled.  Introspection hides
+// the fields that aren't.
+func FieldEnabled(ctx context.Context, field *Field) bool {
+	if field.FeatureFlag == "" {
+		return true
+	}
+	flags, _ := ctx.Value(featureFlagsKey{}).(FeatureFlags)
+	return flags != nil && flags(ctx, field.FeatureFlag)
+}
@@ -0,0 +1,70 @@
+package graphql
+
+import "sort"
+
+// FieldStats describes how the executor resolves a field of an object.
+type FieldStats struct {
+	// Object and Field name the field, e.g. "User" and "friends".
+	Object string
+	Field  string
+
+	// Batch is set if the field is resolved for many sources at once.
+	Batch bool
+	// Expensive is set if the field is resolved in a work unit per source.
+	Expensive bool
+	// External is set if the field is resolved by a function (e.g. a
+	// FieldFunc) rather than read from a struct field.
+	External bool
+}
+
+// FieldStats reports how every field of every object reachable from the
+// schema's query and mutation types is resolved, sorted by object and field,
+// e.g. to audit which fields are batched or expensive.
+func (s *Schema) FieldStats() []FieldStats {
+	var stats []FieldStats
+	seen := make(map[Type]bool)
+
+	var visit func(typ Type)
+	visit = func(typ Type) {
+		if typ == nil || seen[typ] {
+			return
+		}
+		seen[typ] = true
+
+		switch typ := typ.(type) {
+		case *NonNull:
+			visit(typ.Type)
+		case *List:
+			visit(typ.Type)
+		case *Union:
+			for _, member := range typ.Types {
+				visit(member)
+			}
+		case *Interface:
+			for _, member := range typ.Types {
+				visit(member)
+			}
+		case *Object:
+			for name, field := range typ.Fields {
+				stats = append(stats, FieldStats{
+					Object:    typ.Name,
+					Field:     name,
+					Batch:     field.Batch,
+					Expensive: field.Expensive,
+					External:  field.External,
+				})
+				visit(field.Type)
+			}
+		}
+	}
+	visit(s.Query)
+	visit(s.Mutation)
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Object != stats[j].Object {
+			return stats[i].Object < stats[j].Object
+		}
+		return stats[i].Field < stats[j].Field
+	})
+	return stats
+}
@@ -0,0 +1,41 @@
+package graphql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samsarahq/thunder/batch"
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemaFieldStats(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("users", func() []*User {
+		return nil
+	})
+	builder.Mutation().FieldFunc("ping", func() string {
+		return "pong"
+	})
+	user := builder.Object("User", User{})
+	user.FieldFunc("profile", func(u *User) string {
+		return ""
+	}, schemabuilder.Expensive)
+	user.BatchFieldFunc("score", func(ctx context.Context, users map[batch.Index]*User) (map[batch.Index]int64, error) {
+		return nil, nil
+	})
+	schema := builder.MustBuild()
+
+	assert.Equal(t, []graphql.FieldStats{
+		{Object: "Mutation", Field: "ping", External: true},
+		{Object: "Query", Field: "users", External: true},
+		{Object: "User", Field: "name"},
+		{Object: "User", Field: "profile", Expensive: true, External: true},
+		{Object: "User", Field: "score", Batch: true, External: true},
+	}, schema.FieldStats())
+}
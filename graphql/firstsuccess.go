@@ -0,0 +1,100 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/samsarahq/thunder/batch"
+)
+
+// FirstSuccess composes batch resolvers reading from redundant backends (e.g.
+// a primary, secondary and tertiary store) into one.  Each source resolves to
+// the value of the first resolver that succeeds for it: every resolver is only
+// called with the sources that all resolvers before it failed for.  A resolver
+// fails some of its sources by returning results for all of them along with
+// BatchSourceErrors for the failed ones; any other error fails every source.
+//
+// Sources that every resolver fails for are failed with the error of the last
+// resolver, as BatchSourceErrors returned along with the results of the other
+// sources.
+func FirstSuccess(resolvers ...BatchResolver) BatchResolver {
+	return func(ctx context.Context, sources []interface{}, args interface{}, selectionSet *SelectionSet) ([]interface{}, error) {
+		results := make([]interface{}, len(sources))
+		errs := make(map[int]error, len(sources))
+
+		// pending holds the positions of the sources no resolver has
+		// succeeded for yet.
+		pending := make([]int, len(sources))
+		for i := range pending {
+			pending[i] = i
+		}
+
+		for _, resolver := range resolvers {
+			if len(pending) == 0 {
+				break
+			}
+
+			subset := make([]interface{}, len(pending))
+			for i, pos := range pending {
+				subset[i] = sources[pos]
+			}
+			subResults, err := safeExecuteBatch(ctx, resolver, subset, args, selectionSet)
+			if err == nil && len(subResults) != len(subset) {
+				err = fmt.Errorf("batch resolver returned %d results for %d sources", len(subResults), len(subset))
+			}
+			// A resolver can only fail some of its sources if it returns
+			// results for the others.
+			sourceErrs, perSource := splitBatchSourceErrors(err, len(subset))
+			perSource = perSource && len(subResults) == len(subset)
+
+			var failed []int
+			for i, pos := range pending {
+				switch {
+				case err == nil:
+					results[pos] = subResults[i]
+				case perSource && sourceErrs[i] == nil:
+					results[pos] = subResults[i]
+				case perSource:
+					errs[pos] = unwrapBatchSourceError(sourceErrs[i])
+					failed = append(failed, pos)
+				default:
+					errs[pos] = err
+					failed = append(failed, pos)
+				}
+			}
+			pending = failed
+		}
+
+		if len(pending) == 0 {
+			return results, nil
+		}
+		failures := make(batchSourceErrors, 0, len(pending))
+		for _, pos := range pending {
+			failures = append(failures, NewBatchSourceError(batch.NewIndex(pos), errs[pos]))
+		}
+		return results, failures
+	}
+}
+
+// unwrapBatchSourceError returns the error a BatchSourceError fails its source
+// with, so it can be re-indexed.
+func unwrapBatchSourceError(err error) error {
+	if sourceErr, ok := err.(*batchSourceError); ok {
+		return sourceErr.err
+	}
+	return err
+}
+
+// batchSourceErrors joins the BatchSourceErrors of several sources.
+type batchSourceErrors []error
+
+func (e batchSourceErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e batchSourceErrors) Unwrap() []error { return e }
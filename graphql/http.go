@@ -23,29 +23,126 @@ func HTTPHandlerWithExecutor(schema *Schema, executor ExecutorRunner, middleware
 	}
 }
 
+// HTTPHandlerWithOptions creates an http.Handler for the schema, configured
+// by the passed in options.
+func HTTPHandlerWithOptions(schema *Schema, opts ...HTTPHandlerOption) http.Handler {
+	h := &httpHandler{
+		schema:   schema,
+		executor: NewExecutor(NewImmediateGoroutineScheduler()),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// HTTPHandlerOption configures the handler created by HTTPHandlerWithOptions.
+type HTTPHandlerOption func(*httpHandler)
+
+// WithHTTPExecutor runs the queries with executor, instead of an executor
+// with an immediate goroutine scheduler.
+func WithHTTPExecutor(executor ExecutorRunner) HTTPHandlerOption {
+	return func(h *httpHandler) {
+		h.executor = executor
+	}
+}
+
+// WithHTTPMiddlewares runs the queries through the middlewares, like the
+// middlewares passed to HTTPHandler.
+func WithHTTPMiddlewares(middlewares ...MiddlewareFunc) HTTPHandlerOption {
+	return func(h *httpHandler) {
+		h.middlewares = append(h.middlewares, middlewares...)
+	}
+}
+
+// WithApolloTracing includes the timing of every request's resolvers in its
+// response, as extensions.tracing in the Apollo Tracing format.  See
+// ApolloTracing.
+func WithApolloTracing() HTTPHandlerOption {
+	return func(h *httpHandler) {
+		h.apolloTracing = true
+	}
+}
+
+// WithErrorRegistry formats response errors with the registry.  Errors are
+// sent as objects with a message and extensions instead of plain strings.
+// Errors presented by the executor (see WithErrorPresenter) are sent as
+// presented instead; without a presenter or registry, errors are sent as
+// their message.
+func WithErrorRegistry(registry *ErrorRegistry) HTTPHandlerOption {
+	return func(h *httpHandler) {
+		h.errorRegistry = registry
+	}
+}
+
 type httpHandler struct {
-	schema      *Schema
-	middlewares []MiddlewareFunc
-	executor    ExecutorRunner
+	schema        *Schema
+	reloadable    *ReloadableSchema
+	middlewares   []MiddlewareFunc
+	executor      ExecutorRunner
+	errorRegistry *ErrorRegistry
+
+	rateLimiter        RateLimiter
+	rateLimitClientKey func(r *http.Request) string
+
+	apolloTracing bool
+
+	persistedQueries PersistedQueryStore
+	queryCache       *QueryCache
 }
 
 type httpPostBody struct {
-	Query     string                 `json:"query"`
-	Variables map[string]interface{} `json:"variables"`
+	Query      string                 `json:"query"`
+	Variables  map[string]interface{} `json:"variables"`
+	Extensions struct {
+		PersistedQuery *persistedQueryExtension `json:"persistedQuery"`
+	} `json:"extensions"`
 }
 
 type httpResponse struct {
-	Data   interface{} `json:"data"`
-	Errors []string    `json:"errors"`
+	Data       interface{}            `json:"data"`
+	Errors     []interface{}          `json:"errors"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// formatError formats an error for a response, using its presentation (see
+// WithErrorPresenter) if it has one, or else the handler's error registry if
+// one is configured, or else its message.
+func (h *httpHandler) formatError(ctx context.Context, err error) interface{} {
+	var formatted FormattedError
+	var presented *PresentedError
+	switch {
+	case errors.As(err, &presented):
+		formatted = presented.FormattedError
+	case h.errorRegistry != nil:
+		formatted = h.errorRegistry.Format(err)
+	default:
+		return err.Error()
+	}
+	if e, ok := h.executor.(*Executor); ok {
+		if requestID, ok := e.requestID(ctx); ok {
+			extensions := map[string]interface{}{"requestId": requestID}
+			for k, v := range formatted.Extensions {
+				extensions[k] = v
+			}
+			formatted.Extensions = extensions
+		}
+	}
+	return formatted
 }
 
 func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var extensions map[string]interface{}
 	writeResponse := func(value interface{}, err error) {
-		response := httpResponse{}
-		if err != nil {
-			response.Errors = []string{err.Error()}
-		} else {
-			response.Data = value
+		// A value may accompany an error if the result is partial, e.g. when
+		// the operation timed out.
+		response := httpResponse{Data: value, Extensions: extensions}
+		if errs, ok := err.(Errors); ok {
+			for _, err := range errs {
+				response.Errors = append(response.Errors, h.formatError(r.Context(), err))
+			}
+		} else if err != nil {
+			response.Errors = []interface{}{h.formatError(r.Context(), err)}
 		}
 
 		responseJSON, err := json.Marshal(response)
@@ -75,17 +172,51 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query, err := Parse(params.Query, params.Variables)
+	persisted, err := h.loadQuery(r.Context(), &params)
 	if err != nil {
 		writeResponse(nil, err)
 		return
 	}
+	params.Query = persisted.Query
+
+	if !h.allowRequest(r, params.Query) {
+		writeResponse(nil, ErrRateLimited)
+		return
+	}
 
-	schema := h.schema.Query
-	if query.Kind == "mutation" {
-		schema = h.schema.Mutation
+	// Capture the schema once, so the whole request runs against it even if
+	// it is reloaded meanwhile.
+	current := h.schema
+	if h.reloadable != nil {
+		current = h.reloadable.Load()
 	}
-	if err := PrepareQuery(r.Context(), schema, query.SelectionSet); err != nil {
+	var schema Type
+	schemaFor := func(query *Query) (Type, error) {
+		if query.Kind == "subscription" {
+			return nil, NewClientError("subscriptions are not supported over HTTP")
+		}
+		if err := checkQueryLimits(h.executor, query); err != nil {
+			return nil, err
+		}
+		schema = current.Query
+		if query.Kind == "mutation" {
+			schema = current.Mutation
+		}
+		return schema, nil
+	}
+
+	var query *Query
+	if persisted.document == nil && h.queryCache != nil {
+		query, err = h.queryCache.Prepare(r.Context(), persisted.Query, params.Variables, schemaFor)
+	} else {
+		query, err = persisted.Parse(params.Variables)
+		if err == nil {
+			if _, err = schemaFor(query); err == nil {
+				err = PrepareQuery(r.Context(), schema, query.SelectionSet)
+			}
+		}
+	}
+	if err != nil {
 		writeResponse(nil, err)
 		return
 	}
@@ -93,17 +224,32 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var wg sync.WaitGroup
 	e := h.executor
 
+	// Incremental delivery is only used if the client asks for it and the
+	// executor supports it.
+	incrementalExecutor, incremental := e.(IncrementalExecutorRunner)
+	incremental = incremental && acceptsMultipartMixed(r.Header.Get("Accept"))
+	var patches <-chan IncrementalPayload
+
 	wg.Add(1)
 	runner := reactive.NewRerunner(r.Context(), func(ctx context.Context) (interface{}, error) {
 		defer wg.Done()
 
 		ctx = batch.WithBatching(ctx)
+		if h.apolloTracing {
+			tracing := NewApolloTracing()
+			ctx = WithQueryApolloTracing(ctx, tracing)
+			extensions = map[string]interface{}{"tracing": tracing}
+		}
 
 		var middlewares []MiddlewareFunc
 		middlewares = append(middlewares, h.middlewares...)
 		middlewares = append(middlewares, func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
 			output := next(input)
-			output.Current, output.Error = e.Execute(input.Ctx, schema, nil, input.ParsedQuery)
+			if incremental {
+				output.Current, patches, output.Error = incrementalExecutor.ExecuteIncremental(input.Ctx, schema, nil, input.ParsedQuery)
+			} else {
+				output.Current, output.Error = e.Execute(input.Ctx, schema, nil, input.ParsedQuery)
+			}
 			return output
 		})
 
@@ -120,10 +266,15 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				return nil, err
 			}
 
-			writeResponse(nil, err)
+			writeResponse(current, err)
 			return nil, err
 		}
 
+		if patches != nil {
+			h.writeMultipartResponse(ctx, w, current, patches)
+			return nil, nil
+		}
+
 		writeResponse(current, nil)
 		return nil, nil
 	}, DefaultMinRerunInterval, false)
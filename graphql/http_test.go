@@ -1,9 +1,19 @@
 package graphql_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/kylelemons/godebug/pretty"
@@ -80,6 +90,23 @@ func TestHTTPMustHaveQuery(t *testing.T) {
 	}
 }
 
+func TestHTTPRejectsSubscriptions(t *testing.T) {
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "subscription { mirror(value: 1) }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := testHTTPRequest(req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200, but received %d", rr.Code)
+	}
+
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[\"subscriptions are not supported over HTTP\"]}"); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+}
+
 func TestHTTPSuccess(t *testing.T) {
 	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "query TestQuery($value: int64) { mirror(value: $value) }", "variables": { "value": 1 }}`))
 	if err != nil {
@@ -113,3 +140,494 @@ func TestHTTPContentType(t *testing.T) {
 		t.Errorf("expected response to match, but received %s", diff)
 	}
 }
+
+type authError struct{}
+
+func (authError) Error() string          { return "not allowed" }
+func (authError) SanitizedError() string { return "not allowed" }
+
+type downstreamError struct {
+	service string
+}
+
+func (e *downstreamError) Error() string { return e.service + " is unavailable" }
+
+func TestHTTPErrorRegistry(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("fail", func(args struct{ Kind string }) (int64, error) {
+		switch args.Kind {
+		case "auth":
+			return 0, authError{}
+		case "downstream":
+			return 0, fmt.Errorf("loading: %w", &downstreamError{service: "users"})
+		case "joined":
+			return 0, joinedErrors{errors.New("cache miss"), fmt.Errorf("loading: %w", &downstreamError{service: "users"})}
+		default:
+			return 0, errors.New("secret internal details")
+		}
+	})
+	builtSchema := schema.MustBuild()
+
+	registry := &graphql.ErrorRegistry{
+		Default: graphql.CodeErrorFormatter("INTERNAL"),
+	}
+	registry.Register(authError{}, graphql.CodeErrorFormatter("UNAUTHORIZED"))
+	registry.Register(&downstreamError{}, func(err error) graphql.FormattedError {
+		return graphql.FormattedError{
+			Message:    "downstream service failed",
+			Extensions: map[string]interface{}{"code": "DOWNSTREAM", "service": err.(*downstreamError).service},
+		}
+	})
+	handler := graphql.HTTPHandlerWithOptions(builtSchema, graphql.WithErrorRegistry(registry))
+
+	for _, tt := range []struct {
+		kind string
+		want string
+	}{
+		{kind: "auth", want: `{"data":null,"errors":[{"message":"not allowed","extensions":{"code":"UNAUTHORIZED"}}]}`},
+		{kind: "downstream", want: `{"data":null,"errors":[{"message":"downstream service failed","locations":[{"line":1,"column":3}],"extensions":{"code":"DOWNSTREAM","service":"users"}}]}`},
+		{kind: "joined", want: `{"data":null,"errors":[{"message":"downstream service failed","locations":[{"line":1,"column":3}],"extensions":{"code":"DOWNSTREAM","service":"users"}}]}`},
+		{kind: "internal", want: `{"data":null,"errors":[{"message":"Internal server error","locations":[{"line":1,"column":3}],"extensions":{"code":"INTERNAL"}}]}`},
+	} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(fmt.Sprintf(`{"query": "{ fail(kind: \"%s\") }"}`, tt.kind)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if diff := pretty.Compare(rr.Body.String(), tt.want); diff != "" {
+			t.Errorf("expected response for %s to match, but received %s", tt.kind, diff)
+		}
+	}
+}
+
+func TestHTTPErrorPresenter(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("fail", func() (int64, error) {
+		return 0, errors.New("secret internal details")
+	})
+	builtSchema := schema.MustBuild()
+
+	executor := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithErrorPresenter(func(ctx context.Context, err error) graphql.FormattedError {
+		return graphql.FormattedError{Message: "internal server error", Extensions: map[string]interface{}{"code": "INTERNAL"}}
+	}))
+	handler := graphql.HTTPHandlerWithOptions(builtSchema, graphql.WithHTTPExecutor(executor))
+
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ fail }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	want := `{"data":null,"errors":[{"message":"internal server error","locations":[{"line":1,"column":3}],"path":["fail"],"extensions":{"code":"INTERNAL"}}]}`
+	if diff := pretty.Compare(rr.Body.String(), want); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+}
+
+func TestHTTPErrorRegistryPresenter(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("fail", func() (int64, error) {
+		return 0, authError{}
+	})
+	builtSchema := schema.MustBuild()
+
+	registry := &graphql.ErrorRegistry{}
+	registry.Register(authError{}, graphql.CodeErrorFormatter("UNAUTHORIZED"))
+	// The executor's presentation is sent as is, rather than formatted by the
+	// handler's registry.
+	presenter := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithErrorPresenter(func(ctx context.Context, err error) graphql.FormattedError {
+		return graphql.FormattedError{Message: "presented"}
+	}))
+	// A registry can also present the executor's errors.
+	registryPresenter := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithErrorPresenter(registry.Present))
+
+	for _, tt := range []struct {
+		name    string
+		handler http.Handler
+		want    string
+	}{
+		{
+			name:    "presenter",
+			handler: graphql.HTTPHandlerWithOptions(builtSchema, graphql.WithHTTPExecutor(presenter), graphql.WithErrorRegistry(registry)),
+			want:    `{"data":null,"errors":[{"message":"presented"}]}`,
+		},
+		{
+			name:    "registry presenter",
+			handler: graphql.HTTPHandlerWithOptions(builtSchema, graphql.WithHTTPExecutor(registryPresenter)),
+			want:    `{"data":null,"errors":[{"message":"not allowed","extensions":{"code":"UNAUTHORIZED"}}]}`,
+		},
+	} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ fail }"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		tt.handler.ServeHTTP(rr, req)
+
+		if diff := pretty.Compare(rr.Body.String(), tt.want); diff != "" {
+			t.Errorf("expected response for %s to match, but received %s", tt.name, diff)
+		}
+	}
+}
+
+func TestHTTPReloadableSchema(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	oldSchema := schemabuilder.NewSchema()
+	oldSchema.Query().FieldFunc("version", func() string {
+		close(started)
+		<-release
+		return "v1"
+	})
+	newSchema := schemabuilder.NewSchema()
+	newSchema.Query().FieldFunc("version", func() string {
+		return "v2"
+	})
+
+	reloadable := graphql.NewReloadableSchema(oldSchema.MustBuild())
+	handler := graphql.HTTPHandlerWithOptions(nil, graphql.WithReloadableSchema(reloadable))
+	serve := func() string {
+		req := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ version }"}`))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Body.String()
+	}
+
+	inFlight := make(chan string)
+	go func() {
+		inFlight <- serve()
+	}()
+	<-started
+
+	// The in-flight query finishes against the old schema, while new queries
+	// use the new one.
+	reloadable.Store(newSchema.MustBuild())
+	if body := serve(); body != `{"data":{"version":"v2"},"errors":null}` {
+		t.Errorf("expected new query to use the new schema, but received %s", body)
+	}
+	close(release)
+	if body := <-inFlight; body != `{"data":{"version":"v1"},"errors":null}` {
+		t.Errorf("expected in-flight query to use the old schema, but received %s", body)
+	}
+}
+
+// countingRateLimiter allows a fixed number of requests per key.
+type countingRateLimiter struct {
+	mu    sync.Mutex
+	limit int
+	seen  map[graphql.RateLimitKey]int
+}
+
+func (l *countingRateLimiter) Allow(ctx context.Context, key graphql.RateLimitKey) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seen[key]++
+	return l.seen[key] <= l.limit
+}
+
+func TestHTTPRateLimiter(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("mirror", func(args struct{ Value int64 }) int64 {
+		return args.Value * -1
+	})
+	builtSchema := schema.MustBuild()
+
+	limiter := &countingRateLimiter{limit: 2, seen: make(map[graphql.RateLimitKey]int)}
+	handler := graphql.HTTPHandlerWithOptions(builtSchema, graphql.WithRateLimiter(limiter, func(r *http.Request) string {
+		return r.Header.Get("X-Client")
+	}))
+
+	for _, tt := range []struct {
+		client string
+		query  string
+		want   string
+	}{
+		{client: "a", query: `{ mirror(value: 1) }`, want: `{"data":{"mirror":-1},"errors":null}`},
+		{client: "a", query: `{  mirror(value: 1)  }`, want: `{"data":{"mirror":-1},"errors":null}`},
+		{client: "a", query: `{ mirror(value: 1) }`, want: `{"data":null,"errors":["rate limit exceeded"]}`},
+		{client: "a", query: `{ mirror(value: 2) }`, want: `{"data":{"mirror":-2},"errors":null}`},
+		{client: "b", query: `{ mirror(value: 1) }`, want: `{"data":{"mirror":-1},"errors":null}`},
+	} {
+		body, err := json.Marshal(map[string]string{"query": tt.query})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest("POST", "/graphql", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Client", tt.client)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if diff := pretty.Compare(rr.Body.String(), tt.want); diff != "" {
+			t.Errorf("expected response for %s %s to match, but received %s", tt.client, tt.query, diff)
+		}
+	}
+}
+
+func TestHTTPQueryLimits(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("mirror", func(args struct{ Value int64 }) int64 {
+		return args.Value * -1
+	})
+	builtSchema := schema.MustBuild()
+
+	handler := graphql.HTTPHandlerWithOptions(builtSchema, graphql.WithHTTPExecutor(
+		graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithMaxFragmentSpreads(1), graphql.WithMaxDepth(1))))
+
+	// The query is checked against the executor's limits before it is
+	// validated against the schema.
+	for _, tt := range []struct {
+		query string
+		want  string
+	}{
+		{query: `{ a ...F ...F } fragment F on Query { b }`, want: `{"data":null,"errors":["too many fragment spreads: query exceeds the maximum of 1"]}`},
+		{query: `{ a { b { c } } }`, want: `{"data":null,"errors":["query is too deep: a.b exceeds the maximum depth of 1"]}`},
+		{query: `{ a { b } }`, want: `{"data":null,"errors":["unknown field \"a\""]}`},
+	} {
+		body, err := json.Marshal(map[string]string{"query": tt.query})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest("POST", "/graphql", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if diff := pretty.Compare(rr.Body.String(), tt.want); diff != "" {
+			t.Errorf("expected response for %s to match, but received %s", tt.query, diff)
+		}
+	}
+}
+
+func TestHTTPQueryCache(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("mirror", func(args struct{ Value int64 }) int64 {
+		return args.Value * -1
+	})
+	builtSchema := schema.MustBuild()
+
+	cache := graphql.NewQueryCache(10)
+	handler := graphql.HTTPHandlerWithOptions(builtSchema, graphql.WithQueryCache(cache))
+
+	for _, tt := range []struct {
+		value int64
+		want  string
+	}{
+		{value: 1, want: `{"data":{"mirror":-1},"errors":null}`},
+		{value: 1, want: `{"data":{"mirror":-1},"errors":null}`},
+		{value: 2, want: `{"data":{"mirror":-2},"errors":null}`},
+	} {
+		body, err := json.Marshal(map[string]interface{}{
+			"query":     "query TestQuery($value: int64) { mirror(value: $value) }",
+			"variables": map[string]interface{}{"value": tt.value},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest("POST", "/graphql", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if diff := pretty.Compare(rr.Body.String(), tt.want); diff != "" {
+			t.Errorf("expected response for %d to match, but received %s", tt.value, diff)
+		}
+	}
+
+	// The query is parsed once, and only prepared again for new variables.
+	if cache.Parses() != 1 || cache.Prepares() != 2 {
+		t.Errorf("expected 1 parse and 2 prepares, but got %d and %d", cache.Parses(), cache.Prepares())
+	}
+}
+
+// incrementalExecutor runs the query with a real executor, then streams the
+// configured payloads.
+type incrementalExecutor struct {
+	graphql.ExecutorRunner
+	payloads []graphql.IncrementalPayload
+	// next, if set, is received from before sending every payload.
+	next chan struct{}
+}
+
+func (e *incrementalExecutor) ExecuteIncremental(ctx context.Context, typ graphql.Type, source interface{}, query *graphql.Query) (interface{}, <-chan graphql.IncrementalPayload, error) {
+	initial, err := e.Execute(ctx, typ, source, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	patches := make(chan graphql.IncrementalPayload)
+	go func() {
+		defer close(patches)
+		for _, payload := range e.payloads {
+			if e.next != nil {
+				<-e.next
+			}
+			patches <- payload
+		}
+	}()
+	return initial, patches, nil
+}
+
+func TestHTTPMultipartIncremental(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("mirror", func(args struct{ Value int64 }) int64 {
+		return args.Value * -1
+	})
+	builtSchema := schema.MustBuild()
+
+	executor := &incrementalExecutor{
+		ExecutorRunner: graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler()),
+		payloads: []graphql.IncrementalPayload{
+			{Data: map[string]interface{}{"slow": "done"}, Path: []interface{}{}, Label: "slow"},
+			{Items: []interface{}{1, 2}, Path: []interface{}{"list", 0}},
+			{Path: []interface{}{"broken"}, Err: errors.New("broken")},
+		},
+	}
+	handler := graphql.HTTPHandlerWithOptions(builtSchema, graphql.WithHTTPExecutor(executor))
+
+	body := `{"query": "{ mirror(value: 1) }"}`
+
+	// Without the Accept header, the response is a regular JSON response.
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Body.String() != `{"data":{"mirror":-1},"errors":null}` {
+		t.Errorf("expected regular response, received %s", rr.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+	req.Header.Set("Accept", "multipart/mixed, application/json")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	mediaType, params, err := mime.ParseMediaType(rr.Header().Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != "multipart/mixed" {
+		t.Fatalf("expected multipart/mixed, received %s", mediaType)
+	}
+	if !rr.Flushed {
+		t.Error("expected parts to be flushed")
+	}
+
+	var parts []string
+	reader := multipart.NewReader(rr.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if part.Header.Get("Content-Type") != "application/json; charset=utf-8" {
+			t.Errorf("unexpected part content type %s", part.Header.Get("Content-Type"))
+		}
+		partBody, err := ioutil.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, string(partBody))
+	}
+
+	expected := []string{
+		`{"data":{"mirror":-1},"hasNext":true}`,
+		`{"incremental":[{"data":{"slow":"done"},"path":[],"label":"slow"}],"hasNext":true}`,
+		`{"incremental":[{"items":[1,2],"path":["list",0]}],"hasNext":true}`,
+		`{"incremental":[{"path":["broken"],"errors":["broken"]}],"hasNext":true}`,
+		`{"hasNext":false}`,
+	}
+	if diff := pretty.Compare(expected, parts); diff != "" {
+		t.Errorf("unexpected parts: %s", diff)
+	}
+}
+
+func TestHTTPMultipartIncrementalStreams(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("mirror", func(args struct{ Value int64 }) int64 {
+		return args.Value * -1
+	})
+	builtSchema := schema.MustBuild()
+
+	executor := &incrementalExecutor{
+		ExecutorRunner: graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler()),
+		payloads: []graphql.IncrementalPayload{
+			{Data: map[string]interface{}{"first": true}, Path: []interface{}{}},
+			{Data: map[string]interface{}{"second": true}, Path: []interface{}{}},
+		},
+		next: make(chan struct{}),
+	}
+	server := httptest.NewServer(graphql.HTTPHandlerWithOptions(builtSchema, graphql.WithHTTPExecutor(executor)))
+	defer server.Close()
+
+	req, err := http.NewRequest("POST", server.URL, strings.NewReader(`{"query": "{ mirror(value: 1) }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "multipart/mixed")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	boundary := "\r\n--" + params["boundary"]
+
+	// Every part is received, followed by its boundary, before the next
+	// payload is sent.
+	var received string
+	readPart := func() string {
+		buf := make([]byte, 1024)
+		for strings.Count(received, boundary) < 2 {
+			n, err := resp.Body.Read(buf)
+			received += string(buf[:n])
+			if err != nil && strings.Count(received, boundary) < 2 {
+				t.Fatalf("expected a part, but got %v after %q", err, received)
+			}
+		}
+		parts := strings.SplitN(received, boundary, 3)
+		received = boundary + parts[2]
+		return parts[1]
+	}
+
+	var parts []string
+	parts = append(parts, readPart())
+	for range executor.payloads {
+		executor.next <- struct{}{}
+		parts = append(parts, readPart())
+	}
+	parts = append(parts, readPart())
+
+	const header = "\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"
+	expected := []string{
+		header + `{"data":{"mirror":-1},"hasNext":true}`,
+		header + `{"incremental":[{"data":{"first":true},"path":[]}],"hasNext":true}`,
+		header + `{"incremental":[{"data":{"second":true},"path":[]}],"hasNext":true}`,
+		header + `{"hasNext":false}`,
+	}
+	if diff := pretty.Compare(expected, parts); diff != "" {
+		t.Errorf("unexpected parts: %s", diff)
+	}
+	rest, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received+string(rest) != boundary+"--\r\n" {
+		t.Errorf("expected the response to end, but got %q", received+string(rest))
+	}
+
+}
@@ -0,0 +1,146 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// IncrementalPayload is a part of a response delivered after the initial
+// payload, e.g. the result of a deferred fragment or a streamed list item.
+type IncrementalPayload struct {
+	// Data is the result of a deferred fragment, to be merged into the
+	// response at Path.
+	Data interface{}
+	// Items are streamed list items, to be appended to the list at Path.
+	Items []interface{}
+	// Path is the location in the response the payload belongs to.
+	Path []interface{}
+	// Label is the label of the @defer or @stream directive, if any.
+	Label string
+	// Err is set if resolving the payload failed.
+	Err error
+}
+
+// IncrementalExecutorRunner is an ExecutorRunner that can deliver parts of a
+// response incrementally.  ExecuteIncremental returns the initial result along
+// with a channel of subsequent payloads, which is closed once the response is
+// complete.
+type IncrementalExecutorRunner interface {
+	ExecutorRunner
+	ExecuteIncremental(ctx context.Context, typ Type, source interface{}, query *Query) (interface{}, <-chan IncrementalPayload, error)
+}
+
+// multipartBoundary is the boundary recommended by the GraphQL incremental
+// delivery over HTTP spec.
+const multipartBoundary = "-"
+
+// acceptsMultipartMixed returns whether an Accept header allows a
+// multipart/mixed response.
+func acceptsMultipartMixed(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mediaType == "multipart/mixed" {
+			return true
+		}
+	}
+	return false
+}
+
+type initialPayload struct {
+	Data    interface{} `json:"data"`
+	HasNext bool        `json:"hasNext"`
+}
+
+type incrementalResult struct {
+	Data   interface{}   `json:"data,omitempty"`
+	Items  []interface{} `json:"items,omitempty"`
+	Path   []interface{} `json:"path"`
+	Label  string        `json:"label,omitempty"`
+	Errors []interface{} `json:"errors,omitempty"`
+}
+
+type subsequentPayload struct {
+	Incremental []incrementalResult `json:"incremental,omitempty"`
+	HasNext     bool                `json:"hasNext"`
+}
+
+// writeMultipartResponse writes a multipart/mixed response, with the initial
+// result as the first part, every payload as a subsequent part, and a final
+// part with hasNext set to false.
+//
+// Each part is followed by the boundary and flushed as soon as it is written,
+// as the spec recommends, so that clients can parse a part without waiting
+// for the next one.  mime/multipart only writes a boundary at the start of
+// the next part, so the parts are written by hand.
+func (h *httpHandler) writeMultipartResponse(ctx context.Context, w http.ResponseWriter, initial interface{}, patches <-chan IncrementalPayload) {
+	w.Header().Set("Content-Type", `multipart/mixed; boundary="`+multipartBoundary+`"`)
+	flusher, _ := w.(http.Flusher)
+
+	write := func(s string) error {
+		if _, err := io.WriteString(w, s); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+	writePart := func(value interface{}) error {
+		partJSON, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		return write("\r\nContent-Type: application/json; charset=utf-8\r\n\r\n" + string(partJSON) + "\r\n--" + multipartBoundary)
+	}
+
+	if err := write("\r\n--" + multipartBoundary); err != nil {
+		return
+	}
+	if err := writePart(initialPayload{Data: initial, HasNext: true}); err != nil {
+		return
+	}
+
+	// Every payload is written as soon as it arrives, since the next one may
+	// take a while, and the end of the response is sent as a separate part.
+	for {
+		select {
+		case patch, ok := <-patches:
+			if !ok {
+				if err := writePart(subsequentPayload{HasNext: false}); err != nil {
+					return
+				}
+				// Close the response with the final boundary.
+				write("--\r\n")
+				return
+			}
+			if err := writePart(h.subsequentPayload(ctx, &patch)); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (h *httpHandler) subsequentPayload(ctx context.Context, patch *IncrementalPayload) subsequentPayload {
+	result := incrementalResult{
+		Data:  patch.Data,
+		Items: patch.Items,
+		Path:  patch.Path,
+		Label: patch.Label,
+	}
+	if result.Path == nil {
+		result.Path = []interface{}{}
+	}
+	if patch.Err != nil {
+		result.Errors = []interface{}{h.formatError(ctx, patch.Err)}
+	}
+	return subsequentPayload{
+		Incremental: []incrementalResult{result},
+		HasNext:     true,
+	}
+}
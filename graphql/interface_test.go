@@ -0,0 +1,149 @@
+package graphql_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/samsarahq/thunder/batch"
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/internal"
+	"github.com/samsarahq/thunder/internal/testgraphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterfaceType(t *testing.T) {
+	type Dog struct {
+		Name  string
+		Breed string
+	}
+	type Cat struct {
+		Name  string
+		Lives int64
+	}
+	type Pet struct {
+		schemabuilder.Interface
+
+		*Dog
+		*Cat
+	}
+
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("pets", func() []*Pet {
+		return []*Pet{
+			{Dog: &Dog{Name: "rex", Breed: "collie"}},
+			{Cat: &Cat{Name: "tom", Lives: 9}},
+			{Dog: &Dog{Name: "fido", Breed: "pug"}},
+		}
+	})
+	dog := schema.Object("Dog", Dog{})
+	dog.FieldFunc("sound", func(d *Dog) string {
+		return "woof"
+	})
+	cat := schema.Object("Cat", Cat{})
+	cat.FieldFunc("sound", func(c *Cat) string {
+		return "meow"
+	})
+	builtSchema := schema.MustBuild()
+
+	ctx := context.Background()
+	e := testgraphql.NewExecutorWrapper(t)
+
+	q := graphql.MustParse(`{
+		pets {
+			__typename
+			name
+			... on Dog { breed }
+			... on Cat { lives }
+			... on Pet { sound ... on Cat { name } }
+		}
+	}`, nil)
+	require.NoError(t, graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet))
+	val, err := e.Execute(ctx, builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{
+		"pets": [
+			{"__typename": "Dog", "name": "rex", "breed": "collie", "sound": "woof"},
+			{"__typename": "Cat", "name": "tom", "lives": 9, "sound": "meow"},
+			{"__typename": "Dog", "name": "fido", "breed": "pug", "sound": "woof"}
+		]
+	}`), internal.AsJSON(val))
+
+	// Fields that not every member has must be selected in a fragment.
+	q = graphql.MustParse(`{ pets { breed } }`, nil)
+	assert.EqualError(t, graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet), `unknown field "breed"`)
+
+	q = graphql.MustParse(`{ pets { ... on Query { pets { name } } } }`, nil)
+	assert.EqualError(t, graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet), `fragment on "Query" can never apply to interface Pet`)
+}
+
+func TestInterfaceBatchFields(t *testing.T) {
+	type Dog struct {
+		Name string
+	}
+	type Cat struct {
+		Name string
+	}
+	type Pet struct {
+		schemabuilder.Interface
+
+		*Dog
+		*Cat
+	}
+
+	var dogBatches, catBatches int64
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("pets", func() []*Pet {
+		return []*Pet{
+			{Dog: &Dog{Name: "rex"}},
+			{Cat: &Cat{Name: "tom"}},
+			{Dog: &Dog{Name: "fido"}},
+			{Cat: &Cat{Name: "felix"}},
+		}
+	})
+	dog := schema.Object("Dog", Dog{})
+	dog.BatchFieldFunc("owner", func(ctx context.Context, dogs map[batch.Index]*Dog) (map[batch.Index]string, error) {
+		atomic.AddInt64(&dogBatches, 1)
+		res := make(map[batch.Index]string, len(dogs))
+		for idx, d := range dogs {
+			res[idx] = "owner of " + d.Name
+		}
+		return res, nil
+	})
+	cat := schema.Object("Cat", Cat{})
+	cat.BatchFieldFunc("owner", func(ctx context.Context, cats map[batch.Index]*Cat) (map[batch.Index]string, error) {
+		atomic.AddInt64(&catBatches, 1)
+		res := make(map[batch.Index]string, len(cats))
+		for idx, c := range cats {
+			res[idx] = "staff of " + c.Name
+		}
+		return res, nil
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{
+		pets {
+			__typename
+			name
+			owner
+		}
+	}`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{
+		"pets": [
+			{"__typename": "Dog", "name": "rex", "owner": "owner of rex"},
+			{"__typename": "Cat", "name": "tom", "owner": "staff of tom"},
+			{"__typename": "Dog", "name": "fido", "owner": "owner of fido"},
+			{"__typename": "Cat", "name": "felix", "owner": "staff of felix"}
+		]
+	}`), internal.AsJSON(val))
+
+	// The sources of each member are batched together.
+	assert.Equal(t, int64(1), dogBatches)
+	assert.Equal(t, int64(1), catBatches)
+}
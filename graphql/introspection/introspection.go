@@ -11,9 +11,10 @@ import (
 )
 
 type introspection struct {
-	types    map[string]graphql.Type
-	query    graphql.Type
-	mutation graphql.Type
+	types        map[string]graphql.Type
+	query        graphql.Type
+	mutation     graphql.Type
+	subscription graphql.Type
 }
 
 type DirectiveLocation string
@@ -25,6 +26,8 @@ const (
 	FRAGMENT_DEFINITION                   = "FRAGMENT_DEFINITION"
 	FRAGMENT_SPREAD                       = "FRAGMENT_SPREAD"
 	INLINE_FRAGMENT                       = "INLINE_FRAGMENT"
+	FIELD_DEFINITION                      = "FIELD_DEFINITION"
+	ENUM_VALUE                            = "ENUM_VALUE"
 )
 
 type TypeKind string
@@ -123,6 +126,25 @@ var skipDirective = Directive{
 	},
 }
 
+var deprecatedReasonDefault = `"No longer supported"`
+
+var deprecatedDirective = Directive{
+	Description: "Marks an element of a GraphQL schema as no longer supported.",
+	Locations: []DirectiveLocation{
+		FIELD_DEFINITION,
+		ENUM_VALUE,
+	},
+	Name: "deprecated",
+	Args: []InputValue{
+		InputValue{
+			Name:         "reason",
+			Type:         Type{Inner: &graphql.Scalar{Type: "string"}},
+			Description:  "Explains why this element was deprecated.",
+			DefaultValue: &deprecatedReasonDefault,
+		},
+	},
+}
+
 var typeAsOptionalDirective = Directive{
 	Description: "Client-side-only directive that instructs the type generator to mark this field as optional. This is useful for making the generated types compliant with Troy persistence schema.",
 	Locations: []DirectiveLocation{
@@ -140,6 +162,8 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 			return OBJECT
 		case *graphql.Union:
 			return UNION
+		case *graphql.Interface:
+			return INTERFACE
 		case *graphql.Scalar:
 			return SCALAR
 		case *graphql.Enum:
@@ -161,6 +185,8 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 			return &t.Name
 		case *graphql.Union:
 			return &t.Name
+		case *graphql.Interface:
+			return &t.Name
 		case *graphql.Scalar:
 			return &t.Type
 		case *graphql.Enum:
@@ -178,17 +204,18 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 			return t.Description
 		case *graphql.Union:
 			return t.Description
+		case *graphql.Interface:
+			return t.Description
 		default:
 			return ""
 		}
 	})
 
-	object.FieldFunc("interfaces", func() []Type { return nil })
-	object.FieldFunc("possibleTypes", func(t Type) []Type {
+	object.FieldFunc("interfaces", func(t Type) []Type {
 		switch t := t.Inner.(type) {
-		case *graphql.Union:
-			types := make([]Type, 0, len(t.Types))
-			for _, typ := range t.Types {
+		case *graphql.Object:
+			types := make([]Type, 0, len(t.Interfaces))
+			for _, typ := range t.Interfaces {
 				types = append(types, Type{Inner: typ})
 			}
 
@@ -198,6 +225,25 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 			return nil
 		}
 	})
+	object.FieldFunc("possibleTypes", func(t Type) []Type {
+		var members map[string]*graphql.Object
+		switch t := t.Inner.(type) {
+		case *graphql.Union:
+			members = t.Types
+		case *graphql.Interface:
+			members = t.Types
+		default:
+			return nil
+		}
+
+		types := make([]Type, 0, len(members))
+		for _, typ := range members {
+			types = append(types, Type{Inner: typ})
+		}
+
+		sort.Slice(types, func(i, j int) bool { return types[i].Inner.String() < types[j].Inner.String() })
+		return types
+	})
 
 	object.FieldFunc("inputFields", func(t Type) []InputValue {
 		var fields []InputValue
@@ -206,8 +252,9 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 		case *graphql.InputObject:
 			for name, f := range t.InputFields {
 				fields = append(fields, InputValue{
-					Name: name,
-					Type: Type{Inner: f},
+					Name:         name,
+					Type:         Type{Inner: f},
+					DefaultValue: defaultValue(t.DefaultValues, name),
 				})
 			}
 		}
@@ -216,29 +263,44 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 		return fields
 	})
 
-	object.FieldFunc("fields", func(t Type, args struct {
+	object.FieldFunc("fields", func(ctx context.Context, t Type, args struct {
 		IncludeDeprecated *bool
 	}) []field {
 		var fields []field
 
+		var objectFields map[string]*graphql.Field
 		switch t := t.Inner.(type) {
 		case *graphql.Object:
-			for name, f := range t.Fields {
-				var args []InputValue
-				for name, a := range f.Args {
-					args = append(args, InputValue{
-						Name: name,
-						Type: Type{Inner: a},
-					})
-				}
-				sort.Slice(args, func(i, j int) bool { return args[i].Name < args[j].Name })
+			objectFields = t.Fields
+		case *graphql.Interface:
+			objectFields = t.Fields
+		}
+		for name, f := range objectFields {
+			if f.DeprecationReason != "" && !includeDeprecated(args.IncludeDeprecated) {
+				continue
+			}
+			// Fields gated by a disabled feature flag can't be queried.
+			if !graphql.FieldEnabled(ctx, f) {
+				continue
+			}
 
-				fields = append(fields, field{
-					Name: name,
-					Type: Type{Inner: f.Type},
-					Args: args,
+			var args []InputValue
+			for name, a := range f.Args {
+				args = append(args, InputValue{
+					Name:         name,
+					Type:         Type{Inner: a},
+					DefaultValue: defaultValue(f.ArgDefaultValues, name),
 				})
 			}
+			sort.Slice(args, func(i, j int) bool { return args[i].Name < args[j].Name })
+
+			fields = append(fields, field{
+				Name:              name,
+				Type:              Type{Inner: f.Type},
+				Args:              args,
+				IsDeprecated:      f.DeprecationReason != "",
+				DeprecationReason: f.DeprecationReason,
+			})
 		}
 		sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
 
@@ -264,9 +326,13 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 		case *graphql.Enum:
 			var enumVals []EnumValue
 			for k, v := range t.ReverseMap {
+				reason, deprecated := t.DeprecatedValues[v]
+				if deprecated && !includeDeprecated(args.IncludeDeprecated) {
+					continue
+				}
 				val := fmt.Sprintf("%v", k)
 				enumVals = append(enumVals,
-					EnumValue{Name: v, Description: val, IsDeprecated: false, DeprecationReason: ""})
+					EnumValue{Name: v, Description: val, IsDeprecated: deprecated, DeprecationReason: reason})
 			}
 			sort.Slice(enumVals, func(i, j int) bool { return enumVals[i].Name < enumVals[j].Name })
 			return enumVals
@@ -275,6 +341,22 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 	})
 }
 
+// includeDeprecated returns whether deprecated fields and enum values are
+// listed, given the includeDeprecated argument.
+// defaultValue returns the default value of the named input value, if it has
+// one.
+func defaultValue(defaults map[string]string, name string) *string {
+	value, ok := defaults[name]
+	if !ok {
+		return nil
+	}
+	return &value
+}
+
+func includeDeprecated(arg *bool) bool {
+	return arg != nil && *arg
+}
+
 type field struct {
 	Name              string
 	Description       string
@@ -313,6 +395,15 @@ func collectTypes(typ graphql.Type, types map[string]graphql.Type) {
 			collectTypes(graphqlTyp, types)
 		}
 
+	case *graphql.Interface:
+		if _, ok := types[typ.Name]; ok {
+			return
+		}
+		types[typ.Name] = typ
+		for _, graphqlTyp := range typ.Types {
+			collectTypes(graphqlTyp, types)
+		}
+
 	case *graphql.List:
 		collectTypes(typ.Type, types)
 
@@ -354,13 +445,20 @@ func (s *introspection) registerQuery(schema *schemabuilder.Schema) {
 		}
 		sort.Slice(types, func(i, j int) bool { return types[i].Inner.String() < types[j].Inner.String() })
 
+		var subscriptionType *Type
+		if s.subscription != nil {
+			subscriptionType = &Type{Inner: s.subscription}
+		}
+
 		return &Schema{
-			Types:        types,
-			QueryType:    &Type{Inner: s.query},
-			MutationType: &Type{Inner: s.mutation},
+			Types:            types,
+			QueryType:        &Type{Inner: s.query},
+			MutationType:     &Type{Inner: s.mutation},
+			SubscriptionType: subscriptionType,
 			Directives: []Directive{
 				includeDirective,
 				skipDirective,
+				deprecatedDirective,
 				typeAsOptionalDirective,
 			},
 		}
@@ -396,10 +494,14 @@ func BareIntrospectionSchema(schema *graphql.Schema) *graphql.Schema {
 	types := make(map[string]graphql.Type)
 	collectTypes(schema.Query, types)
 	collectTypes(schema.Mutation, types)
+	if schema.Subscription != nil {
+		collectTypes(schema.Subscription, types)
+	}
 	is := &introspection{
-		types:    types,
-		query:    schema.Query,
-		mutation: schema.Mutation,
+		types:        types,
+		query:        schema.Query,
+		mutation:     schema.Mutation,
+		subscription: schema.Subscription,
 	}
 	return is.schema()
 }
@@ -1,12 +1,15 @@
 package introspection_test
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
 	"github.com/samsarahq/go/snapshotter"
+	"github.com/samsarahq/thunder/graphql"
 	"github.com/samsarahq/thunder/graphql/introspection"
 	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/internal"
 	"github.com/stretchr/testify/require"
 )
 
@@ -121,3 +124,287 @@ func (u Uuid) MarshalText() ([]byte, error) {
 func (u *Uuid) UnmarshalText(data []byte) error {
 	return nil
 }
+
+func TestPublicIntrospection(t *testing.T) {
+	type authKey struct{}
+
+	schema := makeSchema().MustBuild()
+	introspection.AddIntrospectionToSchema(schema)
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithPublicIntrospection(func(ctx context.Context) bool {
+		return ctx.Value(authKey{}) != nil
+	}))
+
+	execute := func(ctx context.Context, query string) (interface{}, error) {
+		q := graphql.MustParse(query, nil)
+		require.NoError(t, graphql.PrepareQuery(ctx, schema.Query, q.SelectionSet))
+		return e.Execute(ctx, schema.Query, nil, q)
+	}
+
+	// Unauthenticated callers can introspect the schema, but not query data.
+	res, err := execute(context.Background(), `{ __type(name: "user") { name } }`)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"__type": map[string]interface{}{"name": "user"}}, res)
+
+	_, err = execute(context.Background(), `{ __schema { queryType { name } } me { name } }`)
+	require.Equal(t, graphql.ErrUnauthenticated, err)
+
+	res, err = execute(context.WithValue(context.Background(), authKey{}, true), `{ me { name } }`)
+	require.NoError(t, err)
+	require.Equal(t, "me", res.(map[string]interface{})["me"].(map[string]interface{})["name"])
+}
+
+func TestDeprecated(t *testing.T) {
+	type status int32
+	type Account struct {
+		FullName string
+		Status   status
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Enum(status(0), map[string]status{
+		"active":   0,
+		"inactive": 1,
+		"disabled": 2,
+	}, schemabuilder.DeprecatedEnumValue("disabled", "Use inactive instead."))
+	builder.Query().FieldFunc("account", func() *Account {
+		return &Account{FullName: "Alice Smith", Status: 2}
+	})
+	account := builder.Object("Account", Account{})
+	account.FieldFunc("name", func(a *Account) string {
+		return a.FullName
+	}, schemabuilder.Deprecated("Use fullName instead."))
+	account.FieldFunc("nickname", func(a *Account) string {
+		return "Al"
+	}, schemabuilder.Deprecated(""))
+	schema := builder.MustBuild()
+	introspection.AddIntrospectionToSchema(schema)
+
+	q := graphql.MustParse(`{
+		account { name status }
+		accountType: __type(name: "Account") {
+			fields { name }
+			allFields: fields(includeDeprecated: true) { name isDeprecated deprecationReason }
+		}
+		statusType: __type(name: "status") {
+			enumValues { name }
+			allEnumValues: enumValues(includeDeprecated: true) { name isDeprecated deprecationReason }
+		}
+	}`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+
+	// Deprecated fields and values still resolve.
+	require.Equal(t, map[string]interface{}{"name": "Alice Smith", "status": "disabled"}, res.(map[string]interface{})["account"])
+
+	require.Equal(t, map[string]interface{}{
+		"fields": []interface{}{
+			map[string]interface{}{"name": "fullName"},
+			map[string]interface{}{"name": "status"},
+		},
+		"allFields": []interface{}{
+			map[string]interface{}{"name": "fullName", "isDeprecated": false, "deprecationReason": ""},
+			map[string]interface{}{"name": "name", "isDeprecated": true, "deprecationReason": "Use fullName instead."},
+			map[string]interface{}{"name": "nickname", "isDeprecated": true, "deprecationReason": "No longer supported"},
+			map[string]interface{}{"name": "status", "isDeprecated": false, "deprecationReason": ""},
+		},
+	}, res.(map[string]interface{})["accountType"])
+	require.Equal(t, map[string]interface{}{
+		"enumValues": []interface{}{
+			map[string]interface{}{"name": "active"},
+			map[string]interface{}{"name": "inactive"},
+		},
+		"allEnumValues": []interface{}{
+			map[string]interface{}{"name": "active", "isDeprecated": false, "deprecationReason": ""},
+			map[string]interface{}{"name": "disabled", "isDeprecated": true, "deprecationReason": "Use inactive instead."},
+			map[string]interface{}{"name": "inactive", "isDeprecated": false, "deprecationReason": ""},
+		},
+	}, res.(map[string]interface{})["statusType"])
+}
+
+func TestSubscriptionType(t *testing.T) {
+	type Event struct {
+		Message string
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("unused", func() string { return "" })
+	builder.Subscription().FieldFunc("event", func() <-chan *Event {
+		return nil
+	})
+	schema := builder.MustBuild()
+	introspection.AddIntrospectionToSchema(schema)
+
+	q := graphql.MustParse(`{
+		__schema { subscriptionType { name fields { name type { name } } } }
+		event: __type(name: "Event") { name }
+	}`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+
+	// The subscription's fields have the type of their events.
+	require.Equal(t, map[string]interface{}{
+		"__schema": map[string]interface{}{
+			"subscriptionType": map[string]interface{}{
+				"name": "Subscription",
+				"fields": []interface{}{
+					map[string]interface{}{"name": "event", "type": map[string]interface{}{"name": "Event"}},
+				},
+			},
+		},
+		"event": map[string]interface{}{"name": "Event"},
+	}, res)
+}
+
+func TestFeatureFlagHidesFields(t *testing.T) {
+	type Account struct {
+		Name string
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("account", func() *Account {
+		return &Account{Name: "Alice"}
+	})
+	account := builder.Object("Account", Account{})
+	account.FieldFunc("balance", func(a *Account) int64 {
+		return 10
+	}, schemabuilder.FeatureFlag("balance"))
+	schema := builder.MustBuild()
+	introspection.AddIntrospectionToSchema(schema)
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	fieldNames := func(ctx context.Context) interface{} {
+		q := graphql.MustParse(`{ __type(name: "Account") { fields { name } } }`, nil)
+		require.NoError(t, graphql.PrepareQuery(ctx, schema.Query, q.SelectionSet))
+		res, err := e.Execute(ctx, schema.Query, nil, q)
+		require.NoError(t, err)
+		return res.(map[string]interface{})["__type"]
+	}
+
+	require.Equal(t, map[string]interface{}{
+		"fields": []interface{}{
+			map[string]interface{}{"name": "name"},
+		},
+	}, fieldNames(context.Background()))
+
+	ctx := graphql.WithFeatureFlags(context.Background(), func(ctx context.Context, flag string) bool { return flag == "balance" })
+	require.Equal(t, map[string]interface{}{
+		"fields": []interface{}{
+			map[string]interface{}{"name": "balance"},
+			map[string]interface{}{"name": "name"},
+		},
+	}, fieldNames(ctx))
+}
+
+func TestDefaultValues(t *testing.T) {
+	type Order int64
+	type Filter struct {
+		Order Order `default:"\"desc\""`
+		Tags  []string
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Enum(Order(0), map[string]interface{}{
+		"asc":  Order(1),
+		"desc": Order(2),
+	})
+	builder.Query().FieldFunc("search", func(args struct {
+		Limit  int64  `default:"10"`
+		Filter Filter `default:"{\"order\": \"asc\", \"tags\": [\"a\"]}"`
+		Query  string
+	}) int64 {
+		return args.Limit
+	}, schemabuilder.ArgMapsTo("first", "limit"))
+	schema := builder.MustBuild()
+	introspection.AddIntrospectionToSchema(schema)
+
+	q := graphql.MustParse(`{
+		__schema { queryType { fields { name args { name defaultValue } } } }
+		__type(name: "Filter_InputObject") { inputFields { name defaultValue } }
+	}`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	require.Equal(t, internal.ParseJSON(`{
+		"__schema": {"queryType": {"fields": [{"name": "search", "args": [
+			{"name": "filter", "defaultValue": "{order: asc, tags: [\"a\"]}"},
+			{"name": "first", "defaultValue": "10"},
+			{"name": "query", "defaultValue": null}
+		]}]}},
+		"__type": {"inputFields": [
+			{"name": "order", "defaultValue": "desc"},
+			{"name": "tags", "defaultValue": null}
+		]}
+	}`), internal.AsJSON(res))
+}
+
+func TestIntrospectionQueryTypes(t *testing.T) {
+	schemaJSON, err := introspection.ComputeSchemaJSON(*makeSchema())
+	require.NoError(t, err)
+
+	type typeRef struct {
+		Kind   string
+		Name   *string
+		OfType *typeRef
+	}
+	var result struct {
+		Schema struct {
+			QueryType struct{ Name string }
+			Types     []struct {
+				Kind   string
+				Name   string
+				Fields []struct {
+					Name string
+					Type *typeRef
+				}
+				EnumValues    []struct{ Name string }
+				PossibleTypes []struct{ Name string }
+			}
+			Directives []struct{ Name string }
+		} `json:"__schema"`
+	}
+	require.NoError(t, json.Unmarshal(schemaJSON, &result))
+	require.Equal(t, "Query", result.Schema.QueryType.Name)
+
+	types := make(map[string]int)
+	for i, typ := range result.Schema.Types {
+		types[typ.Name] = i
+	}
+
+	user := result.Schema.Types[types["user"]]
+	require.Equal(t, "OBJECT", user.Kind)
+	var friends *typeRef
+	for _, field := range user.Fields {
+		if field.Name == "friends" {
+			friends = field.Type
+		}
+	}
+	// friends is a [user!]!.
+	require.NotNil(t, friends)
+	require.Equal(t, "NON_NULL", friends.Kind)
+	require.Equal(t, "LIST", friends.OfType.Kind)
+	require.Equal(t, "NON_NULL", friends.OfType.OfType.Kind)
+	require.Equal(t, "OBJECT", friends.OfType.OfType.OfType.Kind)
+	require.Equal(t, "user", *friends.OfType.OfType.OfType.Name)
+
+	gateway := result.Schema.Types[types["Gateway"]]
+	require.Equal(t, "UNION", gateway.Kind)
+	require.Len(t, gateway.PossibleTypes, 2)
+	require.Equal(t, "Asset", gateway.PossibleTypes[0].Name)
+	require.Equal(t, "Vehicle", gateway.PossibleTypes[1].Name)
+
+	enum := result.Schema.Types[types["enumType"]]
+	require.Equal(t, "ENUM", enum.Kind)
+	require.Len(t, enum.EnumValues, 3)
+	require.Equal(t, "random", enum.EnumValues[0].Name)
+
+	var directives []string
+	for _, directive := range result.Schema.Directives {
+		directives = append(directives, directive.Name)
+	}
+	require.Equal(t, []string{"include", "skip", "deprecated", "type_as_optional"}, directives)
+}
@@ -0,0 +1,38 @@
+package graphql
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A ListLengthPolicy decides what MaxListLength does with lists that exceed
+// the limit.
+type ListLengthPolicy int
+
+const (
+	// TruncateList drops the items past the limit.
+	TruncateList ListLengthPolicy = iota
+	// FailList fails the field.
+	FailList
+)
+
+// MaxListLength returns an OutputTransformer that guards against a resolver
+// returning a huge list, e.g. because of a missing filter, by truncating
+// lists longer than max items or failing the field, according to policy.
+// Values that are not slices are passed through unchanged.  MaxListLength
+// panics if max is negative.
+func MaxListLength(max int, policy ListLengthPolicy) OutputTransformer {
+	if max < 0 {
+		panic(fmt.Sprintf("max list length must not be negative, got %d", max))
+	}
+	return func(value interface{}) (interface{}, error) {
+		list := reflect.ValueOf(value)
+		if list.Kind() != reflect.Slice || list.Len() <= max {
+			return value, nil
+		}
+		if policy == FailList {
+			return nil, fmt.Errorf("list has %d items, more than the maximum of %d", list.Len(), max)
+		}
+		return list.Slice(0, max).Interface(), nil
+	}
+}
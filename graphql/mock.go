@@ -0,0 +1,92 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NewMockExecutor returns an executor that resolves fields from canned values
+// rather than their resolvers, to test a schema's shape and the handling of
+// selections without real backends.
+//
+// mocks is keyed by field, in any of these forms, from most to least
+// specific:
+//
+//   - the field's path in the query, by alias, without list indices, e.g.
+//     "users.friends.name"
+//   - the field's type and name, e.g. "User.name"
+//   - the field's name, e.g. "name"
+//
+// A mocked value is resolved like the field's resolver had returned it, so
+// the mock of a field returning an object must be a value its sub-fields can
+// resolve from.  Fields without a mock are resolved as usual if they just read
+// their source (e.g. struct fields), and fail otherwise.
+func NewMockExecutor(mocks map[string]interface{}, options ...ExecutorOption) ExecutorRunner {
+	opts := make([]ExecutorOption, 0, len(options)+1)
+	opts = append(opts, options...)
+	opts = append(opts, func(e *Executor) {
+		e.mocks = mocks
+	})
+	return NewExecutor(NewImmediateGoroutineScheduler(), opts...)
+}
+
+// mockFor returns the mocked value for the field resolved by unit.
+func mockFor(mocks map[string]interface{}, unit *WorkUnit) (interface{}, bool) {
+	if len(unit.destinations) > 0 {
+		if value, ok := mocks[mockPath(unit.destinations[0])]; ok {
+			return value, true
+		}
+	}
+	if value, ok := mocks[unit.objectName+"."+unit.selection.Name]; ok {
+		return value, true
+	}
+	value, ok := mocks[unit.selection.Name]
+	return value, ok
+}
+
+// mockPath returns the path of dest in the query, without list indices.
+func mockPath(dest *outputNode) string {
+	var path []string
+	for cur := dest.pathTracker; cur != nil && cur.parent != nil; cur = cur.parent {
+		if cur.path == "" {
+			continue
+		}
+		if _, err := strconv.Atoi(cur.path); err == nil {
+			continue
+		}
+		path = append(path, cur.path)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return strings.Join(path, ".")
+}
+
+// executeMockWorkUnit resolves unit from mocks, returning false if the field
+// is not mocked and can be resolved as usual.
+func executeMockWorkUnit(unit *WorkUnit, mocks map[string]interface{}) ([]*WorkUnit, bool) {
+	value, ok := mockFor(mocks, unit)
+	if !ok {
+		if !unit.field.External {
+			return nil, false
+		}
+		for _, dest := range unit.destinations {
+			dest.Fail(fmt.Errorf("graphql: no mock for %s.%s", unit.objectName, unit.selection.Name))
+		}
+		return nil, true
+	}
+
+	results := make([]interface{}, len(unit.sources))
+	for idx := range results {
+		results[idx] = value
+	}
+	units, err := resolveBatch(unit.Ctx, results, unit.field.Type, unit.selection.SelectionSet, unit.destinations)
+	if err != nil {
+		for _, dest := range unit.destinations {
+			dest.Fail(err)
+		}
+		return nil, true
+	}
+	return units, true
+}
@@ -0,0 +1,78 @@
+package graphql_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/samsarahq/thunder/batch"
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockExecutor(t *testing.T) {
+	type User struct {
+		Id   int64
+		Name string
+	}
+
+	backendErr := errors.New("backend unavailable")
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("users", func() ([]*User, error) {
+		return nil, backendErr
+	})
+	query.FieldFunc("viewer", func() (*User, error) {
+		return nil, backendErr
+	})
+	query.FieldFunc("version", func() (string, error) {
+		return "", backendErr
+	})
+	query.FieldFunc("uptime", func() (int64, error) {
+		return 0, backendErr
+	})
+	user := schema.Object("User", User{})
+	user.FieldFunc("greeting", func(u *User) (string, error) {
+		return "", backendErr
+	})
+	user.BatchFieldFunc("friendCount", func(ctx context.Context, users map[batch.Index]*User) (map[batch.Index]int64, error) {
+		return nil, backendErr
+	})
+	builtSchema := schema.MustBuild()
+
+	e := graphql.NewMockExecutor(map[string]interface{}{
+		"users":           []*User{{Id: 1, Name: "alice"}, {Id: 2, Name: "bob"}},
+		"viewer":          &User{Id: 3, Name: "carol"},
+		"version":         "v1",
+		"User.greeting":   "hi",
+		"viewer.greeting": "welcome back",
+		"friendCount":     int64(5),
+	})
+
+	q := graphql.MustParse(`{
+		users { id name greeting friendCount }
+		viewer { name greeting }
+		version
+	}`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{
+		"users": [
+			{"id": 1, "name": "alice", "greeting": "hi", "friendCount": 5},
+			{"id": 2, "name": "bob", "greeting": "hi", "friendCount": 5}
+		],
+		"viewer": {"name": "carol", "greeting": "welcome back"},
+		"version": "v1"
+	}`), internal.AsJSON(val))
+
+	// Resolvers without a mock are never called.
+	q = graphql.MustParse(`{ uptime }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	_, err = e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no mock for Query.uptime")
+}
@@ -0,0 +1,165 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// ndjsonChunkSize is the number of elements ExecuteNDJSON resolves at a time.
+const ndjsonChunkSize = 100
+
+// ExecuteNDJSON executes a query whose only root selection is a list field,
+// writing every element of the list to w as a line of JSON (newline-delimited
+// JSON).  Elements are resolved and written in chunks, so the full list is
+// never held in memory; a field returning an iterator (func() (T, bool,
+// error)) is pulled until it is exhausted, regardless of MaxItems.  This suits
+// exports of large amounts of data.
+//
+// Execution stops at the first error, after the elements of the preceding
+// chunks have been written.
+func (e *Executor) ExecuteNDJSON(ctx context.Context, w io.Writer, typ Type, source interface{}, query *Query) error {
+	queryObject, ok := typ.(*Object)
+	if !ok {
+		return fmt.Errorf("expected query or mutation object for execution, got: %s", typ.String())
+	}
+
+	return e.runExecution(ctx, queryObject, query, func(ctx context.Context) error {
+		return e.executeNDJSON(ctx, w, queryObject, source, query)
+	})
+}
+
+func (e *Executor) executeNDJSON(ctx context.Context, w io.Writer, queryObject *Object, source interface{}, query *Query) error {
+	selections, err := Flatten(query.SelectionSet)
+	if err != nil {
+		return err
+	}
+	if len(selections) != 1 {
+		return NewClientError("NDJSON output requires exactly one root selection, got %d", len(selections))
+	}
+	selection := selections[0]
+
+	field, ok := queryObject.Fields[selection.Name]
+	if !ok {
+		return fmt.Errorf("invalid top-level selection %q", selection.Name)
+	}
+	listType := field.Type
+	if nonNull, ok := listType.(*NonNull); ok {
+		listType = nonNull.Type
+	}
+	list, ok := listType.(*List)
+	if !ok {
+		return NewClientError("NDJSON output requires a list field, but %s is %s", selection.Name, field.Type)
+	}
+
+	// The root field is checked like the fields resolved by work units.
+	if err := checkAuthorized(ctx, executionInfoFromContext(ctx).authorize, field, source); err != nil {
+		return nestPathError(selection.Alias, err)
+	}
+
+	unit := &WorkUnit{
+		Ctx:        ctx,
+		field:      field,
+		sources:    []interface{}{source},
+		selection:  selection,
+		objectName: queryObject.Name,
+	}
+	var result interface{}
+	err = callResolver(ctx, unit, nil, func(ctx context.Context) error {
+		if field.Resolve == nil {
+			results, err := SafeExecuteBatchResolver(ctx, field, unit.sources, selection.Args, selection.SelectionSet)
+			if err != nil {
+				return err
+			}
+			result = results[0]
+			return nil
+		}
+		result, err = executeResolverWithFallback(ctx, field, source, selection.Args, selection.SelectionSet)
+		return err
+	})
+	if err == nil {
+		result, err = processResult(field, result)
+	}
+	if err != nil {
+		return nestPathError(selection.Alias, err)
+	}
+
+	next, err := listElements(result)
+	if err != nil {
+		return nestPathError(selection.Alias, err)
+	}
+
+	root := newSelectionOutputNode(newTopLevelOutputNode(query.Name), selection)
+	encoder := json.NewEncoder(w)
+	for i := 0; ; {
+		// Resolve the elements in chunks, so that the fields of the elements
+		// of a chunk are resolved together, e.g. in one call of a batch field.
+		var items []interface{}
+		var dests []*outputNode
+		done := false
+		for len(items) < ndjsonChunkSize {
+			item, ok, err := next()
+			if err != nil {
+				return nestPathError(selection.Alias, err)
+			}
+			if !ok {
+				done = true
+				break
+			}
+			items = append(items, item)
+			dests = append(dests, newOutputNode(root, strconv.Itoa(i)))
+			i++
+		}
+
+		if len(items) > 0 {
+			units, err := resolveBatch(ctx, items, list.Type, selection.SelectionSet, dests)
+			if err != nil {
+				return nestPathErrorMulti(root.getPath(), err)
+			}
+			e.scheduler.Run(e.executionResolver(ctx, len(units)), units...)
+			if err := root.errRecorder.get(); err != nil {
+				return err
+			}
+
+			for _, dest := range dests {
+				if err := encoder.Encode(outputNodeToJSON(dest)); err != nil {
+					return err
+				}
+			}
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// listElements returns an iterator over a resolved list, which is either a
+// slice or an iterator function.
+func listElements(list interface{}) (Iterator, error) {
+	value := reflect.ValueOf(list)
+	if isIterator(value) {
+		if value.IsNil() {
+			return func() (interface{}, bool, error) { return nil, false, nil }, nil
+		}
+		return iteratorFunc(value), nil
+	}
+
+	if !value.IsValid() {
+		return func() (interface{}, bool, error) { return nil, false, nil }, nil
+	}
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a list, got %T", list)
+	}
+	i := 0
+	return func() (interface{}, bool, error) {
+		if i >= value.Len() {
+			return nil, false, nil
+		}
+		item := value.Index(i).Interface()
+		i++
+		return item, true, nil
+	}, nil
+}
@@ -0,0 +1,162 @@
+package graphql_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/samsarahq/thunder/batch"
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteNDJSON(t *testing.T) {
+	type User struct {
+		Id   int64
+		Name string
+	}
+
+	builder := schemabuilder.NewSchema()
+	query := builder.Query()
+	query.FieldFunc("users", func() []*User {
+		return []*User{{Id: 1, Name: "alice"}, {Id: 2, Name: "bob"}}
+	})
+	query.FieldFunc("rows", func() func() (int64, bool, error) {
+		i := int64(0)
+		return func() (int64, bool, error) {
+			i++
+			return i, i <= 3, nil
+		}
+	})
+	user := builder.Object("User", User{})
+	user.FieldFunc("greeting", func(u *User) string {
+		return "hi " + u.Name
+	}, schemabuilder.Expensive)
+	schema := builder.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler()).(*graphql.Executor)
+
+	for _, tt := range []struct {
+		name    string
+		query   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "objects",
+			query: `{ users { id greeting } }`,
+			want:  "{\"greeting\":\"hi alice\",\"id\":1}\n{\"greeting\":\"hi bob\",\"id\":2}\n",
+		},
+		{
+			name:  "iterator",
+			query: `{ rows }`,
+			want:  "1\n2\n3\n",
+		},
+		{
+			name:    "multiple root selections",
+			query:   `{ rows users { id } }`,
+			wantErr: "NDJSON output requires exactly one root selection, got 2",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			q := graphql.MustParse(tt.query, nil)
+			require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+			var buf bytes.Buffer
+			err := e.ExecuteNDJSON(context.Background(), &buf, schema.Query, nil, q)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestExecuteNDJSONBatches(t *testing.T) {
+	type Row struct {
+		Id int64
+	}
+
+	var batchSizes []int
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("rows", func() func() (*Row, bool, error) {
+		i := int64(0)
+		return func() (*Row, bool, error) {
+			i++
+			return &Row{Id: i}, i <= 250, nil
+		}
+	})
+	builder.Object("Row", Row{}).BatchFieldFunc("double", func(ctx context.Context, rows map[batch.Index]*Row) (map[batch.Index]int64, error) {
+		batchSizes = append(batchSizes, len(rows))
+		doubles := make(map[batch.Index]int64, len(rows))
+		for i, row := range rows {
+			doubles[i] = row.Id * 2
+		}
+		return doubles, nil
+	})
+	schema := builder.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler()).(*graphql.Executor)
+	q := graphql.MustParse(`{ rows { double } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	var buf bytes.Buffer
+	require.NoError(t, e.ExecuteNDJSON(context.Background(), &buf, schema.Query, nil, q))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 250)
+	assert.Equal(t, `{"double":2}`, lines[0])
+	assert.Equal(t, `{"double":500}`, lines[249])
+
+	// The rows are resolved in chunks, rather than one at a time.
+	assert.Equal(t, []int{100, 100, 50}, batchSizes)
+}
+
+func TestExecuteNDJSONAuthorize(t *testing.T) {
+	var resolved bool
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("ids", func() []int64 {
+		resolved = true
+		return []int64{1, 2}
+	})
+	schema := builder.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithAuthorizer(func(ctx context.Context, field *graphql.Field, source interface{}) error {
+		return errors.New("denied")
+	})).(*graphql.Executor)
+
+	q := graphql.MustParse(`{ ids }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	// The root field is checked before it is resolved.
+	var buf bytes.Buffer
+	err := e.ExecuteNDJSON(context.Background(), &buf, schema.Query, nil, q)
+	assert.EqualError(t, err, "ids: denied")
+	assert.False(t, resolved)
+	assert.Empty(t, buf.String())
+}
+
+func TestExecuteNDJSONUnauthenticated(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("ids", func() []int64 {
+		return []int64{1, 2}
+	})
+	schema := builder.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithPublicIntrospection(func(ctx context.Context) bool {
+		return false
+	})).(*graphql.Executor)
+
+	q := graphql.MustParse(`{ ids }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	var buf bytes.Buffer
+	err := e.ExecuteNDJSON(context.Background(), &buf, schema.Query, nil, q)
+	assert.Equal(t, graphql.ErrUnauthenticated, err)
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,85 @@
+package graphql
+
+import (
+	"context"
+	"time"
+)
+
+// OperationConfig is the configuration of a single execution, which the
+// operation's directives may adjust.
+type OperationConfig struct {
+	// Timeout bounds the execution of the operation.  Zero means no timeout.
+	Timeout time.Duration
+}
+
+// OperationDirectiveFunc applies a directive on the operation, such as
+// "query @timeout(ms: 100)", to the operation's configuration.
+type OperationDirectiveFunc func(config *OperationConfig, args map[string]interface{}) error
+
+// WithOperationTimeout sets the default timeout for executing an operation.
+// Operations may shorten it, but not extend it, with the @timeout(ms:)
+// directive.
+func WithOperationTimeout(timeout time.Duration) ExecutorOption {
+	return func(e *Executor) {
+		e.operationTimeout = timeout
+	}
+}
+
+// WithOperationDirective registers a handler for an operation directive.
+// Operation directives without a handler are ignored.  @timeout is handled by
+// default.
+func WithOperationDirective(name string, apply OperationDirectiveFunc) ExecutorOption {
+	return func(e *Executor) {
+		e.operationDirectives[name] = apply
+	}
+}
+
+// maxTimeoutDirectiveMs is the largest timeout, in milliseconds, that
+// @timeout(ms:) accepts.
+const maxTimeoutDirectiveMs = float64(time.Hour / time.Millisecond)
+
+// timeoutDirective handles @timeout(ms: Int), which shortens the executor's
+// default operation timeout.  A timeout longer than the default is clamped to
+// it, so that clients can't extend the server's time budget.
+func timeoutDirective(config *OperationConfig, args map[string]interface{}) error {
+	ms, ok := args["ms"].(float64)
+	if !ok || ms <= 0 {
+		return NewClientError("@timeout expects a positive ms argument")
+	}
+	if ms > maxTimeoutDirectiveMs {
+		return NewClientError("@timeout ms argument exceeds the maximum of %d", int64(maxTimeoutDirectiveMs))
+	}
+	timeout := time.Duration(ms) * time.Millisecond
+	if config.Timeout == 0 || timeout < config.Timeout {
+		config.Timeout = timeout
+	}
+	return nil
+}
+
+// operationConfig builds the configuration for executing the query, applying
+// any recognized operation directives on top of the executor's defaults.
+func (e *Executor) operationConfig(query *Query) (*OperationConfig, error) {
+	config := &OperationConfig{
+		Timeout: e.operationTimeout,
+	}
+	for _, directive := range query.Directives {
+		apply, ok := e.operationDirectives[directive.Name]
+		if !ok {
+			continue
+		}
+		args, _ := directive.Args.(map[string]interface{})
+		if err := apply(config, args); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// withOperationConfig applies the operation configuration to the context of
+// the execution.
+func withOperationConfig(ctx context.Context, config *OperationConfig) (context.Context, context.CancelFunc) {
+	if config.Timeout > 0 {
+		return context.WithTimeout(ctx, config.Timeout)
+	}
+	return ctx, func() {}
+}
@@ -0,0 +1,127 @@
+package graphql_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperationTimeoutDirective(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("slow", func(ctx context.Context) (string, error) {
+		select {
+		case <-time.After(50 * time.Millisecond):
+			return "done", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	})
+	schema := builder.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithOperationTimeout(10*time.Millisecond))
+
+	for _, tt := range []struct {
+		name    string
+		query   string
+		wantErr string
+	}{
+		{name: "default timeout", query: `{ slow }`, wantErr: "operation timed out"},
+		{name: "directive is clamped to the default timeout", query: `query @timeout(ms: 10000) { slow }`, wantErr: "operation timed out"},
+		{name: "directive shortens timeout", query: `query @timeout(ms: 1) { slow }`, wantErr: "operation timed out"},
+		{name: "invalid directive", query: `query @timeout(ms: -1) { slow }`, wantErr: "@timeout expects a positive ms argument"},
+		{name: "directive over the maximum", query: `query @timeout(ms: 1e300) { slow }`, wantErr: "@timeout ms argument exceeds the maximum of 3600000"},
+		{name: "unknown directive", query: `query @unknown { slow }`, wantErr: "operation timed out"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			q := graphql.MustParse(tt.query, nil)
+			require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+			res, err := e.Execute(context.Background(), schema.Query, nil, q)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{"slow": "done"}, internal.AsJSON(res))
+		})
+	}
+}
+
+func TestOperationTimeoutDirectiveWithoutDefault(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("deadline", func(ctx context.Context) bool {
+		_, ok := ctx.Deadline()
+		return ok
+	})
+	schema := builder.MustBuild()
+
+	// Without a default timeout, @timeout still bounds the operation.
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	q := graphql.MustParse(`query @timeout(ms: 10000) { deadline }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"deadline": true}, internal.AsJSON(res))
+}
+
+func TestOperationTimeoutPartialResult(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("fast", func() string {
+		return "fast"
+	})
+	builder.Query().FieldFunc("slow", func(ctx context.Context) (string, error) {
+		select {
+		case <-time.After(time.Second):
+			return "slow", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	})
+	schema := builder.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithOperationTimeout(20*time.Millisecond))
+	q := graphql.MustParse(`{ fast slow }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	assert.EqualError(t, err, "operation timed out")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, internal.ParseJSON(`{"fast": "fast", "slow": null}`), internal.AsJSON(res))
+}
+
+func TestCustomOperationDirective(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("deadline", func(ctx context.Context) bool {
+		_, ok := ctx.Deadline()
+		return ok
+	})
+	schema := builder.MustBuild()
+
+	// A directive that disables the default timeout.
+	e := graphql.NewExecutor(
+		graphql.NewImmediateGoroutineScheduler(),
+		graphql.WithOperationTimeout(time.Minute),
+		graphql.WithOperationDirective("noTimeout", func(config *graphql.OperationConfig, args map[string]interface{}) error {
+			config.Timeout = 0
+			return nil
+		}),
+	)
+
+	for query, want := range map[string]bool{
+		`{ deadline }`:                  true,
+		`query @noTimeout { deadline }`: false,
+	} {
+		q := graphql.MustParse(query, nil)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+		res, err := e.Execute(context.Background(), schema.Query, nil, q)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"deadline": want}, internal.AsJSON(res), query)
+	}
+}
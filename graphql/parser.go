@@ -3,8 +3,10 @@ package graphql
 import (
 	"reflect"
 	"strconv"
+	"strings"
 
 	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/location"
 	"github.com/graphql-go/graphql/language/parser"
 )
 
@@ -113,6 +115,7 @@ func parseSelectionSet(input *ast.SelectionSet, globalFragments map[string]*Frag
 
 	var selections []*Selection
 	var fragments []*Fragment
+	var fragmentOffsets []int
 	for _, selection := range input.Selections {
 		switch selection := selection.(type) {
 		case *ast.Field:
@@ -142,6 +145,10 @@ func parseSelectionSet(input *ast.SelectionSet, globalFragments map[string]*Frag
 				UnparsedArgs: args,
 				SelectionSet: selectionSet,
 			}
+			if selection.Loc != nil {
+				loc := location.GetLocation(selection.Loc.Source, selection.Loc.Start)
+				newSelection.Locations = []Location{{Line: loc.Line, Column: loc.Column}}
+			}
 
 			if len(selection.Directives) > 0 {
 				newSelection.Directives = directives
@@ -165,6 +172,7 @@ func parseSelectionSet(input *ast.SelectionSet, globalFragments map[string]*Frag
 			}
 
 			fragments = append(fragments, fragment)
+			fragmentOffsets = append(fragmentOffsets, len(selections))
 
 		case *ast.InlineFragment:
 			on := selection.TypeCondition.Name.Value
@@ -189,6 +197,7 @@ func parseSelectionSet(input *ast.SelectionSet, globalFragments map[string]*Frag
 			}
 
 			fragments = append(fragments, newFragment)
+			fragmentOffsets = append(fragmentOffsets, len(selections))
 		}
 	}
 
@@ -196,9 +205,23 @@ func parseSelectionSet(input *ast.SelectionSet, globalFragments map[string]*Frag
 		Selections: selections,
 		Fragments:  fragments,
 	}
+	// Only keep the offsets if a fragment precedes a selection, as Flatten
+	// otherwise visits the fragments after the selections anyway.
+	if len(fragmentOffsets) > 0 && fragmentOffsets[0] < len(selections) {
+		selectionSet.fragmentOffsets = fragmentOffsets
+	}
 	return selectionSet, nil
 }
 
+// addFragment adds fragment, the i-th fragment of from, to a selection set
+// with the same selections as from, at the same offset.
+func (s *SelectionSet) addFragment(from *SelectionSet, i int, fragment *Fragment) {
+	s.Fragments = append(s.Fragments, fragment)
+	if i < len(from.fragmentOffsets) {
+		s.fragmentOffsets = append(s.fragmentOffsets, from.fragmentOffsets[i])
+	}
+}
+
 type visitState int
 
 const (
@@ -235,18 +258,21 @@ func detectCyclesAndUnusedFragments(selectionSet *SelectionSet, globalFragments
 		return nil
 	}
 
+	var path []*Fragment
 	visitFragment = func(fragment *Fragment) error {
 		switch state[fragment] {
 		case visiting:
-			return NewClientError("fragment contains itself")
+			return fragmentCycleError(append(path, fragment), fragment.SelectionSet)
 		case visited:
 			return nil
 		}
 
 		state[fragment] = visiting
+		path = append(path, fragment)
 		if err := visitSelectionSet(fragment.SelectionSet); err != nil {
 			return err
 		}
+		path = path[:len(path)-1]
 		state[fragment] = visited
 
 		return nil
@@ -264,6 +290,31 @@ func detectCyclesAndUnusedFragments(selectionSet *SelectionSet, globalFragments
 	return nil
 }
 
+// fragmentCycleError returns an error naming the fragments in a cycle.  The
+// last fragment in path spreads selectionSet, which is already being visited.
+func fragmentCycleError(path []*Fragment, selectionSet *SelectionSet) error {
+	start := 0
+	for i, fragment := range path {
+		if fragment.SelectionSet == selectionSet {
+			start = i
+			break
+		}
+	}
+	names := make([]string, 0, len(path)-start)
+	for _, fragment := range path[start:] {
+		names = append(names, fragmentName(fragment))
+	}
+	return NewClientError("fragment spreads must not form cycles: %s", strings.Join(names, " -> "))
+}
+
+// fragmentName returns the name of a fragment for error messages.
+func fragmentName(fragment *Fragment) string {
+	if fragment.Name != "" {
+		return fragment.Name
+	}
+	return "... on " + fragment.On
+}
+
 // detectConflicts finds conflicts
 //
 // Conflicts are selections that can not be merged, for example
@@ -284,9 +335,17 @@ func detectConflicts(selectionSet *SelectionSet) error {
 		state[selectionSet] = visited
 
 		selections := make(map[string]*Selection)
+		// A fragment spread several times only adds its selections once, so
+		// that nested spreads don't expand exponentially.
+		spread := make(map[*SelectionSet]bool)
 
 		var visitSibling func(*SelectionSet) error
 		visitSibling = func(selectionSet *SelectionSet) error {
+			if spread[selectionSet] {
+				return nil
+			}
+			spread[selectionSet] = true
+
 			for _, selection := range selectionSet.Selections {
 				if other, found := selections[selection.Alias]; found {
 					if other.Name != selection.Name {
@@ -319,9 +378,77 @@ func detectConflicts(selectionSet *SelectionSet) error {
 	return visitChild(selectionSet)
 }
 
+// checkFragmentSpreads verifies that the selection set spreads at most max
+// fragments, counting every spread as Flatten would process it (so a fragment
+// spread twice counts twice, as do the spreads nested inside of it).
+//
+// Counting stops as soon as the limit is exceeded, so the check itself is
+// bounded even for queries that would otherwise expand exponentially.
+func checkFragmentSpreads(selectionSet *SelectionSet, max int) error {
+	count := 0
+
+	var visit func(*SelectionSet) error
+	visit = func(selectionSet *SelectionSet) error {
+		if selectionSet == nil {
+			return nil
+		}
+
+		for _, selection := range selectionSet.Selections {
+			if err := visit(selection.SelectionSet); err != nil {
+				return err
+			}
+		}
+
+		for _, fragment := range selectionSet.Fragments {
+			count++
+			if count > max {
+				return NewClientError("too many fragment spreads: query exceeds the maximum of %d", max)
+			}
+			if err := visit(fragment.SelectionSet); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return visit(selectionSet)
+}
+
+// checkDepth returns an error naming the first path in selectionSet that
+// nests more than max selection sets deep.  Fragments are followed as if
+// their selections were spread inline.
+func checkDepth(selectionSet *SelectionSet, max int) error {
+	var visit func(selectionSet *SelectionSet, path []string) error
+	visit = func(selectionSet *SelectionSet, path []string) error {
+		selections, err := Flatten(selectionSet)
+		if err != nil {
+			return err
+		}
+		for _, selection := range selections {
+			if selection.SelectionSet == nil {
+				continue
+			}
+			path := append(path[:len(path):len(path)], selection.Alias)
+			if len(path) > max {
+				return NewClientError("query is too deep: %s exceeds the maximum depth of %d", strings.Join(path, "."), max)
+			}
+			if err := visit(selection.SelectionSet, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return visit(selectionSet, nil)
+}
+
 type Query struct {
 	Name string
 	Kind string
+	// Directives are the directives on the operation itself, e.g.
+	// "query @timeout(ms: 100) { ... }".
+	Directives []*Directive
 	*SelectionSet
 }
 
@@ -332,11 +459,25 @@ type Query struct {
 // does not validate that the query is legal under a given schema, which
 // instead is done by PrepareQuery.
 func Parse(source string, vars map[string]interface{}) (*Query, error) {
+	document, err := parseDocument(source)
+	if err != nil {
+		return nil, err
+	}
+	return parseQuery(document, vars)
+}
+
+// parseDocument parses the syntax of a GraphQL string.
+func parseDocument(source string) (*ast.Document, error) {
 	document, err := parser.Parse(parser.ParseParams{Source: source})
 	if err != nil {
 		return nil, NewClientError(err.Error())
 	}
+	return document, nil
+}
 
+// parseQuery converts a parsed document into a *Query, with the values of vars
+// filled in.  See Parse.
+func parseQuery(document *ast.Document, vars map[string]interface{}) (*Query, error) {
 	var queryDefinition *ast.OperationDefinition
 	fragmentDefinitions := make(map[string]*ast.FragmentDefinition)
 
@@ -350,8 +491,8 @@ func Parse(source string, vars map[string]interface{}) (*Query, error) {
 			fragmentDefinitions[name] = definition
 
 		case *ast.OperationDefinition:
-			if definition.Operation != "query" && definition.Operation != "mutation" {
-				return nil, NewClientError("only support queries or mutations")
+			if definition.Operation != "query" && definition.Operation != "mutation" && definition.Operation != "subscription" {
+				return nil, NewClientError("only support queries, mutations or subscriptions")
 			}
 			if queryDefinition != nil {
 				return nil, NewClientError("only support a single query")
@@ -379,52 +520,17 @@ func Parse(source string, vars map[string]interface{}) (*Query, error) {
 		SelectionSet: nil,
 	}
 
-	// Parse variable definitions, default values, etc.
-	var defaultedVars map[string]interface{}
-	for _, variableDefinition := range queryDefinition.VariableDefinitions {
-		name := variableDefinition.Variable.Name.Value
-
-		if _, ok := variableDefinition.Type.(*ast.NonNull); ok {
-			if variableDefinition.DefaultValue != nil {
-				return rv, NewClientError("required variable cannot provide a default value: $%s", name)
-			}
-
-			continue
-		}
-
-		if variableDefinition.DefaultValue != nil {
-			// Ignore default if the value exists.
-			if vars[name] != nil {
-				continue
-			}
-
-			// Lazily initialize defaultedVars if needed.
-			if defaultedVars == nil {
-				defaultedVars = make(map[string]interface{})
-				for k, v := range vars {
-					defaultedVars[k] = v
-				}
-			}
-
-			// TODO: properly implement coerceValue.
-			// See: https://github.com/graphql/graphql-js/blob/17a0bfd5292f39cafe4eec5b3bd0e22514243b68/src/execution/values.js#L84
-			val, err := valueToJson(variableDefinition.DefaultValue, nil)
-			if err != nil {
-				return rv, NewClientError("failed to parse default value: %s", err.Error())
-			}
-
-			defaultedVars[name] = val
-		}
-	}
-
-	if defaultedVars != nil {
-		vars = defaultedVars
+	// Coerce the variables to their declared types, and fill in defaults.
+	vars, err := coerceVariables(queryDefinition.VariableDefinitions, vars)
+	if err != nil {
+		return rv, err
 	}
 
 	globalFragments := make(map[string]*Fragment)
 	for name, fragment := range fragmentDefinitions {
 		globalFragments[name] = &Fragment{
-			On: fragment.TypeCondition.Name.Value,
+			Name: name,
+			On:   fragment.TypeCondition.Name.Value,
 		}
 	}
 
@@ -449,6 +555,14 @@ func Parse(source string, vars map[string]interface{}) (*Query, error) {
 		return rv, err
 	}
 
+	if len(queryDefinition.Directives) > 0 {
+		directives, err := parseDirectives(queryDefinition.Directives, vars)
+		if err != nil {
+			return rv, err
+		}
+		rv.Directives = directives
+	}
+
 	rv.SelectionSet = selectionSet
 
 	return rv, nil
@@ -481,31 +595,76 @@ func MustParse(source string, vars map[string]interface{}) *Query {
 //
 // Flatten does _not_ flatten out the inner queries, so the name above does not
 // get flattened out yet.
+//
+// The selections are returned in the order their aliases first appear in the
+// query source, including those of fragments, so that e.g. the root fields of
+// a mutation run in source order.
+// Selections and fragments excluded by a @skip or @include directive
+// are dropped, while fragments with a @defer directive are flattened like any
+// other (see ExecuteIncremental).  Fragment spreads that form a cycle are
+// rejected with an error.
 func Flatten(selectionSet *SelectionSet) ([]*Selection, error) {
 	grouped := make(map[string][]*Selection)
+	// aliases holds the aliases in the order they first appear in.
+	var aliases []string
 
 	state := make(map[*SelectionSet]visitState)
+	// path holds the fragments being expanded, to report cycles.
+	var path []*Fragment
 	var visit func(*SelectionSet) error
 	visit = func(selectionSet *SelectionSet) error {
-		if state[selectionSet] == visited {
+		switch state[selectionSet] {
+		case visiting:
+			return fragmentCycleError(path, selectionSet)
+		case visited:
 			return nil
 		}
-
-		for _, selection := range selectionSet.Selections {
-			grouped[selection.Alias] = append(grouped[selection.Alias], selection)
+		state[selectionSet] = visiting
+
+		// next is the index of the next selection to visit.  Selections are
+		// visited up to the offset of every fragment before the fragment, so
+		// that they are visited in source order.
+		next := 0
+		visitSelections := func(end int) error {
+			for ; next < end; next++ {
+				selection := selectionSet.Selections[next]
+				ok, err := ShouldIncludeNode(selection.Directives)
+				if err != nil {
+					return err
+				}
+				if ok {
+					if _, ok := grouped[selection.Alias]; !ok {
+						aliases = append(aliases, selection.Alias)
+					}
+					grouped[selection.Alias] = append(grouped[selection.Alias], selection)
+				}
+			}
+			return nil
 		}
 
-		for _, fragment := range selectionSet.Fragments {
+		for i, fragment := range selectionSet.Fragments {
+			offset := len(selectionSet.Selections)
+			if i < len(selectionSet.fragmentOffsets) {
+				offset = selectionSet.fragmentOffsets[i]
+			}
+			if err := visitSelections(offset); err != nil {
+				return err
+			}
 			ok, err := ShouldIncludeNode(fragment.Directives)
 			if err != nil {
 				return err
 			}
 			if ok {
+				path = append(path, fragment)
 				if err := visit(fragment.SelectionSet); err != nil {
 					return err
 				}
+				path = path[:len(path)-1]
 			}
 		}
+		if err := visitSelections(len(selectionSet.Selections)); err != nil {
+			return err
+		}
 
 		state[selectionSet] = visited
 		return nil
@@ -516,16 +675,19 @@ func Flatten(selectionSet *SelectionSet) ([]*Selection, error) {
 	}
 
 	var flattened []*Selection
-	for _, selections := range grouped {
+	for _, alias := range aliases {
+		selections := grouped[alias]
 		if len(selections) == 1 || selections[0].SelectionSet == nil {
 			flattened = append(flattened, selections[0])
 			continue
 		}
 
 		merged := &SelectionSet{}
+		var locations []Location
 		for _, selection := range selections {
 			merged.Selections = append(merged.Selections, selection.SelectionSet.Selections...)
 			merged.Fragments = append(merged.Fragments, selection.SelectionSet.Fragments...)
+			locations = append(locations, selection.Locations...)
 		}
 
 		flattened = append(flattened, &Selection{
@@ -535,6 +697,7 @@ func Flatten(selectionSet *SelectionSet) ([]*Selection, error) {
 			UnparsedArgs: selections[0].UnparsedArgs,
 			Args:         selections[0].Args,
 			SelectionSet: merged,
+			Locations:    locations,
 		})
 	}
 
@@ -43,17 +43,20 @@ fragment Bar on Foo {
 					Name:         "foo",
 					Alias:        "foo",
 					UnparsedArgs: map[string]interface{}{},
+					Locations:    []Location{{Line: 3, Column: 2}},
 					SelectionSet: &SelectionSet{
 						Selections: []*Selection{
 							{
 								Name:         "bar",
 								Alias:        "alias",
 								UnparsedArgs: map[string]interface{}{},
+								Locations:    []Location{{Line: 4, Column: 3}},
 							},
 							{
 								Name:         "bar",
 								Alias:        "alias",
 								UnparsedArgs: map[string]interface{}{},
+								Locations:    []Location{{Line: 5, Column: 3}},
 							},
 							{
 								Name:  "baz",
@@ -61,6 +64,7 @@ fragment Bar on Foo {
 								UnparsedArgs: map[string]interface{}{
 									"arg": float64(3),
 								},
+								Locations: []Location{{Line: 6, Column: 3}},
 								SelectionSet: &SelectionSet{
 									Selections: []*Selection{
 										{
@@ -71,6 +75,7 @@ fragment Bar on Foo {
 												"y": "123",
 												"z": true,
 											},
+											Locations: []Location{{Line: 7, Column: 4}},
 										},
 										{
 											Name:  "hum",
@@ -84,6 +89,7 @@ fragment Bar on Foo {
 													[]interface{}{float64(4), float64(5)},
 												},
 											},
+											Locations: []Location{{Line: 8, Column: 4}},
 										},
 									},
 								},
@@ -98,17 +104,20 @@ fragment Bar on Foo {
 											Name:         "asd",
 											Alias:        "asd",
 											UnparsedArgs: map[string]interface{}{},
+											Locations:    []Location{{Line: 11, Column: 4}},
 										},
 									},
 									Fragments: []*Fragment{
 										{
-											On: "Foo",
+											Name: "Bar",
+											On:   "Foo",
 											SelectionSet: &SelectionSet{
 												Selections: []*Selection{
 													{
 														Name:         "zxc",
 														Alias:        "zxc",
 														UnparsedArgs: map[string]interface{}{},
+														Locations:    []Location{{Line: 19, Column: 2}},
 													},
 												},
 											},
@@ -123,6 +132,7 @@ fragment Bar on Foo {
 					Name:         "xyz",
 					Alias:        "xyz",
 					UnparsedArgs: map[string]interface{}{},
+					Locations:    []Location{{Line: 15, Column: 2}},
 				},
 			},
 		},
@@ -152,6 +162,7 @@ mutation foo($var: bar) {
 					Name:         "baz",
 					Alias:        "baz",
 					UnparsedArgs: map[string]interface{}{},
+					Locations:    []Location{{Line: 3, Column: 2}},
 				},
 			},
 		},
@@ -223,10 +234,24 @@ func TestParseUnsupported(t *testing.T) {
 fragment foo on Foo {
 	... foo
 }`, map[string]interface{}{})
-	if err == nil || err.Error() != "fragment contains itself" {
+	if err == nil || err.Error() != "fragment spreads must not form cycles: foo -> foo" {
 		t.Error("expected fragment definition to fail", err)
 	}
 
+	_, err = Parse(`
+{
+	... foo
+}
+fragment foo on Foo {
+	... bar
+}
+fragment bar on Foo {
+	... foo
+}`, map[string]interface{}{})
+	if err == nil || err.Error() != "fragment spreads must not form cycles: foo -> bar -> foo" {
+		t.Error("expected mutually recursive fragments to fail", err)
+	}
+
 	_, err = Parse(`
 {
 	bar
@@ -272,3 +297,114 @@ query Operation($x: int64 = 2) {
 		t.Errorf("expected 2, received %v", val)
 	}
 }
+
+func TestParseCoerceVariables(t *testing.T) {
+	for _, testCase := range []struct {
+		name     string
+		query    string
+		vars     map[string]interface{}
+		expected interface{}
+		err      string
+	}{
+		{
+			name:     "provided value",
+			query:    `query Operation($x: Int = 2) { field(x: $x) }`,
+			vars:     map[string]interface{}{"x": float64(3)},
+			expected: float64(3),
+		},
+		{
+			name:     "null value is defaulted",
+			query:    `query Operation($x: Int = 2) { field(x: $x) }`,
+			vars:     map[string]interface{}{"x": nil},
+			expected: float64(2),
+		},
+		{
+			name:  "missing required variable",
+			query: `query Operation($x: Int!) { field(x: $x) }`,
+			vars:  map[string]interface{}{},
+			err:   "missing required variable $x of type Int!",
+		},
+		{
+			name:  "null required variable",
+			query: `query Operation($x: String!) { field(x: $x) }`,
+			vars:  map[string]interface{}{"x": nil},
+			err:   "bad value for variable $x of type String!: must not be null",
+		},
+		{
+			name:  "wrong type",
+			query: `query Operation($x: Int) { field(x: $x) }`,
+			vars:  map[string]interface{}{"x": "three"},
+			err:   "bad value for variable $x of type Int: not a valid Int",
+		},
+		{
+			name:  "fractional int",
+			query: `query Operation($x: Int) { field(x: $x) }`,
+			vars:  map[string]interface{}{"x": 1.5},
+			err:   "bad value for variable $x of type Int: not a valid Int",
+		},
+		{
+			name:     "list",
+			query:    `query Operation($x: [Int!]!) { field(x: $x) }`,
+			vars:     map[string]interface{}{"x": []interface{}{float64(1), float64(2)}},
+			expected: []interface{}{float64(1), float64(2)},
+		},
+		{
+			name:     "single value for a list",
+			query:    `query Operation($x: [Int!]!) { field(x: $x) }`,
+			vars:     map[string]interface{}{"x": float64(1)},
+			expected: []interface{}{float64(1)},
+		},
+		{
+			name:  "null list element",
+			query: `query Operation($x: [Int!]!) { field(x: $x) }`,
+			vars:  map[string]interface{}{"x": []interface{}{float64(1), nil}},
+			err:   "bad value for variable $x of type [Int!]!: 1: must not be null",
+		},
+		{
+			name:     "schema scalar",
+			query:    `query Operation($x: int64!) { field(x: $x) }`,
+			vars:     map[string]interface{}{"x": float64(1)},
+			expected: float64(1),
+		},
+		{
+			name:  "wrong type for schema scalar",
+			query: `query Operation($x: int64!) { field(x: $x) }`,
+			vars:  map[string]interface{}{"x": "1"},
+			err:   "bad value for variable $x of type int64!: not a valid int64",
+		},
+		{
+			name:  "out of range schema scalar",
+			query: `query Operation($x: uint8) { field(x: $x) }`,
+			vars:  map[string]interface{}{"x": float64(256)},
+			err:   "bad value for variable $x of type uint8: not a valid uint8",
+		},
+		{
+			name:  "bad time",
+			query: `query Operation($x: Time) { field(x: $x) }`,
+			vars:  map[string]interface{}{"x": "yesterday"},
+			err:   "bad value for variable $x of type Time: not a valid Time",
+		},
+		{
+			name:     "unknown scalar",
+			query:    `query Operation($x: Money) { field(x: $x) }`,
+			vars:     map[string]interface{}{"x": "$1"},
+			expected: "$1",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			query, err := Parse(testCase.query, testCase.vars)
+			if testCase.err != "" {
+				if err == nil || err.Error() != testCase.err {
+					t.Errorf("expected error %q, received %v", testCase.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+			if val := query.SelectionSet.Selections[0].UnparsedArgs["x"]; !reflect.DeepEqual(val, testCase.expected) {
+				t.Errorf("expected %v, received %v", testCase.expected, val)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// ErrPersistedQueryNotFound is returned for requests that send only the hash
+// of a query that is not in the PersistedQueryStore.  Clients using Automatic
+// Persisted Queries then retry with the full query to register it.
+var ErrPersistedQueryNotFound error = &PresentedError{
+	FormattedError: FormattedError{
+		Message:    "PersistedQueryNotFound",
+		Extensions: map[string]interface{}{"code": "PERSISTED_QUERY_NOT_FOUND"},
+	},
+	err: NewClientError("PersistedQueryNotFound"),
+}
+
+// A PersistedQuery is a query registered under the SHA-256 hash of its text.
+type PersistedQuery struct {
+	// Query is the text of the query.
+	Query string
+
+	// document is the parsed query, if it was parsed when registered, so
+	// that it need not be parsed for every request.
+	document *ast.Document
+}
+
+// Parse parses the query with the values of vars filled in, like Parse.
+func (q *PersistedQuery) Parse(vars map[string]interface{}) (*Query, error) {
+	document := q.document
+	if document == nil {
+		var err error
+		if document, err = parseDocument(q.Query); err != nil {
+			return nil, err
+		}
+	}
+	return parseQuery(document, vars)
+}
+
+// A PersistedQueryStore holds the queries registered with Automatic
+// Persisted Queries, keyed by their hash.  Implementations must be safe for
+// concurrent use.  Stores that keep queries in-process should hold on to the
+// PersistedQuery passed to Put, whose query is already parsed; others may
+// return a new PersistedQuery with just the Query text.
+type PersistedQueryStore interface {
+	Get(ctx context.Context, hash string) (*PersistedQuery, bool)
+	Put(ctx context.Context, hash string, query *PersistedQuery)
+}
+
+// NewPersistedQueryCache returns an in-memory PersistedQueryStore holding up
+// to max queries.  Once it is full, the oldest queries are evicted first.
+func NewPersistedQueryCache(max int) PersistedQueryStore {
+	return &persistedQueryCache{
+		max:     max,
+		queries: make(map[string]*PersistedQuery),
+	}
+}
+
+type persistedQueryCache struct {
+	max int
+
+	mu      sync.Mutex
+	queries map[string]*PersistedQuery
+	// order holds the hashes of the queries, oldest first.
+	order []string
+}
+
+func (c *persistedQueryCache) Get(ctx context.Context, hash string) (*PersistedQuery, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	query, ok := c.queries[hash]
+	return query, ok
+}
+
+func (c *persistedQueryCache) Put(ctx context.Context, hash string, query *PersistedQuery) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.queries[hash]; ok {
+		return
+	}
+	for len(c.order) > 0 && len(c.order) >= c.max {
+		delete(c.queries, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.queries[hash] = query
+	c.order = append(c.order, hash)
+}
+
+// LoadPersistedQuery returns the query of a request using Automatic Persisted
+// Queries, which sends the SHA-256 hash of its query (in hex) along with
+// either the query, to register it, or nothing, to use the registered query.
+// Queries are only registered if they match the hash and are syntactically
+// valid.  If the hash is not registered, LoadPersistedQuery returns
+// ErrPersistedQueryNotFound.
+func LoadPersistedQuery(ctx context.Context, store PersistedQueryStore, hash string, query string) (*PersistedQuery, error) {
+	if query == "" {
+		persisted, ok := store.Get(ctx, hash)
+		if !ok {
+			return nil, ErrPersistedQueryNotFound
+		}
+		return persisted, nil
+	}
+
+	sum := sha256.Sum256([]byte(query))
+	if hex.EncodeToString(sum[:]) != hash {
+		return nil, NewClientError("provided sha does not match query")
+	}
+	document, err := parseDocument(query)
+	if err != nil {
+		return nil, err
+	}
+	persisted := &PersistedQuery{Query: query, document: document}
+	store.Put(ctx, hash, persisted)
+	return persisted, nil
+}
+
+// WithPersistedQueries supports Automatic Persisted Queries, registering and
+// looking up queries in store.  Requests send the hash of their query in the
+// persistedQuery extension, e.g.
+//
+//	{"extensions": {"persistedQuery": {"version": 1, "sha256Hash": "..."}}}
+func WithPersistedQueries(store PersistedQueryStore) HTTPHandlerOption {
+	return func(h *httpHandler) {
+		h.persistedQueries = store
+	}
+}
+
+// persistedQueryExtension is the persistedQuery extension of a request.
+type persistedQueryExtension struct {
+	Version    int    `json:"version"`
+	Sha256Hash string `json:"sha256Hash"`
+}
+
+// loadQuery returns the query of a request, from the handler's persisted
+// queries if the request uses them.
+func (h *httpHandler) loadQuery(ctx context.Context, params *httpPostBody) (*PersistedQuery, error) {
+	extension := params.Extensions.PersistedQuery
+	if h.persistedQueries == nil || extension == nil {
+		return &PersistedQuery{Query: params.Query}, nil
+	}
+	if extension.Version != 1 {
+		return nil, NewClientError("unsupported persisted query version: %d", extension.Version)
+	}
+	return LoadPersistedQuery(ctx, h.persistedQueries, extension.Sha256Hash, params.Query)
+}
@@ -0,0 +1,92 @@
+package graphql_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingQueryStore records the queries returned by a PersistedQueryStore.
+type recordingQueryStore struct {
+	graphql.PersistedQueryStore
+
+	mu   sync.Mutex
+	gets []*graphql.PersistedQuery
+	puts int
+}
+
+func (s *recordingQueryStore) Get(ctx context.Context, hash string) (*graphql.PersistedQuery, bool) {
+	query, ok := s.PersistedQueryStore.Get(ctx, hash)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gets = append(s.gets, query)
+	return query, ok
+}
+
+func (s *recordingQueryStore) Put(ctx context.Context, hash string, query *graphql.PersistedQuery) {
+	s.PersistedQueryStore.Put(ctx, hash, query)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.puts++
+}
+
+func TestHTTPPersistedQueries(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("mirror", func(args struct{ Value int64 }) int64 {
+		return args.Value * -1
+	})
+	builtSchema := schema.MustBuild()
+
+	store := &recordingQueryStore{PersistedQueryStore: graphql.NewPersistedQueryCache(10)}
+	handler := graphql.HTTPHandlerWithOptions(builtSchema, graphql.WithPersistedQueries(store))
+
+	query := `query Mirror($value: int64!) { mirror(value: $value) }`
+	sum := sha256.Sum256([]byte(query))
+	hash := hex.EncodeToString(sum[:])
+
+	request := func(query, hash string, value int64) string {
+		body, err := json.Marshal(map[string]interface{}{
+			"query":      query,
+			"variables":  map[string]interface{}{"value": value},
+			"extensions": map[string]interface{}{"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": hash}},
+		})
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("POST", "/graphql", bytes.NewReader(body)))
+		return rr.Body.String()
+	}
+
+	// The client first sends just the hash, which is not registered yet, and
+	// then registers the query along with it.
+	assert.Equal(t, `{"data":null,"errors":[{"message":"PersistedQueryNotFound","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}]}`, request("", hash, 1))
+	assert.Equal(t, `{"data":{"mirror":-1},"errors":null}`, request(query, hash, 1))
+	assert.Equal(t, 1, store.puts)
+
+	// Later requests only send the hash, and use the query parsed when it was
+	// registered, with their own variables.
+	assert.Equal(t, `{"data":{"mirror":-2},"errors":null}`, request("", hash, 2))
+	require.Len(t, store.gets, 2)
+	require.NotNil(t, store.gets[1])
+	assert.True(t, store.gets[1].IsParsed())
+	assert.Equal(t, 1, store.puts)
+
+	// The hash must match the query.
+	assert.Equal(t, `{"data":null,"errors":["provided sha does not match query"]}`, request(`{ mirror(value: 3) }`, hash, 3))
+	assert.Equal(t, 1, store.puts)
+
+	// Requests without the extension are unaffected.
+	body := `{"query": "{ mirror(value: 4) }"}`
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/graphql", bytes.NewReader([]byte(body))))
+	assert.Equal(t, `{"data":{"mirror":-4},"errors":null}`, rr.Body.String())
+}
@@ -0,0 +1,142 @@
+package graphql
+
+import "sort"
+
+// PruneSelectionSet returns the part of selectionSet, a selection on typ, that
+// a downstream service must return: the fields read directly from the source
+// objects, with fragments and duplicate fields merged.  Fields computed by
+// resolvers in this server (External fields, e.g. FieldFuncs) and __typename
+// are dropped, as are their sub-selections, while the fields they require
+// (see Field.Requires) are kept.  Selections on unions and
+// interfaces are returned as is, since their members may differ.
+//
+// PruneSelectionSet returns nil if nothing needs to be fetched.
+func PruneSelectionSet(selectionSet *SelectionSet, typ Type) (*SelectionSet, error) {
+	if selectionSet == nil {
+		return nil, nil
+	}
+
+	switch typ := typ.(type) {
+	case *NonNull:
+		return PruneSelectionSet(selectionSet, typ.Type)
+	case *List:
+		return PruneSelectionSet(selectionSet, typ.Type)
+	case *Object:
+		return pruneObjectSelectionSet(selectionSet, typ)
+	case *Union, *Interface:
+		return selectionSet, nil
+	default:
+		return nil, nil
+	}
+}
+
+// pruneObjectSelectionSet is a helper function for PruneSelectionSet that
+// prunes a selection on an object.
+func pruneObjectSelectionSet(selectionSet *SelectionSet, typ *Object) (*SelectionSet, error) {
+	selections, err := Flatten(selectionSet)
+	if err != nil {
+		return nil, err
+	}
+
+	// Local resolvers read the fields they require from the source, so those
+	// must be fetched too, even if they are not selected themselves.
+	required := make(map[string]bool)
+	var require func(field *Field)
+	require = func(field *Field) {
+		for _, name := range field.Requires {
+			if required[name] {
+				continue
+			}
+			required[name] = true
+			if requiredField := typ.Fields[name]; requiredField != nil && requiredField.External {
+				require(requiredField)
+			}
+		}
+	}
+
+	// Downstream fields are read by name, so merge the selections of aliases
+	// of the same field.
+	byName := make(map[string]*Selection)
+	for _, selection := range selections {
+		field := typ.Fields[selection.Name]
+		if field == nil {
+			continue
+		}
+		if field.External {
+			require(field)
+			continue
+		}
+
+		merged, ok := byName[selection.Name]
+		if !ok {
+			merged = &Selection{
+				Name:  selection.Name,
+				Alias: selection.Name,
+				Args:  selection.Args,
+			}
+			byName[selection.Name] = merged
+		}
+		if selection.SelectionSet != nil {
+			if merged.SelectionSet == nil {
+				merged.SelectionSet = &SelectionSet{}
+			}
+			merged.SelectionSet.Selections = append(merged.SelectionSet.Selections, selection.SelectionSet.Selections...)
+			merged.SelectionSet.Fragments = append(merged.SelectionSet.Fragments, selection.SelectionSet.Fragments...)
+		}
+	}
+	for name := range required {
+		field := typ.Fields[name]
+		if _, ok := byName[name]; ok || field == nil || field.External {
+			continue
+		}
+		selection := &Selection{Name: name, Alias: name}
+		if !isLeafType(field.Type) {
+			selection.SelectionSet = &SelectionSet{}
+		}
+		byName[name] = selection
+	}
+	if len(byName) == 0 {
+		return nil, nil
+	}
+
+	pruned := &SelectionSet{}
+	for _, selection := range byName {
+		if selection.SelectionSet != nil {
+			if selection.SelectionSet, err = PruneSelectionSet(selection.SelectionSet, typ.Fields[selection.Name].Type); err != nil {
+				return nil, err
+			}
+			// The object itself must still be returned for local resolvers.
+			if selection.SelectionSet == nil {
+				selection.SelectionSet = &SelectionSet{}
+			}
+		}
+		pruned.Selections = append(pruned.Selections, selection)
+	}
+	sort.Slice(pruned.Selections, func(i, j int) bool {
+		return pruned.Selections[i].Name < pruned.Selections[j].Name
+	})
+	return pruned, nil
+}
+
+// isLeafType returns whether typ is a scalar or an enum, or a list of them,
+// which are selected without a selection set.
+func isLeafType(typ Type) bool {
+	switch typ := typ.(type) {
+	case *NonNull:
+		return isLeafType(typ.Type)
+	case *List:
+		return isLeafType(typ.Type)
+	case *Scalar, *Enum:
+		return true
+	default:
+		return false
+	}
+}
+
+// resolverSelectionSet returns the selection set to pass to field's resolver.
+func resolverSelectionSet(field *Field, selectionSet *SelectionSet) (*SelectionSet, error) {
+	if !field.Delegated {
+		return selectionSet, nil
+	}
+	return PruneSelectionSet(selectionSet, field.Type)
+}
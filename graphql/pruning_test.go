@@ -0,0 +1,113 @@
+package graphql_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/internal"
+	"github.com/samsarahq/thunder/internal/testgraphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// printSelections prints the fields of a flattened selection set.
+func printSelections(selectionSet *graphql.SelectionSet) string {
+	var fields []string
+	for _, selection := range selectionSet.Selections {
+		field := selection.Name
+		if selection.SelectionSet != nil {
+			field += " { " + printSelections(selection.SelectionSet) + " }"
+		}
+		fields = append(fields, field)
+	}
+	return strings.Join(fields, " ")
+}
+
+type delegatedUser struct {
+	Id      int64
+	Name    string
+	Email   string
+	Manager *delegatedUser
+}
+
+// fetchDelegatedUser returns the fields of user in selectionSet, like a
+// downstream service would.
+func fetchDelegatedUser(user *delegatedUser, selectionSet *graphql.SelectionSet) *delegatedUser {
+	if user == nil {
+		return nil
+	}
+	fetched := &delegatedUser{}
+	for _, selection := range selectionSet.Selections {
+		switch selection.Name {
+		case "id":
+			fetched.Id = user.Id
+		case "name":
+			fetched.Name = user.Name
+		case "email":
+			fetched.Email = user.Email
+		case "manager":
+			fetched.Manager = fetchDelegatedUser(user.Manager, selection.SelectionSet)
+		}
+	}
+	return fetched
+}
+
+func TestDelegatedSelectionSet(t *testing.T) {
+	var downstream string
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("viewer", func(selectionSet *graphql.SelectionSet) *delegatedUser {
+		downstream = printSelections(selectionSet)
+		viewer := &delegatedUser{Id: 1, Name: "alice", Email: "alice@example.com", Manager: &delegatedUser{Id: 2, Name: "bob", Email: "bob@example.com"}}
+		return fetchDelegatedUser(viewer, selectionSet)
+	}, schemabuilder.Delegated)
+	user := schema.Object("User", delegatedUser{})
+	user.FieldFunc("greeting", func(u *delegatedUser) string {
+		return "hi " + u.Name
+	}, schemabuilder.Requires("name"))
+	user.FieldFunc("loudGreeting", func(u *delegatedUser) string {
+		return "HI " + strings.ToUpper(u.Name)
+	}, schemabuilder.Requires("greeting"))
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{
+		viewer {
+			__typename
+			name
+			userName: name
+			greeting
+			... on User { email }
+			manager { greeting }
+			boss: manager { email loudGreeting }
+		}
+	}`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	e := testgraphql.NewExecutorWrapper(t)
+	val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{
+		"viewer": {
+			"__typename": "User",
+			"name": "alice",
+			"userName": "alice",
+			"greeting": "hi alice",
+			"email": "alice@example.com",
+			"manager": {"greeting": "hi bob"},
+			"boss": {"email": "bob@example.com", "loudGreeting": "HI BOB"}
+		}
+	}`), internal.AsJSON(val))
+
+	// Only fields read from the returned user are fetched downstream, and
+	// aliases and fragments are merged.  The name of the manager is fetched
+	// for the greetings, though it is not selected.
+	assert.Equal(t, "email manager { email name } name", downstream)
+}
+
+func TestRequiresUnknownField(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("a", func() int64 { return 1 }, schemabuilder.Requires("missing"))
+	_, err := schema.Build()
+	assert.EqualError(t, err, "bad type schemabuilder.query: field a requires unknown field missing")
+}
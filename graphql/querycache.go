@@ -0,0 +1,184 @@
+package graphql
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// A QueryCache parses queries like Parse, but only parses the text of every
+// query once, keeping the most recently used parsed queries.  Queries are
+// keyed by their exact text, so that the locations of their errors point at
+// the text of the request.  With Prepare, the cache also keeps the queries
+// it validated against a schema, so repeated requests skip both converting
+// and validating the query.  A QueryCache is safe for concurrent use.
+type QueryCache struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru holds the entries, most recently used first.
+	lru *list.List
+
+	// parses counts the queries parsed because they were not cached, and
+	// prepares the queries prepared because they were not cached.
+	parses   int64
+	prepares int64
+}
+
+type queryCacheEntry struct {
+	key      string
+	document *ast.Document
+
+	// prepared is the query last prepared from the document, if it can be
+	// reused.
+	prepared *preparedQuery
+}
+
+// preparedQuery is a query prepared by QueryCache.Prepare, with the
+// variables and schema it was prepared with.
+type preparedQuery struct {
+	vars   string
+	schema Type
+	query  *Query
+}
+
+// NewQueryCache returns a QueryCache holding up to size parsed queries.
+func NewQueryCache(size int) *QueryCache {
+	return &QueryCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Parse parses an input GraphQL string into a *Query, like Parse.  The values
+// of the variables are filled in on every call, so the returned *Query is
+// never shared.
+func (c *QueryCache) Parse(source string, vars map[string]interface{}) (*Query, error) {
+	entry, err := c.lookup(source)
+	if err != nil {
+		return nil, err
+	}
+	query, err := parseQuery(entry.document, vars)
+	if err != nil {
+		// Invalid queries aren't cached, as they are most likely one-offs.
+		return nil, err
+	}
+	c.store(entry)
+	return query, nil
+}
+
+// Prepare parses an input GraphQL string into a *Query, like Parse, and
+// validates it against the schema returned by schemaFor, like PrepareQuery.
+// schemaFor can also check the query, e.g. with CheckQueryLimits, and fail.
+//
+// A query is only converted and validated again if its variables or schema
+// change, or if it selects fields gated by a feature flag, whose validation
+// depends on ctx.  The returned *Query may be shared with other callers, and
+// must not be modified.
+func (c *QueryCache) Prepare(ctx context.Context, source string, vars map[string]interface{}, schemaFor func(query *Query) (Type, error)) (*Query, error) {
+	entry, err := c.lookup(source)
+	if err != nil {
+		return nil, err
+	}
+
+	// encoding/json sorts the keys of maps, so equal variables are encoded
+	// the same way.
+	varsKey, varsErr := json.Marshal(vars)
+	c.mu.Lock()
+	prepared := entry.prepared
+	c.mu.Unlock()
+	if prepared != nil && varsErr == nil && prepared.vars == string(varsKey) {
+		schema, err := schemaFor(prepared.query)
+		if err != nil {
+			return nil, err
+		}
+		if schema == prepared.schema {
+			return prepared.query, nil
+		}
+	}
+
+	query, err := parseQuery(entry.document, vars)
+	if err != nil {
+		return nil, err
+	}
+	c.store(entry)
+	schema, err := schemaFor(query)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&c.prepares, 1)
+	gated := false
+	flags, _ := ctx.Value(featureFlagsKey{}).(FeatureFlags)
+	prepareCtx := WithFeatureFlags(ctx, func(ctx context.Context, flag string) bool {
+		gated = true
+		return flags != nil && flags(ctx, flag)
+	})
+	if err := PrepareQuery(prepareCtx, schema, query.SelectionSet); err != nil {
+		return nil, err
+	}
+
+	if !gated && varsErr == nil {
+		c.mu.Lock()
+		entry.prepared = &preparedQuery{vars: string(varsKey), schema: schema, query: query}
+		c.mu.Unlock()
+	}
+	return query, nil
+}
+
+// lookup returns the cache entry of source, parsing source into a new entry
+// if it isn't cached.  New entries are only added to the cache by store, once
+// their query is known to be valid.
+func (c *QueryCache) lookup(source string) (*queryCacheEntry, error) {
+	c.mu.Lock()
+	element, ok := c.entries[source]
+	if ok {
+		c.lru.MoveToFront(element)
+	}
+	c.mu.Unlock()
+	if ok {
+		return element.Value.(*queryCacheEntry), nil
+	}
+
+	atomic.AddInt64(&c.parses, 1)
+	document, err := parseDocument(source)
+	if err != nil {
+		return nil, err
+	}
+	return &queryCacheEntry{key: source, document: document}, nil
+}
+
+// store adds entry to the cache, if it isn't cached yet, evicting the least
+// recently used entries past the size limit.
+func (c *QueryCache) store(entry *queryCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[entry.key]; ok {
+		return
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*queryCacheEntry).key)
+	}
+}
+
+// Len returns the number of cached queries.
+func (c *QueryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// WithQueryCache parses and prepares the queries of requests with cache.
+func WithQueryCache(cache *QueryCache) HTTPHandlerOption {
+	return func(h *httpHandler) {
+		h.queryCache = cache
+	}
+}
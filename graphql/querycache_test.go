@@ -0,0 +1,129 @@
+package graphql_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryCache(t *testing.T) {
+	cache := graphql.NewQueryCache(2)
+
+	query := `query Mirror($value: int64!) { mirror(value: $value) }`
+	q, err := cache.Parse(query, map[string]interface{}{"value": float64(1)})
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), q.SelectionSet.Selections[0].UnparsedArgs["value"])
+	assert.Equal(t, 1, cache.Parses())
+
+	// The same query reuses the parse, with the new values of its variables.
+	q, err = cache.Parse(query, map[string]interface{}{"value": float64(2)})
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), q.SelectionSet.Selections[0].UnparsedArgs["value"])
+	assert.Equal(t, 1, cache.Parses())
+
+	// Queries are keyed by their exact text, so the locations of a query
+	// formatted differently are its own.
+	q, err = cache.Parse("\n\n\n      {\n   a\n}", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []graphql.Location{{Line: 5, Column: 4}}, q.SelectionSet.Selections[0].Locations)
+	assert.Equal(t, 2, cache.Parses())
+	cache = graphql.NewQueryCache(2)
+
+	// Invalid queries aren't cached.
+	_, err = cache.Parse(`{ mirror(`, nil)
+	assert.Error(t, err)
+	assert.Equal(t, 1, cache.Parses())
+	assert.Equal(t, 0, cache.Len())
+
+	// Past the size limit, the least recently used query is evicted.
+	_, err = cache.Parse(`{ a }`, nil)
+	require.NoError(t, err)
+	_, err = cache.Parse(query, map[string]interface{}{"value": float64(3)})
+	require.NoError(t, err)
+	_, err = cache.Parse(`{ b }`, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4, cache.Parses())
+	assert.Equal(t, 2, cache.Len())
+
+	_, err = cache.Parse(query, map[string]interface{}{"value": float64(4)})
+	require.NoError(t, err)
+	assert.Equal(t, 4, cache.Parses())
+	_, err = cache.Parse(`{ a }`, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 5, cache.Parses())
+}
+
+func TestQueryCachePrepare(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("mirror", func(args struct{ Value int64 }) int64 {
+		return args.Value
+	})
+	builder.Query().FieldFunc("beta", func() string {
+		return "beta"
+	}, schemabuilder.FeatureFlag("beta"))
+	schema := builder.MustBuild()
+	schemaFor := func(query *graphql.Query) (graphql.Type, error) {
+		return schema.Query, nil
+	}
+
+	cache := graphql.NewQueryCache(2)
+	query := `query Mirror($value: int64!) { mirror(value: $value) }`
+	q, err := cache.Prepare(context.Background(), query, map[string]interface{}{"value": float64(1)}, schemaFor)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), q.SelectionSet.Selections[0].Args.(struct{ Value int64 }).Value)
+	assert.Equal(t, 1, cache.Prepares())
+
+	// The same query with the same variables reuses the prepared query.
+	cached, err := cache.Prepare(context.Background(), query, map[string]interface{}{"value": float64(1)}, schemaFor)
+	require.NoError(t, err)
+	assert.True(t, q == cached)
+	assert.Equal(t, 1, cache.Parses())
+	assert.Equal(t, 1, cache.Prepares())
+
+	// New variables are filled in and validated.
+	q, err = cache.Prepare(context.Background(), query, map[string]interface{}{"value": float64(2)}, schemaFor)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), q.SelectionSet.Selections[0].Args.(struct{ Value int64 }).Value)
+	assert.Equal(t, 1, cache.Parses())
+	assert.Equal(t, 2, cache.Prepares())
+	_, err = cache.Prepare(context.Background(), query, map[string]interface{}{"value": "two"}, schemaFor)
+	assert.Error(t, err)
+
+	// schemaFor can reject a query, even if it was prepared before.
+	_, err = cache.Prepare(context.Background(), query, map[string]interface{}{"value": float64(2)}, func(query *graphql.Query) (graphql.Type, error) {
+		return nil, errors.New("rejected")
+	})
+	assert.EqualError(t, err, "rejected")
+
+	// Queries selecting fields gated by feature flags are validated with the
+	// flags of every call.
+	enabled := graphql.WithFeatureFlags(context.Background(), func(ctx context.Context, flag string) bool {
+		return true
+	})
+	_, err = cache.Prepare(enabled, `{ beta }`, nil, schemaFor)
+	require.NoError(t, err)
+	_, err = cache.Prepare(context.Background(), `{ beta }`, nil, schemaFor)
+	assert.Error(t, err)
+}
+
+func TestQueryCacheConcurrent(t *testing.T) {
+	cache := graphql.NewQueryCache(2)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			queries := []string{`{ a }`, `{ b }`, `{ c }`}
+			_, err := cache.Parse(queries[i%len(queries)], nil)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 2, cache.Len())
+}
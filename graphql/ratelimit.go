@@ -0,0 +1,59 @@
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// ErrRateLimited is returned for requests rejected by a RateLimiter.
+var ErrRateLimited = NewClientError("rate limit exceeded")
+
+// RateLimitKey identifies the requests a RateLimiter counts together.
+type RateLimitKey struct {
+	// Client identifies the caller, as returned by the handler's client key
+	// function.  It is empty if the handler has none.
+	Client string
+	// Operation is the fingerprint of the query, see OperationFingerprint.
+	Operation string
+}
+
+// RateLimiter decides whether to allow a request.  Allow is called once for
+// every request before the query is executed, so implementations can count
+// requests with e.g. a token bucket or a sliding window.  Implementations
+// must be safe for concurrent use.
+type RateLimiter interface {
+	Allow(ctx context.Context, key RateLimitKey) bool
+}
+
+// WithRateLimiter rejects requests that the limiter does not allow with
+// ErrRateLimited, before they are executed.  clientKey, if non-nil, returns
+// the identity of the client making a request (e.g. an API token or remote
+// address).
+func WithRateLimiter(limiter RateLimiter, clientKey func(r *http.Request) string) HTTPHandlerOption {
+	return func(h *httpHandler) {
+		h.rateLimiter = limiter
+		h.rateLimitClientKey = clientKey
+	}
+}
+
+// allowRequest checks the handler's rate limiter, if any.
+func (h *httpHandler) allowRequest(r *http.Request, query string) bool {
+	if h.rateLimiter == nil {
+		return true
+	}
+	key := RateLimitKey{Operation: OperationFingerprint(query)}
+	if h.rateLimitClientKey != nil {
+		key.Client = h.rateLimitClientKey(r)
+	}
+	return h.rateLimiter.Allow(r.Context(), key)
+}
+
+// OperationFingerprint returns a fingerprint of a query's text, which is the
+// same for queries that differ only in whitespace.
+func OperationFingerprint(query string) string {
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(query), " ")))
+	return hex.EncodeToString(sum[:])
+}
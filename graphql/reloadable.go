@@ -0,0 +1,37 @@
+package graphql
+
+import "sync/atomic"
+
+// A ReloadableSchema holds a Schema that can be swapped while queries are
+// running, e.g. after a config change in a long-running server.  Queries
+// capture the schema when they start, so in-flight queries finish against the
+// old schema while new queries use the new one.
+type ReloadableSchema struct {
+	schema atomic.Value // *Schema
+}
+
+// NewReloadableSchema returns a ReloadableSchema holding schema.
+func NewReloadableSchema(schema *Schema) *ReloadableSchema {
+	s := &ReloadableSchema{}
+	s.Store(schema)
+	return s
+}
+
+// Load returns the current schema.
+func (s *ReloadableSchema) Load() *Schema {
+	return s.schema.Load().(*Schema)
+}
+
+// Store atomically replaces the schema used by new queries.
+func (s *ReloadableSchema) Store(schema *Schema) {
+	s.schema.Store(schema)
+}
+
+// WithReloadableSchema serves queries with the schema held by schema at the
+// time each request starts, instead of the schema the handler was created
+// with.
+func WithReloadableSchema(schema *ReloadableSchema) HTTPHandlerOption {
+	return func(h *httpHandler) {
+		h.reloadable = schema
+	}
+}
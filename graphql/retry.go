@@ -0,0 +1,124 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRetryBudget is the number of retries in every execution of an
+// executor that doesn't set WithRetryBudget.
+const DefaultRetryBudget = 10
+
+// DefaultMaxRetryBackoff is the delay between retries of a RetryPolicy that
+// doesn't set MaxBackoff.
+const DefaultMaxRetryBackoff = 10 * time.Second
+
+// RetryPolicy retries the resolver of a field when it fails, e.g. to hide
+// transient errors of a flaky downstream service.  Batch resolvers are
+// retried for the whole batch.
+//
+// Resolvers that panic are not retried, nor are fields resolved by mutations,
+// whose resolvers may have side effects, unless RetryMutations is set.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the resolver is called,
+	// including the first call.
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry.  It doubles for every
+	// later retry, up to MaxBackoff, or DefaultMaxRetryBackoff if unset.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Jitter randomizes every delay by up to this fraction of it, e.g. 0.2
+	// for +/-20%, so that retries of many sources don't hit a downstream all
+	// at once.
+	Jitter float64
+
+	// Retryable, if set, reports whether an error is worth retrying.  By
+	// default, every error is retried.
+	Retryable func(err error) bool
+
+	// RetryMutations retries the field in mutations too.  It should only be
+	// set for fields whose resolvers are safe to repeat.
+	RetryMutations bool
+}
+
+// delay returns the delay before the given retry, counting from 1.
+func (p *RetryPolicy) delay(retry int) time.Duration {
+	maxBackoff := p.MaxBackoff
+	if maxBackoff == 0 {
+		maxBackoff = DefaultMaxRetryBackoff
+	}
+	delay := p.Backoff
+	for i := 1; i < retry && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	if p.Jitter > 0 {
+		delay += time.Duration(float64(delay) * p.Jitter * (2*rand.Float64() - 1))
+	}
+	return delay
+}
+
+// WithRetryBudget limits the number of retries (see RetryPolicy) in every
+// execution to retries, shared across all of its fields, so that a failing
+// downstream can't make a query retry excessively and blow its latency
+// budget.  Once the budget is spent, fields fail with their last error.  A
+// budget of zero or less disables retries.  Without WithRetryBudget, the
+// budget is DefaultRetryBudget.
+func WithRetryBudget(retries int) ExecutorOption {
+	return func(e *Executor) {
+		e.retryBudget = retries
+	}
+}
+
+// takeRetry takes a retry from the execution's retry budget, returning false
+// if it is spent.
+func (info *executionInfo) takeRetry() bool {
+	if info.retriesLeft == nil {
+		return true
+	}
+	return atomic.AddInt64(info.retriesLeft, -1) >= 0
+}
+
+// resolveWithRetries calls resolve, retrying it according to the policy of
+// field, if any, until it succeeds.
+func resolveWithRetries(ctx context.Context, field *Field, resolve func() error) error {
+	policy := field.Retry
+	err := resolve()
+	if policy == nil {
+		return err
+	}
+
+	info := executionInfoFromContext(ctx)
+	if info.operationKind == "mutation" && !policy.RetryMutations {
+		return err
+	}
+	for attempt := 1; err != nil && attempt < policy.MaxAttempts; attempt++ {
+		var panicErr *resolverPanic
+		if errors.As(err, &panicErr) {
+			return err
+		}
+		if policy.Retryable != nil && !policy.Retryable(err) {
+			return err
+		}
+		if !info.takeRetry() {
+			return err
+		}
+
+		timer := time.NewTimer(policy.delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		err = resolve()
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package graphql_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/samsarahq/thunder/batch"
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry(t *testing.T) {
+	type Feed struct{}
+
+	var flakyCalls, batchCalls int64
+	schema := schemabuilder.NewSchema()
+	policy := graphql.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, Jitter: 0.5}
+	schema.Query().FieldFunc("flaky", func() (string, error) {
+		if atomic.AddInt64(&flakyCalls, 1) < 3 {
+			return "", errors.New("unavailable")
+		}
+		return "ok", nil
+	}, schemabuilder.Retry(policy))
+	schema.Query().FieldFunc("feed", func() *Feed {
+		return &Feed{}
+	})
+	schema.Object("Feed", Feed{}).BatchFieldFunc("flaky", func(ctx context.Context, feeds map[batch.Index]*Feed) (map[batch.Index]string, error) {
+		if atomic.AddInt64(&batchCalls, 1) < 2 {
+			return nil, errors.New("unavailable")
+		}
+		res := make(map[batch.Index]string, len(feeds))
+		for idx := range feeds {
+			res[idx] = "ok"
+		}
+		return res, nil
+	}, schemabuilder.Retry(policy))
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ flaky feed { flaky } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"flaky": "ok",
+		"feed":  map[string]interface{}{"flaky": "ok"},
+	}, val)
+	assert.Equal(t, int64(3), flakyCalls)
+	assert.Equal(t, int64(2), batchCalls)
+}
+
+func TestRetryBudget(t *testing.T) {
+	var calls int64
+	schema := schemabuilder.NewSchema()
+	for _, name := range []string{"a", "b"} {
+		schema.Query().FieldFunc(name, func() (string, error) {
+			atomic.AddInt64(&calls, 1)
+			return "", errors.New("unavailable")
+		}, schemabuilder.Retry(graphql.RetryPolicy{MaxAttempts: 10}))
+	}
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ a b }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithRetryBudget(3))
+	_, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unavailable")
+	// Both fields share the budget of 3 retries.
+	assert.Equal(t, int64(5), atomic.LoadInt64(&calls))
+
+	// Every query gets a new budget.
+	atomic.StoreInt64(&calls, 0)
+	_, err = e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.Error(t, err)
+	assert.Equal(t, int64(5), atomic.LoadInt64(&calls))
+}
+
+func TestRetryDefaults(t *testing.T) {
+	var queryCalls, panicCalls, mutationCalls, retriedMutationCalls int64
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("flaky", func() (string, error) {
+		atomic.AddInt64(&queryCalls, 1)
+		return "", errors.New("unavailable")
+	}, schemabuilder.Retry(graphql.RetryPolicy{MaxAttempts: 100}))
+	schema.Query().FieldFunc("panics", func() string {
+		atomic.AddInt64(&panicCalls, 1)
+		panic("bad resolver")
+	}, schemabuilder.Retry(graphql.RetryPolicy{MaxAttempts: 3}))
+	schema.Mutation().FieldFunc("send", func() (string, error) {
+		atomic.AddInt64(&mutationCalls, 1)
+		return "", errors.New("unavailable")
+	}, schemabuilder.Retry(graphql.RetryPolicy{MaxAttempts: 3}))
+	schema.Mutation().FieldFunc("idempotentSend", func() (string, error) {
+		atomic.AddInt64(&retriedMutationCalls, 1)
+		return "", errors.New("unavailable")
+	}, schemabuilder.Retry(graphql.RetryPolicy{MaxAttempts: 3, RetryMutations: true}))
+	builtSchema := schema.MustBuild()
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+
+	run := func(typ graphql.Type, query string) {
+		q := graphql.MustParse(query, nil)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), typ, q.SelectionSet))
+		_, err := e.Execute(context.Background(), typ, nil, q)
+		require.Error(t, err)
+	}
+
+	// Retries are bounded by the default budget.
+	run(builtSchema.Query, `{ flaky }`)
+	assert.Equal(t, int64(1+graphql.DefaultRetryBudget), queryCalls)
+
+	// Panics are not retried.
+	run(builtSchema.Query, `{ panics }`)
+	assert.Equal(t, int64(1), panicCalls)
+
+	// Mutations are only retried if the policy allows it.
+	run(builtSchema.Mutation, `mutation { send }`)
+	assert.Equal(t, int64(1), mutationCalls)
+	run(builtSchema.Mutation, `mutation { idempotentSend }`)
+	assert.Equal(t, int64(3), retriedMutationCalls)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/samsarahq/thunder/batch"
 	"github.com/samsarahq/thunder/graphql"
@@ -74,6 +75,9 @@ func (sb *schemaBuilder) buildBatchFunction(typ reflect.Type, m *method) (*graph
 func (sb *schemaBuilder) buildBatchFunctionAndFuncCtx(typ reflect.Type, m *method) (*graphql.Field, *batchFuncContext, error) {
 	funcCtx := &batchFuncContext{parentTyp: typ}
 
+	if m.wrapResolve != nil {
+		return nil, nil, fmt.Errorf("StaleWhileRevalidate is not supported for batch functions")
+	}
 	if typ.Kind() == reflect.Ptr {
 		return nil, nil, fmt.Errorf("source-type of buildBatchFunction cannot be a pointer (got: %v)", typ)
 	}
@@ -93,7 +97,14 @@ func (sb *schemaBuilder) buildBatchFunctionAndFuncCtx(typ reflect.Type, m *metho
 	if err != nil {
 		return nil, nil, err
 	}
-	argParser, args, in, err := funcCtx.consumeArgs(sb, in)
+	argParser, args, in, err := funcCtx.consumeArgs(sb, m, in)
+	if err != nil {
+		return nil, nil, err
+	}
+	if args, err = m.mapArgs(args); err != nil {
+		return nil, nil, err
+	}
+	field, err := m.newField(argParser, funcCtx.argType)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -124,16 +135,13 @@ func (sb *schemaBuilder) buildBatchFunctionAndFuncCtx(typ reflect.Type, m *metho
 		return funcCtx.extractResultsAndErr(funcOutputArgs, idxValues, retType)
 	}
 
-	return &graphql.Field{
-		BatchResolver:              batchExecFunc,
-		Batch:                      true,
-		External:                   true,
-		Args:                       args,
-		Type:                       retType,
-		ParseArguments:             argParser.Parse,
-		Expensive:                  m.Expensive,
-		NumParallelInvocationsFunc: m.ConcurrencyArgs.numParallelInvocationsFunc,
-	}, funcCtx, nil
+	field.BatchResolver = batchExecFunc
+	field.Batch = true
+	field.Args = args
+	field.Type = retType
+	field.ParseArguments = m.sanitizeArguments(m.mapArguments(argParser.Parse))
+	field.BatchGroup = m.BatchGroup
+	return field, funcCtx, nil
 }
 
 // funcContext is used to parse the function signature in buildFunction.
@@ -150,6 +158,9 @@ type batchFuncContext struct {
 	batchMapType reflect.Type
 	isPtrFunc    bool
 	parentTyp    reflect.Type
+
+	// argType is the input object of the args struct, if any.
+	argType graphql.Type
 }
 
 // getFuncVal returns a reflect.Value of an executable function.
@@ -214,13 +225,13 @@ func (funcCtx *batchFuncContext) consumeRequiredSourceBatch(in []reflect.Type) (
 
 // consumeArgs reads the args parameter if it is there and returns an argParser,
 // argTypeMap and the filtered input parameters.
-func (funcCtx *batchFuncContext) consumeArgs(sb *schemaBuilder, in []reflect.Type) (*argParser, map[string]graphql.Type, []reflect.Type, error) {
+func (funcCtx *batchFuncContext) consumeArgs(sb *schemaBuilder, m *method, in []reflect.Type) (*argParser, map[string]graphql.Type, []reflect.Type, error) {
 	if len(in) == 0 || in[0] == selectionSetType {
 		return nil, nil, in, nil
 	}
 	inType := in[0]
 	in = in[1:]
-	argParser, argType, err := sb.makeStructParser(inType)
+	argParser, argType, err := sb.makeArgsParser(m, inType)
 	if err != nil {
 		return nil, nil, in, fmt.Errorf("attempted to parse %s as arguments struct, but failed: %s", inType.Name(), err.Error())
 	}
@@ -233,6 +244,7 @@ func (funcCtx *batchFuncContext) consumeArgs(sb *schemaBuilder, in []reflect.Typ
 		args[name] = typ
 	}
 	funcCtx.hasArgs = true
+	funcCtx.argType = argType
 	return argParser, args, in, nil
 }
 
@@ -285,12 +297,25 @@ func (funcCtx *batchFuncContext) consumeReturnValue(m *method, sb *schemaBuilder
 			retType = nonNull.Type
 		}
 	}
+	if m.MarkedNullable {
+		if m.MarkedNonNullable {
+			return nil, nil, fmt.Errorf("%s is marked both nullable and non-nullable", funcCtx.funcType)
+		}
+		if nonNull, ok := retType.(*graphql.NonNull); ok {
+			retType = nonNull.Type
+		}
+	}
 	if m.MarkedNonNullable {
 		funcCtx.enforceNoNilResps = true
 		if _, ok := retType.(*graphql.NonNull); !ok {
 			retType = &graphql.NonNull{Type: retType}
 		}
 	}
+	if m.MarkedNullableElements {
+		if err := makeElementsNullable(outType.Elem(), retType); err != nil {
+			return nil, nil, fmt.Errorf("%s %s", funcCtx.funcType, err)
+		}
+	}
 	funcCtx.hasRet = true
 	return retType, out, nil
 }
@@ -359,8 +384,15 @@ func (funcCtx *batchFuncContext) prepareResolveArgs(sources []interface{}, args
 // It also handles reading whether the function ended with errors.
 func (funcCtx *batchFuncContext) extractResultsAndErr(out []reflect.Value, idxValues []reflect.Value, retType graphql.Type) ([]interface{}, error) {
 	if funcCtx.hasError {
-		if err := out[len(out)-1]; !err.IsNil() {
-			return nil, err.Interface().(error)
+		if errValue := out[len(out)-1]; !errValue.IsNil() {
+			err := errValue.Interface().(error)
+			// Results returned along with an error are kept, so callers can
+			// use them for the sources that the error doesn't fail (see
+			// graphql.BatchSourceError).
+			if !funcCtx.hasRet || out[0].IsNil() {
+				return nil, err
+			}
+			return funcCtx.collectResultsWithErr(out[0], idxValues, err)
 		}
 	}
 	if !funcCtx.hasRet {
@@ -370,8 +402,50 @@ func (funcCtx *batchFuncContext) extractResultsAndErr(out []reflect.Value, idxVa
 		}
 		return res, nil
 	}
-	resBatch := out[0]
+	return funcCtx.collectResults(out[0], idxValues)
+}
+
+// collectResultsWithErr orders the values of the map returned by a batch
+// function along with err like its sources.  Sources missing from the map of
+// a non-nullable function are failed with BatchSourceErrors, which only apply
+// to the sources err doesn't fail itself.
+func (funcCtx *batchFuncContext) collectResultsWithErr(resBatch reflect.Value, idxValues []reflect.Value, err error) ([]interface{}, error) {
+	resList := make([]interface{}, len(idxValues))
+	errs := joinedErrors{err}
+	for idx, idxVal := range idxValues {
+		res := resBatch.MapIndex(idxVal)
+		if !res.IsValid() || (res.Kind() == reflect.Ptr && res.IsNil()) {
+			if funcCtx.enforceNoNilResps {
+				errs = append(errs, graphql.NewBatchSourceError(idxVal.Interface().(batch.Index),
+					fmt.Errorf("%s is marked non-nullable but returned a null value", funcCtx.funcType)))
+			}
+			continue
+		}
+		resList[idx] = res.Interface()
+	}
+	if len(errs) == 1 {
+		return resList, err
+	}
+	return resList, errs
+}
+
+// joinedErrors joins the error of a batch function with the errors of its
+// sources, like errors.Join.
+type joinedErrors []error
+
+func (e joinedErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e joinedErrors) Unwrap() []error { return e }
 
+// collectResults orders the values of the map returned by a batch function
+// like its sources.
+func (funcCtx *batchFuncContext) collectResults(resBatch reflect.Value, idxValues []reflect.Value) ([]interface{}, error) {
 	resList := make([]interface{}, len(idxValues))
 	for idx, idxVal := range idxValues {
 		res := resBatch.MapIndex(idxVal)
@@ -4,6 +4,7 @@ import (
 	"encoding"
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/samsarahq/thunder/graphql"
@@ -19,6 +20,10 @@ type schemaBuilder struct {
 	objects      map[reflect.Type]*Object
 	enumMappings map[reflect.Type]*EnumMapping
 	typeCache    map[reflect.Type]cachedType // typeCache maps Go types to GraphQL datatypes
+	interfaces   []*graphql.Interface        // interfaces get their fields once all types are built
+	resultUnions map[reflect.Type]*resultUnion
+	// customScalars are the scalar types registered with RegisterScalar.
+	customScalars map[reflect.Type]*customScalar
 }
 
 // EnumMapping is a representation of an enum that includes both the mapping and
@@ -26,6 +31,41 @@ type schemaBuilder struct {
 type EnumMapping struct {
 	Map        map[string]interface{}
 	ReverseMap map[interface{}]string
+
+	// CaseInsensitiveInput indicates that input values are matched against
+	// Map ignoring case.
+	CaseInsensitiveInput bool
+
+	// DeprecatedValues maps deprecated values to the reason they are
+	// deprecated.
+	DeprecatedValues map[string]string
+
+	// Aliases maps alternative input strings to the value in Map they stand
+	// for.
+	Aliases map[string]string
+}
+
+// lookup returns the enum value for the given input string.
+func (m *EnumMapping) lookup(s string) (interface{}, bool) {
+	if val, ok := m.Map[s]; ok {
+		return val, true
+	}
+	if value, ok := m.Aliases[s]; ok {
+		return m.Map[value], true
+	}
+	if m.CaseInsensitiveInput {
+		for key, val := range m.Map {
+			if strings.EqualFold(key, s) {
+				return val, true
+			}
+		}
+		for alias, value := range m.Aliases {
+			if strings.EqualFold(alias, s) {
+				return m.Map[value], true
+			}
+		}
+	}
+	return nil, false
 }
 
 // cachedType is a container for GraphQL datatype and the list of its fields
@@ -43,7 +83,11 @@ func (sb *schemaBuilder) getType(nodeType reflect.Type) (graphql.Type, error) {
 	// Support scalars and optional scalars. Scalars have precedence over structs
 	// to have eg. time.Time function as a scalar.
 	if typeName, values, ok := sb.getEnum(nodeType); ok {
-		return &graphql.NonNull{Type: &graphql.Enum{Type: typeName, Values: values, ReverseMap: sb.enumMappings[nodeType].ReverseMap}}, nil
+		return &graphql.NonNull{Type: &graphql.Enum{Type: typeName, Values: values, ReverseMap: sb.enumMappings[nodeType].ReverseMap, DeprecatedValues: sb.enumMappings[nodeType].DeprecatedValues}}, nil
+	}
+
+	if argType, ok := sb.getCustomScalarType(nodeType); ok {
+		return argType, nil
 	}
 
 	if typeName, ok := getScalar(nodeType); ok {
@@ -55,6 +99,10 @@ func (sb *schemaBuilder) getType(nodeType reflect.Type) (graphql.Type, error) {
 		}
 	}
 
+	if isProtoTimestamp(nodeType) || (nodeType.Kind() == reflect.Ptr && isProtoTimestamp(nodeType.Elem())) {
+		return getProtoTimestampType(nodeType), nil
+	}
+
 	if nodeType.Implements(textMarshalerType) {
 		return sb.getTextMarshalerType(nodeType)
 	}
@@ -87,11 +135,42 @@ func (sb *schemaBuilder) getType(nodeType reflect.Type) (graphql.Type, error) {
 
 		return &graphql.NonNull{Type: &graphql.List{Type: elementType}}, nil
 
+	case reflect.Func:
+		// Iterators (func() (T, bool, error)) are exposed as lists of T.
+		if !isIteratorType(nodeType) {
+			return nil, fmt.Errorf("bad type %s: funcs must be iterators of the form func() (T, bool, error)", nodeType)
+		}
+		elementType, err := sb.getType(nodeType.Out(0))
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := elementType.(*graphql.NonNull); !ok {
+			elementType = &graphql.NonNull{Type: elementType}
+		}
+		return &graphql.NonNull{Type: &graphql.List{Type: elementType}}, nil
+
+	case reflect.Interface:
+		union, ok := sb.resultUnions[nodeType]
+		if !ok {
+			return nil, fmt.Errorf("bad type %s: interfaces must be registered with ResultUnion", nodeType)
+		}
+		if err := sb.buildResultUnion(nodeType, union); err != nil {
+			return nil, err
+		}
+		return sb.types[nodeType], nil
+
 	default:
 		return nil, fmt.Errorf("bad type %s: should be a scalar, slice, or struct type", nodeType)
 	}
 }
 
+// isIteratorType returns whether the type is an iterator function of the form
+// func() (T, bool, error).
+func isIteratorType(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Func && typ.NumIn() == 0 && typ.NumOut() == 3 &&
+		typ.Out(1).Kind() == reflect.Bool && typ.Out(2) == errType
+}
+
 // getTextMarshalerType returns a graphQL type that can be used to parse a
 // encoding.TextMarshaler and convert it's value into a string in the graphQL
 // response.
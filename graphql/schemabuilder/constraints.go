@@ -0,0 +1,153 @@
+package schemabuilder
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+)
+
+// argConstraints are the constraints declared on an argument's struct field
+// with the `min`, `max`, `minLength`, `maxLength` and `pattern` tags, e.g.
+//
+//	type args struct {
+//		Count int64  `min:"1" max:"100"`
+//		Name  string `maxLength:"20" pattern:"^[a-z]+$"`
+//	}
+//
+// min and max apply to numbers, while minLength, maxLength and pattern apply
+// to strings.  Constraints are checked after an argument is parsed, and are
+// skipped for null optional arguments.
+type argConstraints struct {
+	min, max             *float64
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+}
+
+// parseArgConstraints reads the constraint tags on a struct field, returning
+// nil if there are none.
+func parseArgConstraints(field reflect.StructField) (*argConstraints, error) {
+	c := &argConstraints{}
+	found := false
+
+	for _, tag := range []struct {
+		name string
+		dest **float64
+	}{{"min", &c.min}, {"max", &c.max}} {
+		if value, ok := field.Tag.Lookup(tag.name); ok {
+			bound, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad %s constraint %q: %s", tag.name, value, err)
+			}
+			*tag.dest = &bound
+			found = true
+		}
+	}
+
+	for _, tag := range []struct {
+		name string
+		dest **int
+	}{{"minLength", &c.minLength}, {"maxLength", &c.maxLength}} {
+		if value, ok := field.Tag.Lookup(tag.name); ok {
+			length, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("bad %s constraint %q: %s", tag.name, value, err)
+			}
+			*tag.dest = &length
+			found = true
+		}
+	}
+
+	if value, ok := field.Tag.Lookup("pattern"); ok {
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("bad pattern constraint %q: %s", value, err)
+		}
+		c.pattern = pattern
+		found = true
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	typ := field.Type
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	isNumber := isNumberKind(typ.Kind())
+	isString := typ.Kind() == reflect.String
+	if (c.min != nil || c.max != nil) && !isNumber {
+		return nil, fmt.Errorf("min and max constraints require a number, not %v", field.Type)
+	}
+	if (c.minLength != nil || c.maxLength != nil || c.pattern != nil) && !isString {
+		return nil, fmt.Errorf("minLength, maxLength and pattern constraints require a string, not %v", field.Type)
+	}
+	return c, nil
+}
+
+// wrap returns an argParser that checks the constraints after parsing.
+func (c *argConstraints) wrap(inner *argParser) *argParser {
+	return &argParser{
+		FromJSON: func(value interface{}, dest reflect.Value) error {
+			if err := inner.FromJSON(value, dest); err != nil {
+				return err
+			}
+			return c.check(dest)
+		},
+		Type: inner.Type,
+	}
+}
+
+// check validates a parsed argument against the constraints.
+func (c *argConstraints) check(value reflect.Value) error {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	if value.Kind() == reflect.String {
+		s := value.String()
+		length := utf8.RuneCountInString(s)
+		if c.minLength != nil && length < *c.minLength {
+			return fmt.Errorf("must be at least %d characters long", *c.minLength)
+		}
+		if c.maxLength != nil && length > *c.maxLength {
+			return fmt.Errorf("must be at most %d characters long", *c.maxLength)
+		}
+		if c.pattern != nil && !c.pattern.MatchString(s) {
+			return fmt.Errorf("must match pattern %s", c.pattern)
+		}
+		return nil
+	}
+
+	var number float64
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		number = float64(value.Uint())
+	default:
+		number = value.Float()
+	}
+	if c.min != nil && number < *c.min {
+		return fmt.Errorf("must be at least %v", *c.min)
+	}
+	if c.max != nil && number > *c.max {
+		return fmt.Errorf("must be at most %v", *c.max)
+	}
+	return nil
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -2,8 +2,10 @@ package schemabuilder
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/samsarahq/go/oops"
 	"github.com/samsarahq/thunder/graphql"
@@ -49,7 +51,7 @@ func (sb *schemaBuilder) buildFunctionAndFuncCtx(typ reflect.Type, m *method) (*
 	in := funcCtx.getFuncInputTypes()
 	in = funcCtx.consumeContextAndSource(in)
 
-	argParser, argType, in, err := funcCtx.getArgParserAndTyp(sb, in)
+	argParser, argType, in, err := funcCtx.getArgParserAndTyp(sb, m, in)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -78,25 +80,105 @@ func (sb *schemaBuilder) buildFunctionAndFuncCtx(typ reflect.Type, m *method) (*
 	if err != nil {
 		return nil, nil, err
 	}
+	if args, err = m.mapArgs(args); err != nil {
+		return nil, nil, err
+	}
+	field, err := m.newField(argParser, argType)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return &graphql.Field{
-		Resolve: func(ctx context.Context, source, funcRawArgs interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
-			// Set up function arguments.
-			funcInputArgs := funcCtx.prepareResolveArgs(source, funcCtx.hasArgs, funcRawArgs, ctx, selectionSet)
+	resolve := func(ctx context.Context, source, funcRawArgs interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+		// Set up function arguments.
+		funcInputArgs := funcCtx.prepareResolveArgs(source, funcCtx.hasArgs, funcRawArgs, ctx, selectionSet)
 
-			// Call the function.
-			funcOutputArgs := callableFunc.Call(funcInputArgs)
+		// Call the function.
+		funcOutputArgs := callableFunc.Call(funcInputArgs)
 
-			return funcCtx.extractResultAndErr(funcOutputArgs, retType)
+		return funcCtx.extractResultAndErr(funcOutputArgs, retType)
+	}
+	if m.typedResolver != nil {
+		resolve = funcCtx.wrapTypedResolver(m.typedResolver, retType)
+	}
+	if m.wrapResolve != nil {
+		resolve = m.wrapResolve(resolve)
+	}
 
-		},
-		Args:                       args,
-		Type:                       retType,
-		ParseArguments:             argParser.Parse,
+	parseArguments := m.sanitizeArguments(m.mapArguments(argParser.Parse))
+	if m.FetchesFromKeys {
+		parseArguments = dropUnknownKeyFields(parseArguments, args["keys"])
+	}
+
+	field.Resolve = resolve
+	field.Args = args
+	field.Type = retType
+	field.ParseArguments = parseArguments
+	return field, funcCtx, nil
+}
+
+// newField returns a graphql.Field with the options of m that apply to every
+// kind of FieldFunc, checking its NormalizeArgs and Complexity against the
+// args struct parsed by argParser, if any.  The caller sets how the field is
+// resolved, and its type and arguments.
+func (m *method) newField(argParser *argParser, argType graphql.Type) (*graphql.Field, error) {
+	normalizeArgs, err := m.argsNormalizer(argParser)
+	if err != nil {
+		return nil, err
+	}
+	complexity, err := m.complexityFunc(argParser)
+	if err != nil {
+		return nil, err
+	}
+	return &graphql.Field{
+		ArgDefaultValues:           m.argDefaultValues(argType),
+		NormalizeArgs:              normalizeArgs,
+		Complexity:                 complexity,
 		Expensive:                  m.Expensive,
+		RequiresPrimary:            m.RequiresPrimary,
+		Delegated:                  m.Delegated,
+		Retry:                      m.Retry,
+		Timeout:                    m.Timeout,
+		DependsOn:                  m.DependsOn,
+		Requires:                   m.Requires,
+		Validate:                   m.Validate,
+		Authorize:                  m.Authorize,
+		Transformers:               m.Transformers,
+		FeatureFlag:                m.FeatureFlag,
 		External:                   true,
 		NumParallelInvocationsFunc: m.ConcurrencyArgs.numParallelInvocationsFunc,
-	}, funcCtx, nil
+	}, nil
+}
+
+// wrapTypedResolver converts a typedResolver into a graphql.Resolver.  The
+// source is only converted through reflection if the executor hands us a
+// pointer where the function expects a value (or vice versa).
+func (funcCtx *funcContext) wrapTypedResolver(resolver typedResolver, retType graphql.Type) graphql.Resolver {
+	_, nonNull := retType.(*graphql.NonNull)
+	return func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+		if funcCtx.hasSource {
+			sourceIdx := 0
+			if funcCtx.hasContext {
+				sourceIdx = 1
+			}
+			if source != nil && reflect.TypeOf(source) != funcCtx.funcType.In(sourceIdx) {
+				source = funcCtx.prepareResolveArgs(source, false, nil, ctx, selectionSet)[sourceIdx].Interface()
+			}
+		}
+
+		result, err := resolver(ctx, source, args)
+		if err != nil {
+			return nil, err
+		}
+		result = funcCtx.unwrapNullable(result)
+
+		if nonNull {
+			resultValue := reflect.ValueOf(result)
+			if resultValue.Kind() == reflect.Ptr && resultValue.IsNil() {
+				return nil, fmt.Errorf("%s is marked non-nullable but returned a null value", funcCtx.funcType)
+			}
+		}
+		return result, nil
+	}
 }
 
 // buildFederatedFunction creates a graphql field that exposes all the fields on the object struct.
@@ -112,18 +194,16 @@ func (sb *schemaBuilder) buildFederatedFunction(typ reflect.Type, m *method) (*g
 	if err != nil {
 		return nil, oops.Wrapf(err, "Invalid return type")
 	}
-	field := &graphql.Field{
-		Resolve: func(ctx context.Context, source, funcRawArgs interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
-			return source, nil
-
-		},
-		Args:                       make(map[string]graphql.Type),
-		Type:                       returnType,
-		ParseArguments:             argParser.Parse,
-		Expensive:                  m.Expensive,
-		External:                   true,
-		NumParallelInvocationsFunc: m.ConcurrencyArgs.numParallelInvocationsFunc,
+	field, err := m.newField(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	field.Resolve = func(ctx context.Context, source, funcRawArgs interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+		return source, nil
 	}
+	field.Args = make(map[string]graphql.Type)
+	field.Type = returnType
+	field.ParseArguments = argParser.Parse
 	return field, nil
 }
 
@@ -153,7 +233,7 @@ func (sb *schemaBuilder) buildShadowObjectFederationFunction(typ reflect.Type, m
 	in := make([]reflect.Type, 1)
 	in[0] = input
 
-	argParser, argType, _, err := funcCtx.getArgParserAndTyp(sb, in)
+	argParser, argType, _, err := funcCtx.getArgParserAndTyp(sb, m, in)
 	if err != nil {
 		return nil, oops.Wrapf(err, "Error parsing args for shadow object field")
 	}
@@ -169,22 +249,69 @@ func (sb *schemaBuilder) buildShadowObjectFederationFunction(typ reflect.Type, m
 		return nil, oops.Wrapf(err, "Invalid return type")
 	}
 	rType := &graphql.NonNull{Type: &graphql.List{Type: returnType}}
-	field := &graphql.Field{
-		Resolve: func(ctx context.Context, source, funcRawArgs interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
-			args := reflect.ValueOf(funcRawArgs)
-			keys := reflect.Indirect(args).FieldByName("Keys")
-			return keys.Interface(), nil
-		},
-		Args:                       args,
-		Type:                       rType,
-		ParseArguments:             argParser.Parse,
-		Expensive:                  m.Expensive,
-		External:                   true,
-		NumParallelInvocationsFunc: m.ConcurrencyArgs.numParallelInvocationsFunc,
+	field, err := m.newField(nil, nil)
+	if err != nil {
+		return nil, err
 	}
+	field.Resolve = func(ctx context.Context, source, funcRawArgs interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+		args := reflect.ValueOf(funcRawArgs)
+		keys := reflect.Indirect(args).FieldByName("Keys")
+		return keys.Interface(), nil
+	}
+	field.Args = args
+	field.Type = rType
+	field.ParseArguments = dropUnknownKeyFields(argParser.Parse, args["keys"])
 	return field, nil
 }
 
+// dropUnknownKeyFields wraps parse to drop the fields of the federated keys
+// that the shadow object doesn't have, rather than failing on them as unknown
+// input fields.  Other servers may send keys with fields that this one no
+// longer (or not yet) has, e.g. while a key field is added or removed.
+func dropUnknownKeyFields(parse func(interface{}) (interface{}, error), keysType graphql.Type) func(interface{}) (interface{}, error) {
+	for {
+		switch t := keysType.(type) {
+		case *graphql.NonNull:
+			keysType = t.Type
+			continue
+		case *graphql.List:
+			keysType = t.Type
+			continue
+		}
+		break
+	}
+	shadow, ok := keysType.(*graphql.InputObject)
+	if !ok {
+		return parse
+	}
+	return func(args interface{}) (interface{}, error) {
+		raw, ok := args.(map[string]interface{})
+		if !ok {
+			return parse(args)
+		}
+		keys, ok := raw["keys"].([]interface{})
+		if !ok {
+			return parse(args)
+		}
+		known := make([]interface{}, len(keys))
+		for i, key := range keys {
+			fields, ok := key.(map[string]interface{})
+			if !ok {
+				known[i] = key
+				continue
+			}
+			knownFields := make(map[string]interface{}, len(fields))
+			for name, value := range fields {
+				if _, ok := shadow.InputFields[name]; ok {
+					knownFields[name] = value
+				}
+			}
+			known[i] = knownFields
+		}
+		return parse(map[string]interface{}{"keys": known})
+	}
+}
+
 // funcContext is used to parse the function signature in buildFunction.
 type funcContext struct {
 	hasContext      bool
@@ -194,6 +321,9 @@ type funcContext struct {
 	hasRet          bool
 	hasError        bool
 
+	// returnsNullable is whether the function returns a graphql.Nullable.
+	returnsNullable bool
+
 	funcType  reflect.Type
 	isPtrFunc bool
 	typ       reflect.Type
@@ -246,12 +376,12 @@ func (funcCtx *funcContext) consumeContextAndSource(in []reflect.Type) []reflect
 // of custom parameters for the field func (at this point any input type other
 // than the selectionSet is considered the args input), we will return the
 // argParser for that type and pop that field from the returned input parameters.
-func (funcCtx *funcContext) getArgParserAndTyp(sb *schemaBuilder, in []reflect.Type) (*argParser, graphql.Type, []reflect.Type, error) {
+func (funcCtx *funcContext) getArgParserAndTyp(sb *schemaBuilder, m *method, in []reflect.Type) (*argParser, graphql.Type, []reflect.Type, error) {
 	var argParser *argParser
 	var argType graphql.Type
 	if len(in) > 0 && in[0] != selectionSetType {
 		var err error
-		if argParser, argType, err = sb.makeStructParser(in[0]); err != nil {
+		if argParser, argType, err = sb.makeArgsParser(m, in[0]); err != nil {
 			return nil, nil, in, fmt.Errorf("attempted to parse %s as arguments struct, but failed: %s", in[0].Name(), err.Error())
 		}
 		in = in[1:]
@@ -306,10 +436,28 @@ func (funcCtx *funcContext) parseReturnSignature(m *method) (err error) {
 // function.  So an object "User" that has a linked function which returns a
 // list of "Hats" will resolve the GraphQL type of a "Hat" at this point.
 func (funcCtx *funcContext) getReturnType(sb *schemaBuilder, m *method) (graphql.Type, error) {
+	// Subscriptions resolve to a channel of events, which are resolved like
+	// the results of other fields.
+	isSubscription := funcCtx.typ == reflect.TypeOf(subscription{})
+	if isSubscription && (!funcCtx.hasRet || funcCtx.funcType.Out(0).Kind() != reflect.Chan || funcCtx.funcType.Out(0).ChanDir()&reflect.RecvDir == 0) {
+		return nil, fmt.Errorf("%s is a subscription, but does not return a channel", funcCtx.funcType)
+	}
+
 	var retType graphql.Type
 	if funcCtx.hasRet {
+		out := funcCtx.funcType.Out(0)
+		if isSubscription {
+			out = out.Elem()
+		} else if valueType, ok := nullableValueType(out); ok {
+			if m.MarkedNonNullable {
+				return nil, fmt.Errorf("%s returns a graphql.Nullable, but is marked non-nullable", funcCtx.funcType)
+			}
+			funcCtx.returnsNullable = true
+			out = valueType
+		}
+
 		var err error
-		retType, err = sb.getType(funcCtx.funcType.Out(0))
+		retType, err = sb.getType(out)
 		if err != nil {
 			return nil, err
 		}
@@ -319,6 +467,33 @@ func (funcCtx *funcContext) getReturnType(sb *schemaBuilder, m *method) (graphql
 				retType = &graphql.NonNull{Type: retType}
 			}
 		}
+
+		if m.MarkedNullable && m.MarkedNonNullable {
+			return nil, fmt.Errorf("%s is marked both nullable and non-nullable", funcCtx.funcType)
+		}
+		if m.MarkedNullable || funcCtx.returnsNullable {
+			if nonNull, ok := retType.(*graphql.NonNull); ok {
+				retType = nonNull.Type
+			}
+		}
+
+		if m.MarkedNullableElements {
+			if err := makeElementsNullable(out, retType); err != nil {
+				return nil, fmt.Errorf("%s %s", funcCtx.funcType, err)
+			}
+		}
+
+		if m.MaxItems > 0 {
+			listType := retType
+			if nonNull, ok := listType.(*graphql.NonNull); ok {
+				listType = nonNull.Type
+			}
+			list, ok := listType.(*graphql.List)
+			if !ok {
+				return nil, fmt.Errorf("%s has MaxItems set, but does not return a list", funcCtx.funcType)
+			}
+			list.MaxItems = m.MaxItems
+		}
 	} else {
 		var err error
 		retType, err = sb.getType(reflect.TypeOf(true))
@@ -329,6 +504,23 @@ func (funcCtx *funcContext) getReturnType(sb *schemaBuilder, m *method) (graphql
 	return retType, nil
 }
 
+// makeElementsNullable makes the elements of retType, the list type of typ,
+// nullable, for FieldFuncs passed the NullableElements option.  Only slices
+// of pointers can have nil elements.
+func makeElementsNullable(typ reflect.Type, retType graphql.Type) error {
+	if nonNull, ok := retType.(*graphql.NonNull); ok {
+		retType = nonNull.Type
+	}
+	list, ok := retType.(*graphql.List)
+	if !ok || typ.Kind() != reflect.Slice || typ.Elem().Kind() != reflect.Ptr {
+		return errors.New("has NullableElements set, but does not return a slice of pointers")
+	}
+	if nonNull, ok := list.Type.(*graphql.NonNull); ok {
+		list.Type = nonNull.Type
+	}
+	return nil
+}
+
 // argsTypeMap returns a map from input arg field names to a graphQL type
 // associated with that field name.
 func (funcCtx *funcContext) argsTypeMap(argType graphql.Type) (map[string]graphql.Type, error) {
@@ -387,7 +579,7 @@ func (funcCtx *funcContext) prepareResolveArgs(source interface{}, hasArgs bool,
 func (funcCtx *funcContext) extractResultAndErr(out []reflect.Value, retType graphql.Type) (interface{}, error) {
 	var result interface{}
 	if funcCtx.hasRet {
-		result = out[0].Interface()
+		result = funcCtx.unwrapNullable(out[0].Interface())
 		out = out[1:]
 	} else {
 		result = true
@@ -407,3 +599,28 @@ func (funcCtx *funcContext) extractResultAndErr(out []reflect.Value, retType gra
 
 	return result, nil
 }
+
+// nullablePkgPath is the package path of graphql.Nullable.
+var nullablePkgPath = reflect.TypeOf(graphql.Nullable[bool]{}).PkgPath()
+
+// nullableValueType returns the type of the value of typ, if typ is a
+// graphql.Nullable.
+func nullableValueType(typ reflect.Type) (reflect.Type, bool) {
+	if typ.Kind() != reflect.Struct || typ.PkgPath() != nullablePkgPath || !strings.HasPrefix(typ.Name(), "Nullable[") {
+		return nil, false
+	}
+	return typ.Field(0).Type, true
+}
+
+// unwrapNullable returns the value of a graphql.Nullable result, or nil if it
+// is null.  Other results are returned as is.
+func (funcCtx *funcContext) unwrapNullable(result interface{}) interface{} {
+	if !funcCtx.returnsNullable {
+		return result
+	}
+	nullable := reflect.ValueOf(result)
+	if !nullable.Field(1).Bool() {
+		return nil
+	}
+	return nullable.Field(0).Interface()
+}
@@ -3,9 +3,12 @@ package schemabuilder
 import (
 	"encoding"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/samsarahq/thunder/graphql"
@@ -65,6 +68,153 @@ func (p *argParser) Parse(args interface{}) (interface{}, error) {
 	return parsed.Interface(), nil
 }
 
+// sanitizeArguments wraps parse to first run the method's sanitizers on the
+// raw arguments.
+func (m *method) sanitizeArguments(parse func(interface{}) (interface{}, error)) func(interface{}) (interface{}, error) {
+	if len(m.Sanitizers) == 0 {
+		return parse
+	}
+	return func(args interface{}) (interface{}, error) {
+		raw, ok := args.(map[string]interface{})
+		if !ok {
+			return parse(args)
+		}
+		sanitized := make(map[string]interface{}, len(raw))
+		for name, value := range raw {
+			sanitized[name] = value
+		}
+		for _, s := range m.Sanitizers {
+			for name, value := range sanitized {
+				if s.name != "" && s.name != name {
+					continue
+				}
+				value, err := s.sanitize(value)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s", name, err)
+				}
+				sanitized[name] = value
+			}
+		}
+		return parse(sanitized)
+	}
+}
+
+// mapArgs renames the arguments exposed by the method according to its
+// ArgMapsTo options.
+func (m *method) mapArgs(args map[string]graphql.Type) (map[string]graphql.Type, error) {
+	if len(m.ArgMappings) == 0 {
+		return args, nil
+	}
+	mapped := make(map[string]graphql.Type, len(args))
+	for name, typ := range args {
+		mapped[name] = typ
+	}
+	for name, mapsTo := range m.ArgMappings {
+		typ, ok := args[mapsTo]
+		if !ok {
+			return nil, fmt.Errorf("argument %s maps to unknown argument %s", name, mapsTo)
+		}
+		delete(mapped, mapsTo)
+		mapped[name] = typ
+	}
+	if len(mapped) != len(args) {
+		return nil, fmt.Errorf("argument mappings %v conflict with other arguments", m.ArgMappings)
+	}
+	return mapped, nil
+}
+
+// mapArguments wraps parse to rename the raw arguments from their names in
+// the schema to the names the args struct uses.  The names the args struct
+// uses aren't exposed in the schema, so raw arguments using them are rejected.
+func (m *method) mapArguments(parse func(interface{}) (interface{}, error)) func(interface{}) (interface{}, error) {
+	if len(m.ArgMappings) == 0 {
+		return parse
+	}
+	hidden := make(map[string]bool, len(m.ArgMappings))
+	for _, mapsTo := range m.ArgMappings {
+		hidden[mapsTo] = true
+	}
+	return func(args interface{}) (interface{}, error) {
+		raw, ok := args.(map[string]interface{})
+		if !ok {
+			return parse(args)
+		}
+		mapped := make(map[string]interface{}, len(raw))
+		for name, value := range raw {
+			if mapsTo, ok := m.ArgMappings[name]; ok {
+				name = mapsTo
+			} else if hidden[name] {
+				return nil, fmt.Errorf("unknown field %s", name)
+			}
+			if _, ok := mapped[name]; ok {
+				return nil, fmt.Errorf("argument %s is given more than once", name)
+			}
+			mapped[name] = value
+		}
+		return parse(mapped)
+	}
+}
+
+// argsNormalizer returns the graphql.Field NormalizeArgs func for the method,
+// checking that its NormalizeArgs matches the args struct parsed by argParser.
+func (m *method) argsNormalizer(argParser *argParser) (func(interface{}) (interface{}, error), error) {
+	if m.NormalizeArgs == nil {
+		return nil, nil
+	}
+	if argParser == nil {
+		return nil, fmt.Errorf("NormalizeArgs requires an args struct")
+	}
+
+	fn := reflect.ValueOf(m.NormalizeArgs)
+	typ := fn.Type()
+	if typ.Kind() != reflect.Func || typ.NumIn() != 1 || typ.In(0) != argParser.Type ||
+		typ.NumOut() != 2 || typ.Out(0) != argParser.Type || typ.Out(1) != errType {
+		return nil, fmt.Errorf("NormalizeArgs should be a func(%s) (%s, error), got %s", argParser.Type, argParser.Type, typ)
+	}
+	return func(args interface{}) (interface{}, error) {
+		out := fn.Call([]reflect.Value{reflect.ValueOf(args)})
+		if err, _ := out[1].Interface().(error); err != nil {
+			return nil, err
+		}
+		return out[0].Interface(), nil
+	}, nil
+}
+
+// complexityFunc returns the graphql.Field Complexity func for the method,
+// checking that its Complexity takes the args struct parsed by argParser, if
+// any.
+func (m *method) complexityFunc(argParser *argParser) (graphql.ComplexityFunc, error) {
+	if m.Complexity == nil {
+		return nil, nil
+	}
+
+	fn := reflect.ValueOf(m.Complexity)
+	typ := fn.Type()
+	intType := reflect.TypeOf(0)
+	if argParser == nil {
+		if typ.Kind() != reflect.Func || typ.NumIn() != 1 || typ.In(0) != intType ||
+			typ.NumOut() != 1 || typ.Out(0) != intType {
+			return nil, fmt.Errorf("Complexity should be a func(int) int, got %s", typ)
+		}
+		return func(args interface{}, childComplexity int) int {
+			return int(fn.Call([]reflect.Value{reflect.ValueOf(childComplexity)})[0].Int())
+		}, nil
+	}
+
+	if typ.Kind() != reflect.Func || typ.NumIn() != 2 || typ.In(0) != argParser.Type || typ.In(1) != intType ||
+		typ.NumOut() != 1 || typ.Out(0) != intType {
+		return nil, fmt.Errorf("Complexity should be a func(%s, int) int, got %s", argParser.Type, typ)
+	}
+	return func(args interface{}, childComplexity int) int {
+		argsValue := reflect.ValueOf(args)
+		if !argsValue.IsValid() || argsValue.Type() != argParser.Type {
+			// The query wasn't prepared, so its arguments weren't parsed.
+			argsValue = reflect.Zero(argParser.Type)
+		}
+		return int(fn.Call([]reflect.Value{argsValue, reflect.ValueOf(childComplexity)})[0].Int())
+	}, nil
+}
+
 // nilParseArguments is a default function for parsing args.  It expects to be
 // called with nothing, and will return an error if called with non-empty args.
 func nilParseArguments(args interface{}) (interface{}, error) {
@@ -77,6 +227,14 @@ func nilParseArguments(args interface{}) (interface{}, error) {
 	return nil, nil
 }
 
+// makeArgsParser constructs an argParser for the args struct of a method.
+func (sb *schemaBuilder) makeArgsParser(m *method, typ reflect.Type) (*argParser, graphql.Type, error) {
+	if m.JSONArgs {
+		return sb.makeJSONStructParser(typ)
+	}
+	return sb.makeStructParser(typ)
+}
+
 // makeStructParser constructs an argParser for the passed in struct type.
 func (sb *schemaBuilder) makeStructParser(typ reflect.Type) (*argParser, graphql.Type, error) {
 	argType, fields, err := sb.getStructObjectFields(typ)
@@ -92,13 +250,25 @@ func (sb *schemaBuilder) makeStructParser(typ reflect.Type) (*argParser, graphql
 			}
 
 			for name, field := range fields {
-				value := asMap[name]
+				value, ok := asMap[name]
+				if _, required := argType.InputFields[name].(*graphql.NonNull); required && value == nil {
+					if !ok {
+						return fmt.Errorf("%s: missing required value", name)
+					}
+					return fmt.Errorf("%s: must not be null", name)
+				}
 				fieldDest := dest.FieldByIndex(field.field.Index)
 				if err := field.parser.FromJSON(value, fieldDest); err != nil {
 					return fmt.Errorf("%s: %s", name, err)
 				}
 			}
 
+			for name := range asMap {
+				if _, ok := fields[name]; !ok {
+					return fmt.Errorf("unknown field %s", name)
+				}
+			}
+
 			return nil
 		},
 		Type: typ,
@@ -151,9 +321,26 @@ func (sb *schemaBuilder) getStructObjectFields(typ reflect.Type) (*graphql.Input
 		if err != nil {
 			return nil, nil, err
 		}
+		constraints, err := parseArgConstraints(field)
+		if err != nil {
+			return nil, nil, fmt.Errorf("bad arg type %s: field %s: %s", typ, fieldInfo.Name, err)
+		}
+		if constraints != nil {
+			parser = constraints.wrap(parser)
+		}
 		if fieldInfo.OptionalInputField {
 			parser, fieldArgTyp = wrapWithZeroValue(parser, fieldArgTyp)
 		}
+		if value, ok := field.Tag.Lookup("default"); ok {
+			var literal string
+			if parser, fieldArgTyp, literal, err = wrapWithDefault(parser, fieldArgTyp, value); err != nil {
+				return nil, nil, fmt.Errorf("bad arg type %s: field %s: %s", typ, fieldInfo.Name, err)
+			}
+			if argType.DefaultValues == nil {
+				argType.DefaultValues = make(map[string]string)
+			}
+			argType.DefaultValues[fieldInfo.Name] = literal
+		}
 
 		fields[fieldInfo.Name] = argField{
 			field:  field,
@@ -191,6 +378,10 @@ func (sb *schemaBuilder) makeArgParserInner(typ reflect.Type) (*argParser, graph
 		return parser, argType, nil
 	}
 
+	if parser, argType, ok := sb.getCustomScalarArgParser(typ); ok {
+		return parser, argType, nil
+	}
+
 	if parser, argType, ok := getScalarArgParser(typ); ok {
 		return parser, argType, nil
 	}
@@ -257,6 +448,107 @@ func wrapWithZeroValue(inner *argParser, fieldArgTyp graphql.Type) (*argParser,
 	}, fieldArgTyp
 }
 
+// wrapWithDefault wraps an ArgParser with a helper that will parse non-
+// provided parameters from a default value, given as JSON in the field's
+// `default` tag, e.g.
+//
+//	type args struct {
+//		Limit int64 `default:"10"`
+//	}
+//
+// The default value is checked when the schema is built, and is also returned
+// as a GraphQL literal for introspection.
+func wrapWithDefault(inner *argParser, fieldArgTyp graphql.Type, defaultJSON string) (*argParser, graphql.Type, string, error) {
+	var defaultValue interface{}
+	if err := json.Unmarshal([]byte(defaultJSON), &defaultValue); err != nil {
+		return nil, nil, "", fmt.Errorf("bad default %q: %s", defaultJSON, err)
+	}
+	if defaultValue == nil {
+		return nil, nil, "", fmt.Errorf("bad default %q: must not be null", defaultJSON)
+	}
+	if err := inner.FromJSON(defaultValue, reflect.New(inner.Type).Elem()); err != nil {
+		return nil, nil, "", fmt.Errorf("bad default %q: %s", defaultJSON, err)
+	}
+	literal := graphqlLiteral(defaultValue, fieldArgTyp)
+
+	// Make sure the "fieldArgType" we expose in graphQL is a Nullable field.
+	if f, ok := fieldArgTyp.(*graphql.NonNull); ok {
+		fieldArgTyp = f.Type
+	}
+	return &argParser{
+		FromJSON: func(value interface{}, dest reflect.Value) error {
+			if value == nil {
+				value = defaultValue
+			}
+			return inner.FromJSON(value, dest)
+		},
+		Type: inner.Type,
+	}, fieldArgTyp, literal, nil
+}
+
+// graphqlLiteral formats a JSON value of type typ as a GraphQL literal, e.g.
+// {order: desc, ids: [1, 2]}.
+func graphqlLiteral(value interface{}, typ graphql.Type) string {
+	if nonNull, ok := typ.(*graphql.NonNull); ok {
+		typ = nonNull.Type
+	}
+	switch value := value.(type) {
+	case []interface{}:
+		var elemTyp graphql.Type
+		if list, ok := typ.(*graphql.List); ok {
+			elemTyp = list.Type
+		}
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			items = append(items, graphqlLiteral(item, elemTyp))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]interface{}:
+		var fieldTyps map[string]graphql.Type
+		if inputObject, ok := typ.(*graphql.InputObject); ok {
+			fieldTyps = inputObject.InputFields
+		}
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fields := make([]string, 0, len(names))
+		for _, name := range names {
+			fields = append(fields, name+": "+graphqlLiteral(value[name], fieldTyps[name]))
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	case string:
+		// Enum values are names rather than strings.
+		if _, ok := typ.(*graphql.Enum); ok {
+			return value
+		}
+	}
+	literal, _ := json.Marshal(value)
+	return string(literal)
+}
+
+// argDefaultValues returns the default values of the arguments parsed into
+// argType, named as in the schema (see mapArgs).
+func (m *method) argDefaultValues(argType graphql.Type) map[string]string {
+	inputObject, ok := argType.(*graphql.InputObject)
+	if !ok || len(inputObject.DefaultValues) == 0 {
+		return nil
+	}
+	schemaNames := make(map[string]string, len(m.ArgMappings))
+	for name, mapsTo := range m.ArgMappings {
+		schemaNames[mapsTo] = name
+	}
+	defaults := make(map[string]string, len(inputObject.DefaultValues))
+	for name, value := range inputObject.DefaultValues {
+		if schemaName, ok := schemaNames[name]; ok {
+			name = schemaName
+		}
+		defaults[name] = value
+	}
+	return defaults
+}
+
 // getEnumArgParser creates an arg parser for an Enum type.
 func (sb *schemaBuilder) getEnumArgParser(typ reflect.Type) (*argParser, graphql.Type) {
 	var values []string
@@ -268,13 +560,13 @@ func (sb *schemaBuilder) getEnumArgParser(typ reflect.Type) (*argParser, graphql
 		if !ok {
 			return errors.New("not a string")
 		}
-		val, ok := sb.enumMappings[typ].Map[asString]
+		val, ok := sb.enumMappings[typ].lookup(asString)
 		if !ok {
 			return fmt.Errorf("unknown enum value %v", asString)
 		}
 		dest.Set(reflect.ValueOf(val).Convert(dest.Type()))
 		return nil
-	}, Type: typ}, &graphql.Enum{Type: typ.Name(), Values: values, ReverseMap: sb.enumMappings[typ].ReverseMap}
+	}, Type: typ}, &graphql.Enum{Type: typ.Name(), Values: values, ReverseMap: sb.enumMappings[typ].ReverseMap, DeprecatedValues: sb.enumMappings[typ].DeprecatedValues}
 
 }
 
@@ -0,0 +1,181 @@
+package schemabuilder
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+// makeJSONStructParser constructs an argParser for the args struct of a
+// FieldFunc passed the JSONArgs option.  The arguments are named by the
+// struct's json tags, as encoding/json would decode them, while their values
+// are parsed by the same parsers as other arguments, so enums and scalars
+// (including those implementing encoding.TextUnmarshaler) are checked and
+// converted as usual.
+func (sb *schemaBuilder) makeJSONStructParser(typ reflect.Type) (*argParser, graphql.Type, error) {
+	if typ.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("expected struct but received type %s", typ.Kind())
+	}
+	parser, argType, err := sb.makeJSONStructType(typ, make(map[reflect.Type]bool))
+	if err != nil {
+		return nil, nil, err
+	}
+	return parser, argType, nil
+}
+
+// makeJSONArgParser returns the argParser and graphql type for a value named
+// by json tags.  visiting holds the structs being built, since recursive input
+// structs are not supported.
+func (sb *schemaBuilder) makeJSONArgParser(typ reflect.Type, visiting map[reflect.Type]bool) (*argParser, graphql.Type, error) {
+	if typ.Kind() == reflect.Ptr {
+		parser, argType, err := sb.makeJSONArgParserInner(typ.Elem(), visiting)
+		if err != nil {
+			return nil, nil, err
+		}
+		return wrapPtrParser(parser), argType, nil
+	}
+
+	parser, argType, err := sb.makeJSONArgParserInner(typ, visiting)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parser, &graphql.NonNull{Type: argType}, nil
+}
+
+// makeJSONArgParserInner is a helper function for makeJSONArgParser that
+// doesn't need to worry about pointer types.
+func (sb *schemaBuilder) makeJSONArgParserInner(typ reflect.Type, visiting map[reflect.Type]bool) (*argParser, graphql.Type, error) {
+	if sb.enumMappings[typ] != nil {
+		parser, argType := sb.getEnumArgParser(typ)
+		return parser, argType, nil
+	}
+
+	if parser, argType, ok := sb.getCustomScalarArgParser(typ); ok {
+		return parser, argType, nil
+	}
+
+	if parser, argType, ok := getScalarArgParser(typ); ok {
+		return parser, argType, nil
+	}
+
+	if reflect.PtrTo(typ).Implements(textUnmarshalerType) {
+		return sb.makeTextUnmarshalerParser(typ)
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		return sb.makeJSONStructType(typ, visiting)
+	case reflect.Slice:
+		inner, elemType, err := sb.makeJSONArgParser(typ.Elem(), visiting)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := elemType.(*graphql.NonNull); !ok {
+			elemType = &graphql.NonNull{Type: elemType}
+		}
+		return &argParser{
+			FromJSON: func(value interface{}, dest reflect.Value) error {
+				asSlice, ok := value.([]interface{})
+				if !ok {
+					return errors.New("not a list")
+				}
+				dest.Set(reflect.MakeSlice(typ, len(asSlice), len(asSlice)))
+				for i, value := range asSlice {
+					if value == nil {
+						return fmt.Errorf("%d: required", i)
+					}
+					if err := inner.FromJSON(value, dest.Index(i)); err != nil {
+						return fmt.Errorf("%d: %s", i, err)
+					}
+				}
+				return nil
+			},
+			Type: typ,
+		}, &graphql.List{Type: elemType}, nil
+	default:
+		return nil, nil, fmt.Errorf("bad arg type %s: should be struct, scalar, pointer, or a slice", typ)
+	}
+}
+
+// makeJSONStructType returns the argParser and input object for a struct
+// named by json tags.  Fields tagged omitempty are optional.  The input
+// object is named <Name>_JSONInputObject, so it doesn't collide with the
+// input object of the same struct used without JSONArgs, whose fields are
+// named differently.
+func (sb *schemaBuilder) makeJSONStructType(typ reflect.Type, visiting map[reflect.Type]bool) (*argParser, graphql.Type, error) {
+	if typ.Name() == "" {
+		return nil, nil, fmt.Errorf("bad type %s: should have a name", typ)
+	}
+	if visiting[typ] {
+		return nil, nil, fmt.Errorf("bad arg type %s: recursive types are not supported with JSONArgs", typ)
+	}
+	visiting[typ] = true
+	defer delete(visiting, typ)
+
+	argType := &graphql.InputObject{
+		Name:        typ.Name() + "_JSONInputObject",
+		InputFields: make(map[string]graphql.Type),
+	}
+	fields := make(map[string]argField)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if field.Anonymous {
+			return nil, nil, fmt.Errorf("bad arg type %s: anonymous fields not supported", typ)
+		}
+
+		tags := strings.Split(field.Tag.Get("json"), ",")
+		name := tags[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if _, ok := fields[name]; ok {
+			return nil, nil, fmt.Errorf("bad arg type %s: duplicate field %s", typ, name)
+		}
+
+		parser, fieldType, err := sb.makeJSONArgParser(field.Type, visiting)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, tag := range tags[1:] {
+			if tag == "omitempty" {
+				parser, fieldType = wrapWithZeroValue(parser, fieldType)
+			}
+		}
+		argType.InputFields[name] = fieldType
+		fields[name] = argField{field: field, parser: parser}
+	}
+
+	return &argParser{
+		FromJSON: func(value interface{}, dest reflect.Value) error {
+			asMap, ok := value.(map[string]interface{})
+			if !ok {
+				return errors.New("not an object")
+			}
+			for name := range asMap {
+				if _, ok := fields[name]; !ok {
+					return fmt.Errorf("%s: unknown field", name)
+				}
+			}
+			for name, field := range fields {
+				value := asMap[name]
+				if _, required := argType.InputFields[name].(*graphql.NonNull); required && value == nil {
+					return fmt.Errorf("%s: required", name)
+				}
+				if err := field.parser.FromJSON(value, dest.FieldByIndex(field.field.Index)); err != nil {
+					return fmt.Errorf("%s: %s", name, err)
+				}
+			}
+			return nil
+		},
+		Type: typ,
+	}, argType, nil
+}
@@ -0,0 +1,79 @@
+package schemabuilder
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FieldMapping exposes a field that projects or renames a value of the
+// object's Go type, without a hand-written FieldFunc.  The mapping declares
+// where the value comes from, as a path of struct fields starting from the
+// source object, e.g.
+//
+//	user.FieldMapping("city", "from source.Address.City")
+//
+// resolves city to the City field of the user's Address.  If a pointer along
+// the path is nil, the field resolves to the zero value of its type (e.g. null
+// for a pointer).  FieldMapping panics if the mapping doesn't match the Go
+// type.
+func (s *Object) FieldMapping(name, mapping string, options ...FieldFuncOption) {
+	fn, err := makeMappingFunc(reflect.TypeOf(s.Type), mapping)
+	if err != nil {
+		panic(fmt.Sprintf("bad mapping for field %s: %s", name, err))
+	}
+	s.FieldFunc(name, fn, options...)
+}
+
+// makeMappingFunc builds a func(*T) R for the source type T that resolves the
+// mapping's path, where R is the type of the last field on the path.
+func makeMappingFunc(typ reflect.Type, mapping string) (interface{}, error) {
+	if typ == nil {
+		return nil, fmt.Errorf("object has no type")
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	parts := strings.Fields(mapping)
+	if len(parts) != 2 || parts[0] != "from" {
+		return nil, fmt.Errorf("mapping %q should look like \"from source.Field\"", mapping)
+	}
+	path := strings.Split(parts[1], ".")
+	if path[0] != "source" || len(path) < 2 {
+		return nil, fmt.Errorf("mapping %q should start with \"source.\"", mapping)
+	}
+	path = path[1:]
+
+	var indices [][]int
+	cur := typ
+	for _, name := range path {
+		if cur.Kind() == reflect.Ptr {
+			cur = cur.Elem()
+		}
+		if cur.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("%s is not a struct, so it has no field %s", cur, name)
+		}
+		field, ok := cur.FieldByName(name)
+		if !ok || field.PkgPath != "" {
+			return nil, fmt.Errorf("%s has no exported field %s", cur, name)
+		}
+		indices = append(indices, field.Index)
+		cur = field.Type
+	}
+
+	funcType := reflect.FuncOf([]reflect.Type{reflect.PtrTo(typ)}, []reflect.Type{cur}, false)
+	return reflect.MakeFunc(funcType, func(args []reflect.Value) []reflect.Value {
+		value := args[0]
+		for _, index := range indices {
+			if value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					return []reflect.Value{reflect.Zero(cur)}
+				}
+				value = value.Elem()
+			}
+			value = value.FieldByIndex(index)
+		}
+		return []reflect.Value{value}
+	}).Interface(), nil
+}
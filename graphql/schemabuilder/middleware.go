@@ -0,0 +1,61 @@
+package schemabuilder
+
+import (
+	"context"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+// FieldMiddleware wraps the resolver of a field, e.g. to check that the
+// caller is authorized to read it.  field is the name of the field being
+// resolved, and next resolves it for a batch of sources.  Fields that are not
+// batched are resolved with a single source at a time.
+type FieldMiddleware func(field string, next graphql.BatchResolver) graphql.BatchResolver
+
+// Use wraps every field of the object, including plain struct fields and the
+// fallbacks of fields (see Fallback), with the middlewares.  Middlewares run in the order they are registered, so the
+// first one is the outermost.
+func (s *Object) Use(middlewares ...FieldMiddleware) {
+	s.middlewares = append(s.middlewares, middlewares...)
+}
+
+// applyFieldMiddlewares wraps the resolvers of the fields with middlewares.
+func applyFieldMiddlewares(fields map[string]*graphql.Field, middlewares []FieldMiddleware) {
+	if len(middlewares) == 0 {
+		return
+	}
+	for name, field := range fields {
+		wrap := func(next graphql.BatchResolver) graphql.BatchResolver {
+			for i := len(middlewares) - 1; i >= 0; i-- {
+				next = middlewares[i](name, next)
+			}
+			return next
+		}
+
+		if field.BatchResolver != nil {
+			field.BatchResolver = wrap(field.BatchResolver)
+		}
+		if field.Fallback != nil {
+			// The fallback resolves the field too, so it must go through the
+			// same checks as the resolver.
+			field.Fallback = wrap(field.Fallback)
+		}
+		if field.Resolve != nil {
+			resolve := field.Resolve
+			batch := wrap(func(ctx context.Context, sources []interface{}, args interface{}, selectionSet *graphql.SelectionSet) ([]interface{}, error) {
+				result, err := resolve(ctx, sources[0], args, selectionSet)
+				if err != nil {
+					return nil, err
+				}
+				return []interface{}{result}, nil
+			})
+			field.Resolve = func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+				results, err := batch(ctx, []interface{}{source}, args, selectionSet)
+				if err != nil {
+					return nil, err
+				}
+				return results[0], nil
+			}
+		}
+	}
+}
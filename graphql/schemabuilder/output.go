@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 
 	"github.com/samsarahq/thunder/graphql"
 )
@@ -26,15 +27,25 @@ func (sb *schemaBuilder) buildStruct(typ reflect.Type) error {
 		return sb.buildUnionStruct(typ)
 	}
 
+	if typ == interfaceType {
+		return fmt.Errorf("schemabuilder.Interface can only be used as an embedded anonymous non-pointer struct")
+	}
+
+	if hasInterfaceMarkerEmbedded(typ) {
+		return sb.buildInterfaceStruct(typ)
+	}
+
 	var name string
 	var description string
 	var methods Methods
 	var objectKey string
+	var middlewares []FieldMiddleware
 	if object, ok := sb.objects[typ]; ok {
 		name = object.Name
 		description = object.Description
 		methods = object.Methods
 		objectKey = object.key
+		middlewares = object.middlewares
 	}
 
 	if name == "" {
@@ -55,8 +66,13 @@ func (sb *schemaBuilder) buildStruct(typ reflect.Type) error {
 	sb.types[typ] = object
 	sb.typeNames[name] = typ
 
+	isProto := isProtoMessage(typ)
+
 	for i := 0; i < typ.NumField(); i++ {
 		field := typ.Field(i)
+		if isProto && isProtoInternalField(field) {
+			continue
+		}
 		fieldInfo, err := parseGraphQLFieldInfo(field)
 		if err != nil {
 			return fmt.Errorf("bad type %s: %s", typ, fieldInfo.Name)
@@ -69,6 +85,15 @@ func (sb *schemaBuilder) buildStruct(typ reflect.Type) error {
 			return fmt.Errorf("bad type %s: two fields named %s", typ, fieldInfo.Name)
 		}
 
+		if isProto && isProtoOneofField(field) {
+			built, err := sb.buildProtoOneofField(typ, field)
+			if err != nil {
+				return fmt.Errorf("bad field %s on type %s: %s", fieldInfo.Name, typ, err)
+			}
+			object.Fields[fieldInfo.Name] = built
+			continue
+		}
+
 		built, err := sb.buildField(field)
 		if err != nil {
 			return fmt.Errorf("bad field %s on type %s: %s", fieldInfo.Name, typ, err)
@@ -134,6 +159,16 @@ func (sb *schemaBuilder) buildStruct(typ reflect.Type) error {
 		}
 		object.Fields[name] = built
 	}
+	for _, name := range names {
+		object.Fields[name].DeprecationReason = methods[name].DeprecationReason
+		object.Fields[name].Fallback = methods[name].Fallback
+		object.Fields[name].EnrichContext = methods[name].EnrichContext
+	}
+
+	if err := checkFieldDependencies(object.Fields); err != nil {
+		return fmt.Errorf("bad type %s: %s", typ, err)
+	}
+	applyFieldMiddlewares(object.Fields, middlewares)
 
 	if objectKey != "" {
 		keyPtr, ok := object.Fields[objectKey]
@@ -215,6 +250,158 @@ func (sb *schemaBuilder) buildUnionStruct(typ reflect.Type) error {
 	return nil
 }
 
+// buildResultUnion builds a graphql.Union for an interface type registered
+// with ResultUnion.  The union's sources are the member values themselves.
+func (sb *schemaBuilder) buildResultUnion(typ reflect.Type, result *resultUnion) error {
+	if sb.types[typ] != nil {
+		return nil
+	}
+	if originalType, ok := sb.typeNames[result.name]; ok {
+		return fmt.Errorf("duplicate name %s: seen both %v and %v", result.name, originalType, typ)
+	}
+
+	memberNames := make(map[reflect.Type]string, len(result.members))
+	union := &graphql.Union{
+		Name:  result.name,
+		Types: make(map[string]*graphql.Object),
+		MemberOf: func(source interface{}) (string, bool) {
+			name, ok := memberNames[reflect.TypeOf(source)]
+			return name, ok
+		},
+	}
+	sb.types[typ] = union
+	sb.typeNames[result.name] = typ
+
+	for _, member := range result.members {
+		memberTyp, err := sb.getType(member)
+		if err != nil {
+			return err
+		}
+		obj, ok := memberTyp.(*graphql.Object)
+		if !ok {
+			return fmt.Errorf("bad type %s: union type member must be a pointer to a struct, received %s", result.name, memberTyp.String())
+		}
+		if union.Types[obj.Name] != nil {
+			return fmt.Errorf("bad type %s: union type member may only appear once", result.name)
+		}
+		union.Types[obj.Name] = obj
+		memberNames[member] = obj.Name
+	}
+	return nil
+}
+
+// hasInterfaceMarkerEmbedded determines if a struct has an embedded
+// schemabuilder.Interface field embedded on the type.
+func hasInterfaceMarkerEmbedded(typ reflect.Type) bool {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Anonymous && field.Type == interfaceType {
+			return true
+		}
+	}
+	return false
+}
+
+// buildInterfaceStruct builds a graphql.Interface type whose values are one of
+// its member types.  Its fields are filled in by buildInterfaceFields once the
+// members are built.
+func (sb *schemaBuilder) buildInterfaceStruct(typ reflect.Type) error {
+	name := typ.Name()
+	if name == "" {
+		return fmt.Errorf("bad type %s: should have a name", typ)
+	}
+	if originalType, ok := sb.typeNames[name]; ok {
+		return fmt.Errorf("duplicate name %s: seen both %v and %v", name, originalType, typ)
+	}
+
+	iface := &graphql.Interface{
+		Name:  name,
+		Types: make(map[string]*graphql.Object),
+	}
+	sb.types[typ] = iface
+	sb.typeNames[name] = typ
+	sb.interfaces = append(sb.interfaces, iface)
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" || (field.Anonymous && field.Type == interfaceType) {
+			continue
+		}
+
+		if !field.Anonymous {
+			return fmt.Errorf("bad type %s: interface type member types must be anonymous", name)
+		}
+
+		typ, err := sb.getType(field.Type)
+		if err != nil {
+			return err
+		}
+
+		obj, ok := typ.(*graphql.Object)
+		if !ok {
+			return fmt.Errorf("bad type %s: interface type member must be a pointer to a struct, received %s", name, typ.String())
+		}
+
+		if iface.Types[obj.Name] != nil {
+			return fmt.Errorf("bad type %s: interface type member may only appear once", name)
+		}
+
+		iface.Types[obj.Name] = obj
+	}
+	if len(iface.Types) == 0 {
+		return fmt.Errorf("bad type %s: interface type must have at least one member", name)
+	}
+	return nil
+}
+
+// buildInterfaceFields sets the fields of an interface to those that all of
+// its members have with the same type and arguments, and registers the
+// interface on its members.
+func buildInterfaceFields(iface *graphql.Interface) {
+	names := make([]string, 0, len(iface.Types))
+	for name := range iface.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	iface.Fields = make(map[string]*graphql.Field)
+	for fieldName, field := range iface.Types[names[0]].Fields {
+		shared := true
+		for _, name := range names[1:] {
+			other, ok := iface.Types[name].Fields[fieldName]
+			if !ok || !sameFieldSignature(field, other) {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			iface.Fields[fieldName] = field
+		}
+	}
+
+	for _, member := range iface.Types {
+		if member.Interfaces == nil {
+			member.Interfaces = make(map[string]*graphql.Interface)
+		}
+		member.Interfaces[iface.Name] = iface
+	}
+}
+
+// sameFieldSignature returns whether two fields have the same type and
+// arguments.
+func sameFieldSignature(a, b *graphql.Field) bool {
+	if a.Type.String() != b.Type.String() || len(a.Args) != len(b.Args) {
+		return false
+	}
+	for name, typ := range a.Args {
+		other, ok := b.Args[name]
+		if !ok || typ.String() != other.String() {
+			return false
+		}
+	}
+	return true
+}
+
 // isScalarType returns whether a graphql.Type is a scalar type (or a non-null
 // wrapped scalar type).
 func isScalarType(typ graphql.Type) bool {
@@ -247,3 +434,51 @@ func (sb *schemaBuilder) buildField(field reflect.StructField) (*graphql.Field,
 		ParseArguments: nilParseArguments,
 	}, nil
 }
+
+// checkFieldDependencies verifies that every field's DependsOn and Requires
+// name a sibling field, and that the dependencies do not form a cycle.
+func checkFieldDependencies(fields map[string]*graphql.Field) error {
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("field dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dependency := range fields[name].DependsOn {
+			if _, ok := fields[dependency]; !ok {
+				return fmt.Errorf("field %s depends on unknown field %s", name, dependency)
+			}
+			if err := visit(dependency, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+
+	for _, name := range names {
+		for _, required := range fields[name].Requires {
+			if _, ok := fields[required]; !ok {
+				return fmt.Errorf("field %s requires unknown field %s", name, required)
+			}
+		}
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
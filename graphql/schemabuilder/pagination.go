@@ -1042,6 +1042,10 @@ func (c *connectionContext) parsePaginatedReturnSignature(m *method) (err error)
 
 // buildPaginatedFieldWithFallback corresponds to buildFunction on a manually paginated type and a fallback paginated type
 func (sb *schemaBuilder) buildPaginatedFieldWithFallback(typ reflect.Type, m *method) (*graphql.Field, error) {
+	if m.NormalizeArgs != nil {
+		// The versions parse their arguments into different structs.
+		return nil, fmt.Errorf("NormalizeArgs is not supported for manually paginated fields with a fallback")
+	}
 	fallbackField, fallbackFuncCtx, err := sb.buildPaginatedFunctionAndFuncCtx(typ, &method{
 		Fn:                m.ManualPaginationArgs.FallbackFunc,
 		Expensive:         m.Expensive,
@@ -1085,33 +1089,43 @@ func (sb *schemaBuilder) buildPaginatedFieldWithFallback(typ reflect.Type, m *me
 		argParser:         manualPaginationField.ParseArguments,
 		fallbackArgParser: fallbackField.ParseArguments,
 	}
-	field := &graphql.Field{
-		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (i interface{}, e error) {
-			dualArgs := args.(dualArgResponses)
-			if m.ManualPaginationArgs.ShouldUseBatchFunc(ctx) {
-				return fallbackField.Resolve(ctx, source, dualArgs.fallbackArgValue, selectionSet)
+	// The field takes the options of the manually paginated version, but
+	// resolves and parses arguments for both versions.
+	field := *manualPaginationField
+	field.Resolve = func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (i interface{}, e error) {
+		dualArgs := args.(dualArgResponses)
+		if m.ManualPaginationArgs.ShouldUseBatchFunc(ctx) {
+			return fallbackField.Resolve(ctx, source, dualArgs.fallbackArgValue, selectionSet)
+		}
+		return manualPaginationField.Resolve(ctx, source, dualArgs.argValue, selectionSet)
+	}
+	if m.wrapResolve != nil {
+		field.Resolve = m.wrapResolve(field.Resolve)
+	}
+	field.ParseArguments = dualParser.Parse
+	if complexity := manualPaginationField.Complexity; complexity != nil {
+		field.Complexity = func(args interface{}, childComplexity int) int {
+			if dualArgs, ok := args.(dualArgResponses); ok {
+				args = dualArgs.argValue
 			}
-			return manualPaginationField.Resolve(ctx, source, dualArgs.argValue, selectionSet)
-		},
-		Type:                       manualPaginationField.Type,
-		Args:                       manualPaginationField.Args,
-		ParseArguments:             dualParser.Parse,
-		UseBatchFunc:               manualPaginationField.UseBatchFunc,
-		Batch:                      manualPaginationField.Batch,
-		External:                   manualPaginationField.External,
-		Expensive:                  manualPaginationField.Expensive,
-		NumParallelInvocationsFunc: manualPaginationField.NumParallelInvocationsFunc,
+			return complexity(args, childComplexity)
+		}
 	}
 
-	return field, nil
-
+	return &field, nil
 }
 
 // buildPaginatedField corresponds to buildFunction on a paginated type. It wraps the return result
 // of f in a connection type.
 func (sb *schemaBuilder) buildPaginatedField(typ reflect.Type, m *method) (*graphql.Field, error) {
 	paginatedField, _, err := sb.buildPaginatedFunctionAndFuncCtx(typ, m)
-	return paginatedField, err
+	if err != nil {
+		return nil, err
+	}
+	if m.wrapResolve != nil {
+		paginatedField.Resolve = m.wrapResolve(paginatedField.Resolve)
+	}
+	return paginatedField, nil
 }
 
 func (sb *schemaBuilder) buildPaginatedFunctionAndFuncCtx(typ reflect.Type, m *method) (*graphql.Field, *connectionContext, error) {
@@ -1178,35 +1192,40 @@ func (sb *schemaBuilder) buildPaginatedFunctionAndFuncCtx(typ reflect.Type, m *m
 	}
 
 	args, err := c.argsTypeMap(argType)
+	if err == nil {
+		args, err = m.mapArgs(args)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
 
-	ret := &graphql.Field{
-		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+	ret, err := m.newField(argParser, argType)
+	if err != nil {
+		return nil, nil, err
+	}
+	ret.Resolve = func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
 
-			argsVal := args
-			hasArgs := true
-			if !c.IsExternallyManaged() {
-				val, ok := args.(ConnectionArgs)
-				if !ok {
-					return nil, fmt.Errorf("arguments should implement ConnectionArgs")
-				}
-				hasArgs = val.Args != nil
-				if hasArgs {
-					argsVal = reflect.ValueOf(val.Args).Elem().Interface()
-				}
+		argsVal := args
+		hasArgs := true
+		if !c.IsExternallyManaged() {
+			val, ok := args.(ConnectionArgs)
+			if !ok {
+				return nil, fmt.Errorf("arguments should implement ConnectionArgs")
 			}
-			in := c.prepareResolveArgs(source, hasArgs, argsVal, ctx, selectionSet)
-			var out []reflect.Value
-			out = fun.Call(in)
-			return c.extractReturnAndErr(ctx, out, args, retType)
+			hasArgs = val.Args != nil
+			if hasArgs {
+				argsVal = reflect.ValueOf(val.Args).Elem().Interface()
+			}
+		}
+		in := c.prepareResolveArgs(source, hasArgs, argsVal, ctx, selectionSet)
+		var out []reflect.Value
+		out = fun.Call(in)
+		return c.extractReturnAndErr(ctx, out, args, retType)
 
-		},
-		Args:                       args,
-		Type:                       retType,
-		ParseArguments:             argParser.Parse,
-		Expensive:                  m.Expensive,
-		External:                   true,
-		NumParallelInvocationsFunc: m.ConcurrencyArgs.numParallelInvocationsFunc,
 	}
+	ret.Args = args
+	ret.Type = retType
+	ret.ParseArguments = m.sanitizeArguments(m.mapArguments(argParser.Parse))
 	return ret, c, nil
 }
 
@@ -1410,6 +1429,7 @@ func (sb *schemaBuilder) buildPaginatedArgParser(originalArgType reflect.Type) (
 		for name, typ := range userInputObject.InputFields {
 			argType.InputFields[name] = typ
 		}
+		argType.DefaultValues = userInputObject.DefaultValues
 	}
 
 	return &argParser{
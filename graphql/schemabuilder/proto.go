@@ -0,0 +1,190 @@
+package schemabuilder
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+// protoMessage matches the methods generated on every protobuf message by
+// both golang/protobuf and gogo/protobuf.  We declare it locally so the
+// schemabuilder does not need to depend on either package.
+type protoMessage interface {
+	Reset()
+	String() string
+	ProtoMessage()
+}
+
+var protoMessageType = reflect.TypeOf((*protoMessage)(nil)).Elem()
+
+// isProtoMessage returns whether the passed in struct type is a generated
+// protobuf message.
+func isProtoMessage(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Struct && reflect.PtrTo(typ).Implements(protoMessageType)
+}
+
+// isProtoInternalField returns whether a struct field is one of the XXX_
+// bookkeeping fields generated on protobuf messages, which should never be
+// exposed over GraphQL.
+func isProtoInternalField(field reflect.StructField) bool {
+	return strings.HasPrefix(field.Name, "XXX_")
+}
+
+// isProtoOneofField returns whether a struct field holds a protobuf oneof.
+func isProtoOneofField(field reflect.StructField) bool {
+	return field.Tag.Get("protobuf_oneof") != "" && field.Type.Kind() == reflect.Interface
+}
+
+// protoTimestampPkgPaths are the packages declaring the generated Go type of
+// the google.protobuf.Timestamp well-known type.
+var protoTimestampPkgPaths = map[string]bool{
+	"github.com/golang/protobuf/ptypes/timestamp":        true,
+	"google.golang.org/protobuf/types/known/timestamppb": true,
+	"github.com/gogo/protobuf/types":                     true,
+}
+
+// isProtoTimestamp returns whether the type is the google.protobuf.Timestamp
+// well-known type (from either golang/protobuf or gogo/protobuf).
+func isProtoTimestamp(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct || typ.Name() != "Timestamp" || !protoTimestampPkgPaths[typ.PkgPath()] {
+		return false
+	}
+	seconds, ok := typ.FieldByName("Seconds")
+	if !ok || seconds.Type.Kind() != reflect.Int64 {
+		return false
+	}
+	nanos, ok := typ.FieldByName("Nanos")
+	if !ok || nanos.Type.Kind() != reflect.Int32 {
+		return false
+	}
+	return true
+}
+
+// getProtoTimestampType returns a "Time" scalar that converts a protobuf
+// Timestamp into a time.Time in the GraphQL response.
+func getProtoTimestampType(typ reflect.Type) graphql.Type {
+	scalar := &graphql.Scalar{
+		Type: "Time",
+		Unwrapper: func(source interface{}) (interface{}, error) {
+			value := reflect.ValueOf(source)
+			if value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					return nil, nil
+				}
+				value = value.Elem()
+			}
+			if !value.IsValid() {
+				return nil, nil
+			}
+			seconds := value.FieldByName("Seconds").Int()
+			nanos := value.FieldByName("Nanos").Int()
+			return time.Unix(seconds, nanos).UTC(), nil
+		},
+	}
+	if typ.Kind() == reflect.Ptr {
+		return scalar
+	}
+	return &graphql.NonNull{Type: scalar}
+}
+
+// protoOneofWrappers returns the wrapper types that may be assigned to the
+// oneof fields of a protobuf message.  Newer generators expose these through
+// XXX_OneofWrappers, while older ones return them as the last value of
+// XXX_OneofFuncs.
+func protoOneofWrappers(typ reflect.Type) []reflect.Type {
+	msg := reflect.New(typ)
+	var wrappers []interface{}
+	for _, name := range []string{"XXX_OneofWrappers", "XXX_OneofFuncs"} {
+		method := msg.MethodByName(name)
+		if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() == 0 {
+			continue
+		}
+		out := method.Call(nil)
+		if w, ok := out[len(out)-1].Interface().([]interface{}); ok {
+			wrappers = w
+			break
+		}
+	}
+
+	types := make([]reflect.Type, 0, len(wrappers))
+	for _, wrapper := range wrappers {
+		types = append(types, reflect.TypeOf(wrapper))
+	}
+	return types
+}
+
+// buildProtoOneofField builds a field for a protobuf oneof.  The oneof is
+// exposed as a graphql.Union whose members are the generated wrapper types,
+// e.g. a oneof "payload" on a message Event is resolved as one of
+// Event_Text, Event_Number, etc.
+func (sb *schemaBuilder) buildProtoOneofField(typ reflect.Type, field reflect.StructField) (*graphql.Field, error) {
+	name := fmt.Sprintf("%s_%s", typ.Name(), field.Name)
+	if originalType, ok := sb.typeNames[name]; ok {
+		return nil, fmt.Errorf("duplicate name %s: seen both %v and oneof %s on %v", name, originalType, field.Name, typ)
+	}
+
+	union := &graphql.Union{
+		Name:  name,
+		Types: make(map[string]*graphql.Object),
+	}
+	sb.typeNames[name] = field.Type
+
+	// The union is resolved by the executor as a one-hot struct, so we
+	// synthesize one with a field for every possible wrapper type.
+	var unionFields []reflect.StructField
+	for _, wrapperType := range protoOneofWrappers(typ) {
+		if !wrapperType.Implements(field.Type) {
+			continue
+		}
+		if wrapperType.Kind() != reflect.Ptr || wrapperType.Elem().Kind() != reflect.Struct {
+			return nil, fmt.Errorf("bad oneof %s on %v: wrapper %v should be a pointer to a struct", field.Name, typ, wrapperType)
+		}
+
+		memberType, err := sb.getType(wrapperType)
+		if err != nil {
+			return nil, err
+		}
+		obj, ok := memberType.(*graphql.Object)
+		if !ok {
+			return nil, fmt.Errorf("bad oneof %s on %v: wrapper %v should be an object", field.Name, typ, wrapperType)
+		}
+		union.Types[obj.Name] = obj
+		unionFields = append(unionFields, reflect.StructField{Name: obj.Name, Type: wrapperType})
+	}
+	if len(unionFields) == 0 {
+		return nil, fmt.Errorf("bad oneof %s on %v: no wrapper types found", field.Name, typ)
+	}
+
+	unionStruct := reflect.StructOf(unionFields)
+	fieldIndexes := make(map[reflect.Type]int, len(unionFields))
+	for i, unionField := range unionFields {
+		fieldIndexes[unionField.Type] = i
+	}
+
+	// Oneof fields are always nullable, since none of the options may be set.
+	return &graphql.Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+			value := reflect.ValueOf(source)
+			if value.Kind() == reflect.Ptr {
+				value = value.Elem()
+			}
+			oneof := value.FieldByIndex(field.Index)
+			if oneof.IsNil() {
+				return nil, nil
+			}
+			idx, ok := fieldIndexes[oneof.Elem().Type()]
+			if !ok {
+				return nil, fmt.Errorf("unknown oneof type %v", oneof.Elem().Type())
+			}
+			result := reflect.New(unionStruct)
+			result.Elem().Field(idx).Set(oneof.Elem())
+			return result.Interface(), nil
+		},
+		Type:           union,
+		ParseArguments: nilParseArguments,
+	}, nil
+}
@@ -0,0 +1,131 @@
+package schemabuilder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/internal"
+	"github.com/stretchr/testify/require"
+)
+
+// The types below mirror what protoc-gen-go generates for:
+//
+//	message ProtoEvent {
+//	  string event_name = 1;
+//	  google.protobuf.Timestamp created_at = 2;
+//	  oneof payload {
+//	    string text = 3;
+//	    int64 count = 4;
+//	  }
+//	}
+type ProtoEvent struct {
+	EventName string           `protobuf:"bytes,1,opt,name=event_name,json=eventName,proto3" json:"event_name,omitempty"`
+	CreatedAt *types.Timestamp `protobuf:"bytes,2,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	// Types that are valid to be assigned to Payload:
+	//	*ProtoEvent_Text
+	//	*ProtoEvent_Count
+	Payload              isProtoEvent_Payload `protobuf_oneof:"payload"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *ProtoEvent) Reset()         { *m = ProtoEvent{} }
+func (m *ProtoEvent) String() string { return "" }
+func (*ProtoEvent) ProtoMessage()    {}
+
+type isProtoEvent_Payload interface {
+	isProtoEvent_Payload()
+}
+
+type ProtoEvent_Text struct {
+	Text string `protobuf:"bytes,3,opt,name=text,proto3,oneof"`
+}
+
+type ProtoEvent_Count struct {
+	Count int64 `protobuf:"varint,4,opt,name=count,proto3,oneof"`
+}
+
+func (*ProtoEvent_Text) isProtoEvent_Payload()  {}
+func (*ProtoEvent_Count) isProtoEvent_Payload() {}
+
+func (*ProtoEvent) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
+		(*ProtoEvent_Text)(nil),
+		(*ProtoEvent_Count)(nil),
+	}
+}
+
+func TestProtoMessage(t *testing.T) {
+	schema := NewSchema()
+	query := schema.Query()
+	query.FieldFunc("events", func() []*ProtoEvent {
+		return []*ProtoEvent{
+			{
+				EventName: "first",
+				CreatedAt: &types.Timestamp{Seconds: 1458757911},
+				Payload:   &ProtoEvent_Text{Text: "hello"},
+			},
+			{
+				EventName: "second",
+				Payload:   &ProtoEvent_Count{Count: 5},
+			},
+			{
+				EventName: "third",
+			},
+		}
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`
+		{
+			events {
+				eventName
+				createdAt
+				payload {
+					__typename
+					... on ProtoEvent_Text { text }
+					... on ProtoEvent_Count { count }
+				}
+			}
+		}
+	`, nil)
+	ctx := context.Background()
+	require.NoError(t, graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	result, err := e.Execute(ctx, builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	require.Equal(t, internal.ParseJSON(`
+		{"events": [
+			{"eventName": "first", "createdAt": "2016-03-23T18:31:51Z", "payload": {"__typename": "ProtoEvent_Text", "text": "hello"}},
+			{"eventName": "second", "createdAt": null, "payload": {"__typename": "ProtoEvent_Count", "count": 5}},
+			{"eventName": "third", "createdAt": null, "payload": null}
+		]}`), internal.AsJSON(result))
+}
+
+// Timestamp has the fields of google.protobuf.Timestamp, but is declared in
+// another package, so it is an object rather than a Time scalar.
+type Timestamp struct {
+	Seconds int64
+	Nanos   int32
+}
+
+func TestProtoTimestampPackage(t *testing.T) {
+	schema := NewSchema()
+	schema.Query().FieldFunc("timestamp", func() *Timestamp {
+		return &Timestamp{Seconds: 1458757911}
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ timestamp { seconds } }`, nil)
+	ctx := context.Background()
+	require.NoError(t, graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	result, err := e.Execute(ctx, builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	require.Equal(t, internal.ParseJSON(`{"timestamp": {"seconds": 1458757911}}`), internal.AsJSON(result))
+}
@@ -382,6 +382,51 @@ func TestExecuteErrorNullReturn(t *testing.T) {
 	}
 }
 
+func TestNullableElements(t *testing.T) {
+	type Item struct {
+		Id int64
+	}
+
+	schema := NewSchema()
+	query := schema.Query()
+	query.FieldFunc("items", func() []*Item {
+		return []*Item{{Id: 1}, nil}
+	}, NullableElements)
+	query.FieldFunc("ids", func() []*int64 {
+		return nil
+	}, Nullable, NullableElements)
+	schema.Object("Item", Item{})
+	builtSchema := schema.MustBuild()
+
+	fields := builtSchema.Query.(*graphql.Object).Fields
+	if typ := fields["items"].Type.String(); typ != "[Item]!" {
+		t.Errorf("expected items to be [Item]!, but got %s", typ)
+	}
+	if typ := fields["ids"].Type.String(); typ != "[int64]" {
+		t.Errorf("expected ids to be [int64], but got %s", typ)
+	}
+
+	q := graphql.MustParse(`{ items { id } }`, nil)
+	if err := graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, internal.ParseJSON(`{"items": [{"id": 1}, null]}`), internal.AsJSON(val))
+
+	schema = NewSchema()
+	schema.Query().FieldFunc("items", func() []Item {
+		return nil
+	}, NullableElements)
+	schema.Object("Item", Item{})
+	if _, err := schema.Build(); err == nil || !strings.Contains(err.Error(), "does not return a slice of pointers") {
+		t.Errorf("expected an error for elements that can't be nil, but received %v", err)
+	}
+}
+
 func TestExecuteErrorBasic(t *testing.T) {
 	schema := NewSchema()
 	query := schema.Query()
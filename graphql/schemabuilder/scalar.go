@@ -0,0 +1,104 @@
+package schemabuilder
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/samsarahq/thunder/graphql"
+)
+
+// customScalar is a scalar registered with RegisterScalar.
+type customScalar struct {
+	name       string
+	parseValue func(value interface{}) (interface{}, error)
+	serialize  func(value interface{}) (interface{}, error)
+}
+
+// RegisterScalar registers the Go type of val as a new scalar type, e.g.
+// DateTime, usable for both fields and arguments.  Argument and variable
+// inputs are converted into the type by parseValue, which must return a
+// value of the type, and field values of the type are converted into their
+// JSON output by serialize.  As with Enum, val is any value of the type, for
+// reflection.
+//
+// For example, a DateTime scalar could be registered as:
+//
+//	s.RegisterScalar(DateTime{}, "DateTime",
+//		func(value interface{}) (interface{}, error) {
+//			s, ok := value.(string)
+//			if !ok {
+//				return nil, errors.New("not a string")
+//			}
+//			t, err := time.Parse(time.RFC3339, s)
+//			return DateTime{t}, err
+//		},
+//		func(value interface{}) (interface{}, error) {
+//			return value.(DateTime).Format(time.RFC3339), nil
+//		})
+//
+// Pointers to the type are nullable.
+func (s *Schema) RegisterScalar(val interface{}, name string, parseValue func(value interface{}) (interface{}, error), serialize func(value interface{}) (interface{}, error)) {
+	typ := reflect.TypeOf(val)
+	if typ == nil || typ.Kind() == reflect.Ptr {
+		panic(fmt.Sprintf("RegisterScalar %s should be passed a non-pointer value of its type, received %v", name, typ))
+	}
+	if s.customScalars == nil {
+		s.customScalars = make(map[reflect.Type]*customScalar)
+	}
+	s.customScalars[typ] = &customScalar{name: name, parseValue: parseValue, serialize: serialize}
+}
+
+// getCustomScalarType returns the graphql type of a registered scalar type or
+// a pointer to one.
+func (sb *schemaBuilder) getCustomScalarType(typ reflect.Type) (graphql.Type, bool) {
+	nullable := typ.Kind() == reflect.Ptr
+	if nullable {
+		typ = typ.Elem()
+	}
+	scalar, ok := sb.customScalars[typ]
+	if !ok {
+		return nil, false
+	}
+
+	argType := &graphql.Scalar{
+		Type: scalar.name,
+		Unwrapper: func(source interface{}) (interface{}, error) {
+			value := reflect.ValueOf(source)
+			if value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					return nil, nil
+				}
+				source = value.Elem().Interface()
+			}
+			return scalar.serialize(source)
+		},
+	}
+	if nullable {
+		return argType, true
+	}
+	return &graphql.NonNull{Type: argType}, true
+}
+
+// getCustomScalarArgParser returns the argParser of a registered scalar type.
+func (sb *schemaBuilder) getCustomScalarArgParser(typ reflect.Type) (*argParser, graphql.Type, bool) {
+	scalar, ok := sb.customScalars[typ]
+	if !ok {
+		return nil, nil, false
+	}
+
+	return &argParser{
+		FromJSON: func(value interface{}, dest reflect.Value) error {
+			parsed, err := scalar.parseValue(value)
+			if err != nil {
+				return err
+			}
+			parsedValue := reflect.ValueOf(parsed)
+			if !parsedValue.IsValid() || parsedValue.Type() != typ {
+				return fmt.Errorf("%s parser returned %T, not %s", scalar.name, parsed, typ)
+			}
+			dest.Set(parsedValue)
+			return nil
+		},
+		Type: typ,
+	}, &graphql.Scalar{Type: scalar.name}, true
+}
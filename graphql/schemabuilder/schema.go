@@ -15,9 +15,11 @@ const federationName = "Federation"
 // can be registered against the "Mutation" and "Query" objects in order to
 // build out a full GraphQL schema.
 type Schema struct {
-	Name      string
-	objects   map[string]*Object
-	enumTypes map[reflect.Type]*EnumMapping
+	Name          string
+	objects       map[string]*Object
+	enumTypes     map[reflect.Type]*EnumMapping
+	resultUnions  map[reflect.Type]*resultUnion
+	customScalars map[reflect.Type]*customScalar
 }
 
 // NewSchema creates a new schema.
@@ -68,14 +70,141 @@ func NewSchemaWithName(name string) *Schema {
 //     "two":   enumType(2),
 //     "three": enumType(3),
 //   })
-func (s *Schema) Enum(val interface{}, enumMap interface{}) {
+//
+// Options can be passed to configure how the enum is parsed, for example:
+//   s.Enum(enumType(1), enumMap, schemabuilder.CaseInsensitiveInput)
+func (s *Schema) Enum(val interface{}, enumMap interface{}, options ...EnumOption) {
 	typ := reflect.TypeOf(val)
 	if s.enumTypes == nil {
 		s.enumTypes = make(map[reflect.Type]*EnumMapping)
 	}
 
 	eMap, rMap := getEnumMap(enumMap, typ)
-	s.enumTypes[typ] = &EnumMapping{Map: eMap, ReverseMap: rMap}
+	mapping := &EnumMapping{Map: eMap, ReverseMap: rMap}
+	for _, opt := range options {
+		opt.apply(mapping)
+	}
+	for alias, value := range mapping.Aliases {
+		if _, ok := eMap[value]; !ok {
+			panic(fmt.Sprintf("enum alias %s stands for %s, which is not a value of %s", alias, value, typ))
+		}
+		if _, ok := eMap[alias]; ok {
+			panic(fmt.Sprintf("enum alias %s is already a value of %s", alias, typ))
+		}
+	}
+	if mapping.CaseInsensitiveInput {
+		// Inputs matching several strings case-insensitively must stand for
+		// the same value.
+		seen := make(map[string]string, len(eMap)+len(mapping.Aliases))
+		check := func(key, value string) {
+			if other, ok := seen[strings.ToLower(key)]; ok && other != value {
+				panic(fmt.Sprintf("enum values %s and %s conflict when matched case-insensitively", other, value))
+			}
+			seen[strings.ToLower(key)] = value
+		}
+		for key := range eMap {
+			check(key, key)
+		}
+		for alias, value := range mapping.Aliases {
+			check(alias, value)
+		}
+	}
+	for value := range mapping.DeprecatedValues {
+		if _, ok := eMap[value]; !ok {
+			panic(fmt.Sprintf("deprecated enum value %s is not a value of %s", value, typ))
+		}
+	}
+	s.enumTypes[typ] = mapping
+}
+
+// ResultUnion registers a Go interface type as a union of the given member
+// types, so that FieldFuncs can return any member as the interface instead of
+// filling in a one-hot struct embedding Union.  This makes the "errors as
+// data" pattern ergonomic, where a field resolves to either its payload or a
+// typed error object.
+//
+// For example, a mutation that may fail because an email is taken could be
+// declared as:
+//   type CreateUserResult interface{}
+//   s.ResultUnion("CreateUserResult", (*CreateUserResult)(nil), &User{}, &EmailTakenError{})
+//   s.Mutation().FieldFunc("createUser", func(args CreateUserArgs) CreateUserResult {
+//     ...
+//     return &EmailTakenError{Email: args.Email}
+//   })
+//
+// iface must be a nil pointer to the interface type, and every member a
+// pointer to a struct implementing it.
+func (s *Schema) ResultUnion(name string, iface interface{}, members ...interface{}) {
+	typ := reflect.TypeOf(iface)
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Interface {
+		panic(fmt.Sprintf("ResultUnion %s should be passed a nil pointer to an interface type, received %v", name, typ))
+	}
+	if s.resultUnions == nil {
+		s.resultUnions = make(map[reflect.Type]*resultUnion)
+	}
+
+	union := &resultUnion{name: name}
+	for _, member := range members {
+		memberTyp := reflect.TypeOf(member)
+		if memberTyp == nil || memberTyp.Kind() != reflect.Ptr || memberTyp.Elem().Kind() != reflect.Struct {
+			panic(fmt.Sprintf("ResultUnion %s members should be pointers to structs, received %v", name, memberTyp))
+		}
+		if !memberTyp.Implements(typ.Elem()) {
+			panic(fmt.Sprintf("ResultUnion %s member %v does not implement %v", name, memberTyp, typ.Elem()))
+		}
+		union.members = append(union.members, memberTyp)
+	}
+	s.resultUnions[typ.Elem()] = union
+}
+
+// resultUnion is a union registered with ResultUnion.
+type resultUnion struct {
+	name    string
+	members []reflect.Type
+}
+
+// EnumOption is an interface for the variadic options that can be passed
+// to Enum for configuring options on that enum.
+type EnumOption interface {
+	apply(*EnumMapping)
+}
+
+// enumOptionFunc is a helper to define EnumOptions from a func.
+type enumOptionFunc func(*EnumMapping)
+
+func (f enumOptionFunc) apply(m *EnumMapping) { f(m) }
+
+// CaseInsensitiveInput is an option that can be passed to Enum to indicate
+// that input values should be matched ignoring case, e.g. "active" will be
+// accepted for the enum value "ACTIVE".
+var CaseInsensitiveInput enumOptionFunc = func(m *EnumMapping) {
+	m.CaseInsensitiveInput = true
+}
+
+// EnumAlias is an option that can be passed to Enum to accept alias as an
+// input for its value named value, e.g. both "US" and "usa" for
+// "UnitedStates".  Aliases are only accepted as inputs; outputs always use
+// the value's name.  Aliases are matched ignoring case if the enum is also
+// passed CaseInsensitiveInput.
+func EnumAlias(alias, value string) EnumOption {
+	return enumOptionFunc(func(m *EnumMapping) {
+		if m.Aliases == nil {
+			m.Aliases = make(map[string]string)
+		}
+		m.Aliases[alias] = value
+	})
+}
+
+// DeprecatedEnumValue is an option that can be passed to Enum to mark one of
+// its values as deprecated for the given reason, as reported by
+// introspection.  The value can still be used.
+func DeprecatedEnumValue(value, reason string) EnumOption {
+	return enumOptionFunc(func(m *EnumMapping) {
+		if m.DeprecatedValues == nil {
+			m.DeprecatedValues = make(map[string]string)
+		}
+		m.DeprecatedValues[value] = deprecationReason(reason)
+	})
 }
 
 func getEnumMap(enumMap interface{}, typ reflect.Type) (map[string]interface{}, map[interface{}]string) {
@@ -122,7 +251,7 @@ type federation struct{}
 
 func FetchObjectFromKeys(f interface{}, options ...ObjectOption) ObjectOption {
 	// Create a method on the "Federation" object to create the shadow object from the federated keys
-	m := &method{Fn: f, Expensive: true}
+	m := &method{Fn: f, Expensive: true, FetchesFromKeys: true}
 
 	var FetchObjectFromKeysField objectOptionFunc = func(s *Schema, obj *Object) {
 		q := s.Query()
@@ -199,6 +328,16 @@ func (s *Schema) Mutation() *Object {
 	return s.Object("Mutation", mutation{})
 }
 
+type subscription struct{}
+
+// Subscription returns an Object struct that we can use to register the top
+// level graphql subscriptions we'd like to expose, for graphql.Executor's
+// Subscribe.  Subscription FieldFuncs return a channel of events, e.g.
+// <-chan *Event, and their type is the type of the events.
+func (s *Schema) Subscription() *Object {
+	return s.Object("Subscription", subscription{})
+}
+
 // Build takes the schema we have built on our Query and Mutation starting
 // points and builds a full graphql.Schema we can use to execute and run
 // queries.  Essentially we read through all the methods we've attached to our
@@ -206,11 +345,13 @@ func (s *Schema) Mutation() *Object {
 // other Objects that we can resolve in our GraphQL graph.
 func (s *Schema) Build() (*graphql.Schema, error) {
 	sb := &schemaBuilder{
-		types:        make(map[reflect.Type]graphql.Type),
-		typeNames:    make(map[string]reflect.Type),
-		objects:      make(map[reflect.Type]*Object),
-		enumMappings: s.enumTypes,
-		typeCache:    make(map[reflect.Type]cachedType, 0),
+		types:         make(map[reflect.Type]graphql.Type),
+		typeNames:     make(map[string]reflect.Type),
+		objects:       make(map[reflect.Type]*Object),
+		enumMappings:  s.enumTypes,
+		resultUnions:  s.resultUnions,
+		customScalars: s.customScalars,
+		typeCache:     make(map[reflect.Type]cachedType, 0),
 	}
 
 	s.Object("Query", query{})
@@ -237,9 +378,20 @@ func (s *Schema) Build() (*graphql.Schema, error) {
 	if err != nil {
 		return nil, err
 	}
+	// The Subscription object is optional, as few schemas have one.
+	var subscriptionTyp graphql.Type
+	if _, ok := s.objects["Subscription"]; ok {
+		if subscriptionTyp, err = sb.getType(reflect.TypeOf(&subscription{})); err != nil {
+			return nil, err
+		}
+	}
+	for _, iface := range sb.interfaces {
+		buildInterfaceFields(iface)
+	}
 	return &graphql.Schema{
-		Query:    queryTyp,
-		Mutation: mutationTyp,
+		Query:        queryTyp,
+		Mutation:     mutationTyp,
+		Subscription: subscriptionTyp,
 	}, nil
 }
 
@@ -0,0 +1,31 @@
+package schemabuilder
+
+import "context"
+
+// FieldFunc is a type-safe variant of Object.FieldFunc.  The source, args and
+// result types are checked at compile time, and the function is called
+// directly instead of through reflection.
+//
+// For example, for an object of type User, a greeting field might be
+// registered as:
+//
+//	schemabuilder.FieldFunc(user, "greeting", func(ctx context.Context, u *User, args struct{ Greeting string }) (string, error) {
+//	   return args.Greeting + ", " + u.FirstName, nil
+//	})
+//
+// Source must be the object's type (or a pointer to it), and Args must be a
+// struct (use struct{} if the field takes no arguments).
+func FieldFunc[Source, Args, Result any](object *Object, name string, f func(ctx context.Context, source Source, args Args) (Result, error), options ...FieldFuncOption) {
+	resolver := typedResolver(func(ctx context.Context, source, args interface{}) (interface{}, error) {
+		var typedSource Source
+		if source != nil {
+			typedSource = source.(Source)
+		}
+		var typedArgs Args
+		if args != nil {
+			typedArgs = args.(Args)
+		}
+		return f(ctx, typedSource, typedArgs)
+	})
+	object.FieldFunc(name, f, append(options, resolver)...)
+}
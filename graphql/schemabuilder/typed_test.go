@@ -0,0 +1,54 @@
+package schemabuilder
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedFieldFunc(t *testing.T) {
+	type Person struct {
+		Name string
+	}
+
+	schema := NewSchema()
+	schema.Query().FieldFunc("people", func() []Person {
+		return []Person{{Name: "Alice"}, {Name: "Bob"}}
+	})
+
+	person := schema.Object("Person", Person{})
+	FieldFunc(person, "greeting", func(ctx context.Context, p *Person, args struct{ Greeting string }) (string, error) {
+		if p.Name == "" {
+			return "", errors.New("missing name")
+		}
+		return args.Greeting + ", " + p.Name, nil
+	})
+	FieldFunc(person, "self", func(ctx context.Context, p Person, args struct{}) (*Person, error) {
+		return &p, nil
+	}, NonNullable)
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`
+		{
+			people {
+				greeting(greeting: "Hello")
+				self { name }
+			}
+		}
+	`, nil)
+	ctx := context.Background()
+	require.NoError(t, graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	result, err := e.Execute(ctx, builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	require.Equal(t, internal.ParseJSON(`
+		{"people": [
+			{"greeting": "Hello, Alice", "self": {"name": "Alice"}},
+			{"greeting": "Hello, Bob", "self": {"name": "Bob"}}
+		]}`), internal.AsJSON(result))
+}
@@ -3,6 +3,9 @@ package schemabuilder
 import (
 	"context"
 	"reflect"
+	"time"
+
+	"github.com/samsarahq/thunder/graphql"
 )
 
 // A Object represents a Go type and set of methods to be converted into an
@@ -14,6 +17,7 @@ type Object struct {
 	Methods     Methods // Deprecated, use FieldFunc instead.
 	key         string
 	ServiceName string
+	middlewares []FieldMiddleware
 }
 
 type paginationObject struct {
@@ -38,8 +42,33 @@ var NonNullable fieldFuncOptionFunc = func(m *method) {
 	m.MarkedNonNullable = true
 }
 
+// Nullable is an option that can be passed to a FieldFunc to indicate that
+// its return value may be null, even if the return value is not a pointer
+// type, e.g. so that its errors only null the field (see
+// graphql.WithPartialResults).  To resolve a value-typed field to null, return
+// a graphql.Nullable instead.
+var Nullable fieldFuncOptionFunc = func(m *method) {
+	m.MarkedNullable = true
+}
+
+// NullableElements is an option that can be passed to a FieldFunc returning a
+// slice of pointers, e.g. []*User, to indicate that the elements of the list
+// may be null.  A nil element, or one that fails with partial results (see
+// graphql.WithPartialResults), is then null, rather than nulling the list.
+var NullableElements fieldFuncOptionFunc = func(m *method) {
+	m.MarkedNullableElements = true
+}
+
 // Paginated is an option that can be passed to a FieldFunc to indicate that
-// its return value should be paginated.
+// its return value should be paginated.  The FieldFunc returns a slice of all
+// its nodes, in order, and the field resolves to a Relay connection of them
+// instead: it takes the first, after, last and before arguments, and has the
+// totalCount, edges { node cursor } and pageInfo { hasNextPage hasPrevPage
+// startCursor endCursor pages } fields.  The cursor of a node is its key, as
+// set with Object.Key, base64 encoded.
+//
+// FieldFuncs that paginate their nodes themselves, e.g. in a SQL query, embed
+// PaginationArgs in their args and return PaginationInfo along with the page.
 var Paginated fieldFuncOptionFunc = func(m *method) {
 	m.Paginated = true
 }
@@ -50,6 +79,226 @@ var Expensive fieldFuncOptionFunc = func(m *method) {
 	m.Expensive = true
 }
 
+// JSONArgs is an option that can be passed to a FieldFunc to decode its args
+// struct as encoding/json would, treating the arguments as a JSON object, so
+// that existing JSON-tagged structs can be used as arguments.  Arguments are
+// named by the json tags of the struct's fields, and fields tagged omitempty
+// are optional.  Enum and scalar arguments are still parsed by their usual
+// parsers.
+var JSONArgs fieldFuncOptionFunc = func(m *method) {
+	m.JSONArgs = true
+}
+
+// RequiresPrimary is an option that can be passed to a FieldFunc to indicate
+// that it must read from the primary database, even when executing a query.
+// See graphql.DataSourceFromContext.
+var RequiresPrimary fieldFuncOptionFunc = func(m *method) {
+	m.RequiresPrimary = true
+}
+
+// Delegated is an option that can be passed to a FieldFunc that fetches its
+// result from a downstream service, to pass it the selection set pruned to the
+// fields the downstream must return.  See graphql.PruneSelectionSet.
+var Delegated fieldFuncOptionFunc = func(m *method) {
+	m.Delegated = true
+}
+
+// DependsOn is an option that can be passed to a FieldFunc to indicate that it
+// must only be resolved after the named sibling fields, e.g. because it reads
+// state they populate.  Cycles are reported when the schema is built.
+func DependsOn(fields ...string) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.DependsOn = append(m.DependsOn, fields...)
+	})
+}
+
+// Requires is an option that can be passed to a FieldFunc to name the fields
+// of its source it reads, so that they are fetched from the downstream service
+// of a Delegated field whenever it is selected.  See graphql.Field.Requires.
+func Requires(fields ...string) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.Requires = append(m.Requires, fields...)
+	})
+}
+
+// Validate is an option that can be passed to a FieldFunc to check the value
+// it returns, failing the field with the returned error if it is invalid.
+func Validate(fn func(value interface{}) error) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.Validate = fn
+	})
+}
+
+// Authorize is an option that can be passed to a FieldFunc to check access to
+// it for every source before it is called, failing the field with the returned
+// error if access is denied.
+func Authorize(fn graphql.AuthorizeFunc) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.Authorize = fn
+	})
+}
+
+// Transform is an option that can be passed to a FieldFunc to transform the
+// values it returns with a pipeline of transformers, applied in order, before
+// they are checked by Validate.  Transform can be passed several times to
+// append more transformers.
+func Transform(transformers ...graphql.OutputTransformer) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.Transformers = append(m.Transformers, transformers...)
+	})
+}
+
+// MaxListLength is an option that can be passed to a FieldFunc returning a
+// list to limit the number of items it resolves to.  Longer lists are
+// truncated or fail the field, according to policy.  See
+// graphql.MaxListLength.
+func MaxListLength(max int, policy graphql.ListLengthPolicy) FieldFuncOption {
+	return Transform(graphql.MaxListLength(max, policy))
+}
+
+// MaxLength is an option that can be passed to a FieldFunc returning text to
+// limit the number of bytes it resolves to.  Longer strings are truncated
+// (without splitting a rune), ellipsized, or fail the field, according to
+// policy.  See graphql.MaxStringLength.
+func MaxLength(max int, policy graphql.StringLengthPolicy) FieldFuncOption {
+	return Transform(graphql.MaxStringLength(max, policy))
+}
+
+// StaleWhileRevalidate is an option that can be passed to a FieldFunc to
+// serve the value cached for a source if the FieldFunc takes longer than
+// softTimeout, while the FieldFunc refreshes the cache in the background for
+// up to refreshTimeout.  See graphql.StaleWhileRevalidate.  It is not supported
+// by BatchFieldFuncs.
+func StaleWhileRevalidate(cache graphql.StaleCache, key func(source, args interface{}) string, softTimeout, refreshTimeout time.Duration) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.wrapResolve = func(resolve graphql.Resolver) graphql.Resolver {
+			return graphql.StaleWhileRevalidate(resolve, cache, key, softTimeout, refreshTimeout)
+		}
+	})
+}
+
+// BatchGroup is an option that can be passed to a BatchFieldFunc to resolve it
+// together with the other BatchFieldFuncs of the same object in the same
+// group.  See graphql.Field.BatchGroup.
+func BatchGroup(group string) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.BatchGroup = group
+	})
+}
+
+// EnrichContext is an option that can be passed to a FieldFunc to derive the
+// context it is called with from its source, e.g. to add per-tenant tracing
+// tags.  See graphql.Field.EnrichContext.
+func EnrichContext(enrich func(ctx context.Context, source interface{}) context.Context) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.EnrichContext = enrich
+	})
+}
+
+// Fallback is an option that can be passed to a FieldFunc to resolve the
+// sources it fails for with fallback instead, e.g. to serve stale data from a
+// cache.  The original error is returned if fallback fails too.  See
+// graphql.Field.Fallback.
+func Fallback(fallback graphql.BatchResolver) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.Fallback = fallback
+	})
+}
+
+// Retry is an option that can be passed to a FieldFunc to retry it when it
+// fails, according to policy.  See graphql.RetryPolicy and
+// graphql.WithRetryBudget.
+func Retry(policy graphql.RetryPolicy) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.Retry = &policy
+	})
+}
+
+// Timeout is an option that can be passed to a FieldFunc to bound the time it
+// may take, so that a slow FieldFunc can't blow the latency budget of the
+// whole query.  See graphql.Field.Timeout.
+func Timeout(timeout time.Duration) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.Timeout = timeout
+	})
+}
+
+// NormalizeArgs is an option that can be passed to a FieldFunc to compute
+// derived arguments or check constraints between arguments once they are
+// parsed, e.g. defaulting an argument to a value computed from another.
+// normalize must be a func(Args) (Args, error) for the FieldFunc's args
+// struct Args, and its result is what the FieldFunc is called with.  The args
+// of a Paginated FieldFunc are ConnectionArgs, unless its args struct embeds
+// PaginationArgs.  It is not supported by ManualPaginationWithFallback.
+func NormalizeArgs(normalize interface{}) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.NormalizeArgs = normalize
+	})
+}
+
+// Deprecated is an option that can be passed to a FieldFunc to mark it as
+// deprecated for the given reason, e.g. "Use fullName instead.", as reported
+// by introspection.  The field still resolves as usual.
+func Deprecated(reason string) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.DeprecationReason = deprecationReason(reason)
+	})
+}
+
+// deprecationReason returns the reason reported for a deprecation, defaulting
+// to the one of the GraphQL spec.
+func deprecationReason(reason string) string {
+	if reason == "" {
+		return "No longer supported"
+	}
+	return reason
+}
+
+// Complexity is an option that can be passed to a FieldFunc to compute its
+// cost for graphql.WithMaxComplexity from its arguments, e.g. to multiply the
+// cost of a paginated list by its "first" argument.  complexity must be a
+// func(args Args, childComplexity int) int for the FieldFunc's args struct
+// Args, or a func(childComplexity int) int if it has none, where
+// childComplexity is the cost of the field's sub-selections for a single
+// value.  The args of a Paginated FieldFunc are ConnectionArgs, unless its
+// args struct embeds PaginationArgs.
+func Complexity(complexity interface{}) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.Complexity = complexity
+	})
+}
+
+// ArgMapsTo is an option that can be passed to a FieldFunc to expose one of
+// its arguments under a different name than its args struct uses: clients
+// pass the argument as name, and it is bound to the struct field named mapsTo
+// (by its graphql tag, or the default name of the field).
+func ArgMapsTo(name, mapsTo string) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		if m.ArgMappings == nil {
+			m.ArgMappings = make(map[string]string)
+		}
+		m.ArgMappings[name] = mapsTo
+	})
+}
+
+// FeatureFlag is an option that can be passed to a FieldFunc to gate it
+// behind a feature flag, e.g. while rolling out a new field.  See
+// graphql.WithFeatureFlags.
+func FeatureFlag(flag string) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.FeatureFlag = flag
+	})
+}
+
+// MaxItems is an option that can be passed to a FieldFunc returning an
+// iterator (func() (T, bool, error)) to bound the number of items pulled
+// from the iterator.
+func MaxItems(n int) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.MaxItems = n
+	})
+}
+
 func FilterField(name string, filter interface{}, options ...FieldFuncOption) FieldFuncOption {
 	textFilterMethod := &method{Fn: filter, Batch: false, MarkedNonNullable: true}
 	for _, opt := range options {
@@ -276,8 +525,10 @@ func (s *Object) Key(f string) {
 }
 
 type method struct {
-	MarkedNonNullable bool
-	Fn                interface{}
+	MarkedNonNullable      bool
+	MarkedNullable         bool
+	MarkedNullableElements bool
+	Fn                     interface{}
 
 	// Whether or not the FieldFunc is paginated.
 	Paginated bool
@@ -285,6 +536,48 @@ type method struct {
 	// Whether or not the FieldFunc has been marked as expensive.
 	Expensive bool
 
+	// Whether or not the FieldFunc must read from the primary database.
+	RequiresPrimary bool
+
+	// Whether or not the FieldFunc fetches its result from a downstream service.
+	Delegated bool
+
+	// Retry, if set, retries the FieldFunc when it fails.
+	Retry *graphql.RetryPolicy
+
+	// Timeout, if set, bounds the time the FieldFunc may take.
+	Timeout time.Duration
+
+	// MaxItems bounds the number of items pulled from an iterator result.
+	MaxItems int
+
+	// DependsOn lists the sibling fields that must resolve before this one.
+	DependsOn []string
+
+	// Requires lists the fields of the source the FieldFunc reads.
+	Requires []string
+
+	// Validate checks the values returned by the FieldFunc.
+	Validate func(value interface{}) error
+
+	// Authorize checks access to the FieldFunc before it is called.
+	Authorize graphql.AuthorizeFunc
+
+	// Fallback resolves the sources the FieldFunc fails for.
+	Fallback graphql.BatchResolver
+
+	// EnrichContext derives the context of the FieldFunc from its source.
+	EnrichContext func(ctx context.Context, source interface{}) context.Context
+
+	// Transformers transform the values returned by the FieldFunc.
+	Transformers []graphql.OutputTransformer
+
+	// FeatureFlag gates the FieldFunc behind a feature flag.
+	FeatureFlag string
+
+	// BatchGroup groups the BatchFieldFunc with others resolved together.
+	BatchGroup string
+
 	// Text filter methods
 	TextFilterMethods map[string]*method
 
@@ -308,6 +601,70 @@ type method struct {
 	// is a shadow object. A shadow object's fields are each of the
 	// field that are sent as args to a federated sunquery.
 	ShadowObjectType reflect.Type
+
+	// FetchesFromKeys is set for the fields that fetch objects from their
+	// federated keys (see FetchObjectFromKeys).
+	FetchesFromKeys bool
+
+	// Sanitizers are run on the raw arguments before they are parsed.
+	Sanitizers []argSanitizer
+
+	// ArgMappings maps the names of arguments in the schema to their names in
+	// the args struct.
+	ArgMappings map[string]string
+
+	// NormalizeArgs, if set, is a func(Args) (Args, error) called with the
+	// parsed args struct.
+	NormalizeArgs interface{}
+
+	// Complexity, if set, is a func([Args, ]childComplexity int) int that
+	// computes the cost of the field.
+	Complexity interface{}
+
+	// DeprecationReason, if set, marks the field as deprecated.
+	DeprecationReason string
+
+	// JSONArgs names the args struct's fields by their json tags.
+	JSONArgs bool
+
+	// typedResolver, if set, is called instead of Fn with the already
+	// converted source and args.  It is set by the generic FieldFunc helper
+	// so the call avoids going through reflection.
+	typedResolver typedResolver
+
+	// wrapResolve, if set, wraps the resolver of a non-batch FieldFunc.
+	wrapResolve func(graphql.Resolver) graphql.Resolver
+}
+
+// typedResolver resolves a field given the source object and parsed args.
+type typedResolver func(ctx context.Context, source, args interface{}) (interface{}, error)
+
+func (f typedResolver) apply(m *method) { m.typedResolver = f }
+
+// A Sanitizer cleans up a raw argument value (e.g. trimming whitespace) before
+// it is parsed into the FieldFunc's args, or rejects it by returning an error.
+type Sanitizer func(value interface{}) (interface{}, error)
+
+type argSanitizer struct {
+	// name is the argument to sanitize, or empty for every argument.
+	name     string
+	sanitize Sanitizer
+}
+
+func (s argSanitizer) apply(m *method) { m.Sanitizers = append(m.Sanitizers, s) }
+
+// SanitizeArg returns a FieldFuncOption that runs sanitize on the named
+// argument before it reaches the FieldFunc.  Sanitizers see the raw JSON value
+// (e.g. a string, float64 or map[string]interface{}), and are not called for
+// arguments that were not passed in.
+func SanitizeArg(name string, sanitize Sanitizer) FieldFuncOption {
+	return argSanitizer{name: name, sanitize: sanitize}
+}
+
+// SanitizeArgs returns a FieldFuncOption that runs sanitize on every argument
+// passed to the FieldFunc.
+func SanitizeArgs(sanitize Sanitizer) FieldFuncOption {
+	return argSanitizer{sanitize: sanitize}
 }
 
 type concurrencyArgs struct {
@@ -353,3 +710,22 @@ type Methods map[string]*method
 type Union struct{}
 
 var unionType = reflect.TypeOf(Union{})
+
+// Interface is a special marker struct that can be embedded into to denote
+// that a type should be treated as an interface type by the schemabuilder.
+// It is declared like a Union, but the fields that all of its members share
+// can be selected without narrowing it to a member with a fragment.
+//
+// For example, an interface implemented by *Asset and *Vehicle might look
+// like:
+//   type Gateway struct {
+//     schemabuilder.Interface
+//     *Asset
+//     *Vehicle
+//   }
+//
+// Fields returning an interface type should expect to return this type as a
+// one-hot struct, i.e. only Asset or Vehicle should be specified, but not both.
+type Interface struct{}
+
+var interfaceType = reflect.TypeOf(Interface{})
@@ -100,6 +100,24 @@ type mutateMessage struct {
 	Variables map[string]interface{} `json:"variables"`
 }
 
+// errorMessage returns the message of an "error" envelope for err: the
+// presentation of err (see WithErrorPresenter), or the messages of each of
+// its Errors, and otherwise its sanitized message.
+func errorMessage(err error) interface{} {
+	if errs, ok := err.(Errors); ok {
+		messages := make([]interface{}, 0, len(errs))
+		for _, err := range errs {
+			messages = append(messages, errorMessage(err))
+		}
+		return messages
+	}
+	var presented *PresentedError
+	if errors.As(err, &presented) {
+		return presented.FormattedError
+	}
+	return SanitizeError(err)
+}
+
 func (c *conn) writeOrClose(out outEnvelope) {
 	c.writeMu.Lock()
 	defer c.writeMu.Unlock()
@@ -149,6 +167,10 @@ func (c *conn) handleSubscribe(in *inEnvelope) error {
 		c.logger.Error(c.ctx, err, tags)
 		return err
 	}
+	if err := checkQueryLimits(c.executor, query); err != nil {
+		c.logger.Error(c.ctx, err, tags)
+		return err
+	}
 	if err := PrepareQuery(context.Background(), c.schema.Query, query.SelectionSet); err != nil {
 		c.logger.Error(c.ctx, err, tags)
 		return err
@@ -217,7 +239,7 @@ func (c *conn) handleSubscribe(in *inEnvelope) error {
 			c.writeOrClose(outEnvelope{
 				ID:       id,
 				Type:     "error",
-				Message:  SanitizeError(err),
+				Message:  errorMessage(err),
 				Metadata: output.Metadata,
 			})
 			go c.closeSubscription(id)
@@ -277,6 +299,10 @@ func (c *conn) handleMutate(in *inEnvelope) error {
 		c.logger.Error(c.ctx, err, tags)
 		return err
 	}
+	if err := checkQueryLimits(c.executor, query); err != nil {
+		c.logger.Error(c.ctx, err, tags)
+		return err
+	}
 	if err := PrepareQuery(c.ctx, c.mutationSchema.Mutation, query.SelectionSet); err != nil {
 		c.logger.Error(c.ctx, err, tags)
 		return err
@@ -323,7 +349,7 @@ func (c *conn) handleMutate(in *inEnvelope) error {
 			c.writeOrClose(outEnvelope{
 				ID:       id,
 				Type:     "error",
-				Message:  SanitizeError(err),
+				Message:  errorMessage(err),
 				Metadata: output.Metadata,
 			})
 
@@ -587,7 +613,7 @@ func (c *conn) ServeJSONSocket() {
 			c.writeOrClose(outEnvelope{
 				ID:       envelope.ID,
 				Type:     "error",
-				Message:  SanitizeError(err),
+				Message:  errorMessage(err),
 				Metadata: nil,
 			})
 		}
@@ -0,0 +1,79 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chanSocket is a JSONSocket reading the messages sent on in, and writing
+// the messages it is sent to out, as JSON.
+type chanSocket struct {
+	in  chan interface{}
+	out chan json.RawMessage
+}
+
+func (s *chanSocket) ReadJSON(value interface{}) error {
+	message, ok := <-s.in
+	if !ok {
+		return io.EOF
+	}
+	bytes, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, value)
+}
+
+func (s *chanSocket) WriteJSON(value interface{}) error {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	s.out <- bytes
+	return nil
+}
+
+func (s *chanSocket) Close() error {
+	return nil
+}
+
+func TestServerErrorPresenter(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("fail", func() (int64, error) {
+		return 0, errors.New("secret internal details")
+	})
+	schema.Mutation().FieldFunc("fail", func() (int64, error) {
+		return 0, errors.New("secret internal details")
+	})
+	builtSchema := schema.MustBuild()
+
+	executor := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithErrorPresenter(func(ctx context.Context, err error) graphql.FormattedError {
+		return graphql.FormattedError{Message: "internal server error", Extensions: map[string]interface{}{"code": "INTERNAL"}}
+	}))
+	socket := &chanSocket{in: make(chan interface{}), out: make(chan json.RawMessage, 10)}
+	conn := graphql.CreateConnection(context.Background(), socket, builtSchema, graphql.WithExecutor(executor))
+	go conn.ServeJSONSocket()
+	defer close(socket.in)
+
+	socket.in <- map[string]interface{}{"id": "1", "type": "subscribe", "message": map[string]interface{}{"query": "{ fail }"}}
+	assert.Equal(t, internal.ParseJSON(`{"id": "1", "type": "error", "message": [{"message": "internal server error", "locations": [{"line": 1, "column": 3}], "path": ["fail"], "extensions": {"code": "INTERNAL"}}]}`), parseEnvelope(t, <-socket.out))
+
+	socket.in <- map[string]interface{}{"id": "2", "type": "mutate", "message": map[string]interface{}{"query": "mutation { fail }"}}
+	assert.Equal(t, internal.ParseJSON(`{"id": "2", "type": "error", "message": [{"message": "internal server error", "locations": [{"line": 1, "column": 12}], "path": ["fail"], "extensions": {"code": "INTERNAL"}}]}`), parseEnvelope(t, <-socket.out))
+}
+
+// parseEnvelope parses an envelope written to a socket.
+func parseEnvelope(t *testing.T, message json.RawMessage) interface{} {
+	var envelope interface{}
+	require.NoError(t, json.Unmarshal(message, &envelope))
+	return envelope
+}
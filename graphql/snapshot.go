@@ -0,0 +1,37 @@
+package graphql
+
+import "context"
+
+// SnapshotFunc establishes a consistent view of the data for an execution,
+// e.g. by starting a read-only transaction or picking an MVCC timestamp, and
+// returns a token identifying it.  Resources held by the snapshot can be
+// released with OnComplete.
+type SnapshotFunc func(ctx context.Context) (token interface{}, err error)
+
+// WithSnapshot calls snapshot once at the start of every execution, so that
+// all of its resolvers can read from the same view of the data (see
+// Snapshot) rather than observing writes made while the query runs.  If
+// snapshot fails, the execution fails with its error.
+func WithSnapshot(snapshot SnapshotFunc) ExecutorOption {
+	return func(e *Executor) {
+		e.snapshot = snapshot
+	}
+}
+
+// Snapshot returns the token of the snapshot established for the current
+// execution by the executor's SnapshotFunc, if any.
+func Snapshot(ctx context.Context) (token interface{}, ok bool) {
+	info := executionInfoFromContext(ctx)
+	return info.snapshot, info.hasSnapshot
+}
+
+// establishSnapshot stores the token of a new snapshot in the execution.
+func (e *Executor) establishSnapshot(ctx context.Context) error {
+	token, err := e.snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	info := executionInfoFromContext(ctx)
+	info.snapshot, info.hasSnapshot = token, true
+	return nil
+}
@@ -0,0 +1,67 @@
+package graphql_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	type User struct {
+		Id int64
+	}
+
+	// version is bumped by every write to the simulated database.
+	var version int64 = 1
+	var snapshots int64
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("users", func() []*User {
+		return []*User{{Id: 1}, {Id: 2}, {Id: 3}}
+	})
+	user := builder.Object("User", User{})
+	user.FieldFunc("readVersion", func(ctx context.Context, u *User) (int64, error) {
+		// Simulate a write made while the query runs.
+		atomic.AddInt64(&version, 1)
+
+		token, ok := graphql.Snapshot(ctx)
+		if !ok {
+			return 0, errors.New("no snapshot")
+		}
+		return token.(int64), nil
+	}, schemabuilder.Expensive)
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`{ users { id readVersion } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithSnapshot(func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt64(&snapshots, 1)
+		return atomic.LoadInt64(&version), nil
+	}))
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+
+	// Every resolver reads from the snapshot taken when the query started.
+	assert.Equal(t, internal.ParseJSON(`{"users": [
+		{"id": 1, "readVersion": 1},
+		{"id": 2, "readVersion": 1},
+		{"id": 3, "readVersion": 1}
+	]}`), internal.AsJSON(res))
+	assert.Equal(t, int64(4), atomic.LoadInt64(&version))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&snapshots))
+
+	// Failing to establish a snapshot fails the execution.
+	e = graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithSnapshot(func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("database unavailable")
+	}))
+	_, err = e.Execute(context.Background(), schema.Query, nil, q)
+	assert.EqualError(t, err, "database unavailable")
+}
@@ -0,0 +1,73 @@
+package graphql
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// A StaleCache holds the last value resolved for each key, to be served by
+// StaleWhileRevalidate while a fresh value is resolved.  It must be safe for
+// concurrent use.
+type StaleCache interface {
+	Get(key string) (value interface{}, ok bool)
+	Set(key string, value interface{})
+}
+
+// StaleWhileRevalidate wraps resolver to improve its tail latency: if
+// resolving takes longer than softTimeout and cache holds a value for the
+// source's key, the cached value is returned right away, while the resolver
+// keeps running in the background to refresh the cache.  Values are only
+// cached if the resolver succeeds.
+//
+// Concurrent calls for the same key share a single refresh.  The refresh keeps
+// the context's values but is not canceled with it, so that it can finish
+// after the request does; instead, its context expires after refreshTimeout.
+func StaleWhileRevalidate(resolver Resolver, cache StaleCache, key func(source, args interface{}) string, softTimeout, refreshTimeout time.Duration) Resolver {
+	var refreshes singleflight.Group
+	return func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+		k := key(source, args)
+		stale, ok := cache.Get(k)
+		if !ok {
+			value, err := resolver(ctx, source, args, selectionSet)
+			if err == nil {
+				cache.Set(k, value)
+			}
+			return value, err
+		}
+
+		done := refreshes.DoChan(k, func() (value interface{}, err error) {
+			refreshCtx, cancel := context.WithTimeout(detachedContext{ctx}, refreshTimeout)
+			defer cancel()
+			defer func() {
+				if panicErr := recover(); panicErr != nil {
+					value, err = nil, panicError(panicErr)
+				}
+			}()
+			value, err = resolver(refreshCtx, source, args, selectionSet)
+			if err == nil {
+				cache.Set(k, value)
+			}
+			return value, err
+		})
+
+		timer := time.NewTimer(softTimeout)
+		defer timer.Stop()
+		select {
+		case r := <-done:
+			return r.Val, r.Err
+		case <-timer.C:
+			return stale, nil
+		}
+	}
+}
+
+// detachedContext keeps the values of a context, but is never canceled.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
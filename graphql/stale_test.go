@@ -0,0 +1,122 @@
+package graphql_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mapStaleCache struct {
+	mu     sync.Mutex
+	values map[string]interface{}
+	set    chan string
+}
+
+func (c *mapStaleCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.values[key]
+	return value, ok
+}
+
+func (c *mapStaleCache) Set(key string, value interface{}) {
+	c.mu.Lock()
+	c.values[key] = value
+	c.mu.Unlock()
+	c.set <- key
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	type User struct {
+		Id int64
+	}
+
+	cache := &mapStaleCache{values: make(map[string]interface{}), set: make(chan string, 10)}
+	var version int
+	var release chan struct{}
+
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("user", func() *User {
+		return &User{Id: 1}
+	})
+	user := schema.Object("User", User{})
+	user.FieldFunc("status", func(u *User) string {
+		if release != nil {
+			<-release
+		}
+		return fmt.Sprintf("v%d", version)
+	}, schemabuilder.StaleWhileRevalidate(cache, func(source, args interface{}) string {
+		return fmt.Sprint(source.(*User).Id)
+	}, 20*time.Millisecond, time.Second))
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ user { status } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	run := func() interface{} {
+		val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+		require.NoError(t, err)
+		return internal.AsJSON(val)
+	}
+
+	// Without a cached value, the resolver's value is used and cached.
+	version = 1
+	assert.Equal(t, internal.ParseJSON(`{"user": {"status": "v1"}}`), run())
+	assert.Equal(t, "1", <-cache.set)
+
+	// A slow resolver returns the stale value, and refreshes the cache once it
+	// finishes.
+	version = 2
+	release = make(chan struct{})
+	assert.Equal(t, internal.ParseJSON(`{"user": {"status": "v1"}}`), run())
+	close(release)
+	assert.Equal(t, "1", <-cache.set)
+	value, _ := cache.Get("1")
+	assert.Equal(t, "v2", value)
+
+	// A fast resolver returns the fresh value.
+	version = 3
+	assert.Equal(t, internal.ParseJSON(`{"user": {"status": "v3"}}`), run())
+}
+
+func TestStaleWhileRevalidateSharesRefreshes(t *testing.T) {
+	cache := &mapStaleCache{values: map[string]interface{}{"1": "stale"}, set: make(chan string, 10)}
+	var calls int32
+	refreshed := make(chan error, 10)
+	resolver := graphql.StaleWhileRevalidate(func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-ctx.Done()
+		refreshed <- ctx.Err()
+		return nil, ctx.Err()
+	}, cache, func(source, args interface{}) string {
+		return "1"
+	}, 10*time.Millisecond, 100*time.Millisecond)
+
+	// Concurrent stale hits are served the stale value and share one refresh.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := resolver(context.Background(), nil, nil, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, "stale", value)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// The refresh is canceled after the refresh timeout.
+	assert.Equal(t, context.DeadlineExceeded, <-refreshed)
+	value, _ := cache.Get("1")
+	assert.Equal(t, "stale", value)
+}
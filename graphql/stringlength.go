@@ -0,0 +1,92 @@
+package graphql
+
+import (
+	"fmt"
+	"reflect"
+	"unicode/utf8"
+)
+
+// A StringLengthPolicy decides what MaxStringLength does with strings that
+// exceed the limit.
+type StringLengthPolicy int
+
+const (
+	// TruncateString drops the bytes past the limit.
+	TruncateString StringLengthPolicy = iota
+	// EllipsizeString drops the bytes past the limit, and ends the string
+	// with an ellipsis ("…") to show it was truncated.  The ellipsis counts
+	// toward the limit.
+	EllipsizeString
+	// FailString fails the field.
+	FailString
+)
+
+const ellipsis = "…"
+
+// MaxStringLength returns an OutputTransformer that guards against a resolver
+// returning huge text, to protect clients and bandwidth, by truncating
+// strings longer than max bytes or failing the field, according to policy.
+// Strings are only truncated between runes, so a truncated string may be
+// shorter than max.  Values that are not strings (or pointers to strings) are
+// passed through unchanged.  MaxStringLength panics if max is negative.
+func MaxStringLength(max int, policy StringLengthPolicy) OutputTransformer {
+	if max < 0 {
+		panic(fmt.Sprintf("max string length must not be negative, got %d", max))
+	}
+	return func(value interface{}) (interface{}, error) {
+		str := reflect.ValueOf(value)
+		if str.Kind() == reflect.Ptr && !str.IsNil() && str.Elem().Kind() == reflect.String {
+			if str.Elem().Len() <= max {
+				return value, nil
+			}
+			truncated, err := truncateString(str.Elem(), max, policy)
+			if err != nil {
+				return nil, err
+			}
+			// Copy the string rather than modifying the resolver's.
+			ptr := reflect.New(str.Elem().Type())
+			ptr.Elem().Set(truncated)
+			return ptr.Interface(), nil
+		}
+		if str.Kind() != reflect.String {
+			return value, nil
+		}
+		truncated, err := truncateString(str, max, policy)
+		if err != nil {
+			return nil, err
+		}
+		return truncated.Interface(), nil
+	}
+}
+
+// truncateString applies MaxStringLength to a string value, returning it
+// unchanged if it isn't too long.
+func truncateString(str reflect.Value, max int, policy StringLengthPolicy) (reflect.Value, error) {
+	s := str.String()
+	if len(s) <= max {
+		return str, nil
+	}
+
+	switch policy {
+	case FailString:
+		return reflect.Value{}, fmt.Errorf("string has %d bytes, more than the maximum of %d", len(s), max)
+	case EllipsizeString:
+		if max >= len(ellipsis) {
+			s = truncateRunes(s, max-len(ellipsis)) + ellipsis
+			break
+		}
+		s = truncateRunes(s, max)
+	default:
+		s = truncateRunes(s, max)
+	}
+	return reflect.ValueOf(s).Convert(str.Type()), nil
+}
+
+// truncateRunes returns the longest prefix of s of at most max bytes that
+// doesn't split a rune.
+func truncateRunes(s string, max int) string {
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
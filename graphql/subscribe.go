@@ -0,0 +1,153 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Subscribe executes a subscription.  The single root field the query selects
+// on typ resolves to a channel of events, and every event is resolved with the
+// field's selections as if the field had returned it, producing a result like
+// Execute's.  The results are sent on the returned channel, which is closed
+// once ctx is done or the channel of events is closed.
+//
+// The root field is resolved within an execution, like the fields of Execute,
+// whose OnComplete callbacks run once the stream is opened.  Its resolver's
+// context has the values of the execution, but lasts as long as the
+// subscription.  Schemas built with schemabuilder have subscription roots, see
+// schemabuilder.Schema.Subscription.
+//
+// Events are not resolved by calling the field's resolver, so the options of
+// its calls (e.g. Timeout, Retry, Fallback, EnrichContext, Authorize, or a
+// StaleWhileRevalidate resolver) only apply to the call opening the stream.
+// The field's Transformers and Validate apply to every event.
+//
+// An event that fails to resolve, or that is itself an error, is sent as an
+// error rather than a result, and does not end the subscription.  Once the
+// executor is shut down (see Shutdown), the next event closes the channel
+// instead.  If the root field is denied by the executor's or the field's
+// AuthorizeFunc, Subscribe returns the error without resolving it.
+func (e *Executor) Subscribe(ctx context.Context, typ Type, source interface{}, query *Query) (<-chan interface{}, error) {
+	object, ok := typ.(*Object)
+	if !ok {
+		return nil, fmt.Errorf("expected subscription object for execution, got: %s", typ.String())
+	}
+
+	selections, err := Flatten(query.SelectionSet)
+	if err != nil {
+		return nil, err
+	}
+	if len(selections) != 1 {
+		return nil, NewClientError("subscriptions must select exactly one root field")
+	}
+	selection := selections[0]
+	field, ok := object.Fields[selection.Name]
+	if !ok {
+		return nil, fmt.Errorf("invalid top-level selection %q", selection.Name)
+	}
+	if field.Resolve == nil {
+		return nil, fmt.Errorf("subscription field %q has no resolver", selection.Name)
+	}
+
+	// The root field is resolved within an execution, like the fields of a
+	// query, which checks the query once, rather than for every event.
+	var stream interface{}
+	err = e.runExecution(ctx, object, query, func(executionCtx context.Context) error {
+		// The root field is checked before the stream is opened, like the
+		// fields resolved by executions.
+		if err := checkAuthorized(executionCtx, executionInfoFromContext(executionCtx).authorize, field, source); err != nil {
+			return nestPathError(selection.Alias, err)
+		}
+		var err error
+		stream, err = SafeExecuteResolver(subscriptionContext{Context: executionCtx, subscription: ctx}, field, source, selection.Args, selection.SelectionSet)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	events := reflect.ValueOf(stream)
+	if events.Kind() != reflect.Chan || events.Type().ChanDir()&reflect.RecvDir == 0 {
+		return nil, fmt.Errorf("subscription field %q must resolve to a channel, got %T", selection.Name, stream)
+	}
+
+	results := make(chan interface{})
+	go func() {
+		defer close(results)
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+			{Dir: reflect.SelectRecv, Chan: events},
+		}
+		for {
+			chosen, event, ok := reflect.Select(cases)
+			if chosen == 0 || !ok {
+				return
+			}
+
+			var payload interface{}
+			result, err := e.executeEvent(ctx, object, field, selection, source, query, event.Interface())
+			if err == ErrExecutorShutdown {
+				return
+			}
+			if err != nil {
+				payload = err
+			} else {
+				payload = result
+			}
+
+			select {
+			case results <- payload:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results, nil
+}
+
+// subscriptionContext is the context of a subscription's root resolver.  It
+// has the values of the execution resolving the root field, but lasts as long
+// as the subscription, rather than the execution, since the stream the
+// resolver opens is read until the subscription ends.
+type subscriptionContext struct {
+	context.Context
+	subscription context.Context
+}
+
+func (c subscriptionContext) Deadline() (time.Time, bool) { return c.subscription.Deadline() }
+func (c subscriptionContext) Done() <-chan struct{}       { return c.subscription.Done() }
+func (c subscriptionContext) Err() error                  { return c.subscription.Err() }
+
+// executeEvent executes a subscription's query for one of its events, with the
+// root field resolving to the event.
+func (e *Executor) executeEvent(ctx context.Context, object *Object, field *Field, selection *Selection, source interface{}, query *Query, event interface{}) (interface{}, error) {
+	if err, ok := event.(error); ok {
+		return nil, err
+	}
+
+	eventField := *field
+	eventField.Resolve = func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error) {
+		return event, nil
+	}
+	eventField.BatchResolver = nil
+	eventField.Batch = false
+	eventField.UseBatchFunc = nil
+	eventField.Expensive = false
+	eventField.NumParallelInvocationsFunc = nil
+	eventField.Retry = nil
+	eventField.Timeout = 0
+	eventField.EnrichContext = nil
+	eventField.Fallback = nil
+	eventField.Authorize = nil
+	eventField.DependsOn = nil
+
+	eventObject := *object
+	eventObject.Fields = map[string]*Field{selection.Name: &eventField}
+	var result interface{}
+	err := e.runExecution(ctx, nil, query, func(ctx context.Context) (err error) {
+		result, err = e.execute(ctx, &eventObject, source, query)
+		return err
+	})
+	return result, err
+}
@@ -0,0 +1,181 @@
+package graphql_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/samsarahq/thunder/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribe(t *testing.T) {
+	type Event struct {
+		Id int64
+	}
+
+	// The subscription's root field resolves to a channel of events.
+	events := make(chan *Event)
+	var operationKind string
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("unused", func() string { return "" })
+	builder.Subscription().FieldFunc("event", func(ctx context.Context) <-chan *Event {
+		operationKind = graphql.OperationKindFromContext(ctx)
+		return events
+	})
+	builder.Object("Event", Event{}).FieldFunc("message", func(e *Event) (string, error) {
+		if e.Id == 2 {
+			return "", errors.New("bad event")
+		}
+		return "hello", nil
+	})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`subscription { event { id message } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Subscription, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler()).(*graphql.Executor)
+	results, err := e.Subscribe(context.Background(), schema.Subscription, nil, q)
+	require.NoError(t, err)
+	// The root field is resolved within an execution.
+	assert.Equal(t, "subscription", operationKind)
+
+	go func() {
+		defer close(events)
+		for id := int64(1); id <= 3; id++ {
+			events <- &Event{Id: id}
+		}
+	}()
+
+	var payloads []interface{}
+	for payload := range results {
+		payloads = append(payloads, payload)
+	}
+	require.Len(t, payloads, 3)
+	assert.Equal(t, internal.ParseJSON(`{"event": {"id": 1, "message": "hello"}}`), internal.AsJSON(payloads[0]))
+	eventErr, ok := payloads[1].(error)
+	require.True(t, ok, "expected an error, got %v", payloads[1])
+	assert.EqualError(t, eventErr, "event.message: bad event")
+	assert.Equal(t, internal.ParseJSON(`{"event": {"id": 3, "message": "hello"}}`), internal.AsJSON(payloads[2]))
+}
+
+func TestSubscribeCanceled(t *testing.T) {
+	events := make(chan string)
+	var rootCtx context.Context
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("unused", func() string { return "" })
+	builder.Subscription().FieldFunc("tick", func(ctx context.Context) <-chan string {
+		rootCtx = ctx
+		return events
+	})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`subscription { tick }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Subscription, q.SelectionSet))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler()).(*graphql.Executor)
+	results, err := e.Subscribe(ctx, schema.Subscription, nil, q)
+	require.NoError(t, err)
+
+	// The root resolver's context lasts as long as the subscription, rather
+	// than the execution that resolved it.
+	events <- "tock"
+	assert.Equal(t, map[string]interface{}{"tick": "tock"}, <-results)
+	assert.NoError(t, rootCtx.Err())
+
+	cancel()
+	_, ok := <-results
+	assert.False(t, ok)
+	assert.Equal(t, context.Canceled, rootCtx.Err())
+}
+
+func TestSubscribeShutdown(t *testing.T) {
+	events := make(chan string)
+	var authorized int
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("unused", func() string { return "" })
+	builder.Subscription().FieldFunc("tick", func() <-chan string {
+		return events
+	}, schemabuilder.Authorize(func(ctx context.Context, field *graphql.Field, source interface{}) error {
+		authorized++
+		return nil
+	}))
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`subscription { tick }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Subscription, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler()).(*graphql.Executor)
+	results, err := e.Subscribe(context.Background(), schema.Subscription, nil, q)
+	require.NoError(t, err)
+
+	events <- "tock"
+	assert.Equal(t, map[string]interface{}{"tick": "tock"}, <-results)
+	// The field is authorized when the stream is opened, not for every event.
+	assert.Equal(t, 1, authorized)
+
+	// Once the executor is shut down, the next event closes the stream rather
+	// than sending an error.
+	require.NoError(t, e.Shutdown(context.Background()))
+	go func() { events <- "tock" }()
+	_, ok := <-results
+	assert.False(t, ok)
+}
+
+func TestSubscribeAuthorize(t *testing.T) {
+	var opened bool
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("unused", func() string { return "" })
+	builder.Subscription().FieldFunc("tick", func() <-chan string {
+		opened = true
+		return make(chan string)
+	}, schemabuilder.Authorize(func(ctx context.Context, field *graphql.Field, source interface{}) error {
+		return errors.New("denied")
+	}))
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`subscription { tick }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Subscription, q.SelectionSet))
+
+	// The stream isn't opened for a denied root field.
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler()).(*graphql.Executor)
+	results, err := e.Subscribe(context.Background(), schema.Subscription, nil, q)
+	assert.EqualError(t, err, "tick: denied")
+	assert.Nil(t, results)
+	assert.False(t, opened)
+}
+
+func TestSubscribeUnauthenticated(t *testing.T) {
+	var opened bool
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("unused", func() string { return "" })
+	builder.Subscription().FieldFunc("tick", func() <-chan string {
+		opened = true
+		return make(chan string)
+	})
+	schema := builder.MustBuild()
+
+	q := graphql.MustParse(`subscription { tick }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Subscription, q.SelectionSet))
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithPublicIntrospection(func(ctx context.Context) bool {
+		return false
+	})).(*graphql.Executor)
+	results, err := e.Subscribe(context.Background(), schema.Subscription, nil, q)
+	assert.Equal(t, graphql.ErrUnauthenticated, err)
+	assert.Nil(t, results)
+	assert.False(t, opened)
+}
+
+func TestSubscriptionMustReturnChannel(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("unused", func() string { return "" })
+	builder.Subscription().FieldFunc("tick", func() string { return "" })
+	_, err := builder.Build()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is a subscription, but does not return a channel")
+}
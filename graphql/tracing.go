@@ -0,0 +1,236 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// Tracer creates spans for the resolvers run by an Executor.
+type Tracer interface {
+	// StartSpan starts a span around a single resolver call, or a work unit
+	// (see WithWorkUnitSpans).  The name is the resolved field, e.g.
+	// "User.friends", and tags include the request ID when the executor is
+	// configured with WithRequestIDKey.  The returned context is passed to the
+	// resolver.
+	StartSpan(ctx context.Context, name string, tags map[string]string) (context.Context, Span)
+}
+
+// Span is a traced resolver call or work unit started by a Tracer.
+type Span interface {
+	// Finish ends the span with the error returned by the resolver, if any.
+	Finish(err error)
+}
+
+// ErrorLogger is notified of errors returned by an Executor.  GraphqlLogger
+// implements ErrorLogger.
+type ErrorLogger interface {
+	Error(ctx context.Context, err error, tags map[string]string)
+}
+
+// WithTracer starts a span with the tracer around every call to an external
+// resolver (i.e. every FieldFunc, but not plain struct fields), and around
+// every work unit with WithWorkUnitSpans.
+func WithTracer(tracer Tracer) ExecutorOption {
+	return func(e *Executor) {
+		e.tracer = tracer
+	}
+}
+
+// WithTraceSampleRate only traces a fraction rate (from 0 to 1) of the
+// executions, since tracing every resolver of every request is costly.  The
+// decision is made once when an execution starts, and executions that are
+// not sampled don't start any spans.  See TraceSampled.
+func WithTraceSampleRate(rate float64) ExecutorOption {
+	return func(e *Executor) {
+		e.traceSampled = func() bool {
+			return rand.Float64() < rate
+		}
+	}
+}
+
+// TraceSampled reports whether the execution ctx belongs to is traced, e.g.
+// for resolvers to decide whether to trace their own work in more detail.
+func TraceSampled(ctx context.Context) bool {
+	info := executionInfoFromContext(ctx)
+	return info.tracer != nil
+}
+
+// WithWorkUnitSpans also starts a span with the WithTracer tracer around the
+// resolution of every work unit, i.e. a field for a batch of sources.  Spans
+// are named "resolve/<Type>.<Field>" and tagged with the number of sources and
+// whether the field is expensive and resolved as a batch, and finish with the
+// error of the first destination the unit failed, if any.  The fields of a
+// BatchGroup are resolved in a "resolve/<Type>/<Group>" span wrapping theirs.
+// The spans of resolvers are started within the span of their unit.
+func WithWorkUnitSpans() ExecutorOption {
+	return func(e *Executor) {
+		e.traceUnits = true
+	}
+}
+
+// traceWorkUnit executes a work unit in a span started by tracer.
+func traceWorkUnit(tracer Tracer, unit *WorkUnit) []*WorkUnit {
+	tags := executionInfoFromContext(unit.Ctx).tags()
+	tags["object"] = unit.objectName
+	name := "resolve/" + unit.objectName + "." + unit.selection.Name
+	if len(unit.grouped) > 0 {
+		tags["group"] = unit.field.BatchGroup
+		tags["fields"] = strconv.Itoa(len(unit.grouped))
+		name = "resolve/" + unit.objectName + "/" + unit.field.BatchGroup
+	} else {
+		tags["field"] = unit.selection.Name
+		tags["sources"] = strconv.Itoa(len(unit.sources))
+		tags["expensive"] = strconv.FormatBool(unit.field.Expensive)
+		tags["batch"] = strconv.FormatBool(unit.field.Batch && unit.useBatch)
+	}
+
+	ctx, span := tracer.StartSpan(unit.Ctx, name, tags)
+	unit.Ctx = ctx
+	// The members' spans are started within the group's.
+	for _, member := range unit.grouped {
+		member.Ctx = ctx
+	}
+	units := runWorkUnit(unit)
+	// A group fails with the first error of its members.
+	destinations := unit.destinations
+	for _, member := range unit.grouped {
+		destinations = append(destinations, member.destinations...)
+	}
+	var err error
+	for _, dest := range destinations {
+		if err = dest.failure(); err != nil {
+			break
+		}
+	}
+	span.Finish(err)
+	return units
+}
+
+// WithRequestIDKey reads the request (or correlation) ID stored in the
+// execution context under key, and tags spans and logged errors with it as
+// "requestId".  Responses served by HTTPHandlerWithOptions with an error
+// registry also include it in each error's extensions.
+func WithRequestIDKey(key interface{}) ExecutorOption {
+	return func(e *Executor) {
+		e.requestIDKey = key
+	}
+}
+
+// WithErrorLogger logs every error returned by Execute with the logger.
+func WithErrorLogger(logger ErrorLogger) ExecutorOption {
+	return func(e *Executor) {
+		e.errorLogger = logger
+	}
+}
+
+// requestID returns the request ID stored under the executor's request ID
+// key, if any.
+func (e *Executor) requestID(ctx context.Context) (string, bool) {
+	if e.requestIDKey == nil {
+		return "", false
+	}
+	value := ctx.Value(e.requestIDKey)
+	if value == nil {
+		return "", false
+	}
+	if s, ok := value.(string); ok {
+		return s, true
+	}
+	return fmt.Sprint(value), true
+}
+
+// tags returns the tags that spans and logged errors are annotated with.
+func (info *executionInfo) tags() map[string]string {
+	tags := make(map[string]string)
+	if info.requestID != "" {
+		tags["requestId"] = info.requestID
+	}
+	return tags
+}
+
+// callResolver calls resolve with the context for resolving the unit's
+// field for destinations: it is marked for the primary database if the field
+// requires it, bounded by the field's timeout if it has one, timed for the
+// execution's ApolloTracing if it has one, and wrapped in a span if the
+// execution has a tracer and the field is resolved by an external resolver.
+func callResolver(ctx context.Context, unit *WorkUnit, destinations []*outputNode, resolve func(ctx context.Context) error) error {
+	if unit.field.RequiresPrimary {
+		ctx = context.WithValue(ctx, primaryKey{}, true)
+	}
+	if unit.field.Timeout > 0 {
+		resolve = withFieldTimeout(unit.field.Timeout, resolve)
+	}
+
+	info := executionInfoFromContext(ctx)
+	if info.apolloTracing != nil {
+		resolve = info.apolloTracing.wrap(unit, destinations, resolve)
+	}
+	if info.tracer == nil || !unit.field.External {
+		return resolve(ctx)
+	}
+
+	tags := info.tags()
+	tags["field"] = unit.selection.Name
+	tags["object"] = unit.objectName
+	ctx, span := info.tracer.StartSpan(ctx, unit.objectName+"."+unit.selection.Name, tags)
+	err := resolve(ctx)
+	span.Finish(err)
+	return err
+}
+
+// withFieldTimeout wraps resolve to pass it a context that expires after
+// timeout, failing with a deadline error once it does.  resolve runs in its own
+// goroutine, so that a resolver that ignores its context can't hold up the
+// query past the timeout; like in runUntilDeadline, it keeps going in the
+// background, and the execution's OnComplete callbacks are delayed until it
+// returns.
+func withFieldTimeout(timeout time.Duration, resolve func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		fieldCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		done := make(chan struct{})
+		var err error
+		go func() {
+			defer close(done)
+			defer func() {
+				if panicErr := recover(); panicErr != nil {
+					err = panicError(panicErr)
+				}
+			}()
+			err = resolve(fieldCtx)
+		}()
+
+		select {
+		case <-done:
+		case <-fieldCtx.Done():
+			if ctx.Err() != nil {
+				// The execution itself is done; let the resolver notice.
+				<-done
+				return err
+			}
+			executionInfoFromContext(ctx).detachCleanups(done)
+			return &fieldTimeoutError{timeout: timeout}
+		}
+		if fieldCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return &fieldTimeoutError{timeout: timeout}
+		}
+		return err
+	}
+}
+
+// fieldTimeoutError is returned for fields that exceed their Timeout.
+type fieldTimeoutError struct {
+	timeout time.Duration
+}
+
+func (e *fieldTimeoutError) Error() string {
+	return fmt.Sprintf("field timed out after %s", e.timeout)
+}
+
+// Unwrap returns context.DeadlineExceeded.
+func (e *fieldTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
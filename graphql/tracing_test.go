@@ -0,0 +1,376 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samsarahq/thunder/batch"
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type requestIDKey struct{}
+
+type recordedSpan struct {
+	name string
+	tags map[string]string
+	err  error
+}
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, name string, tags map[string]string) (context.Context, graphql.Span) {
+	span := &recordedSpan{name: name, tags: tags}
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+	return ctx, span
+}
+
+func (s *recordedSpan) Finish(err error) { s.err = err }
+
+type recordingErrorLogger struct {
+	err  error
+	tags map[string]string
+}
+
+func (l *recordingErrorLogger) Error(ctx context.Context, err error, tags map[string]string) {
+	l.err = err
+	l.tags = tags
+}
+
+func TestTracingRequestID(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("users", func() []*User {
+		return []*User{{Name: "alice"}, {Name: "bob"}}
+	})
+	user := schema.Object("User", User{})
+	user.FieldFunc("greeting", func(u *User) string {
+		return "hi " + u.Name
+	})
+	query.FieldFunc("broken", func() (string, error) {
+		return "", errors.New("broken")
+	})
+	builtSchema := schema.MustBuild()
+
+	tracer := &recordingTracer{}
+	logger := &recordingErrorLogger{}
+	e := graphql.NewExecutor(
+		graphql.NewImmediateGoroutineScheduler(),
+		graphql.WithTracer(tracer),
+		graphql.WithRequestIDKey(requestIDKey{}),
+		graphql.WithErrorLogger(logger),
+	)
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "req-123")
+
+	q := graphql.MustParse(`{ users { name greeting } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet))
+	_, err := e.Execute(ctx, builtSchema.Query, nil, q)
+	require.NoError(t, err)
+
+	// Plain struct fields like "name" are not traced.
+	var names []string
+	for _, span := range tracer.spans {
+		names = append(names, span.name)
+		assert.Equal(t, "req-123", span.tags["requestId"])
+		assert.NoError(t, span.err)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"Query.users", "User.greeting", "User.greeting"}, names)
+	assert.Nil(t, logger.err)
+
+	q = graphql.MustParse(`{ broken }`, nil)
+	require.NoError(t, graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet))
+	_, err = e.Execute(ctx, builtSchema.Query, nil, q)
+	require.Error(t, err)
+
+	lastSpan := tracer.spans[len(tracer.spans)-1]
+	assert.Equal(t, "Query.broken", lastSpan.name)
+	assert.EqualError(t, lastSpan.err, "broken")
+	assert.Equal(t, err, logger.err)
+	assert.Equal(t, map[string]string{"requestId": "req-123"}, logger.tags)
+}
+
+func TestTraceSampleRate(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("users", func() []*User {
+		return []*User{{Name: "alice"}, {Name: "bob"}}
+	})
+	user := schema.Object("User", User{})
+	var sampled []bool
+	var mu sync.Mutex
+	user.FieldFunc("greeting", func(ctx context.Context, u *User) string {
+		mu.Lock()
+		sampled = append(sampled, graphql.TraceSampled(ctx))
+		mu.Unlock()
+		return "hi " + u.Name
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ users { name greeting } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+
+	for _, tc := range []struct {
+		rate  float64
+		spans int
+	}{
+		{rate: 0, spans: 0},
+		{rate: 1, spans: 30},
+	} {
+		tracer := &recordingTracer{}
+		sampled = nil
+		e := graphql.NewExecutor(
+			graphql.NewImmediateGoroutineScheduler(),
+			graphql.WithTracer(tracer),
+			graphql.WithTraceSampleRate(tc.rate),
+		)
+		for i := 0; i < 10; i++ {
+			_, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+			require.NoError(t, err)
+		}
+		assert.Len(t, tracer.spans, tc.spans, "rate %v", tc.rate)
+		for _, s := range sampled {
+			assert.Equal(t, tc.rate == 1, s)
+		}
+	}
+}
+
+func TestApolloTracing(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("users", func() []*User {
+		return []*User{{Name: "alice"}, {Name: "bob"}}
+	})
+	user := schema.Object("User", User{})
+	user.FieldFunc("greeting", func(u *User) string {
+		return "hi " + u.Name
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ users { name hello: greeting } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+
+	tracing := graphql.NewApolloTracing()
+	ctx := graphql.WithQueryApolloTracing(context.Background(), tracing)
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	_, err := e.Execute(ctx, builtSchema.Query, nil, q)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(tracing)
+	require.NoError(t, err)
+	var parsed struct {
+		Version   int           `json:"version"`
+		Duration  time.Duration `json:"duration"`
+		Execution struct {
+			Resolvers []*graphql.ApolloResolverTiming `json:"resolvers"`
+		} `json:"execution"`
+	}
+	require.NoError(t, json.Unmarshal(data, &parsed))
+	assert.Equal(t, 1, parsed.Version)
+
+	byPath := make(map[string]*graphql.ApolloResolverTiming)
+	for _, r := range parsed.Execution.Resolvers {
+		assert.True(t, r.StartOffset >= 0)
+		assert.True(t, r.StartOffset+r.Duration <= parsed.Duration)
+		byPath[fmt.Sprint(r.Path)] = r
+	}
+	var paths []string
+	for path := range byPath {
+		paths = append(paths, path)
+	}
+	assert.ElementsMatch(t, []string{"[users]", "[users 0 name]", "[users 0 hello]", "[users 1 name]", "[users 1 hello]"}, paths)
+
+	hello := byPath["[users 0 hello]"]
+	assert.Equal(t, &graphql.ApolloResolverTiming{
+		Path:        []interface{}{"users", float64(0), "hello"},
+		ParentType:  "User",
+		FieldName:   "greeting",
+		ReturnType:  "string!",
+		StartOffset: hello.StartOffset,
+		Duration:    hello.Duration,
+	}, hello)
+	users := byPath["[users]"]
+	assert.True(t, hello.StartOffset >= users.StartOffset+users.Duration)
+}
+
+type spanNameKey struct{}
+
+// namingTracer records spans and passes their name to the resolvers, and to
+// the spans started within them.
+type namingTracer struct {
+	recordingTracer
+	mu      sync.Mutex
+	parents map[string]string
+}
+
+func (t *namingTracer) StartSpan(ctx context.Context, name string, tags map[string]string) (context.Context, graphql.Span) {
+	t.mu.Lock()
+	if t.parents == nil {
+		t.parents = make(map[string]string)
+	}
+	t.parents[name], _ = ctx.Value(spanNameKey{}).(string)
+	t.mu.Unlock()
+	ctx, span := t.recordingTracer.StartSpan(ctx, name, tags)
+	return context.WithValue(ctx, spanNameKey{}, name), span
+}
+
+func TestWorkUnitSpans(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("users", func() []*User {
+		return []*User{{Name: "alice"}, {Name: "bob"}}
+	})
+	user := schema.Object("User", User{})
+	user.BatchFieldFunc("greeting", func(ctx context.Context, users map[batch.Index]*User) (map[batch.Index]string, error) {
+		results := make(map[batch.Index]string)
+		for i, u := range users {
+			results[i] = fmt.Sprintf("%s from %v", u.Name, ctx.Value(spanNameKey{}))
+		}
+		return results, nil
+	})
+	user.FieldFunc("fail", func(u *User) (string, error) {
+		if u.Name == "bob" {
+			return "", errors.New("no bob")
+		}
+		return u.Name, nil
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ users { greeting } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+
+	tracer := &namingTracer{}
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithTracer(tracer), graphql.WithWorkUnitSpans())
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"users": []interface{}{
+			map[string]interface{}{"greeting": "alice from User.greeting"},
+			map[string]interface{}{"greeting": "bob from User.greeting"},
+		},
+	}, result)
+
+	spans := make(map[string]*recordedSpan)
+	for _, span := range tracer.spans {
+		spans[span.name] = span
+	}
+	require.Len(t, spans, 4)
+	assert.Equal(t, map[string]string{
+		"field":     "greeting",
+		"object":    "User",
+		"sources":   "2",
+		"expensive": "false",
+		"batch":     "true",
+	}, spans["resolve/User.greeting"].tags)
+	assert.Equal(t, "1", spans["resolve/Query.users"].tags["sources"])
+	assert.NoError(t, spans["resolve/User.greeting"].err)
+	// Resolver spans are started within the span of their unit, and units
+	// within the span of the unit that resolved their sources.
+	assert.Equal(t, map[string]string{
+		"resolve/Query.users":   "",
+		"Query.users":           "resolve/Query.users",
+		"resolve/User.greeting": "resolve/Query.users",
+		"User.greeting":         "resolve/User.greeting",
+	}, tracer.parents)
+
+	q = graphql.MustParse(`{ users { fail } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	tracer = &namingTracer{}
+	e = graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithTracer(tracer), graphql.WithWorkUnitSpans())
+	_, err = e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.Error(t, err)
+
+	var failed []*recordedSpan
+	for _, span := range tracer.spans {
+		if span.name == "resolve/User.fail" {
+			failed = append(failed, span)
+		}
+	}
+	require.NotEmpty(t, failed)
+	var errs []error
+	for _, span := range failed {
+		if span.err != nil {
+			errs = append(errs, span.err)
+		}
+	}
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "no bob")
+}
+
+func TestWorkUnitSpansBatchGroup(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("users", func() []*User {
+		return []*User{{Name: "alice"}, {Name: "bob"}}
+	})
+	user := schema.Object("User", User{})
+	user.BatchFieldFunc("first", func(users map[batch.Index]*User) map[batch.Index]string {
+		results := make(map[batch.Index]string)
+		for i, u := range users {
+			results[i] = u.Name
+		}
+		return results
+	}, schemabuilder.BatchGroup("names"))
+	user.BatchFieldFunc("last", func(users map[batch.Index]*User) (map[batch.Index]string, error) {
+		return nil, errors.New("no last names")
+	}, schemabuilder.BatchGroup("names"))
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ users { first last } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+
+	tracer := &namingTracer{}
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithTracer(tracer), graphql.WithWorkUnitSpans())
+	_, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.Error(t, err)
+
+	spans := make(map[string]*recordedSpan)
+	for _, span := range tracer.spans {
+		spans[span.name] = span
+	}
+	// The members of the group are traced within the group's span, which
+	// fails with their error.
+	group := spans["resolve/User/names"]
+	require.NotNil(t, group)
+	assert.Equal(t, map[string]string{"object": "User", "group": "names", "fields": "2"}, group.tags)
+	require.Error(t, group.err)
+	assert.Contains(t, group.err.Error(), "no last names")
+	assert.Equal(t, "resolve/User/names", tracer.parents["resolve/User.first"])
+	assert.Equal(t, "resolve/User/names", tracer.parents["resolve/User.last"])
+	assert.Equal(t, "2", spans["resolve/User.first"].tags["sources"])
+	assert.NoError(t, spans["resolve/User.first"].err)
+	require.Error(t, spans["resolve/User.last"].err)
+	assert.Contains(t, spans["resolve/User.last"].err.Error(), "no last names")
+}
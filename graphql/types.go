@@ -3,6 +3,7 @@ package graphql
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 // Type represents a GraphQL type, and should be either an Object, a Scalar,
@@ -33,6 +34,10 @@ type Enum struct {
 	Type       string
 	Values     []string
 	ReverseMap map[interface{}]string
+
+	// DeprecatedValues maps the deprecated values of the enum to the reason
+	// they are deprecated, as reported by introspection.
+	DeprecatedValues map[string]string
 }
 
 func (e *Enum) isType() {}
@@ -51,6 +56,9 @@ type Object struct {
 	Description string
 	KeyField    *Field
 	Fields      map[string]*Field
+
+	// Interfaces are the interfaces the object implements, by name.
+	Interfaces map[string]*Interface
 }
 
 func (o *Object) isType() {}
@@ -62,6 +70,36 @@ func (o *Object) String() string {
 // List is a collection of other values
 type List struct {
 	Type Type
+
+	// MaxItems bounds the number of items pulled from an Iterator for this
+	// list.  If zero, DefaultMaxIteratorItems is used.
+	MaxItems int
+}
+
+// DefaultMaxIteratorItems is the maximum number of items pulled from an
+// Iterator when the list does not configure MaxItems.
+const DefaultMaxIteratorItems = 10000
+
+// Iterator produces the items of a list one at a time.  It returns ok=false
+// once there are no more items.  Resolvers can return an Iterator (or any
+// func() (T, bool, error)) instead of a slice to avoid materializing large
+// lists; the executor pulls at most List.MaxItems items from it.  A nil
+// Iterator resolves like a nil slice: as [], or as null with
+// WithNilListsAsNull.  An Iterator's error only fails its own list.
+type Iterator func() (item interface{}, ok bool, err error)
+
+// Nullable is the result of a resolver whose field may be null, e.g. to tell
+// an explicit null apart from the zero value of a value-typed field.  It
+// resolves to Value if Valid, and to null otherwise; the zero Nullable is
+// null.  FieldFuncs returning a Nullable[T] have T's type, but are nullable.
+type Nullable[T any] struct {
+	Value T
+	Valid bool
+}
+
+// NewNullable returns a Nullable resolving to value.
+func NewNullable[T any](value T) Nullable[T] {
+	return Nullable[T]{Value: value, Valid: true}
 }
 
 func (l *List) isType() {}
@@ -73,6 +111,10 @@ func (l *List) String() string {
 type InputObject struct {
 	Name        string
 	InputFields map[string]Type
+
+	// DefaultValues are the default values of the input fields that have
+	// one, as GraphQL literals, as reported by introspection.
+	DefaultValues map[string]string
 }
 
 func (io *InputObject) isType() {}
@@ -97,6 +139,11 @@ type Union struct {
 	Name        string
 	Description string
 	Types       map[string]*Object
+
+	// MemberOf, if set, returns the name of the member type of a source,
+	// for unions whose sources are the member values themselves rather than
+	// one-hot structs.
+	MemberOf func(source interface{}) (string, bool)
 }
 
 func (*Union) isType() {}
@@ -105,6 +152,23 @@ func (u *Union) String() string {
 	return u.Name
 }
 
+// Interface is a option between multiple object types that share a set of
+// fields
+type Interface struct {
+	Name        string
+	Description string
+	// Fields are the fields shared by every member, which can be selected
+	// without narrowing the interface to a member with a fragment.
+	Fields map[string]*Field
+	Types  map[string]*Object
+}
+
+func (*Interface) isType() {}
+
+func (i *Interface) String() string {
+	return i.Name
+}
+
 // Verify *Scalar, *Object, *List, *InputObject, and *NonNull implement Type
 var _ Type = &Scalar{}
 var _ Type = &Object{}
@@ -113,6 +177,7 @@ var _ Type = &InputObject{}
 var _ Type = &NonNull{}
 var _ Type = &Enum{}
 var _ Type = &Union{}
+var _ Type = &Interface{}
 
 // A Resolver calculates the value of a field of an object
 type Resolver func(ctx context.Context, source, args interface{}, selectionSet *SelectionSet) (interface{}, error)
@@ -130,16 +195,99 @@ type Field struct {
 	Args           map[string]Type
 	ParseArguments func(json interface{}) (interface{}, error)
 
+	// ArgDefaultValues are the default values of the arguments that have
+	// one, as GraphQL literals, as reported by introspection.
+	ArgDefaultValues map[string]string
+
+	// NormalizeArgs, if set, is called with the arguments returned by
+	// ParseArguments, to compute derived arguments or check constraints
+	// between arguments (e.g. from <= to).  It returns the arguments passed to
+	// the resolver.
+	NormalizeArgs func(args interface{}) (interface{}, error)
+
+	// Complexity, if set, computes the cost of selecting the field for
+	// WithMaxComplexity, instead of its default weight.
+	Complexity ComplexityFunc
+
+	// DeprecationReason, if set, marks the field as deprecated, as reported
+	// by introspection.  Deprecated fields still resolve as usual.
+	DeprecationReason string
+
 	UseBatchFunc func(context.Context) bool
 	Batch        bool
 	External     bool
 	Expensive    bool
 
+	// RequiresPrimary marks the field as needing to read from the primary
+	// database.  See DataSourceFromContext.
+	RequiresPrimary bool
+
 	// NumParallelInvocationsFunc controls how many goroutines we'll create for a
 	// field execution (batch or non-expensive).  We pass in the number of srcs
 	// we're executing with so implementers can write custom logic.
 	NumParallelInvocationsFunc func(ctx context.Context, numNodes int) int
 
+	// Retry, if set, retries the field's resolver when it fails.
+	Retry *RetryPolicy
+
+	// Timeout, if set, bounds the time the field's resolver may take.  The
+	// resolver's context expires after Timeout, and the field fails with a
+	// deadline error, while the rest of the query resolves as usual and is
+	// returned along with the error.
+	Timeout time.Duration
+
+	// Delegated marks a field resolved by a downstream service.  Its resolver
+	// is passed the selection set pruned to the fields the downstream must
+	// return (see PruneSelectionSet), rather than the selection set of the query.
+	Delegated bool
+
+	// EnrichContext, if set, is called for every source before the field is
+	// resolved for it, e.g. to add per-tenant tracing tags.  It is not used
+	// when the field is resolved as a batch, since a batch shares one context.
+	EnrichContext func(ctx context.Context, source interface{}) context.Context
+
+	// Fallback, if set, is called with the sources whose resolver returned an
+	// error, e.g. to serve stale data from a cache.  Its results are used if
+	// it succeeds, otherwise the original error is returned.  A batch
+	// resolver that fails only some of its sources with BatchSourceErrors
+	// falls back for just those sources.
+	Fallback BatchResolver
+
+	// BatchGroup, if set, resolves the field's batches together with those of
+	// the other batch fields of the object in the same group: they run
+	// concurrently in a single work unit, so that e.g. the calls of a
+	// batch.Func shared by their resolvers are coalesced, while fields in
+	// different groups (or in none) run as separate units.
+	BatchGroup string
+
+	// FeatureFlag, if set, gates the field behind a feature flag: queries can
+	// only select the field if the flag is enabled by the FeatureFlags in the
+	// context (see WithFeatureFlags), and otherwise fail as if it didn't exist.
+	FeatureFlag string
+
+	// Transformers, if set, are applied in order to every value the field
+	// resolves to, e.g. to mask or round it.  A failing transformer fails the
+	// field and skips the transformers after it.
+	Transformers []OutputTransformer
+
+	// Validate, if set, checks every value the field resolves to before it is
+	// used in the response.  The field fails with the returned error, if any.
+	Validate func(value interface{}) error
+
+	// Authorize, if set, is called for every source before the field is
+	// resolved for it.  If it returns an error, the field fails with it for
+	// that source and its resolver is not called.  See also WithAuthorizer.
+	Authorize AuthorizeFunc
+
+	// DependsOn names sibling fields that must finish resolving before this
+	// field is resolved.  Dependencies that are not selected are ignored.
+	DependsOn []string
+
+	// Requires names the fields of the source that the field's resolver reads,
+	// e.g. the name a greeting is computed from.  When the field is selected,
+	// PruneSelectionSet keeps them, so that a downstream service returns them.
+	Requires []string
+
 	// FederatedKey tells us which services need this field as federated key.
 	FederatedKey map[string]bool
 }
@@ -147,6 +295,9 @@ type Field struct {
 type Schema struct {
 	Query    Type
 	Mutation Type
+	// Subscription is the root of subscriptions (see Executor.Subscribe), if
+	// the schema has any.
+	Subscription Type
 }
 
 // SelectionSet represents a core GraphQL query
@@ -171,6 +322,12 @@ type Schema struct {
 type SelectionSet struct {
 	Selections []*Selection
 	Fragments  []*Fragment
+
+	// fragmentOffsets holds, for every fragment, the number of selections
+	// that precede it in the query source, so that Flatten can return the
+	// selections in source order.  It is nil if the fragments follow the
+	// selections, e.g. for selection sets that were not parsed.
+	fragmentOffsets []int
 }
 
 // A selection represents a part of a GraphQL query
@@ -200,6 +357,22 @@ type Selection struct {
 
 	// ParentType is the type that this field hangs off of.
 	ParentType string
+
+	// Locations are the positions of the field in the query source.  A field
+	// selected several times (e.g. by different fragments) has several
+	// locations.
+	Locations []Location
+}
+
+// An OutputTransformer transforms the value a field resolved to before it is
+// used in the response.
+type OutputTransformer func(value interface{}) (interface{}, error)
+
+// A Location is a position in a query source, as reported in the "locations"
+// of GraphQL errors.  Lines and columns start at 1.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
 }
 
 // A Fragment represents a reusable part of a GraphQL query
@@ -208,6 +381,9 @@ type Selection struct {
 // this Fragment should be used. That is not currently implemented in this
 // package.
 type Fragment struct {
+	// Name is the name of a fragment definition, and empty for inline
+	// fragments.
+	Name         string
 	On           string
 	SelectionSet *SelectionSet
 	Directives   []*Directive
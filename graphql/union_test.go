@@ -302,3 +302,238 @@ func TestUnionStruct(t *testing.T) {
 		t.Errorf("expected did not match result: %s", d)
 	}
 }
+
+func TestNestedUnionPathError(t *testing.T) {
+	type UnionType struct {
+		schemabuilder.Union
+
+		*UnionPart1
+		*UnionPart2
+	}
+	type Member struct {
+		Name string
+		Pet  *UnionType
+	}
+	type Group struct {
+		Members []*Member
+	}
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("groups", func() []*Group {
+		return []*Group{
+			{Members: []*Member{{Name: "a", Pet: &UnionType{UnionPart1: &UnionPart1{"a"}}}}},
+			{Members: []*Member{
+				{Name: "b", Pet: &UnionType{UnionPart2: &UnionPart2{"b"}}},
+				{Name: "c", Pet: &UnionType{UnionPart1: &UnionPart1{"c"}, UnionPart2: &UnionPart2{"c"}}},
+			}},
+		}
+	})
+	builtSchema := schema.MustBuild()
+	ctx := context.Background()
+
+	q := graphql.MustParse(`{ groups { members { name pet { ... on UnionPart1 { otherThing } ... on UnionPart2 { thing } } } } }`, nil)
+	if err := graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	_, err := e.Execute(ctx, builtSchema.Query, nil, q)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if want := "groups.1.members.1.pet: union type field should only return one value"; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("expected error to start with %q, received %q", want, err.Error())
+	}
+}
+
+func TestUnionWithoutOneMember(t *testing.T) {
+	type UnionType struct {
+		schemabuilder.Union
+
+		*UnionPart1
+		*UnionPart2
+	}
+
+	for name, tc := range map[string]struct {
+		value *UnionType
+		want  string
+	}{
+		"empty": {
+			value: &UnionType{},
+			want:  "unions.1: union type field should return one value of UnionType, but received none",
+		},
+		"two members": {
+			value: &UnionType{UnionPart1: &UnionPart1{"a"}, UnionPart2: &UnionPart2{"b"}},
+			want:  "unions.1: union type field should only return one value, but received:",
+		},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			schema := schemabuilder.NewSchema()
+			schema.Query().FieldFunc("unions", func() []*UnionType {
+				return []*UnionType{{UnionPart1: &UnionPart1{"a"}}, tc.value, nil}
+			})
+			builtSchema := schema.MustBuild()
+			ctx := context.Background()
+
+			q := graphql.MustParse(`{ unions { ... on UnionPart1 { otherThing } ... on UnionPart2 { thing } } }`, nil)
+			if err := graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet); err != nil {
+				t.Fatal(err)
+			}
+
+			e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+			_, err := e.Execute(ctx, builtSchema.Query, nil, q)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.HasPrefix(err.Error(), tc.want) {
+				t.Errorf("expected error to start with %q, received %q", tc.want, err.Error())
+			}
+		})
+	}
+}
+
+func TestUnionCommonFields(t *testing.T) {
+	type Vehicle struct {
+		Name  string
+		Speed int64
+	}
+	type Asset struct {
+		Name         string
+		BatteryLevel int64
+		Speed        string
+	}
+
+	type Gateway struct {
+		schemabuilder.Union
+
+		*Vehicle
+		*Asset
+	}
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("gateways", func() []*Gateway {
+		return []*Gateway{
+			{Vehicle: &Vehicle{Name: "a", Speed: 50}},
+			{Asset: &Asset{Name: "b", BatteryLevel: 5}},
+		}
+	})
+	builtSchema := schema.MustBuild()
+	ctx := context.Background()
+
+	q := graphql.MustParse(`{ gateways { name ... on Asset { batteryLevel } } }`, nil)
+	if err := graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := testgraphql.NewExecutorWrapper(t)
+	result, err := e.Execute(ctx, builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := pretty.Compare(internal.AsJSON(result), internal.ParseJSON(`
+		{"gateways": [{"name": "a"}, {"name": "b", "batteryLevel": 5}]}`)); d != "" {
+		t.Errorf("expected did not match result: %s", d)
+	}
+
+	for query, wantErr := range map[string]string{
+		`{ gateways { batteryLevel } }`: `unknown field "batteryLevel"`,
+		`{ gateways { speed } }`:        `field "speed" has different types on members of union Gateway`,
+	} {
+		q := graphql.MustParse(query, nil)
+		err := graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet)
+		if err == nil || err.Error() != wantErr {
+			t.Errorf("expected error %q for %s, received %v", wantErr, query, err)
+		}
+	}
+}
+
+func TestUnionTypename(t *testing.T) {
+	type UnionType struct {
+		schemabuilder.Union
+
+		*UnionPart1
+		*UnionPart2
+	}
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("list", func() []*UnionType {
+		return []*UnionType{
+			{UnionPart2: &UnionPart2{"b"}},
+			{UnionPart1: &UnionPart1{"a"}},
+		}
+	})
+	builtSchema := schema.MustBuild()
+	ctx := context.Background()
+
+	for query, want := range map[string]string{
+		`{ list { __typename } }`: `
+			{"list": [{"__typename": "UnionPart2"}, {"__typename": "UnionPart1"}]}`,
+		// __typename resolves for members without a matching fragment too.
+		`{ list { kind: __typename ... on UnionPart1 { otherThing } } }`: `
+			{"list": [{"kind": "UnionPart2"}, {"kind": "UnionPart1", "otherThing": "a"}]}`,
+	} {
+		q := graphql.MustParse(query, nil)
+		if err := graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet); err != nil {
+			t.Fatal(err)
+		}
+
+		e := testgraphql.NewExecutorWrapper(t)
+		result, err := e.Execute(ctx, builtSchema.Query, nil, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d := pretty.Compare(internal.AsJSON(result), internal.ParseJSON(want)); d != "" {
+			t.Errorf("expected did not match result for %s: %s", query, d)
+		}
+	}
+}
+
+type CreateUserResult interface{}
+
+type CreatedUser struct {
+	Id    int64
+	Email string
+}
+
+type EmailTakenError struct {
+	Email string
+}
+
+func TestResultUnion(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.ResultUnion("CreateUserResult", (*CreateUserResult)(nil), &CreatedUser{}, &EmailTakenError{})
+	schema.Mutation().FieldFunc("createUser", func(args struct{ Email string }) CreateUserResult {
+		if args.Email == "taken@example.com" {
+			return &EmailTakenError{Email: args.Email}
+		}
+		return &CreatedUser{Id: 1, Email: args.Email}
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`mutation {
+		created: createUser(email: "new@example.com") { __typename ... on CreatedUser { id email } ... on EmailTakenError { email } }
+		taken: createUser(email: "taken@example.com") { __typename ... on CreatedUser { id email } ... on EmailTakenError { email } }
+	}`, nil)
+	ctx := context.Background()
+	if err := graphql.PrepareQuery(ctx, builtSchema.Mutation, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := testgraphql.NewExecutorWrapper(t)
+	result, err := e.Execute(ctx, builtSchema.Mutation, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := pretty.Compare(internal.AsJSON(result), internal.ParseJSON(`{
+		"created": {"__typename": "CreatedUser", "id": 1, "email": "new@example.com"},
+		"taken": {"__typename": "EmailTakenError", "email": "taken@example.com"}
+	}`)); d != "" {
+		t.Errorf("expected did not match result: %s", d)
+	}
+}
@@ -0,0 +1,90 @@
+package graphql
+
+import "sync"
+
+// ErrTooManyExecutionUnits is returned for executions aborted by the limit
+// set with WithMaxExecutionUnits.
+var ErrTooManyExecutionUnits = NewClientError("resource exhausted: query exceeds the maximum number of execution units")
+
+// WithMaxExecutionUnits aborts executions that schedule more than max work
+// units in total, e.g. because a broad query nests expensive fields under long
+// lists, with ErrTooManyExecutionUnits, before they exhaust memory.  Once the
+// limit is exceeded, no more units are scheduled and the destinations of the
+// pending units are failed.
+func WithMaxExecutionUnits(max int) ExecutorOption {
+	return func(e *Executor) {
+		e.maxExecutionUnits = max
+	}
+}
+
+// unitLimiter counts the work units scheduled by an execution.
+type unitLimiter struct {
+	max int
+
+	mu        sync.Mutex
+	scheduled int
+	exceeded  bool
+}
+
+// schedule counts the units an execution starts the scheduler with, e.g. for
+// its top-level selections.
+func (l *unitLimiter) schedule(units int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.scheduled += units
+	if l.scheduled > l.max {
+		l.exceeded = true
+	}
+}
+
+// wrap returns a UnitResolver that stops scheduling resolver's units once the
+// limit is exceeded.
+func (l *unitLimiter) wrap(resolver UnitResolver) UnitResolver {
+	return func(unit *WorkUnit) []*WorkUnit {
+		if l.isExceeded() {
+			failWorkUnit(unit, ErrTooManyExecutionUnits)
+			return nil
+		}
+
+		units := resolver(unit)
+
+		l.mu.Lock()
+		l.scheduled += len(units)
+		if l.scheduled > l.max {
+			l.exceeded = true
+		}
+		exceeded := l.exceeded
+		l.mu.Unlock()
+
+		if exceeded {
+			for _, unit := range units {
+				failWorkUnit(unit, ErrTooManyExecutionUnits)
+			}
+			return nil
+		}
+		return units
+	}
+}
+
+// isExceeded returns whether the execution exceeded the limit.
+func (l *unitLimiter) isExceeded() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.exceeded
+}
+
+// failWorkUnit fails the destinations of a unit that will not be run.
+func failWorkUnit(unit *WorkUnit, err error) {
+	for _, member := range unit.grouped {
+		failWorkUnit(member, err)
+	}
+	for _, dest := range unit.destinations {
+		dest.Fail(err)
+	}
+	for _, gate := range unit.dependents {
+		// Units waiting on this one are released, and failed in turn.
+		for _, released := range gate.release() {
+			failWorkUnit(released, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package graphql_test
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxExecutionUnits(t *testing.T) {
+	type Node struct {
+		Id int64
+	}
+
+	var resolved int64
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("root", func() *Node {
+		return &Node{}
+	})
+	builder.Query().FieldFunc("roots", func() []*Node {
+		roots := make([]*Node, 100)
+		for i := range roots {
+			roots[i] = &Node{Id: int64(i)}
+		}
+		return roots
+	})
+	// Every node has 100 children, each resolved in its own unit, so every
+	// level of nesting multiplies the number of units by 100.
+	builder.Object("Node", Node{}).FieldFunc("children", func(n *Node) []*Node {
+		atomic.AddInt64(&resolved, 1)
+		children := make([]*Node, 100)
+		for i := range children {
+			children[i] = &Node{Id: int64(i)}
+		}
+		return children
+	}, schemabuilder.Expensive)
+	schema := builder.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithMaxExecutionUnits(1000))
+
+	q := graphql.MustParse(`{ root { children { id } } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	_, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+
+	// 1 + 100 + 10,000 + 1,000,000 units, if nothing stopped it.
+	atomic.StoreInt64(&resolved, 0)
+	q = graphql.MustParse(`{ root { children { children { children { children { id } } } } } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	assert.Equal(t, graphql.ErrTooManyExecutionUnits, err)
+	assert.Nil(t, res)
+	assert.True(t, atomic.LoadInt64(&resolved) <= 1000, "resolved %d nodes", atomic.LoadInt64(&resolved))
+
+	// NDJSON executions are limited too, across all their elements: every
+	// root schedules 1 + 100 units.
+	atomic.StoreInt64(&resolved, 0)
+	q = graphql.MustParse(`{ roots { children { children { id } } } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	var buf bytes.Buffer
+	err = e.(*graphql.Executor).ExecuteNDJSON(context.Background(), &buf, schema.Query, nil, q)
+	assert.Equal(t, graphql.ErrTooManyExecutionUnits, err)
+	assert.True(t, atomic.LoadInt64(&resolved) <= 1000, "resolved %d nodes", atomic.LoadInt64(&resolved))
+}
@@ -0,0 +1,178 @@
+package graphql
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// coerceVariables returns the values of the query's variables: the provided
+// values, or the defaults of the variables that were not provided, coerced
+// to the variables' declared types.  A value of a list type that is not a
+// list is wrapped in a list, and values of the built-in scalar types (see
+// isScalarValue) are checked.  Values of other types are checked when the arguments they are
+// passed to are parsed.
+func coerceVariables(definitions []*ast.VariableDefinition, vars map[string]interface{}) (map[string]interface{}, error) {
+	if len(definitions) == 0 {
+		return vars, nil
+	}
+
+	coerced := make(map[string]interface{}, len(vars))
+	for name, value := range vars {
+		coerced[name] = value
+	}
+	for _, definition := range definitions {
+		name := definition.Variable.Name.Value
+
+		if _, ok := definition.Type.(*ast.NonNull); ok && definition.DefaultValue != nil {
+			return nil, NewClientError("required variable cannot provide a default value: $%s", name)
+		}
+
+		value, provided := vars[name]
+		if value == nil && definition.DefaultValue != nil {
+			defaultValue, err := valueToJson(definition.DefaultValue, nil)
+			if err != nil {
+				return nil, NewClientError("failed to parse default value: %s", err.Error())
+			}
+			value, provided = defaultValue, true
+		}
+		if !provided {
+			if _, ok := definition.Type.(*ast.NonNull); ok {
+				return nil, NewClientError("missing required variable $%s of type %s", name, astTypeString(definition.Type))
+			}
+			continue
+		}
+
+		value, err := coerceVariable(definition.Type, value)
+		if err != nil {
+			return nil, NewClientError("bad value for variable $%s of type %s: %s", name, astTypeString(definition.Type), err)
+		}
+		coerced[name] = value
+	}
+	return coerced, nil
+}
+
+// coerceVariable coerces the value of a variable to typ.
+func coerceVariable(typ ast.Type, value interface{}) (interface{}, error) {
+	switch typ := typ.(type) {
+	case *ast.NonNull:
+		if value == nil {
+			return nil, fmt.Errorf("must not be null")
+		}
+		return coerceVariable(typ.Type, value)
+
+	case *ast.List:
+		if value == nil {
+			return nil, nil
+		}
+		list, ok := value.([]interface{})
+		if !ok {
+			item, err := coerceVariable(typ.Type, value)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		coerced := make([]interface{}, len(list))
+		for i, item := range list {
+			item, err := coerceVariable(typ.Type, item)
+			if err != nil {
+				return nil, fmt.Errorf("%d: %s", i, err)
+			}
+			coerced[i] = item
+		}
+		return coerced, nil
+
+	case *ast.Named:
+		if value == nil {
+			return nil, nil
+		}
+		if !isScalarValue(typ.Name.Value, value) {
+			return nil, fmt.Errorf("not a valid %s", typ.Name.Value)
+		}
+		return value, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported type %s", astTypeString(typ))
+	}
+}
+
+// isScalarValue returns whether value is valid for the scalar type name,
+// which is either a GraphQL scalar or one of the scalars schemabuilder maps Go
+// types to (e.g. int64 or string).  Any value is valid for other types.
+func isScalarValue(name string, value interface{}) bool {
+	switch name {
+	case "Int", "int32":
+		return isIntegerValue(value, math.MinInt32, math.MaxInt32)
+	case "int8":
+		return isIntegerValue(value, math.MinInt8, math.MaxInt8)
+	case "int16":
+		return isIntegerValue(value, math.MinInt16, math.MaxInt16)
+	case "int", "int64":
+		return isIntegerValue(value, math.MinInt64, math.MaxInt64)
+	case "uint8":
+		return isIntegerValue(value, 0, math.MaxUint8)
+	case "uint16":
+		return isIntegerValue(value, 0, math.MaxUint16)
+	case "uint32":
+		return isIntegerValue(value, 0, math.MaxUint32)
+	case "uint", "uint64":
+		return isIntegerValue(value, 0, math.MaxUint64)
+	case "Float", "float32", "float64":
+		_, ok := value.(float64)
+		return ok
+	case "String", "string":
+		_, ok := value.(string)
+		return ok
+	case "Boolean", "bool":
+		_, ok := value.(bool)
+		return ok
+	case "ID":
+		switch value.(type) {
+		case string, float64:
+			return true
+		}
+		return false
+	case "Time":
+		asString, ok := value.(string)
+		if !ok {
+			return false
+		}
+		_, err := time.Parse(time.RFC3339, asString)
+		return err == nil
+	case "bytes":
+		asString, ok := value.(string)
+		if !ok {
+			return false
+		}
+		_, err := base64.StdEncoding.DecodeString(asString)
+		return err == nil
+	default:
+		return true
+	}
+}
+
+// isIntegerValue returns whether value is a whole number between min and max.
+// Bounds are compared as float64, so the largest 64-bit integers are only
+// checked approximately.
+func isIntegerValue(value interface{}, min, max float64) bool {
+	number, ok := value.(float64)
+	return ok && number == math.Trunc(number) && number >= min && number <= max
+}
+
+// astTypeString formats a type as it is written in queries, e.g. [Int!]!.
+func astTypeString(typ ast.Type) string {
+	switch typ := typ.(type) {
+	case *ast.NonNull:
+		return astTypeString(typ.Type) + "!"
+	case *ast.List:
+		return "[" + astTypeString(typ.Type) + "]"
+	case *ast.Named:
+		return typ.Name.Value
+	default:
+		return fmt.Sprint(typ)
+	}
+}
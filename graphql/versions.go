@@ -0,0 +1,53 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+)
+
+type expectedVersionsKey struct{}
+
+// WithExpectedVersions records the versions of the aggregates (e.g. of an
+// event-sourced backend) that the execution run with the returned context
+// expects to read, keyed by aggregate ID, e.g. as sent by a client that
+// edits what it read earlier.  Resolvers enforce them with CheckVersion, so
+// that the request fails rather than act on data that has since changed.
+func WithExpectedVersions(ctx context.Context, versions map[string]int64) context.Context {
+	return context.WithValue(ctx, expectedVersionsKey{}, versions)
+}
+
+// ExpectedVersion returns the version of the aggregate that the current
+// request expects, if any.
+func ExpectedVersion(ctx context.Context, aggregate string) (version int64, ok bool) {
+	versions, _ := ctx.Value(expectedVersionsKey{}).(map[string]int64)
+	version, ok = versions[aggregate]
+	return version, ok
+}
+
+// CheckVersion returns a *VersionConflictError if the current request
+// expects a version of the aggregate other than version, its current
+// version.
+func CheckVersion(ctx context.Context, aggregate string, version int64) error {
+	expected, ok := ExpectedVersion(ctx, aggregate)
+	if !ok || expected == version {
+		return nil
+	}
+	return &VersionConflictError{Aggregate: aggregate, Expected: expected, Actual: version}
+}
+
+// VersionConflictError is returned by CheckVersion when an aggregate is not
+// at the version the request expects.  Like other resolver errors, it is
+// returned by Execute nested in the path of the field that failed; to report
+// it to clients, e.g. so they know to refetch, register it with an
+// ErrorRegistry:
+//
+//	registry.Register(&graphql.VersionConflictError{}, graphql.CodeErrorFormatter("CONFLICT"))
+type VersionConflictError struct {
+	Aggregate string
+	Expected  int64
+	Actual    int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("version conflict on %s: expected version %d, but it is at version %d", e.Aggregate, e.Expected, e.Actual)
+}
@@ -0,0 +1,64 @@
+package graphql_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckVersion(t *testing.T) {
+	type Account struct {
+		ID      string `graphql:"id"`
+		Version int64
+		Balance int64
+	}
+	accounts := map[string]*Account{
+		"a": {ID: "a", Version: 3, Balance: 10},
+		"b": {ID: "b", Version: 5, Balance: 20},
+	}
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("accounts", func() []*Account {
+		return []*Account{accounts["a"], accounts["b"]}
+	})
+	account := schema.Object("Account", Account{})
+	account.FieldFunc("checkedBalance", func(ctx context.Context, a *Account) (int64, error) {
+		if err := graphql.CheckVersion(ctx, a.ID, a.Version); err != nil {
+			return 0, err
+		}
+		return a.Balance, nil
+	})
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`{ accounts { id checkedBalance } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+
+	ctx := graphql.WithExpectedVersions(context.Background(), map[string]int64{"a": 3, "b": 5})
+	result, err := e.Execute(ctx, builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"accounts": []interface{}{
+			map[string]interface{}{"id": "a", "checkedBalance": int64(10)},
+			map[string]interface{}{"id": "b", "checkedBalance": int64(20)},
+		},
+	}, result)
+
+	ctx = graphql.WithExpectedVersions(context.Background(), map[string]int64{"b": 4})
+	_, err = e.Execute(ctx, builtSchema.Query, nil, q)
+	require.Error(t, err)
+	assert.Equal(t, "accounts.1.checkedBalance: version conflict on b: expected version 4, but it is at version 5", err.Error())
+	var conflict *graphql.VersionConflictError
+	require.True(t, errors.As(err, &conflict))
+	assert.Equal(t, &graphql.VersionConflictError{Aggregate: "b", Expected: 4, Actual: 5}, conflict)
+
+	registry := &graphql.ErrorRegistry{}
+	registry.Register(&graphql.VersionConflictError{}, graphql.CodeErrorFormatter("CONFLICT"))
+	assert.Equal(t, "CONFLICT", registry.Format(err).Extensions["code"])
+}
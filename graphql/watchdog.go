@@ -0,0 +1,140 @@
+package graphql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithStallTimeout aborts executions whose work units make no progress (no
+// unit finishes) for the given duration while units are still pending, e.g.
+// because resolvers deadlocked.  The execution then fails with a
+// *StallError describing the pending units, rather than hanging forever.
+//
+// The timeout should be well above the time the slowest resolver takes, since
+// a single slow resolver is indistinguishable from a stalled one.
+func WithStallTimeout(timeout time.Duration) ExecutorOption {
+	return func(e *Executor) {
+		e.stallTimeout = timeout
+	}
+}
+
+// StallError is returned by executions aborted by the watchdog configured with
+// WithStallTimeout.
+type StallError struct {
+	// Stalled is how long the execution went without progress.
+	Stalled time.Duration
+	// Pending is the number of work units that have not finished.
+	Pending int
+	// Queued is the number of pending units the scheduler has not started.
+	Queued int
+	// Running describes the units that have started but not finished, e.g.
+	// "User.name (3 sources) running for 5s", sorted.
+	Running []string
+}
+
+func (e *StallError) Error() string {
+	return fmt.Sprintf("graphql: execution made no progress for %s: %d units pending (%d queued, %d running: %s)",
+		e.Stalled, e.Pending, e.Queued, len(e.Running), strings.Join(e.Running, ", "))
+}
+
+// stallWatchdog tracks the work units of an execution to detect when they
+// stop making progress.
+type stallWatchdog struct {
+	timeout time.Duration
+
+	mu           sync.Mutex
+	pending      int
+	running      map[*WorkUnit]time.Time
+	lastProgress time.Time
+	err          *StallError
+
+	// stalled is closed once a stall is detected.
+	stalled chan struct{}
+}
+
+func newStallWatchdog(timeout time.Duration, initialUnits int) *stallWatchdog {
+	return &stallWatchdog{
+		timeout:      timeout,
+		pending:      initialUnits,
+		running:      make(map[*WorkUnit]time.Time),
+		lastProgress: time.Now(),
+		stalled:      make(chan struct{}),
+	}
+}
+
+// wrap returns a UnitResolver that reports the progress of resolver's units to
+// the watchdog.
+func (w *stallWatchdog) wrap(resolver UnitResolver) UnitResolver {
+	return func(unit *WorkUnit) []*WorkUnit {
+		w.mu.Lock()
+		w.running[unit] = time.Now()
+		w.mu.Unlock()
+
+		units := resolver(unit)
+
+		w.mu.Lock()
+		delete(w.running, unit)
+		w.pending += len(units) - 1
+		w.lastProgress = time.Now()
+		w.mu.Unlock()
+		return units
+	}
+}
+
+// watch checks for stalls until done is closed.
+func (w *stallWatchdog) watch(done <-chan struct{}) {
+	ticker := time.NewTicker(w.timeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if w.check() {
+				close(w.stalled)
+				return
+			}
+		}
+	}
+}
+
+// check records a StallError if the units have not made progress within the
+// timeout, returning whether they stalled.
+func (w *stallWatchdog) check() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	stalled := now.Sub(w.lastProgress)
+	if w.pending == 0 || stalled < w.timeout {
+		return false
+	}
+
+	running := make([]string, 0, len(w.running))
+	for unit, started := range w.running {
+		running = append(running, fmt.Sprintf("%s.%s (%d sources) running for %s",
+			unit.objectName, unit.selection.Name, len(unit.sources), now.Sub(started).Round(time.Millisecond)))
+	}
+	sort.Strings(running)
+
+	w.err = &StallError{
+		Stalled: stalled.Round(time.Millisecond),
+		Pending: w.pending,
+		Queued:  w.pending - len(w.running),
+		Running: running,
+	}
+	return true
+}
+
+// stallError returns the StallError of the execution, if it stalled.
+func (w *stallWatchdog) stallError() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		return nil
+	}
+	return w.err
+}
@@ -0,0 +1,48 @@
+package graphql_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samsarahq/thunder/graphql"
+	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStallTimeout(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("fast", func() string {
+		return "fast"
+	})
+	builder.Query().FieldFunc("stuck", func(ctx context.Context) (string, error) {
+		// Simulate a deadlocked resolver, which only returns once the
+		// execution is aborted.
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	schema := builder.MustBuild()
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithStallTimeout(50*time.Millisecond))
+
+	q := graphql.MustParse(`{ fast }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"fast": "fast"}, res)
+
+	q = graphql.MustParse(`{ fast stuck }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	_, err = e.Execute(context.Background(), schema.Query, nil, q)
+
+	var stallErr *graphql.StallError
+	require.True(t, errors.As(err, &stallErr), "expected a stall error, got %v", err)
+	assert.GreaterOrEqual(t, int64(stallErr.Stalled), int64(50*time.Millisecond))
+	assert.Equal(t, 1, stallErr.Pending)
+	assert.Equal(t, 0, stallErr.Queued)
+	require.Len(t, stallErr.Running, 1)
+	assert.Contains(t, stallErr.Running[0], "Query.stuck (1 sources) running for")
+	assert.Contains(t, err.Error(), "graphql: execution made no progress for")
+}
@@ -8,22 +8,45 @@ import (
 // errorRecorder is a concurrency-safe way where we can record the first error
 // we get from executing the graphql query.
 type errorRecorder struct {
-	sync.Once
+	mu  sync.Mutex
 	err error
+	// errs holds every recorded error, in order.
+	errs []error
 }
 
 func (e *errorRecorder) record(err error) {
 	if err == nil {
 		return
 	}
-	e.Do(func() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err == nil {
 		e.err = err
-	})
+	}
+	e.errs = append(e.errs, err)
+}
+
+// get returns the first recorded error, if any.
+func (e *errorRecorder) get() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// all returns every recorded error, if any.
+func (e *errorRecorder) all() Errors {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return Errors(e.errs)
 }
 
 type pathTracker struct {
 	parent *pathTracker
 	path   string
+
+	// locations are the positions in the query of the field this path
+	// element was selected by, if any.
+	locations []Location
 }
 
 func (p *pathTracker) getPath() []string {
@@ -38,6 +61,16 @@ func (p *pathTracker) getPath() []string {
 	return path
 }
 
+// getLocations returns the locations of the innermost field on the path.
+func (p *pathTracker) getLocations() []Location {
+	for cur := p; cur != nil; cur = cur.parent {
+		if cur.locations != nil {
+			return cur.locations
+		}
+	}
+	return nil
+}
+
 // newTopLevelOutputNode creates a top-level object writer, this should be
 // the object writer that starts the graphql query.
 func newTopLevelOutputNode(path string) *outputNode {
@@ -57,24 +90,77 @@ func newOutputNode(parent *outputNode, path string) *outputNode {
 	}
 }
 
+// newSelectionOutputNode creates an object writer for the result of a
+// selection, so that errors can point at the selection in the query.
+func newSelectionOutputNode(parent *outputNode, selection *Selection) *outputNode {
+	node := newOutputNode(parent, selection.Alias)
+	node.pathTracker.locations = selection.Locations
+	return node
+}
+
+// isNonNull returns whether typ is a non-null type.
+func isNonNull(typ Type) bool {
+	_, ok := typ.(*NonNull)
+	return ok
+}
+
+// outputNode holds the result of a single value in the response.  A value is
+// only attached to the response tree once it is complete (e.g. an object's map
+// is filled after all of its fields have output nodes), so the tree can be
+// read while execution is still in progress.
 type outputNode struct {
 	pathTracker *pathTracker
+	mu          sync.Mutex
 	res         interface{}
 	errRecorder *errorRecorder
+	// err is the error the node failed with, if any.
+	err error
+	// nonNull is set if the node's value has a non-null type, so that
+	// partial results null its parent if it fails.
+	nonNull bool
 }
 
 func (o *outputNode) MarshalJSON() ([]byte, error) {
-	return json.Marshal(o.res)
+	return json.Marshal(o.result())
 }
 
 func (o *outputNode) Fill(res interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
 	o.res = res
 }
 
+// result returns the value the node has been filled with, if any.
+func (o *outputNode) result() interface{} {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.res
+}
+
 func (o *outputNode) Fail(err error) {
 	path := o.getPath()
 	err = nestPathErrorMulti(path, err)
-	o.errRecorder.record(err)
+	if pe, ok := err.(*pathError); ok && pe.locations == nil {
+		pe.locations = o.pathTracker.getLocations()
+	}
+	o.mu.Lock()
+	failed := o.err != nil
+	if !failed {
+		o.err = err
+	}
+	o.mu.Unlock()
+	// Only record the first error of the node, so that every error of the
+	// execution is about a different value.
+	if !failed {
+		o.errRecorder.record(err)
+	}
+}
+
+// failure returns the error the node failed with, if any.
+func (o *outputNode) failure() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.err
 }
 
 // getPath traverses the parent list to get the current execution path.
@@ -98,7 +184,7 @@ func outputNodeToJSON(src interface{}) interface{} {
 		}
 		return newList
 	case *outputNode:
-		return outputNodeToJSON(src.res)
+		return outputNodeToJSON(src.result())
 	case []interface{}:
 		for idx := range src {
 			src[idx] = outputNodeToJSON(src[idx])
@@ -113,3 +199,61 @@ func outputNodeToJSON(src interface{}) interface{} {
 		return src
 	}
 }
+
+// outputNodeToPartialJSON is outputNodeToJSON for the partial result of a
+// query whose fields failed: failed values are null, and a null in a non-null
+// position nulls its parent instead.  null reports whether src itself must be
+// null as a result.
+func outputNodeToPartialJSON(src interface{}) (res interface{}, null bool) {
+	switch src := src.(type) {
+	case map[string]*outputNode:
+		newMap := make(map[string]interface{}, len(src))
+		for key, val := range src {
+			if newMap[key], null = outputNodeToPartialJSON(val); null {
+				return nil, true
+			}
+		}
+		return newMap, false
+	case []*outputNode:
+		newList := make([]interface{}, len(src))
+		for idx, val := range src {
+			if newList[idx], null = outputNodeToPartialJSON(val); null {
+				return nil, true
+			}
+		}
+		return newList, false
+	case *outputNode:
+		if src.failure() != nil {
+			return nil, src.nonNull
+		}
+		if res, null = outputNodeToPartialJSON(src.result()); null {
+			return nil, src.nonNull
+		}
+		return res, false
+	case []interface{}:
+		for idx := range src {
+			if src[idx], null = outputNodeToPartialJSON(src[idx]); null {
+				return nil, true
+			}
+		}
+		return src, false
+	case map[string]interface{}:
+		for key := range src {
+			if src[key], null = outputNodeToPartialJSON(src[key]); null {
+				return nil, true
+			}
+		}
+		return src, false
+	default:
+		return src, false
+	}
+}
+
+// MarshalResult encodes a result returned by Execute as JSON.  Execute already
+// returns plain maps and slices, so this is equivalent to json.Marshal, but it
+// also accepts a result still holding output nodes (e.g. one read while the
+// query executes), encoding values that were not filled yet as null.  Errors
+// are not part of the result; they are returned by Execute.
+func MarshalResult(result interface{}) (json.RawMessage, error) {
+	return json.Marshal(outputNodeToJSON(result))
+}